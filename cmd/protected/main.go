@@ -1,16 +1,24 @@
 package main
 
 import (
+	"context"
 	_ "github.com/vondr/identity-go/docs"
 	"log"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
+	"github.com/vondr/identity-go/internal/api/adapters"
 	"github.com/vondr/identity-go/internal/api/middleware"
 	"github.com/vondr/identity-go/internal/api/protected"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/application/services"
 	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/secrets"
+	"github.com/vondr/identity-go/internal/core/tokens"
 	"github.com/vondr/identity-go/internal/infrastructure/cache"
 	"github.com/vondr/identity-go/internal/infrastructure/database"
 	"github.com/vondr/identity-go/internal/infrastructure/geoip"
@@ -26,6 +34,12 @@ func main() {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
+	if cfg.RunDBSetup {
+		if err := database.AutoMigrate(); err != nil {
+			log.Fatalf("Failed to run database migrations: %v", err)
+		}
+	}
+
 	if err := cache.InitRedis(cfg.KeyDBURL); err != nil {
 		log.Fatalf("Failed to initialize redis: %v", err)
 	}
@@ -34,6 +48,75 @@ func main() {
 		log.Printf("Warning: Failed to initialize GeoIP: %v", err)
 	}
 
+	db := database.GetDB()
+	orgRepo := repositories.NewGormOrganizationRepository(db)
+	memberRepo := repositories.NewGormMemberRepository(db)
+	appRepo := repositories.NewGormAppRepository(db)
+	countryRepo := repositories.NewGormAppAllowedCountryRepository(db)
+	identityProviderRepo := repositories.NewGormIdentityProviderRepository(db)
+	samlConnectionRepo := repositories.NewGormSAMLConnectionRepository(db)
+	domainRepo := repositories.NewGormOrganizationDomainRepository(db)
+	credentialRepo := repositories.NewGormWebAuthnCredentialRepository(db)
+	refreshTokenRepo := repositories.NewGormRefreshTokenRepository(db)
+
+	sessionPolicyService := services.NewSessionPolicyService(orgRepo, services.SessionLifetimes{
+		IdleTimeout: time.Duration(cfg.SessionIdleMinutes) * time.Minute,
+		Lifetime:    time.Duration(cfg.SessionTTLDays) * 24 * time.Hour,
+	})
+	sessionManager := services.NewSessionManager(
+		cache.NewRedisSessionRepository(cache.GetClient()),
+		refreshTokenRepo,
+		sessionPolicyService,
+		geoip.GetService(),
+		time.Duration(cfg.AccessTokenTTLMinutes)*time.Minute,
+	)
+	memberService := services.NewMemberService(memberRepo, orgRepo, repositories.NewGormMemberIdentityRepository(db))
+	orgService := services.NewOrganizationService(orgRepo)
+	appService := services.NewAppService(appRepo, orgRepo)
+	countryService := services.NewAppAllowedCountryService(countryRepo)
+	identityProviderService := services.NewIdentityProviderService(
+		identityProviderRepo,
+		orgRepo,
+		secrets.DeriveKey(cfg.EncryptionKey, services.IdentityProviderSecretsPurpose),
+		cfg.AuthLoginURL,
+	)
+	samlConnectionService := services.NewSAMLConnectionService(samlConnectionRepo, orgRepo, nil, nil, cfg.AuthLoginURL)
+	domainService := services.NewOrganizationDomainService(domainRepo, orgRepo, identityProviderRepo, samlConnectionRepo)
+	mfaService := services.NewMFAService(
+		memberRepo,
+		orgRepo,
+		credentialRepo,
+		cache.NewRedisMFAChallengeRepository(cache.GetClient()),
+		secrets.DeriveKey(cfg.EncryptionKey, services.MFASecretsPurpose),
+	)
+
+	var forwardAuthTokens types.ForwardAuthTokenIssuer
+	if cfg.ForwardAuthJWTEnabled {
+		if err := cfg.CheckEncryptionKey(); err != nil {
+			log.Fatalf("Refusing to initialize signing keys: %v", err)
+		}
+		signingKeyService, err := services.NewSigningKeyService(
+			repositories.NewGormSigningKeyRepository(db),
+			secrets.DeriveKey(cfg.EncryptionKey, services.SigningKeySecretsPurpose),
+			cfg.SigningKeyAlgorithm,
+			time.Duration(cfg.SigningKeyRotationDays)*24*time.Hour,
+			time.Duration(cfg.SigningKeyRetentionDays)*24*time.Hour,
+		)
+		if err != nil {
+			log.Fatalf("Failed to initialize signing keys: %v", err)
+		}
+		if err := signingKeyService.Rotate(context.Background(), false); err != nil {
+			log.Fatalf("Failed to initialize signing keys: %v", err)
+		}
+		go signingKeyService.RunRotation(context.Background(), time.Hour)
+
+		forwardAuthTokens = adapters.NewForwardAuthTokenServiceAdapter(services.NewForwardAuthTokenService(
+			tokens.NewSigner(signingKeyService),
+			cfg.AuthLoginURL,
+			time.Duration(cfg.ForwardAuthJWTTTLSeconds)*time.Second,
+		))
+	}
+
 	r := gin.Default()
 
 	allowedOrigins := cfg.CORSOrigins()
@@ -41,13 +124,29 @@ func main() {
 		r.Use(middleware.CORSMiddleware(allowedOrigins))
 	}
 
+	clientCredentialsService := services.NewClientCredentialsService(
+		appRepo,
+		cache.NewRedisClientAccessTokenRepository(cache.GetClient()),
+		cfg.AuthLoginURL,
+		time.Duration(cfg.ClientAccessTokenTTLSeconds)*time.Second,
+	)
+
 	forwardAuthHandler := protected.NewForwardAuthHandler(
-		nil,
-		nil,
-		nil,
-		nil,
-		nil,
-		nil,
+		adapters.NewSessionManagerAdapter(sessionManager),
+		adapters.NewMemberServiceAdapter(memberService),
+		adapters.NewAppServiceAdapter(appService),
+		adapters.NewOrganizationServiceAdapter(orgService),
+		adapters.NewAppAllowedCountryServiceAdapter(countryService),
+		geoip.GetService(),
+		adapters.NewUserGroupServiceAdapter(services.NewUserGroupService(
+			repositories.NewGormUserGroupRepository(db),
+			repositories.NewGormUserGroupMemberRepository(db),
+			orgRepo,
+			memberRepo,
+		)),
+		forwardAuthTokens,
+		adapters.NewClientCredentialsServiceAdapter(clientCredentialsService),
+		cfg.LegacyAppTokensEnabled,
 		cfg.AuthLoginURL,
 		cfg.ErrorLoginRedirect,
 	)
@@ -63,6 +162,67 @@ func main() {
 
 	r.GET("/docs/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	identityProviderHandler := protected.NewIdentityProviderHandler(adapters.NewIdentityProviderServiceAdapter(identityProviderService))
+	identityProviders := r.Group("/api/v1/organizations/:org_id/identity-providers")
+	{
+		identityProviders.GET("", identityProviderHandler.List)
+		identityProviders.POST("", identityProviderHandler.Create)
+		identityProviders.GET("/:provider_id", identityProviderHandler.Get)
+		identityProviders.PUT("/:provider_id", identityProviderHandler.Update)
+		identityProviders.DELETE("/:provider_id", identityProviderHandler.Delete)
+	}
+
+	samlConnectionHandler := protected.NewSAMLConnectionHandler(adapters.NewSAMLConnectionServiceAdapter(samlConnectionService))
+	samlConnections := r.Group("/api/v1/organizations/:org_id/saml-connections")
+	{
+		samlConnections.GET("", samlConnectionHandler.List)
+		samlConnections.POST("", samlConnectionHandler.Create)
+		samlConnections.GET("/:connection_id", samlConnectionHandler.Get)
+		samlConnections.PUT("/:connection_id", samlConnectionHandler.Update)
+		samlConnections.DELETE("/:connection_id", samlConnectionHandler.Delete)
+	}
+
+	domainHandler := protected.NewOrganizationDomainHandler(adapters.NewOrganizationDomainServiceAdapter(domainService))
+	domains := r.Group("/api/v1/organizations/:org_id/domains")
+	{
+		domains.GET("", domainHandler.List)
+		domains.POST("", domainHandler.Create)
+		domains.POST("/:domain_id/verify", domainHandler.Verify)
+		domains.DELETE("/:domain_id", domainHandler.Delete)
+	}
+
+	appAssuranceHandler := protected.NewAppAssuranceHandler(adapters.NewAppServiceAdapter(appService))
+	r.GET("/api/v1/organizations/:org_id/apps/:app_id/assurance-policy", appAssuranceHandler.GetPolicy)
+	r.PUT("/api/v1/organizations/:org_id/apps/:app_id/assurance-policy", appAssuranceHandler.SetPolicy)
+
+	appOIDCClientHandler := protected.NewAppOIDCClientHandler(adapters.NewAppServiceAdapter(appService))
+	r.GET("/api/v1/organizations/:org_id/apps/:app_id/oidc-client", appOIDCClientHandler.GetClient)
+	r.PUT("/api/v1/organizations/:org_id/apps/:app_id/oidc-client", appOIDCClientHandler.SetClient)
+	r.POST("/api/v1/organizations/:org_id/apps/:app_id/oidc-client/secret", appOIDCClientHandler.RotateSecret)
+
+	appClientCredentialsHandler := protected.NewAppClientCredentialsHandler(adapters.NewAppServiceAdapter(appService))
+	r.GET("/api/v1/organizations/:org_id/apps/:app_id/client-credentials", appClientCredentialsHandler.GetClientCredentials)
+	r.PUT("/api/v1/organizations/:org_id/apps/:app_id/client-credentials", appClientCredentialsHandler.SetClientCredentials)
+	r.DELETE("/api/v1/organizations/:org_id/apps/:app_id/client-credentials", appClientCredentialsHandler.DisableClientCredentials)
+	r.POST("/api/v1/organizations/:org_id/apps/:app_id/client-credentials/secret", appClientCredentialsHandler.RotateClientSecret)
+
+	mfaHandler := protected.NewMFAHandler(adapters.NewMFAServiceAdapter(mfaService))
+	r.GET("/api/v1/organizations/:org_id/mfa-policy", mfaHandler.GetPolicy)
+	r.PUT("/api/v1/organizations/:org_id/mfa-policy", mfaHandler.SetPolicy)
+	r.DELETE("/api/v1/organizations/:org_id/members/:member_id/mfa", mfaHandler.ResetMember)
+
+	sessionPolicyHandler := protected.NewSessionPolicyHandler(adapters.NewSessionPolicyServiceAdapter(sessionPolicyService))
+	r.GET("/api/v1/organizations/:org_id/session-policy", sessionPolicyHandler.GetPolicy)
+	r.PUT("/api/v1/organizations/:org_id/session-policy", sessionPolicyHandler.SetPolicy)
+
+	sessionHandler := protected.NewSessionHandler(adapters.NewSessionRevocationServiceAdapter(
+		services.NewSessionRevocationService(sessionManager, memberRepo, orgRepo),
+	))
+	r.GET("/api/v1/organizations/:org_id/members/:member_id/sessions", sessionHandler.ListMemberSessions)
+	r.DELETE("/api/v1/organizations/:org_id/members/:member_id/sessions", sessionHandler.RevokeMemberSessions)
+	r.DELETE("/api/v1/organizations/:org_id/members/:member_id/sessions/:session_id", sessionHandler.RevokeMemberSession)
+	r.DELETE("/api/v1/organizations/:org_id/sessions", sessionHandler.RevokeOrganizationSessions)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8000"
@@ -1,21 +1,31 @@
 package main
 
 import (
+	"context"
 	_ "github.com/vondr/identity-go/docs"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/swaggo/files"
 	"github.com/swaggo/gin-swagger"
+	"github.com/vondr/identity-go/internal/api/adapters"
 	"github.com/vondr/identity-go/internal/api/middleware"
 	"github.com/vondr/identity-go/internal/api/public"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/application/services"
 	"github.com/vondr/identity-go/internal/core"
 	"github.com/vondr/identity-go/internal/core/oauth"
+	"github.com/vondr/identity-go/internal/core/saml"
+	"github.com/vondr/identity-go/internal/core/secrets"
+	"github.com/vondr/identity-go/internal/core/tokens"
 	"github.com/vondr/identity-go/internal/infrastructure/cache"
 	"github.com/vondr/identity-go/internal/infrastructure/database"
 	"github.com/vondr/identity-go/internal/infrastructure/geoip"
+	"github.com/vondr/identity-go/internal/infrastructure/mail"
 )
 
 func main() {
@@ -36,6 +46,148 @@ func main() {
 		log.Printf("Warning: Failed to initialize GeoIP: %v", err)
 	}
 
+	db := database.GetDB()
+	orgRepo := repositories.NewGormOrganizationRepository(db)
+	memberRepo := repositories.NewGormMemberRepository(db)
+	appRepo := repositories.NewGormAppRepository(db)
+	identityProviderRepo := repositories.NewGormIdentityProviderRepository(db)
+	samlConnectionRepo := repositories.NewGormSAMLConnectionRepository(db)
+	domainRepo := repositories.NewGormOrganizationDomainRepository(db)
+	credentialRepo := repositories.NewGormWebAuthnCredentialRepository(db)
+	refreshTokenRepo := repositories.NewGormRefreshTokenRepository(db)
+
+	sessionPolicyService := services.NewSessionPolicyService(orgRepo, services.SessionLifetimes{
+		IdleTimeout: time.Duration(cfg.SessionIdleMinutes) * time.Minute,
+		Lifetime:    time.Duration(cfg.SessionTTLDays) * 24 * time.Hour,
+	})
+	sessionManager := services.NewSessionManager(
+		cache.NewRedisSessionRepository(cache.GetClient()),
+		refreshTokenRepo,
+		sessionPolicyService,
+		geoip.GetService(),
+		time.Duration(cfg.AccessTokenTTLMinutes)*time.Minute,
+	)
+	loginTxManager := services.NewLoginTransactionManager(cache.NewRedisLoginTransactionRepository(cache.GetClient()))
+	memberService := services.NewMemberService(memberRepo, orgRepo, repositories.NewGormMemberIdentityRepository(db))
+	orgService := services.NewOrganizationService(orgRepo)
+	appService := services.NewAppService(appRepo, orgRepo)
+	sessionService := services.NewSessionService()
+	userGroupService := services.NewUserGroupService(
+		repositories.NewGormUserGroupRepository(db),
+		repositories.NewGormUserGroupMemberRepository(db),
+		orgRepo,
+		memberRepo,
+	)
+	identityProviderService := services.NewIdentityProviderService(
+		identityProviderRepo,
+		orgRepo,
+		secrets.DeriveKey(cfg.EncryptionKey, services.IdentityProviderSecretsPurpose),
+		cfg.AuthLoginURL,
+	)
+
+	domainService := services.NewOrganizationDomainService(domainRepo, orgRepo, identityProviderRepo, samlConnectionRepo)
+
+	var samlProviders types.SAMLServiceProviderResolver
+	if cfg.SAMLSPCertPath != "" {
+		samlKey, samlCertificate, err := saml.LoadKeyPair(cfg.SAMLSPCertPath, cfg.SAMLSPKeyPath)
+		if err != nil {
+			log.Fatalf("Failed to initialize SAML: %v", err)
+		}
+		samlProviders = adapters.NewSAMLConnectionServiceAdapter(services.NewSAMLConnectionService(
+			samlConnectionRepo,
+			orgRepo,
+			samlKey,
+			samlCertificate,
+			cfg.AuthLoginURL,
+		))
+	}
+
+	mfaService := services.NewMFAService(
+		memberRepo,
+		orgRepo,
+		credentialRepo,
+		cache.NewRedisMFAChallengeRepository(cache.GetClient()),
+		secrets.DeriveKey(cfg.EncryptionKey, services.MFASecretsPurpose),
+	)
+
+	relyingParty, err := services.NewWebAuthnRelyingParty(cfg.WebAuthnRPID, cfg.WebAuthnRPOrigins())
+	if err != nil {
+		log.Fatalf("Failed to initialize WebAuthn: %v", err)
+	}
+	webAuthnService := services.NewWebAuthnService(
+		credentialRepo,
+		memberRepo,
+		orgRepo,
+		cache.NewRedisWebAuthnCeremonyRepository(cache.GetClient()),
+		relyingParty,
+	)
+
+	refreshTokenService := services.NewRefreshTokenService(
+		refreshTokenRepo,
+		repositories.NewGormAuditEventRepository(db),
+		memberRepo,
+		sessionManager,
+		time.Duration(cfg.RefreshTokenTTLDays)*24*time.Hour,
+	)
+
+	deviceService := services.NewDeviceAuthorizationService(
+		cache.NewRedisDeviceAuthorizationRepository(cache.GetClient()),
+		sessionManager,
+		refreshTokenService,
+		cfg.DeviceClientIDs(),
+		cfg.AuthLoginURL,
+	)
+
+	// Private keys are encrypted with ENCRYPTION_KEY; the development default
+	// is public.
+	if err := cfg.CheckEncryptionKey(); err != nil {
+		log.Fatalf("Refusing to initialize signing keys: %v", err)
+	}
+	signingKeyService, err := services.NewSigningKeyService(
+		repositories.NewGormSigningKeyRepository(db),
+		secrets.DeriveKey(cfg.EncryptionKey, services.SigningKeySecretsPurpose),
+		cfg.SigningKeyAlgorithm,
+		time.Duration(cfg.SigningKeyRotationDays)*24*time.Hour,
+		time.Duration(cfg.SigningKeyRetentionDays)*24*time.Hour,
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	if err := signingKeyService.Rotate(context.Background(), false); err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+	go signingKeyService.RunRotation(context.Background(), time.Hour)
+
+	oidcProviderService := services.NewOIDCProviderService(
+		appRepo,
+		memberRepo,
+		orgRepo,
+		cache.NewRedisOIDCAuthorizationCodeRepository(cache.GetClient()),
+		sessionManager,
+		refreshTokenService,
+		tokens.NewSigner(signingKeyService),
+		cfg.AuthLoginURL,
+	)
+
+	clientCredentialsService := services.NewClientCredentialsService(
+		appRepo,
+		cache.NewRedisClientAccessTokenRepository(cache.GetClient()),
+		cfg.AuthLoginURL,
+		time.Duration(cfg.ClientAccessTokenTTLSeconds)*time.Second,
+	)
+
+	mailer, err := mail.NewFromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize mail: %v", err)
+	}
+	magicLinkService := services.NewMagicLinkService(
+		cache.NewRedisMagicLinkRepository(cache.GetClient()),
+		memberRepo,
+		mailer,
+		cfg.AuthLoginURL,
+		time.Duration(cfg.MagicLinkTTLMinutes)*time.Minute,
+	)
+
 	r := gin.Default()
 
 	allowedOrigins := cfg.CORSOrigins()
@@ -51,34 +203,110 @@ func main() {
 
 	auth := r.Group("/auth")
 	{
-		oauthConfig := &oauth.MicrosoftOAuthConfig{
-			ClientID:     cfg.MicrosoftClientID,
-			ClientSecret: cfg.MicrosoftClientSecret,
-			TenantID:     cfg.MicrosoftTenantID,
-			CallbackURL:  cfg.OAuthCallbackURL,
+		providers := []oauth.IdentityProvider{
+			oauth.NewMicrosoftProvider(&oauth.MicrosoftOAuthConfig{
+				ClientID:     cfg.MicrosoftClientID,
+				ClientSecret: cfg.MicrosoftClientSecret,
+				TenantID:     cfg.MicrosoftTenantID,
+				CallbackURL:  cfg.OAuthCallbackURL,
+				Authority:    cfg.MicrosoftAuthority,
+			}),
+		}
+		if cfg.RelaticsClientID != "" {
+			providers = append(providers, oauth.NewRelaticsProvider(&oauth.RelaticsOAuthConfig{
+				ClientID:     cfg.RelaticsClientID,
+				ClientSecret: cfg.RelaticsClientSecret,
+				Realm:        cfg.RelaticsRealm,
+				RedirectURI:  cfg.RelaticsRedirectURI,
+			}))
 		}
 		authHandler := public.NewAuthHandler(
-			oauth.NewMicrosoftOAuthConfig(oauthConfig),
-			cfg.OAuthCallbackURL,
+			providers,
+			adapters.NewIdentityProviderServiceAdapter(identityProviderService),
+			samlProviders,
+			adapters.NewOrganizationDomainServiceAdapter(domainService),
+			adapters.NewMagicLinkServiceAdapter(magicLinkService),
+			adapters.NewMFAServiceAdapter(mfaService),
+			adapters.NewWebAuthnServiceAdapter(webAuthnService),
+			adapters.NewDeviceAuthorizationServiceAdapter(deviceService),
+			adapters.NewOIDCProviderServiceAdapter(oidcProviderService),
+			adapters.NewClientCredentialsServiceAdapter(clientCredentialsService),
 			cfg.PostLoginRedirectURL,
+			cfg.ErrorLoginRedirect,
 			cfg.CookieDomain,
 			cfg.CookieSecure,
 			http.SameSiteLaxMode,
-			cfg.SessionTTLDays,
-			nil,
-			nil,
-			nil,
-			nil,
+			adapters.NewSessionManagerAdapter(sessionManager),
+			adapters.NewLoginTransactionStoreAdapter(loginTxManager),
+			adapters.NewMemberServiceAdapter(memberService),
+			adapters.NewOrganizationServiceAdapter(orgService),
+			adapters.NewAppServiceAdapter(appService),
+			adapters.NewSessionServiceAdapter(sessionService),
+			adapters.NewUserGroupServiceAdapter(userGroupService),
 			cfg.SystemEmails(),
-			"",
-			"",
+			core.DefaultOrganizationID.String(),
+			core.DefaultOrganizationName,
+			cfg.ReturnToAllowedHosts(),
 		)
-		auth.GET("/microsoft/login", authHandler.MicrosoftLogin)
-		auth.GET("/microsoft/callback", authHandler.MicrosoftCallback)
+		auth.GET("/:provider/login", authHandler.Login)
+		auth.GET("/:provider/callback", authHandler.Callback)
+		auth.GET("/discover", authHandler.Discover)
+		auth.GET("/step-up", authHandler.StepUp)
+		auth.GET("/saml/:slug/metadata", authHandler.SAMLMetadata)
+		auth.GET("/saml/:slug/login", authHandler.SAMLLogin)
+		auth.POST("/saml/:slug/acs", authHandler.SAMLACS)
+		auth.POST("/email/start", authHandler.EmailStart)
+		auth.GET("/email/verify", authHandler.EmailVerifyPage)
+		auth.POST("/email/verify", authHandler.EmailVerify)
+		auth.GET("/mfa", authHandler.MFAChallengePage)
+		auth.POST("/mfa/verify", authHandler.MFAVerify)
+		auth.GET("/mfa/status", authHandler.MFAStatus)
+		auth.POST("/mfa/totp/enroll", authHandler.MFAEnroll)
+		auth.POST("/mfa/totp/confirm", authHandler.MFAConfirm)
+		auth.DELETE("/mfa/totp", authHandler.MFADisable)
+		auth.POST("/mfa/recovery-codes", authHandler.MFARegenerateRecoveryCodes)
+		auth.POST("/webauthn/register/begin", authHandler.PasskeyRegisterBegin)
+		auth.POST("/webauthn/register/finish", authHandler.PasskeyRegisterFinish)
+		auth.GET("/webauthn/credentials", authHandler.PasskeyList)
+		auth.DELETE("/webauthn/credentials/:credential_id", authHandler.PasskeyDelete)
+		auth.POST("/webauthn/login/begin", authHandler.PasskeyLoginBegin)
+		auth.POST("/webauthn/login/finish", authHandler.PasskeyLoginFinish)
+		auth.POST("/webauthn/mfa/begin", authHandler.PasskeyMFABegin)
+		auth.POST("/webauthn/mfa/finish", authHandler.PasskeyMFAFinish)
+		auth.POST("/device/code", authHandler.DeviceCode)
+		auth.POST("/device/token", authHandler.DeviceToken)
+		auth.GET("/device", authHandler.DevicePage)
+		auth.POST("/device", authHandler.DeviceDecide)
+		auth.GET("/identities", authHandler.IdentityList)
+		auth.GET("/identities/link/:provider", authHandler.IdentityLink)
+		auth.GET("/identities/link/saml/:slug", authHandler.IdentityLinkSAML)
+		auth.DELETE("/identities/:identity_id", authHandler.IdentityUnlink)
+		auth.GET("/sessions", authHandler.SessionList)
+		auth.DELETE("/sessions/:session_id", authHandler.SessionRevoke)
 		auth.POST("/logout", authHandler.Logout)
 		auth.GET("/me", authHandler.Me)
+
+		r.GET("/.well-known/openid-configuration", authHandler.OIDCDiscovery)
+		r.GET("/oidc/authorize", authHandler.OIDCAuthorize)
+		r.POST("/oidc/authorize", authHandler.OIDCAuthorize)
+		r.POST("/oidc/token", authHandler.OIDCToken)
+		r.GET("/oidc/userinfo", authHandler.OIDCUserInfo)
+		r.POST("/oidc/userinfo", authHandler.OIDCUserInfo)
 	}
 
+	tokenHandler := public.NewTokenHandler(adapters.NewTokenServiceAdapter(services.NewTokenService(
+		clientCredentialsService,
+		refreshTokenService,
+		sessionManager,
+		cache.NewRedisRevokedTokenRepository(cache.GetClient()),
+		cfg.AuthLoginURL,
+	)))
+	r.POST("/oidc/introspect", tokenHandler.Introspect)
+	r.POST("/oidc/revoke", tokenHandler.Revoke)
+
+	jwksHandler := public.NewJWKSHandler(adapters.NewSigningKeyServiceAdapter(signingKeyService))
+	r.GET("/.well-known/jwks.json", jwksHandler.JWKS)
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8000"
@@ -0,0 +1,146 @@
+// Command signing-keys manages the keys identity-go signs tokens with.
+//
+//	signing-keys list
+//	signing-keys rotate [-force]
+//	signing-keys generate -algorithm ES256
+//	signing-keys import -file key.pem
+//	signing-keys retire <kid>
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/secrets"
+	"github.com/vondr/identity-go/internal/infrastructure/database"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+const usage = `Usage: signing-keys <command> [flags]
+
+Commands:
+  list                          List signing keys and their status
+  rotate [-force]               Apply the rotation schedule; -force replaces the active key now
+  generate -algorithm <alg>     Replace the next key with a new RS256, ES256 or EdDSA key
+  import -file <path>           Replace the next key with a PEM encoded private key
+  retire <kid>                  Withdraw a key immediately; tokens it signed stop verifying
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	cfg, err := core.LoadConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	// Private keys are encrypted with ENCRYPTION_KEY; the development default
+	// is public.
+	if err := cfg.CheckEncryptionKey(); err != nil {
+		log.Fatalf("Refusing to manage signing keys: %v", err)
+	}
+	if err := database.InitDB(cfg.DatabaseURL); err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+
+	keyService, err := services.NewSigningKeyService(
+		repositories.NewGormSigningKeyRepository(database.GetDB()),
+		secrets.DeriveKey(cfg.EncryptionKey, services.SigningKeySecretsPurpose),
+		cfg.SigningKeyAlgorithm,
+		time.Duration(cfg.SigningKeyRotationDays)*24*time.Hour,
+		time.Duration(cfg.SigningKeyRetentionDays)*24*time.Hour,
+	)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing keys: %v", err)
+	}
+
+	ctx := context.Background()
+	command, args := os.Args[1], os.Args[2:]
+	switch command {
+	case "list":
+		err = listKeys(ctx, keyService)
+	case "rotate":
+		flags := flag.NewFlagSet("rotate", flag.ExitOnError)
+		force := flags.Bool("force", false, "replace the active key regardless of its age")
+		flags.Parse(args)
+		if err = keyService.Rotate(ctx, *force); err == nil {
+			err = listKeys(ctx, keyService)
+		}
+	case "generate":
+		flags := flag.NewFlagSet("generate", flag.ExitOnError)
+		algorithm := flags.String("algorithm", cfg.SigningKeyAlgorithm, "RS256, ES256 or EdDSA")
+		flags.Parse(args)
+		var key *models.SigningKey
+		if key, err = keyService.Generate(ctx, *algorithm); err == nil {
+			fmt.Printf("Created next signing key %s (%s); it becomes active at the next rotation\n", key.KeyID, key.Algorithm)
+		}
+	case "import":
+		flags := flag.NewFlagSet("import", flag.ExitOnError)
+		path := flags.String("file", "", "PEM encoded private key")
+		flags.Parse(args)
+		if *path == "" {
+			log.Fatal("import requires -file")
+		}
+		var data []byte
+		if data, err = os.ReadFile(*path); err != nil {
+			break
+		}
+		var key *models.SigningKey
+		if key, err = keyService.Import(ctx, data); err == nil {
+			fmt.Printf("Imported next signing key %s (%s); run rotate -force to activate it now\n", key.KeyID, key.Algorithm)
+		}
+	case "retire":
+		if len(args) != 1 {
+			log.Fatal("retire requires a key ID")
+		}
+		if err = keyService.Retire(ctx, args[0]); err == nil {
+			err = listKeys(ctx, keyService)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s failed: %v", command, err)
+	}
+}
+
+func listKeys(ctx context.Context, keyService *services.SigningKeyService) error {
+	keys, err := keyService.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KID\tALG\tSTATUS\tCREATED\tACTIVATED\tRETIRING\tRETIRED")
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			key.KeyID,
+			key.Algorithm,
+			key.Status,
+			key.CreatedAt.Format(time.RFC3339),
+			formatTime(key.ActivatedAt),
+			formatTime(key.RetiringAt),
+			formatTime(key.RetiredAt),
+		)
+	}
+	return w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
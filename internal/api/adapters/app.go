@@ -0,0 +1,203 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type AppServiceAdapter struct {
+	service *services.AppService
+}
+
+func NewAppServiceAdapter(service *services.AppService) *AppServiceAdapter {
+	return &AppServiceAdapter{service: service}
+}
+
+func (a *AppServiceAdapter) GetByToken(ctx context.Context, token string) (*types.App, error) {
+	app, err := a.service.GetByToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func (a *AppServiceAdapter) GetByID(ctx context.Context, appID string) (*types.App, error) {
+	id, err := uuid.Parse(appID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	app, err := a.service.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func (a *AppServiceAdapter) GetAllowedDomainsForOrg(ctx context.Context, orgID, hostname string) ([]string, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	return a.service.GetAllowedDomainsForOrganization(ctx, id, optionalString(hostname))
+}
+
+func (a *AppServiceAdapter) GetDomainAppMap(ctx context.Context, orgID, hostname string) (map[string]*types.App, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	domainMap, err := a.service.GetDomainAppMap(ctx, id, optionalString(hostname))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*types.App, len(domainMap))
+	for domain, app := range domainMap {
+		result[domain] = toApp(app)
+	}
+	return result, nil
+}
+
+func (a *AppServiceAdapter) ListByOrganization(ctx context.Context, orgID string) ([]*types.App, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	apps, err := a.service.ListByOrganizationID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.App, len(apps))
+	for i, app := range apps {
+		result[i] = toApp(app)
+	}
+	return result, nil
+}
+
+func (a *AppServiceAdapter) GetForOrganization(ctx context.Context, orgID, appID string) (*types.App, error) {
+	orgUUID, appUUID, err := parseOrgScopedIDs(orgID, appID)
+	if err != nil {
+		return nil, err
+	}
+	app, err := a.service.GetForOrganization(ctx, orgUUID, appUUID)
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func (a *AppServiceAdapter) SetAssurancePolicy(ctx context.Context, orgID, appID string, policy types.AppAssurancePolicy) (*types.App, error) {
+	orgUUID, appUUID, err := parseOrgScopedIDs(orgID, appID)
+	if err != nil {
+		return nil, err
+	}
+	app, err := a.service.SetAssurancePolicy(ctx, orgUUID, appUUID, services.AppAssuranceInput{
+		RequiredAuthMethod: policy.RequiredAuthMethod,
+		RequireMFA:         policy.RequireMFA,
+		MaxAuthAgeSeconds:  int(policy.MaxAuthAge / time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func (a *AppServiceAdapter) SetOIDCClient(ctx context.Context, orgID, appID string, redirectURIs []string) (*types.App, error) {
+	orgUUID, appUUID, err := parseOrgScopedIDs(orgID, appID)
+	if err != nil {
+		return nil, err
+	}
+	app, err := a.service.SetOIDCClient(ctx, orgUUID, appUUID, redirectURIs)
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func (a *AppServiceAdapter) RotateOIDCClientSecret(ctx context.Context, orgID, appID string) (*types.App, string, error) {
+	orgUUID, appUUID, err := parseOrgScopedIDs(orgID, appID)
+	if err != nil {
+		return nil, "", err
+	}
+	app, secret, err := a.service.RotateOIDCClientSecret(ctx, orgUUID, appUUID)
+	if err != nil {
+		return nil, "", err
+	}
+	return toApp(app), secret, nil
+}
+
+func (a *AppServiceAdapter) SetClientCredentials(ctx context.Context, orgID, appID string, scopes []string, jwks *string) (*types.App, error) {
+	orgUUID, appUUID, err := parseOrgScopedIDs(orgID, appID)
+	if err != nil {
+		return nil, err
+	}
+	app, err := a.service.SetClientCredentials(ctx, orgUUID, appUUID, services.AppClientCredentialsInput{
+		Scopes: scopes,
+		JWKS:   jwks,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func (a *AppServiceAdapter) RotateClientSecret(ctx context.Context, orgID, appID string) (*types.App, string, error) {
+	orgUUID, appUUID, err := parseOrgScopedIDs(orgID, appID)
+	if err != nil {
+		return nil, "", err
+	}
+	app, secret, err := a.service.RotateClientSecret(ctx, orgUUID, appUUID)
+	if err != nil {
+		return nil, "", err
+	}
+	return toApp(app), secret, nil
+}
+
+func (a *AppServiceAdapter) DisableClientCredentials(ctx context.Context, orgID, appID string) (*types.App, error) {
+	orgUUID, appUUID, err := parseOrgScopedIDs(orgID, appID)
+	if err != nil {
+		return nil, err
+	}
+	app, err := a.service.DisableClientCredentials(ctx, orgUUID, appUUID)
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func toApp(app *models.App) *types.App {
+	return &types.App{
+		ID:              app.ID.String(),
+		OrganizationID:  app.OrganizationID.String(),
+		Name:            app.Name,
+		SubdomainLabels: app.SubdomainLabels,
+		MainLabel:       app.MainLabel,
+		IsPlatformApp:   app.IsPlatformApp,
+		Assurance:       toAppAssurancePolicy(app),
+		OIDC: types.AppOIDCClient{
+			RedirectURIs: app.OIDCRedirectURIs,
+			Confidential: app.OIDCClientSecretHash != nil,
+		},
+		Client: types.AppClientCredentials{
+			Scopes:    app.ClientScopes,
+			HasSecret: app.ClientSecretHash != nil,
+			JWKS:      app.ClientJWKS,
+		},
+	}
+}
+
+func toAppAssurancePolicy(app *models.App) types.AppAssurancePolicy {
+	policy := types.AppAssurancePolicy{
+		RequireMFA: app.RequireMFA,
+		MaxAuthAge: time.Duration(app.MaxAuthAgeSeconds) * time.Second,
+	}
+	if app.RequiredAuthMethod != nil {
+		policy.RequiredAuthMethod = core.AuthMethod(*app.RequiredAuthMethod)
+	}
+	return policy
+}
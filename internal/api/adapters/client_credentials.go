@@ -0,0 +1,55 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+)
+
+type ClientCredentialsServiceAdapter struct {
+	service *services.ClientCredentialsService
+}
+
+func NewClientCredentialsServiceAdapter(service *services.ClientCredentialsService) *ClientCredentialsServiceAdapter {
+	return &ClientCredentialsServiceAdapter{service: service}
+}
+
+func (a *ClientCredentialsServiceAdapter) Issue(ctx context.Context, request types.ClientCredentialsRequest) (*types.ClientCredentialsToken, error) {
+	token, err := a.service.Issue(ctx, services.ClientCredentialsRequest{
+		ClientAuthentication: toClientAuthentication(request.ClientAuthentication),
+		Scope:                request.Scope,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &types.ClientCredentialsToken{
+		AccessToken: token.AccessToken,
+		Scope:       token.Scope,
+		ExpiresIn:   token.ExpiresIn,
+	}, nil
+}
+
+func (a *ClientCredentialsServiceAdapter) Validate(ctx context.Context, accessToken string) (*types.ClientAccessToken, error) {
+	token, err := a.service.Validate(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &types.ClientAccessToken{
+		ClientID:       token.ClientID,
+		OrganizationID: token.OrganizationID,
+		Scope:          token.Scope,
+		IssuedAt:       time.Unix(token.IssuedAt, 0),
+		ExpiresAt:      time.Unix(token.ExpiresAt, 0),
+	}, nil
+}
+
+func toClientAuthentication(caller types.ClientAuthentication) services.ClientAuthentication {
+	return services.ClientAuthentication{
+		ClientID:            caller.ClientID,
+		ClientSecret:        caller.ClientSecret,
+		ClientAssertionType: caller.ClientAssertionType,
+		ClientAssertion:     caller.ClientAssertion,
+	}
+}
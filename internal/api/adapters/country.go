@@ -0,0 +1,25 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type AppAllowedCountryServiceAdapter struct {
+	service services.AppAllowedCountryService
+}
+
+func NewAppAllowedCountryServiceAdapter(service services.AppAllowedCountryService) *AppAllowedCountryServiceAdapter {
+	return &AppAllowedCountryServiceAdapter{service: service}
+}
+
+func (a *AppAllowedCountryServiceAdapter) ListCountryCodes(ctx context.Context, appID string) ([]string, error) {
+	id, err := uuid.Parse(appID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	return a.service.ListCountryCodes(ctx, id)
+}
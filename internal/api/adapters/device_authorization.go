@@ -0,0 +1,78 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+)
+
+type DeviceAuthorizationServiceAdapter struct {
+	service *services.DeviceAuthorizationService
+}
+
+func NewDeviceAuthorizationServiceAdapter(service *services.DeviceAuthorizationService) *DeviceAuthorizationServiceAdapter {
+	return &DeviceAuthorizationServiceAdapter{service: service}
+}
+
+func (a *DeviceAuthorizationServiceAdapter) VerificationURI() string {
+	return a.service.VerificationURI()
+}
+
+func (a *DeviceAuthorizationServiceAdapter) Start(ctx context.Context, clientID string) (*types.DeviceAuthorizationGrant, error) {
+	grant, err := a.service.Start(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	return &types.DeviceAuthorizationGrant{
+		DeviceCode:              grant.DeviceCode,
+		UserCode:                grant.UserCode,
+		VerificationURI:         grant.VerificationURI,
+		VerificationURIComplete: grant.VerificationURIComplete,
+		ExpiresIn:               grant.ExpiresIn,
+		Interval:                grant.Interval,
+	}, nil
+}
+
+func (a *DeviceAuthorizationServiceAdapter) Lookup(ctx context.Context, userCode string) (*types.DeviceAuthorizationRequest, error) {
+	authorization, err := a.service.Lookup(ctx, userCode)
+	if err != nil {
+		return nil, err
+	}
+	return &types.DeviceAuthorizationRequest{
+		ClientID: authorization.ClientID,
+		UserCode: authorization.UserCode,
+	}, nil
+}
+
+func (a *DeviceAuthorizationServiceAdapter) Approve(ctx context.Context, userCode, sessionToken string) error {
+	return a.service.Approve(ctx, userCode, sessionToken)
+}
+
+func (a *DeviceAuthorizationServiceAdapter) Deny(ctx context.Context, userCode string) error {
+	return a.service.Deny(ctx, userCode)
+}
+
+func (a *DeviceAuthorizationServiceAdapter) Poll(ctx context.Context, clientID, deviceCode string) (*types.DeviceAccessToken, error) {
+	tokens, err := a.service.Poll(ctx, clientID, deviceCode)
+	if err != nil {
+		return nil, err
+	}
+	return toDeviceAccessToken(tokens), nil
+}
+
+func (a *DeviceAuthorizationServiceAdapter) Refresh(ctx context.Context, clientID, refreshToken string) (*types.DeviceAccessToken, error) {
+	tokens, err := a.service.Refresh(ctx, clientID, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return toDeviceAccessToken(tokens), nil
+}
+
+func toDeviceAccessToken(tokens *services.MemberTokens) *types.DeviceAccessToken {
+	return &types.DeviceAccessToken{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
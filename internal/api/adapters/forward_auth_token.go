@@ -0,0 +1,30 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+)
+
+type ForwardAuthTokenServiceAdapter struct {
+	service *services.ForwardAuthTokenService
+}
+
+func NewForwardAuthTokenServiceAdapter(service *services.ForwardAuthTokenService) *ForwardAuthTokenServiceAdapter {
+	return &ForwardAuthTokenServiceAdapter{service: service}
+}
+
+func (a *ForwardAuthTokenServiceAdapter) Issue(ctx context.Context, identity types.ForwardAuthIdentity) (string, error) {
+	return a.service.Issue(ctx, services.ForwardAuthIdentity{
+		MemberID:       identity.MemberID,
+		ClientID:       identity.ClientID,
+		Email:          identity.Email,
+		OrganizationID: identity.OrganizationID,
+		Role:           identity.Role,
+		Groups:         identity.Groups,
+		Scope:          identity.Scope,
+		AppID:          identity.AppID,
+		Host:           identity.Host,
+	})
+}
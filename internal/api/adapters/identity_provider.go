@@ -0,0 +1,129 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/oauth"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type IdentityProviderServiceAdapter struct {
+	service *services.IdentityProviderService
+}
+
+func NewIdentityProviderServiceAdapter(service *services.IdentityProviderService) *IdentityProviderServiceAdapter {
+	return &IdentityProviderServiceAdapter{service: service}
+}
+
+func (a *IdentityProviderServiceAdapter) List(ctx context.Context, orgID string) ([]*types.IdentityProviderConfig, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	providers, err := a.service.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.IdentityProviderConfig, len(providers))
+	for i, provider := range providers {
+		result[i] = toIdentityProviderConfig(provider)
+	}
+	return result, nil
+}
+
+func (a *IdentityProviderServiceAdapter) Get(ctx context.Context, orgID, providerID string) (*types.IdentityProviderConfig, error) {
+	orgUUID, providerUUID, err := parseOrgScopedIDs(orgID, providerID)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := a.service.Get(ctx, orgUUID, providerUUID)
+	if err != nil {
+		return nil, err
+	}
+	return toIdentityProviderConfig(provider), nil
+}
+
+func (a *IdentityProviderServiceAdapter) Create(ctx context.Context, orgID string, input *types.IdentityProviderInput) (*types.IdentityProviderConfig, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	provider, err := a.service.Create(ctx, id, toIdentityProviderInput(input))
+	if err != nil {
+		return nil, err
+	}
+	return toIdentityProviderConfig(provider), nil
+}
+
+func (a *IdentityProviderServiceAdapter) Update(ctx context.Context, orgID, providerID string, input *types.IdentityProviderInput) (*types.IdentityProviderConfig, error) {
+	orgUUID, providerUUID, err := parseOrgScopedIDs(orgID, providerID)
+	if err != nil {
+		return nil, err
+	}
+	provider, err := a.service.Update(ctx, orgUUID, providerUUID, toIdentityProviderInput(input))
+	if err != nil {
+		return nil, err
+	}
+	return toIdentityProviderConfig(provider), nil
+}
+
+func (a *IdentityProviderServiceAdapter) Delete(ctx context.Context, orgID, providerID string) error {
+	orgUUID, providerUUID, err := parseOrgScopedIDs(orgID, providerID)
+	if err != nil {
+		return err
+	}
+	return a.service.Delete(ctx, orgUUID, providerUUID)
+}
+
+func (a *IdentityProviderServiceAdapter) Resolve(ctx context.Context, slug string) (oauth.IdentityProvider, string, error) {
+	provider, record, err := a.service.Resolve(ctx, slug)
+	if err != nil {
+		return nil, "", err
+	}
+	return provider, record.OrganizationID.String(), nil
+}
+
+func parseOrgScopedIDs(orgID, resourceID string) (uuid.UUID, uuid.UUID, error) {
+	orgUUID, err := uuid.Parse(orgID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, core.ErrNotFound
+	}
+	resourceUUID, err := uuid.Parse(resourceID)
+	if err != nil {
+		return uuid.Nil, uuid.Nil, core.ErrNotFound
+	}
+	return orgUUID, resourceUUID, nil
+}
+
+func toIdentityProviderInput(input *types.IdentityProviderInput) *services.IdentityProviderInput {
+	return &services.IdentityProviderInput{
+		Slug:             input.Slug,
+		Type:             input.Type,
+		Issuer:           input.Issuer,
+		ClientID:         input.ClientID,
+		ClientSecret:     input.ClientSecret,
+		AllowedTenantIDs: input.AllowedTenantIDs,
+		ClaimMappings:    input.ClaimMappings,
+		Enabled:          input.Enabled,
+	}
+}
+
+func toIdentityProviderConfig(provider *models.IdentityProvider) *types.IdentityProviderConfig {
+	return &types.IdentityProviderConfig{
+		ID:               provider.ID.String(),
+		OrganizationID:   provider.OrganizationID.String(),
+		Slug:             provider.Slug,
+		Type:             provider.Type,
+		Issuer:           provider.Issuer,
+		ClientID:         provider.ClientID,
+		AllowedTenantIDs: provider.AllowedTenantIDs,
+		ClaimMappings:    provider.ClaimMappings,
+		Enabled:          provider.Enabled,
+		CreatedAt:        provider.CreatedAt,
+		UpdatedAt:        provider.UpdatedAt,
+	}
+}
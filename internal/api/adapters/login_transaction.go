@@ -0,0 +1,45 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+)
+
+type LoginTransactionStoreAdapter struct {
+	manager *services.LoginTransactionManager
+}
+
+func NewLoginTransactionStoreAdapter(manager *services.LoginTransactionManager) *LoginTransactionStoreAdapter {
+	return &LoginTransactionStoreAdapter{manager: manager}
+}
+
+func (a *LoginTransactionStoreAdapter) Save(ctx context.Context, state string, transaction *types.LoginTransaction) error {
+	return a.manager.Save(ctx, state, cache.LoginTransaction{
+		Provider:     transaction.Provider,
+		CodeVerifier: transaction.CodeVerifier,
+		Nonce:        transaction.Nonce,
+		ReturnTo:     transaction.ReturnTo,
+		Host:         transaction.Host,
+		LinkMemberID: transaction.LinkMemberID,
+		Binding:      transaction.Binding,
+	})
+}
+
+func (a *LoginTransactionStoreAdapter) Consume(ctx context.Context, state string) (*types.LoginTransaction, error) {
+	transaction, err := a.manager.Consume(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	return &types.LoginTransaction{
+		Provider:     transaction.Provider,
+		CodeVerifier: transaction.CodeVerifier,
+		Nonce:        transaction.Nonce,
+		ReturnTo:     transaction.ReturnTo,
+		Host:         transaction.Host,
+		LinkMemberID: transaction.LinkMemberID,
+		Binding:      transaction.Binding,
+	}, nil
+}
@@ -0,0 +1,32 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+)
+
+type MagicLinkServiceAdapter struct {
+	service *services.MagicLinkService
+}
+
+func NewMagicLinkServiceAdapter(service *services.MagicLinkService) *MagicLinkServiceAdapter {
+	return &MagicLinkServiceAdapter{service: service}
+}
+
+func (a *MagicLinkServiceAdapter) Start(ctx context.Context, email, returnTo, binding string) error {
+	return a.service.Start(ctx, email, returnTo, binding)
+}
+
+func (a *MagicLinkServiceAdapter) Verify(ctx context.Context, token string) (*types.MagicLink, error) {
+	link, err := a.service.Verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return &types.MagicLink{
+		Email:    link.Email,
+		ReturnTo: link.ReturnTo,
+		Binding:  link.Binding,
+	}, nil
+}
@@ -0,0 +1,157 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type MemberServiceAdapter struct {
+	service *services.MemberService
+}
+
+func NewMemberServiceAdapter(service *services.MemberService) *MemberServiceAdapter {
+	return &MemberServiceAdapter{service: service}
+}
+
+func (a *MemberServiceAdapter) GetByEmail(ctx context.Context, email string) (*types.Member, error) {
+	member, err := a.service.GetByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	return toMember(member), nil
+}
+
+func (a *MemberServiceAdapter) GetByID(ctx context.Context, memberID string) (*types.Member, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	member, err := a.service.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toMember(member), nil
+}
+
+func (a *MemberServiceAdapter) GetByExternalAccount(ctx context.Context, account types.ExternalAccount) (*types.Member, error) {
+	member, err := a.service.GetByExternalAccount(ctx, toExternalAccount(account))
+	if err != nil {
+		return nil, err
+	}
+	return toMember(member), nil
+}
+
+func (a *MemberServiceAdapter) LinkAccountByEmail(ctx context.Context, email string, account types.ExternalAccount, firstName, lastName string) (*types.Member, error) {
+	member, err := a.service.LinkAccountByEmail(ctx, email, toExternalAccount(account), optionalString(firstName), optionalString(lastName))
+	if err != nil {
+		return nil, err
+	}
+	return toMember(member), nil
+}
+
+func (a *MemberServiceAdapter) LinkAccount(ctx context.Context, memberID string, account types.ExternalAccount) (*types.MemberIdentity, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	identity, err := a.service.LinkAccount(ctx, id, toExternalAccount(account))
+	if err != nil {
+		return nil, err
+	}
+	return toMemberIdentity(identity), nil
+}
+
+func (a *MemberServiceAdapter) ListIdentities(ctx context.Context, memberID string) ([]*types.MemberIdentity, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	identities, err := a.service.ListIdentities(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.MemberIdentity, len(identities))
+	for i, identity := range identities {
+		result[i] = toMemberIdentity(identity)
+	}
+	return result, nil
+}
+
+func (a *MemberServiceAdapter) UnlinkIdentity(ctx context.Context, memberID, identityID string) error {
+	memberUUID, err := uuid.Parse(memberID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	identityUUID, err := uuid.Parse(identityID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	return a.service.UnlinkIdentity(ctx, memberUUID, identityUUID)
+}
+
+func (a *MemberServiceAdapter) CreateSystemMember(ctx context.Context, orgID, orgName, email string, account *types.ExternalAccount, firstName, lastName string) (*types.Member, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrBadRequest
+	}
+	var externalAccount *services.ExternalAccount
+	if account != nil {
+		converted := toExternalAccount(*account)
+		externalAccount = &converted
+	}
+	member, err := a.service.CreateSystem(
+		ctx,
+		id,
+		orgName,
+		email,
+		externalAccount,
+		optionalString(firstName),
+		optionalString(lastName),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return toMember(member), nil
+}
+
+func toMember(member *models.OrganizationMember) *types.Member {
+	return &types.Member{
+		ID:             member.ID.String(),
+		Email:          member.Email,
+		FirstName:      member.FirstName,
+		LastName:       member.LastName,
+		OrganizationID: member.OrganizationID.String(),
+		Role:           member.Role,
+	}
+}
+
+func toExternalAccount(account types.ExternalAccount) services.ExternalAccount {
+	return services.ExternalAccount{
+		Provider: account.Provider,
+		Issuer:   account.Issuer,
+		Subject:  account.Subject,
+	}
+}
+
+func toMemberIdentity(identity *models.MemberIdentity) *types.MemberIdentity {
+	return &types.MemberIdentity{
+		ID:         identity.ID.String(),
+		Provider:   identity.Provider,
+		Issuer:     identity.Issuer,
+		Subject:    identity.Subject,
+		LinkedAt:   identity.LinkedAt,
+		LastUsedAt: identity.LastUsedAt,
+	}
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
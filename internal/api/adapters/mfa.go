@@ -0,0 +1,134 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+)
+
+type MFAServiceAdapter struct {
+	service *services.MFAService
+}
+
+func NewMFAServiceAdapter(service *services.MFAService) *MFAServiceAdapter {
+	return &MFAServiceAdapter{service: service}
+}
+
+func (a *MFAServiceAdapter) Status(ctx context.Context, memberID string) (*types.MFAStatus, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	status, err := a.service.Status(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &types.MFAStatus{
+		Enrolled:               status.Enrolled,
+		Required:               status.Required,
+		TOTP:                   status.TOTP,
+		Passkeys:               status.Passkeys,
+		RecoveryCodesRemaining: status.RecoveryCodesRemaining,
+	}, nil
+}
+
+func (a *MFAServiceAdapter) BeginChallenge(ctx context.Context, memberID, returnTo, host string, authMethods []string) (string, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return "", core.ErrNotFound
+	}
+	return a.service.BeginChallenge(ctx, id, returnTo, host, authMethods)
+}
+
+func (a *MFAServiceAdapter) CompleteChallenge(ctx context.Context, token, code string) (*types.MFAChallenge, error) {
+	challenge, err := a.service.CompleteChallenge(ctx, token, code)
+	if err != nil {
+		return nil, err
+	}
+	return toMFAChallenge(challenge), nil
+}
+
+func (a *MFAServiceAdapter) PendingChallenge(ctx context.Context, token string) (*types.MFAChallenge, error) {
+	challenge, err := a.service.PendingChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return toMFAChallenge(challenge), nil
+}
+
+func (a *MFAServiceAdapter) ResolveChallenge(ctx context.Context, token string) (*types.MFAChallenge, error) {
+	challenge, err := a.service.ResolveChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return toMFAChallenge(challenge), nil
+}
+
+func (a *MFAServiceAdapter) Enroll(ctx context.Context, memberID string) (string, string, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return "", "", core.ErrNotFound
+	}
+	return a.service.Enroll(ctx, id)
+}
+
+func (a *MFAServiceAdapter) ConfirmEnrollment(ctx context.Context, memberID, code string) ([]string, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	return a.service.ConfirmEnrollment(ctx, id, code)
+}
+
+func (a *MFAServiceAdapter) RegenerateRecoveryCodes(ctx context.Context, memberID string) ([]string, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	return a.service.RegenerateRecoveryCodes(ctx, id)
+}
+
+func (a *MFAServiceAdapter) Disable(ctx context.Context, memberID string) error {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	return a.service.Disable(ctx, id, true)
+}
+
+func (a *MFAServiceAdapter) GetOrganizationPolicy(ctx context.Context, orgID string) (bool, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return false, core.ErrNotFound
+	}
+	return a.service.GetOrganizationPolicy(ctx, id)
+}
+
+func (a *MFAServiceAdapter) SetOrganizationPolicy(ctx context.Context, orgID string, required bool) error {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	return a.service.SetOrganizationPolicy(ctx, id, required)
+}
+
+func (a *MFAServiceAdapter) ResetMember(ctx context.Context, orgID, memberID string) error {
+	orgUUID, memberUUID, err := parseOrgScopedIDs(orgID, memberID)
+	if err != nil {
+		return err
+	}
+	return a.service.ResetMember(ctx, orgUUID, memberUUID)
+}
+
+func toMFAChallenge(challenge *cache.MFAChallenge) *types.MFAChallenge {
+	return &types.MFAChallenge{
+		MemberID:    challenge.MemberID,
+		ReturnTo:    challenge.ReturnTo,
+		Host:        challenge.Host,
+		AuthMethods: challenge.AuthMethods,
+	}
+}
@@ -0,0 +1,90 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+)
+
+type OIDCProviderServiceAdapter struct {
+	service *services.OIDCProviderService
+}
+
+func NewOIDCProviderServiceAdapter(service *services.OIDCProviderService) *OIDCProviderServiceAdapter {
+	return &OIDCProviderServiceAdapter{service: service}
+}
+
+func (a *OIDCProviderServiceAdapter) Issuer() string {
+	return a.service.Issuer()
+}
+
+func (a *OIDCProviderServiceAdapter) GetClient(ctx context.Context, clientID, redirectURI string) (*types.App, error) {
+	app, err := a.service.GetClient(ctx, clientID, redirectURI)
+	if err != nil {
+		return nil, err
+	}
+	return toApp(app), nil
+}
+
+func (a *OIDCProviderServiceAdapter) Authorize(ctx context.Context, request types.OIDCAuthorizationRequest, sessionToken string) (string, error) {
+	return a.service.Authorize(ctx, services.OIDCAuthorizationRequest{
+		ClientID:      request.ClientID,
+		RedirectURI:   request.RedirectURI,
+		Scope:         request.Scope,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+	}, sessionToken)
+}
+
+func (a *OIDCProviderServiceAdapter) Exchange(ctx context.Context, request types.OIDCTokenRequest) (*types.OIDCTokens, error) {
+	tokens, err := a.service.Exchange(ctx, services.OIDCTokenRequest{
+		ClientID:     request.ClientID,
+		ClientSecret: request.ClientSecret,
+		Code:         request.Code,
+		RedirectURI:  request.RedirectURI,
+		CodeVerifier: request.CodeVerifier,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOIDCTokens(tokens), nil
+}
+
+func (a *OIDCProviderServiceAdapter) Refresh(ctx context.Context, request types.OIDCRefreshRequest) (*types.OIDCTokens, error) {
+	tokens, err := a.service.Refresh(ctx, services.OIDCRefreshRequest{
+		ClientID:     request.ClientID,
+		ClientSecret: request.ClientSecret,
+		RefreshToken: request.RefreshToken,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toOIDCTokens(tokens), nil
+}
+
+func (a *OIDCProviderServiceAdapter) UserInfo(ctx context.Context, accessToken string) (*types.OIDCUserInfo, error) {
+	info, err := a.service.UserInfo(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+	return &types.OIDCUserInfo{
+		Subject:        info.Subject,
+		Email:          info.Email,
+		EmailVerified:  info.EmailVerified,
+		Name:           info.Name,
+		GivenName:      info.GivenName,
+		FamilyName:     info.FamilyName,
+		OrganizationID: info.OrganizationID,
+	}, nil
+}
+
+func toOIDCTokens(tokens *services.OIDCTokens) *types.OIDCTokens {
+	return &types.OIDCTokens{
+		AccessToken:  tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+		Scope:        tokens.Scope,
+		ExpiresIn:    tokens.ExpiresIn,
+	}
+}
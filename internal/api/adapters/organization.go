@@ -0,0 +1,44 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type OrganizationServiceAdapter struct {
+	service *services.OrganizationService
+}
+
+func NewOrganizationServiceAdapter(service *services.OrganizationService) *OrganizationServiceAdapter {
+	return &OrganizationServiceAdapter{service: service}
+}
+
+func (a *OrganizationServiceAdapter) GetByID(ctx context.Context, orgID string) (*types.Organization, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	org, err := a.service.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toOrganization(org), nil
+}
+
+func toOrganization(org *models.Organization) *types.Organization {
+	hostname := ""
+	if org.Hostname != nil {
+		hostname = *org.Hostname
+	}
+	return &types.Organization{
+		ID:          org.ID.String(),
+		Name:        org.Name,
+		Hostname:    hostname,
+		MFARequired: org.MFARequired,
+	}
+}
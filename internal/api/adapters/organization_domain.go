@@ -0,0 +1,93 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type OrganizationDomainServiceAdapter struct {
+	service *services.OrganizationDomainService
+}
+
+func NewOrganizationDomainServiceAdapter(service *services.OrganizationDomainService) *OrganizationDomainServiceAdapter {
+	return &OrganizationDomainServiceAdapter{service: service}
+}
+
+func (a *OrganizationDomainServiceAdapter) List(ctx context.Context, orgID string) ([]*types.OrganizationDomain, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	domains, err := a.service.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.OrganizationDomain, len(domains))
+	for i, domain := range domains {
+		result[i] = a.toOrganizationDomain(domain)
+	}
+	return result, nil
+}
+
+func (a *OrganizationDomainServiceAdapter) Create(ctx context.Context, orgID, domain string) (*types.OrganizationDomain, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	record, err := a.service.Create(ctx, id, domain)
+	if err != nil {
+		return nil, err
+	}
+	return a.toOrganizationDomain(record), nil
+}
+
+func (a *OrganizationDomainServiceAdapter) Verify(ctx context.Context, orgID, domainID string) (*types.OrganizationDomain, error) {
+	orgUUID, domainUUID, err := parseOrgScopedIDs(orgID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	record, err := a.service.Verify(ctx, orgUUID, domainUUID)
+	if err != nil {
+		return nil, err
+	}
+	return a.toOrganizationDomain(record), nil
+}
+
+func (a *OrganizationDomainServiceAdapter) Delete(ctx context.Context, orgID, domainID string) error {
+	orgUUID, domainUUID, err := parseOrgScopedIDs(orgID, domainID)
+	if err != nil {
+		return err
+	}
+	return a.service.Delete(ctx, orgUUID, domainUUID)
+}
+
+func (a *OrganizationDomainServiceAdapter) Discover(ctx context.Context, emailOrDomain string) (*types.HomeRealm, error) {
+	realm, err := a.service.Discover(ctx, emailOrDomain)
+	if err != nil {
+		return nil, err
+	}
+	return &types.HomeRealm{
+		OrganizationID: realm.OrganizationID.String(),
+		Domain:         realm.Domain,
+		ProviderSlug:   realm.ProviderSlug,
+		SAML:           realm.SAML,
+	}, nil
+}
+
+func (a *OrganizationDomainServiceAdapter) toOrganizationDomain(domain *models.OrganizationDomain) *types.OrganizationDomain {
+	name, value := a.service.VerificationRecord(domain)
+	return &types.OrganizationDomain{
+		ID:                domain.ID.String(),
+		OrganizationID:    domain.OrganizationID.String(),
+		Domain:            domain.Domain,
+		VerificationName:  name,
+		VerificationValue: value,
+		VerifiedAt:        domain.VerifiedAt,
+		CreatedAt:         domain.CreatedAt,
+	}
+}
@@ -0,0 +1,113 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/saml"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type SAMLConnectionServiceAdapter struct {
+	service *services.SAMLConnectionService
+}
+
+func NewSAMLConnectionServiceAdapter(service *services.SAMLConnectionService) *SAMLConnectionServiceAdapter {
+	return &SAMLConnectionServiceAdapter{service: service}
+}
+
+func (a *SAMLConnectionServiceAdapter) List(ctx context.Context, orgID string) ([]*types.SAMLConnection, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	connections, err := a.service.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.SAMLConnection, len(connections))
+	for i, connection := range connections {
+		result[i] = a.toSAMLConnection(connection)
+	}
+	return result, nil
+}
+
+func (a *SAMLConnectionServiceAdapter) Get(ctx context.Context, orgID, connectionID string) (*types.SAMLConnection, error) {
+	orgUUID, connectionUUID, err := parseOrgScopedIDs(orgID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	connection, err := a.service.Get(ctx, orgUUID, connectionUUID)
+	if err != nil {
+		return nil, err
+	}
+	return a.toSAMLConnection(connection), nil
+}
+
+func (a *SAMLConnectionServiceAdapter) Create(ctx context.Context, orgID string, input *types.SAMLConnectionInput) (*types.SAMLConnection, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	connection, err := a.service.Create(ctx, id, toSAMLConnectionInput(input))
+	if err != nil {
+		return nil, err
+	}
+	return a.toSAMLConnection(connection), nil
+}
+
+func (a *SAMLConnectionServiceAdapter) Update(ctx context.Context, orgID, connectionID string, input *types.SAMLConnectionInput) (*types.SAMLConnection, error) {
+	orgUUID, connectionUUID, err := parseOrgScopedIDs(orgID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	connection, err := a.service.Update(ctx, orgUUID, connectionUUID, toSAMLConnectionInput(input))
+	if err != nil {
+		return nil, err
+	}
+	return a.toSAMLConnection(connection), nil
+}
+
+func (a *SAMLConnectionServiceAdapter) Delete(ctx context.Context, orgID, connectionID string) error {
+	orgUUID, connectionUUID, err := parseOrgScopedIDs(orgID, connectionID)
+	if err != nil {
+		return err
+	}
+	return a.service.Delete(ctx, orgUUID, connectionUUID)
+}
+
+func (a *SAMLConnectionServiceAdapter) Resolve(ctx context.Context, slug string) (*saml.ServiceProvider, string, error) {
+	provider, connection, err := a.service.Resolve(ctx, slug)
+	if err != nil {
+		return nil, "", err
+	}
+	return provider, connection.OrganizationID.String(), nil
+}
+
+func (a *SAMLConnectionServiceAdapter) toSAMLConnection(connection *models.SAMLConnection) *types.SAMLConnection {
+	return &types.SAMLConnection{
+		ID:                connection.ID.String(),
+		OrganizationID:    connection.OrganizationID.String(),
+		Slug:              connection.Slug,
+		IDPEntityID:       connection.IDPEntityID,
+		AttributeMappings: connection.AttributeMappings,
+		Enabled:           connection.Enabled,
+		SPEntityID:        a.service.EntityID(connection.Slug),
+		SPACSURL:          a.service.ACSURL(connection.Slug),
+		CreatedAt:         connection.CreatedAt,
+		UpdatedAt:         connection.UpdatedAt,
+	}
+}
+
+func toSAMLConnectionInput(input *types.SAMLConnectionInput) *services.SAMLConnectionInput {
+	return &services.SAMLConnectionInput{
+		Slug:              input.Slug,
+		MetadataXML:       input.MetadataXML,
+		MetadataURL:       input.MetadataURL,
+		AttributeMappings: input.AttributeMappings,
+		Enabled:           input.Enabled,
+	}
+}
@@ -0,0 +1,268 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+)
+
+type SessionManagerAdapter struct {
+	manager *services.SessionManager
+}
+
+func NewSessionManagerAdapter(manager *services.SessionManager) *SessionManagerAdapter {
+	return &SessionManagerAdapter{manager: manager}
+}
+
+func (a *SessionManagerAdapter) CreateSession(ctx context.Context, memberID, email, orgID, microsoftID string, authMethods []string, mfaSatisfied bool, client types.SessionClient) (string, time.Time, error) {
+	memberUUID, err := uuid.Parse(memberID)
+	if err != nil {
+		return "", time.Time{}, core.ErrBadRequest
+	}
+	orgUUID, err := uuid.Parse(orgID)
+	if err != nil {
+		return "", time.Time{}, core.ErrBadRequest
+	}
+	return a.manager.CreateSession(ctx, memberUUID, email, orgUUID, microsoftID, authMethods, mfaSatisfied, toSessionClient(client))
+}
+
+func (a *SessionManagerAdapter) GetSession(ctx context.Context, token string) (*types.SessionData, error) {
+	sessionData, err := a.manager.GetSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	return toSessionData(sessionData), nil
+}
+
+func (a *SessionManagerAdapter) VerifySession(ctx context.Context, token string, client types.SessionClient) (*types.SessionData, error) {
+	sessionData, err := a.manager.VerifySession(ctx, token, toSessionClient(client))
+	if err != nil {
+		return nil, err
+	}
+	return toSessionData(sessionData), nil
+}
+
+func (a *SessionManagerAdapter) MarkMFASatisfied(ctx context.Context, token string, method core.AuthMethod) error {
+	return a.manager.MarkMFASatisfied(ctx, token, method)
+}
+
+func (a *SessionManagerAdapter) DeleteSession(ctx context.Context, token string) error {
+	return a.manager.DeleteSession(ctx, token)
+}
+
+func (a *SessionManagerAdapter) DeleteAllSessionsForMember(ctx context.Context, memberID string) (int, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return 0, core.ErrNotFound
+	}
+	return a.manager.DeleteAllSessionsForMember(ctx, id)
+}
+
+func (a *SessionManagerAdapter) ListSessions(ctx context.Context, memberID, currentToken string) ([]types.MemberSession, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	sessions, err := a.manager.ListSessions(ctx, id, currentToken)
+	if err != nil {
+		return nil, err
+	}
+	return toMemberSessions(sessions), nil
+}
+
+func (a *SessionManagerAdapter) RevokeSession(ctx context.Context, memberID, sessionID string) error {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	return a.manager.RevokeSession(ctx, id, sessionID)
+}
+
+func toSessionClient(client types.SessionClient) services.SessionClient {
+	return services.SessionClient{UserAgent: client.UserAgent, IP: client.IP}
+}
+
+func toSessionData(sessionData *cache.SessionData) *types.SessionData {
+	result := &types.SessionData{
+		MemberID:       sessionData.MemberID,
+		Email:          sessionData.Email,
+		OrganizationID: sessionData.OrganizationID,
+		MicrosoftID:    sessionData.MicrosoftID,
+		MFASatisfied:   sessionData.MFASatisfied,
+		AuthTime:       time.Unix(sessionData.AuthTime, 0),
+		AuthMethods:    sessionData.AuthMethods,
+		ClientID:       sessionData.ClientID,
+		FamilyID:       sessionData.FamilyID,
+		Audience:       sessionData.Audience,
+		UserAgent:      sessionData.UserAgent,
+		Device:         sessionData.Device,
+		Browser:        sessionData.Browser,
+		IP:             sessionData.IP,
+		Country:        sessionData.Country,
+		CreatedAt:      time.Unix(sessionData.CreatedAt, 0),
+		ExpiresAt:      time.Unix(sessionData.ExpiresAt, 0),
+	}
+	if sessionData.LastSeenAt != 0 {
+		result.LastSeenAt = time.Unix(sessionData.LastSeenAt, 0)
+	}
+	return result
+}
+
+func toMemberSessions(sessions []services.SessionInfo) []types.MemberSession {
+	result := make([]types.MemberSession, len(sessions))
+	for i, session := range sessions {
+		result[i] = types.MemberSession{
+			ID:         session.ID,
+			Kind:       session.Kind,
+			ClientID:   session.ClientID,
+			UserAgent:  session.UserAgent,
+			Device:     session.Device,
+			Browser:    session.Browser,
+			IP:         session.IP,
+			Country:    session.Country,
+			Current:    session.Current,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		}
+	}
+	return result
+}
+
+type SessionRevocationServiceAdapter struct {
+	service *services.SessionRevocationService
+}
+
+func NewSessionRevocationServiceAdapter(service *services.SessionRevocationService) *SessionRevocationServiceAdapter {
+	return &SessionRevocationServiceAdapter{service: service}
+}
+
+func (a *SessionRevocationServiceAdapter) ListMemberSessions(ctx context.Context, orgID, memberID string) ([]types.MemberSession, error) {
+	orgUUID, memberUUID, err := parseOrgScopedIDs(orgID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := a.service.ListMember(ctx, orgUUID, memberUUID)
+	if err != nil {
+		return nil, err
+	}
+	return toMemberSessions(sessions), nil
+}
+
+func (a *SessionRevocationServiceAdapter) RevokeMemberSession(ctx context.Context, orgID, memberID, sessionID string) error {
+	orgUUID, memberUUID, err := parseOrgScopedIDs(orgID, memberID)
+	if err != nil {
+		return err
+	}
+	return a.service.RevokeMemberSession(ctx, orgUUID, memberUUID, sessionID)
+}
+
+func (a *SessionRevocationServiceAdapter) RevokeMemberSessions(ctx context.Context, orgID, memberID string) (int, error) {
+	orgUUID, memberUUID, err := parseOrgScopedIDs(orgID, memberID)
+	if err != nil {
+		return 0, err
+	}
+	return a.service.RevokeMember(ctx, orgUUID, memberUUID)
+}
+
+func (a *SessionRevocationServiceAdapter) RevokeOrganizationSessions(ctx context.Context, orgID string) (int, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return 0, core.ErrNotFound
+	}
+	return a.service.RevokeOrganization(ctx, id)
+}
+
+type SessionPolicyServiceAdapter struct {
+	service *services.SessionPolicyService
+}
+
+func NewSessionPolicyServiceAdapter(service *services.SessionPolicyService) *SessionPolicyServiceAdapter {
+	return &SessionPolicyServiceAdapter{service: service}
+}
+
+func (a *SessionPolicyServiceAdapter) GetOrganizationPolicy(ctx context.Context, orgID string) (*types.SessionPolicy, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	policy, err := a.service.GetOrganizationPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return toSessionPolicy(policy), nil
+}
+
+func (a *SessionPolicyServiceAdapter) SetOrganizationPolicy(ctx context.Context, orgID string, idleTimeoutMinutes, lifetimeDays *int) (*types.SessionPolicy, error) {
+	id, err := uuid.Parse(orgID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	policy, err := a.service.SetOrganizationPolicy(ctx, id, idleTimeoutMinutes, lifetimeDays)
+	if err != nil {
+		return nil, err
+	}
+	return toSessionPolicy(policy), nil
+}
+
+func toSessionPolicy(policy *services.SessionPolicy) *types.SessionPolicy {
+	return &types.SessionPolicy{
+		IdleTimeoutMinutes:   policy.IdleTimeoutMinutes,
+		LifetimeDays:         policy.LifetimeDays,
+		EffectiveIdleTimeout: policy.Effective.IdleTimeout,
+		EffectiveLifetime:    policy.Effective.Lifetime,
+	}
+}
+
+type SessionServiceAdapter struct {
+	service *services.SessionService
+}
+
+func NewSessionServiceAdapter(service *services.SessionService) *SessionServiceAdapter {
+	return &SessionServiceAdapter{service: service}
+}
+
+func (a *SessionServiceAdapter) RecordLogin(ctx context.Context, memberID, email, orgID, microsoftID string) error {
+	memberUUID, err := uuid.Parse(memberID)
+	if err != nil {
+		return core.ErrBadRequest
+	}
+	orgUUID, err := uuid.Parse(orgID)
+	if err != nil {
+		return core.ErrBadRequest
+	}
+	return a.service.RecordLogin(ctx, memberUUID, email, orgUUID, microsoftID)
+}
+
+func (a *SessionServiceAdapter) GetLastLoginForMember(ctx context.Context, memberID string) (*time.Time, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	return a.service.GetLastLoginForMember(ctx, id)
+}
+
+func (a *SessionServiceAdapter) GetLastLoginsBatch(ctx context.Context, memberIDs []string) (map[string]*time.Time, error) {
+	ids := make([]uuid.UUID, 0, len(memberIDs))
+	for _, memberID := range memberIDs {
+		id, err := uuid.Parse(memberID)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	logins, err := a.service.GetLastLoginsBatch(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]*time.Time, len(logins))
+	for id, lastLogin := range logins {
+		result[id.String()] = lastLogin
+	}
+	return result, nil
+}
@@ -0,0 +1,20 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/vondr/identity-go/internal/application/services"
+)
+
+type SigningKeyServiceAdapter struct {
+	service *services.SigningKeyService
+}
+
+func NewSigningKeyServiceAdapter(service *services.SigningKeyService) *SigningKeyServiceAdapter {
+	return &SigningKeyServiceAdapter{service: service}
+}
+
+func (a *SigningKeyServiceAdapter) KeySet(ctx context.Context) (jose.JSONWebKeySet, error) {
+	return a.service.KeySet(ctx)
+}
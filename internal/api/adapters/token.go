@@ -0,0 +1,42 @@
+package adapters
+
+import (
+	"context"
+	"time"
+
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+)
+
+type TokenServiceAdapter struct {
+	service *services.TokenService
+}
+
+func NewTokenServiceAdapter(service *services.TokenService) *TokenServiceAdapter {
+	return &TokenServiceAdapter{service: service}
+}
+
+func (a *TokenServiceAdapter) Introspect(ctx context.Context, caller types.ClientAuthentication, token string) (*types.TokenIntrospection, error) {
+	introspection, err := a.service.Introspect(ctx, toClientAuthentication(caller), token)
+	if err != nil {
+		return nil, err
+	}
+	if !introspection.Active {
+		return &types.TokenIntrospection{}, nil
+	}
+	return &types.TokenIntrospection{
+		Active:         true,
+		Issuer:         introspection.Issuer,
+		Subject:        introspection.Subject,
+		Username:       introspection.Username,
+		ClientID:       introspection.ClientID,
+		OrganizationID: introspection.OrganizationID,
+		Scope:          introspection.Scope,
+		IssuedAt:       time.Unix(introspection.IssuedAt, 0),
+		ExpiresAt:      time.Unix(introspection.ExpiresAt, 0),
+	}, nil
+}
+
+func (a *TokenServiceAdapter) Revoke(ctx context.Context, caller types.ClientAuthentication, token string) error {
+	return a.service.Revoke(ctx, toClientAuthentication(caller), token)
+}
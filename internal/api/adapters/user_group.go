@@ -0,0 +1,38 @@
+package adapters
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type UserGroupServiceAdapter struct {
+	service services.UserGroupService
+}
+
+func NewUserGroupServiceAdapter(service services.UserGroupService) *UserGroupServiceAdapter {
+	return &UserGroupServiceAdapter{service: service}
+}
+
+func (a *UserGroupServiceAdapter) ListGroupsForMember(ctx context.Context, memberID string) ([]*types.UserGroup, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	groups, err := a.service.ListGroupsForMember(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.UserGroup, len(groups))
+	for i, group := range groups {
+		result[i] = &types.UserGroup{
+			ID:          group.ID.String(),
+			Name:        group.Name,
+			Description: group.Description,
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,111 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/application/services"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type WebAuthnServiceAdapter struct {
+	service *services.WebAuthnService
+}
+
+func NewWebAuthnServiceAdapter(service *services.WebAuthnService) *WebAuthnServiceAdapter {
+	return &WebAuthnServiceAdapter{service: service}
+}
+
+func (a *WebAuthnServiceAdapter) List(ctx context.Context, memberID string) ([]*types.WebAuthnCredential, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	credentials, err := a.service.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*types.WebAuthnCredential, len(credentials))
+	for i, credential := range credentials {
+		result[i] = toWebAuthnCredential(credential)
+	}
+	return result, nil
+}
+
+func (a *WebAuthnServiceAdapter) Delete(ctx context.Context, memberID, credentialID string) error {
+	memberUUID, err := uuid.Parse(memberID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	credentialUUID, err := uuid.Parse(credentialID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	return a.service.Delete(ctx, memberUUID, credentialUUID)
+}
+
+func (a *WebAuthnServiceAdapter) BeginRegistration(ctx context.Context, memberID string) (json.RawMessage, string, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, "", core.ErrNotFound
+	}
+	return a.service.BeginRegistration(ctx, id)
+}
+
+func (a *WebAuthnServiceAdapter) FinishRegistration(ctx context.Context, memberID, ceremony, name string, response []byte) (*types.WebAuthnCredential, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	credential, err := a.service.FinishRegistration(ctx, id, ceremony, name, response)
+	if err != nil {
+		return nil, err
+	}
+	return toWebAuthnCredential(credential), nil
+}
+
+func (a *WebAuthnServiceAdapter) BeginLogin(ctx context.Context, returnTo, host string) (json.RawMessage, string, error) {
+	return a.service.BeginLogin(ctx, returnTo, host)
+}
+
+func (a *WebAuthnServiceAdapter) FinishLogin(ctx context.Context, ceremony string, response []byte) (*types.PasskeyLogin, error) {
+	login, err := a.service.FinishLogin(ctx, ceremony, response)
+	if err != nil {
+		return nil, err
+	}
+	return &types.PasskeyLogin{
+		MemberID: login.MemberID.String(),
+		ReturnTo: login.ReturnTo,
+		Host:     login.Host,
+	}, nil
+}
+
+func (a *WebAuthnServiceAdapter) BeginSecondFactor(ctx context.Context, memberID string) (json.RawMessage, string, error) {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return nil, "", core.ErrNotFound
+	}
+	return a.service.BeginSecondFactor(ctx, id)
+}
+
+func (a *WebAuthnServiceAdapter) FinishSecondFactor(ctx context.Context, memberID, ceremony string, response []byte) error {
+	id, err := uuid.Parse(memberID)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	return a.service.FinishSecondFactor(ctx, id, ceremony, response)
+}
+
+func toWebAuthnCredential(credential *models.WebAuthnCredential) *types.WebAuthnCredential {
+	return &types.WebAuthnCredential{
+		ID:           credential.ID.String(),
+		Name:         credential.Name,
+		Transports:   []string(credential.Transports),
+		CloneWarning: credential.CloneWarning,
+		LastUsedAt:   credential.LastUsedAt,
+		CreatedAt:    credential.CreatedAt,
+	}
+}
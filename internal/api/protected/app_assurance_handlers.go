@@ -0,0 +1,95 @@
+package protected
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type AppAssuranceHandler struct {
+	appService types.AppAssuranceService
+}
+
+func NewAppAssuranceHandler(appService types.AppAssuranceService) *AppAssuranceHandler {
+	return &AppAssuranceHandler{
+		appService: appService,
+	}
+}
+
+type appAssurancePolicyRequest struct {
+	RequiredAuthMethod string `json:"required_auth_method"`
+	RequireMFA         bool   `json:"require_mfa"`
+	MaxAuthAgeSeconds  int    `json:"max_auth_age_seconds"`
+}
+
+type appAssurancePolicyResponse struct {
+	AppID              string `json:"app_id"`
+	RequiredAuthMethod string `json:"required_auth_method,omitempty"`
+	RequireMFA         bool   `json:"require_mfa"`
+	MaxAuthAgeSeconds  int    `json:"max_auth_age_seconds"`
+}
+
+func toAppAssurancePolicyResponse(app *types.App) appAssurancePolicyResponse {
+	return appAssurancePolicyResponse{
+		AppID:              app.ID,
+		RequiredAuthMethod: app.Assurance.RequiredAuthMethod.String(),
+		RequireMFA:         app.Assurance.RequireMFA,
+		MaxAuthAgeSeconds:  int(app.Assurance.MaxAuthAge / time.Second),
+	}
+}
+
+// GetPolicy godoc
+// @Summary Get app assurance policy
+// @Description How recently and with which methods a session must have authenticated to pass forward auth for the app
+// @Tags apps
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Success 200 {object} appAssurancePolicyResponse
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/assurance-policy [get]
+func (h *AppAssuranceHandler) GetPolicy(c *gin.Context) {
+	app, err := h.appService.GetForOrganization(c.Request.Context(), c.Param("org_id"), c.Param("app_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAppAssurancePolicyResponse(app))
+}
+
+// SetPolicy godoc
+// @Summary Set app assurance policy
+// @Description Replace the app's step-up policy. required_auth_method is one of microsoft, relatics, oidc, saml, email, passkey or otp; max_auth_age_seconds of 0 means no limit. Sessions that fall short are sent to step-up by forward auth.
+// @Tags apps
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Param policy body appAssurancePolicyRequest true "Assurance policy"
+// @Success 200 {object} appAssurancePolicyResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/assurance-policy [put]
+func (h *AppAssuranceHandler) SetPolicy(c *gin.Context) {
+	var req appAssurancePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	app, err := h.appService.SetAssurancePolicy(c.Request.Context(), c.Param("org_id"), c.Param("app_id"), types.AppAssurancePolicy{
+		RequiredAuthMethod: core.AuthMethod(req.RequiredAuthMethod),
+		RequireMFA:         req.RequireMFA,
+		MaxAuthAge:         time.Duration(req.MaxAuthAgeSeconds) * time.Second,
+	})
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAppAssurancePolicyResponse(app))
+}
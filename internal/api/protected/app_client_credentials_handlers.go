@@ -0,0 +1,153 @@
+package protected
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type AppClientCredentialsHandler struct {
+	appService types.AppClientCredentialsService
+}
+
+func NewAppClientCredentialsHandler(appService types.AppClientCredentialsService) *AppClientCredentialsHandler {
+	return &AppClientCredentialsHandler{
+		appService: appService,
+	}
+}
+
+type appClientCredentialsRequest struct {
+	Scopes []string        `json:"scopes"`
+	JWKS   json.RawMessage `json:"jwks"`
+}
+
+type appClientCredentialsResponse struct {
+	ClientID  string          `json:"client_id"`
+	Enabled   bool            `json:"enabled"`
+	Scopes    []string        `json:"scopes"`
+	HasSecret bool            `json:"has_secret"`
+	JWKS      json.RawMessage `json:"jwks,omitempty"`
+}
+
+type appClientSecretResponse struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+func toAppClientCredentialsResponse(app *types.App) appClientCredentialsResponse {
+	scopes := app.Client.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	response := appClientCredentialsResponse{
+		ClientID:  app.ID,
+		Enabled:   app.Client.HasSecret || app.Client.JWKS != nil,
+		Scopes:    scopes,
+		HasSecret: app.Client.HasSecret,
+	}
+	if app.Client.JWKS != nil {
+		response.JWKS = json.RawMessage(*app.Client.JWKS)
+	}
+	return response
+}
+
+// GetClientCredentials godoc
+// @Summary Get app client credentials registration
+// @Description The app's registration for the client credentials grant at /oidc/token. The client ID is the app ID; the grant is enabled once the app has a client secret or keys.
+// @Tags apps
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Success 200 {object} appClientCredentialsResponse
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/client-credentials [get]
+func (h *AppClientCredentialsHandler) GetClientCredentials(c *gin.Context) {
+	app, err := h.appService.GetForOrganization(c.Request.Context(), c.Param("org_id"), c.Param("app_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAppClientCredentialsResponse(app))
+}
+
+// SetClientCredentials godoc
+// @Summary Set app client credentials scopes and keys
+// @Description Replace the scopes the app may request with the client credentials grant and the JSON Web Key Set of public keys it signs private_key_jwt client assertions with. Omitting jwks removes the keys.
+// @Tags apps
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Param client body appClientCredentialsRequest true "Scopes and keys"
+// @Success 200 {object} appClientCredentialsResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/client-credentials [put]
+func (h *AppClientCredentialsHandler) SetClientCredentials(c *gin.Context) {
+	var req appClientCredentialsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var jwks *string
+	if len(req.JWKS) > 0 && string(req.JWKS) != "null" {
+		raw := string(req.JWKS)
+		jwks = &raw
+	}
+
+	app, err := h.appService.SetClientCredentials(c.Request.Context(), c.Param("org_id"), c.Param("app_id"), req.Scopes, jwks)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAppClientCredentialsResponse(app))
+}
+
+// RotateClientSecret godoc
+// @Summary Rotate app client credentials secret
+// @Description Issue a new client secret for the client credentials grant. The previous secret stops working immediately; access tokens already issued stay valid until they expire. The secret is only returned in this response.
+// @Tags apps
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Success 200 {object} appClientSecretResponse
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/client-credentials/secret [post]
+func (h *AppClientCredentialsHandler) RotateClientSecret(c *gin.Context) {
+	app, secret, err := h.appService.RotateClientSecret(c.Request.Context(), c.Param("org_id"), c.Param("app_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, appClientSecretResponse{
+		ClientID:     app.ID,
+		ClientSecret: secret,
+	})
+}
+
+// DisableClientCredentials godoc
+// @Summary Disable app client credentials
+// @Description Discard the app's client secret, keys and scopes. Access tokens the app already obtained stop working.
+// @Tags apps
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Success 200 {object} appClientCredentialsResponse
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/client-credentials [delete]
+func (h *AppClientCredentialsHandler) DisableClientCredentials(c *gin.Context) {
+	app, err := h.appService.DisableClientCredentials(c.Request.Context(), c.Param("org_id"), c.Param("app_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAppClientCredentialsResponse(app))
+}
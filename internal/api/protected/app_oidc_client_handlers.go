@@ -0,0 +1,119 @@
+package protected
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type AppOIDCClientHandler struct {
+	appService types.AppOIDCClientService
+}
+
+func NewAppOIDCClientHandler(appService types.AppOIDCClientService) *AppOIDCClientHandler {
+	return &AppOIDCClientHandler{
+		appService: appService,
+	}
+}
+
+type appOIDCClientRequest struct {
+	RedirectURIs []string `json:"redirect_uris"`
+}
+
+type appOIDCClientResponse struct {
+	ClientID     string   `json:"client_id"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Confidential bool     `json:"confidential"`
+}
+
+type appOIDCClientSecretResponse struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+}
+
+func toAppOIDCClientResponse(app *types.App) appOIDCClientResponse {
+	redirectURIs := app.OIDC.RedirectURIs
+	if redirectURIs == nil {
+		redirectURIs = []string{}
+	}
+	return appOIDCClientResponse{
+		ClientID:     app.ID,
+		RedirectURIs: redirectURIs,
+		Confidential: app.OIDC.Confidential,
+	}
+}
+
+// GetClient godoc
+// @Summary Get app OpenID Connect client
+// @Description The app's registration as a client of the built-in OpenID Connect provider. The client ID is the app ID.
+// @Tags apps
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Success 200 {object} appOIDCClientResponse
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/oidc-client [get]
+func (h *AppOIDCClientHandler) GetClient(c *gin.Context) {
+	app, err := h.appService.GetForOrganization(c.Request.Context(), c.Param("org_id"), c.Param("app_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAppOIDCClientResponse(app))
+}
+
+// SetClient godoc
+// @Summary Set app OpenID Connect redirect URIs
+// @Description Replace the redirect URIs the app may receive authorization codes at. Redirect URIs must use https, or http on a loopback host. An empty list unregisters the client and discards its secret.
+// @Tags apps
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Param client body appOIDCClientRequest true "Redirect URIs"
+// @Success 200 {object} appOIDCClientResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/oidc-client [put]
+func (h *AppOIDCClientHandler) SetClient(c *gin.Context) {
+	var req appOIDCClientRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	app, err := h.appService.SetOIDCClient(c.Request.Context(), c.Param("org_id"), c.Param("app_id"), req.RedirectURIs)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toAppOIDCClientResponse(app))
+}
+
+// RotateSecret godoc
+// @Summary Rotate app OpenID Connect client secret
+// @Description Issue a new client secret, making the app a confidential client. The previous secret stops working immediately. The secret is only returned in this response.
+// @Tags apps
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param app_id path string true "App ID"
+// @Success 200 {object} appOIDCClientSecretResponse
+// @Failure 400 {object} map[string]string "App has no redirect URIs"
+// @Failure 404 {object} map[string]string "App not found"
+// @Router /api/v1/organizations/{org_id}/apps/{app_id}/oidc-client/secret [post]
+func (h *AppOIDCClientHandler) RotateSecret(c *gin.Context) {
+	app, secret, err := h.appService.RotateOIDCClientSecret(c.Request.Context(), c.Param("org_id"), c.Param("app_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, appOIDCClientSecretResponse{
+		ClientID:     app.ID,
+		ClientSecret: secret,
+	})
+}
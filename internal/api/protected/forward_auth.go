@@ -2,14 +2,19 @@ package protected
 
 import (
 	"context"
+	"log"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/vondr/identity-go/docs"
 	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/pkg/forwardauth"
 )
 
 type ForwardAuthHandler struct {
@@ -19,8 +24,16 @@ type ForwardAuthHandler struct {
 	orgService     types.OrganizationService
 	countryService types.AppAllowedCountryService
 	geoipService   types.GeoIPService
+	groupService   types.UserGroupService
+	tokenIssuer    types.ForwardAuthTokenIssuer
+	clientTokens   types.ClientCredentialsService
+	legacyTokens   bool
 	authLoginURL   string
 	errorLoginURL  string
+
+	// legacyTokenApps remembers which apps were already warned about
+	// using the deprecated x-vondr-auth token.
+	legacyTokenApps sync.Map
 }
 
 func NewForwardAuthHandler(
@@ -30,6 +43,10 @@ func NewForwardAuthHandler(
 	orgService types.OrganizationService,
 	countryService types.AppAllowedCountryService,
 	geoipService types.GeoIPService,
+	groupService types.UserGroupService,
+	tokenIssuer types.ForwardAuthTokenIssuer,
+	clientTokens types.ClientCredentialsService,
+	legacyTokens bool,
 	authLoginURL string,
 	errorLoginURL string,
 ) *ForwardAuthHandler {
@@ -40,6 +57,10 @@ func NewForwardAuthHandler(
 		orgService:     orgService,
 		countryService: countryService,
 		geoipService:   geoipService,
+		groupService:   groupService,
+		tokenIssuer:    tokenIssuer,
+		clientTokens:   clientTokens,
+		legacyTokens:   legacyTokens,
 		authLoginURL:   authLoginURL,
 		errorLoginURL:  errorLoginURL,
 	}
@@ -144,21 +165,33 @@ func buildLoginRedirectURL(c *gin.Context, authLoginURL string) string {
 	return authLoginURL + "?auto=1&return_to=" + url.QueryEscape(originalURL)
 }
 
+// bearerToken returns the token of an "Authorization: Bearer" header, used by
+// clients such as CLIs that hold a session token from the device flow and
+// by apps holding a client credentials access token.
+func bearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
 func buildErrorRedirectURL(errorLoginURL string, errorCode string) string {
 	return errorLoginURL + "/" + errorCode
 }
 
 // Verify godoc
 // @Summary Traefik forward auth
-// @Description Traefik forward auth endpoint - validates session or M2M token
+// @Description Traefik forward auth endpoint - validates the session cookie, a session token or client credentials access token sent as Authorization Bearer, or a deprecated M2M token
 // @Tags auth
 // @Accept  json
 // @Produce  json
-// @Param x-vondr-auth header string false "M2M token"
+// @Param x-vondr-auth header string false "Deprecated M2M token, only accepted with LEGACY_APP_TOKENS_ENABLED"
 // @Param x-vondr-user-id header string false "User ID (required with M2M token)"
-// @Success 200 {header} string "Headers: x-vondr-user-id, x-vondr-email, x-vondr-organization-id"
+// @Param Authorization header string false "Bearer session token from the device authorization grant or OpenID Connect, or a client credentials access token"
+// @Success 200 {header} string "Headers: x-vondr-user-id, x-vondr-email, x-vondr-organization-id (x-vondr-client-id, x-vondr-organization-id and x-vondr-scope for client credentials tokens) and, with FORWARD_AUTH_JWT_ENABLED, x-vondr-jwt"
 // @Success 302 {string} string "Redirect to login"
-// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 401 {object} map[string]string "Unauthorized, or stepUpRequiredResponse when the app's assurance policy is not met"
 // @Failure 403 {object} map[string]string "Forbidden"
 // @Router /auth/verify [get]
 func (h *ForwardAuthHandler) Verify(c *gin.Context) {
@@ -170,7 +203,11 @@ func (h *ForwardAuthHandler) Verify(c *gin.Context) {
 	}
 
 	isPreflight := strings.ToUpper(originalMethod) == "OPTIONS" || c.GetHeader("access-control-request-method") != ""
+	bearer := bearerToken(c)
 	sessionToken, _ := c.Cookie("session_token")
+	if sessionToken == "" {
+		sessionToken = bearer
+	}
 
 	if isPreflight && sessionToken == "" {
 		c.Status(http.StatusOK)
@@ -191,8 +228,15 @@ func (h *ForwardAuthHandler) Verify(c *gin.Context) {
 		return
 	}
 
-	sessionData, err := h.sessionManager.GetSession(ctx, sessionToken)
+	client := types.SessionClient{UserAgent: c.Request.UserAgent(), IP: extractClientIP(c)}
+	sessionData, err := h.sessionManager.VerifySession(ctx, sessionToken, client)
 	if err != nil {
+		if sessionToken == bearer && h.clientTokens != nil {
+			if clientToken, err := h.clientTokens.Validate(ctx, bearer); err == nil {
+				h.handleClientAuth(ctx, c, clientToken, isBrowserRequest)
+				return
+			}
+		}
 		h.handleInvalidSession(c, isBrowserRequest)
 		return
 	}
@@ -203,14 +247,29 @@ func (h *ForwardAuthHandler) Verify(c *gin.Context) {
 		return
 	}
 
+	if !sessionData.MFASatisfied {
+		org, err := h.orgService.GetByID(ctx, member.OrganizationID)
+		if err != nil {
+			h.handleInvalidSession(c, isBrowserRequest)
+			return
+		}
+		if org.MFARequired {
+			h.handleMFARequired(c, isBrowserRequest)
+			return
+		}
+	}
+
 	forwardedHost := c.GetHeader("x-forwarded-host")
 	forwardedProto := c.GetHeader("x-forwarded-proto")
 
+	if sessionData.Audience != "" && !h.isAudienceHost(ctx, member, forwardedHost, sessionData.Audience) {
+		log.Printf("Security: access token of client %s presented for %q", sessionData.ClientID, forwardedHost)
+		h.handleAppNotAllowed(c, isBrowserRequest)
+		return
+	}
+
 	if member.Role == "system" {
-		c.Header("x-vondr-user-id", member.ID)
-		c.Header("x-vondr-email", member.Email)
-		c.Header("x-vondr-organization-id", member.OrganizationID)
-		c.Status(http.StatusOK)
+		h.allow(c, member, "")
 		return
 	}
 
@@ -219,6 +278,7 @@ func (h *ForwardAuthHandler) Verify(c *gin.Context) {
 		return
 	}
 
+	var appID string
 	if forwardedHost != "" {
 		org, err := h.orgService.GetByID(ctx, member.OrganizationID)
 		if err != nil {
@@ -251,22 +311,54 @@ func (h *ForwardAuthHandler) Verify(c *gin.Context) {
 					h.handleCountryBlocked(c, isBrowserRequest)
 					return
 				}
+
+				if unmet := targetApp.Assurance.Unmet(sessionData, time.Now()); len(unmet) > 0 {
+					h.handleStepUpRequired(c, isBrowserRequest, targetApp.Assurance, unmet)
+					return
+				}
+				appID = targetApp.ID
 			}
 		}
 	}
 
-	c.Header("x-vondr-user-id", member.ID)
-	c.Header("x-vondr-email", member.Email)
-	c.Header("x-vondr-organization-id", member.OrganizationID)
-	c.Status(http.StatusOK)
+	h.allow(c, member, appID)
+}
+
+// isAudienceHost reports whether forwardedHost is served by the app an
+// access token is restricted to. OpenID Connect access tokens only work at
+// the client that obtained them, not at every app of the organization.
+func (h *ForwardAuthHandler) isAudienceHost(ctx context.Context, member *types.Member, forwardedHost, audience string) bool {
+	if forwardedHost == "" {
+		return false
+	}
+	org, err := h.orgService.GetByID(ctx, member.OrganizationID)
+	if err != nil {
+		return false
+	}
+	domainMap, err := h.appService.GetDomainAppMap(ctx, member.OrganizationID, org.Hostname)
+	if err != nil {
+		return false
+	}
+	app := domainMap[forwardedHost]
+	return app != nil && app.ID == audience
 }
 
+// handleM2MAuth accepts the deprecated static app token, which trusts the
+// caller's x-vondr-user-id. Apps should use the client credentials grant.
 func (h *ForwardAuthHandler) handleM2MAuth(ctx context.Context, c *gin.Context, m2mToken string, isBrowserRequest bool) {
+	if !h.legacyTokens {
+		h.handleUnauthorized(c, isBrowserRequest, "x-vondr-auth tokens are no longer accepted; use a client credentials access token")
+		return
+	}
+
 	app, err := h.appService.GetByToken(ctx, m2mToken)
 	if err != nil {
 		h.handleUnauthorized(c, isBrowserRequest, "Invalid authentication token")
 		return
 	}
+	if _, warned := h.legacyTokenApps.LoadOrStore(app.ID, true); !warned {
+		log.Printf("Warning: App %s authenticates with the deprecated x-vondr-auth token; switch it to the client credentials grant", app.ID)
+	}
 
 	rawUserID := c.GetHeader("x-vondr-user-id")
 	if rawUserID == "" {
@@ -316,12 +408,115 @@ func (h *ForwardAuthHandler) handleM2MAuth(ctx context.Context, c *gin.Context,
 		}
 	}
 
+	h.allow(c, member, app.ID)
+}
+
+// handleClientAuth lets through an app holding a client credentials access
+// token. The token identifies the app itself, so no member headers are set;
+// like M2M tokens it only reaches its own organization's domains.
+func (h *ForwardAuthHandler) handleClientAuth(ctx context.Context, c *gin.Context, token *types.ClientAccessToken, isBrowserRequest bool) {
+	app, err := h.appService.GetByID(ctx, token.ClientID)
+	if err != nil {
+		h.handleUnauthorized(c, isBrowserRequest, "Invalid access token")
+		return
+	}
+
+	forwardedHost := c.GetHeader("x-forwarded-host")
+	if forwardedHost != "" {
+		org, err := h.orgService.GetByID(ctx, app.OrganizationID)
+		if err != nil {
+			h.handleUnauthorized(c, isBrowserRequest, "Organization associated with this application no longer exists")
+			return
+		}
+
+		allowedDomains, _ := h.appService.GetAllowedDomainsForOrg(ctx, app.OrganizationID, org.Hostname)
+		if !slices.Contains(allowedDomains, forwardedHost) {
+			h.handleUnauthorized(c, isBrowserRequest, "Access to this domain is not allowed for this client")
+			return
+		}
+
+		clientIP := extractClientIP(c)
+		countryError := checkCountryAccess(ctx, app, clientIP, h.countryService, h.geoipService)
+		if countryError != "" {
+			h.handleUnauthorized(c, isBrowserRequest, countryError)
+			return
+		}
+	}
+
+	c.Header("x-vondr-client-id", app.ID)
+	c.Header("x-vondr-organization-id", app.OrganizationID)
+	c.Header("x-vondr-scope", token.Scope)
+
+	if !h.issueIdentityToken(c, types.ForwardAuthIdentity{
+		ClientID:       app.ID,
+		OrganizationID: app.OrganizationID,
+		Scope:          token.Scope,
+		AppID:          app.ID,
+	}) {
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// allow lets the request through with the member's identity in the
+// x-vondr-* headers and, when enabled, in a signed token upstreams can
+// verify with the forwardauth package.
+func (h *ForwardAuthHandler) allow(c *gin.Context, member *types.Member, appID string) {
 	c.Header("x-vondr-user-id", member.ID)
 	c.Header("x-vondr-email", member.Email)
 	c.Header("x-vondr-organization-id", member.OrganizationID)
+
+	if h.tokenIssuer != nil && getForwardedValue(c.GetHeader("x-forwarded-host")) != "" {
+		groups, err := h.groupService.ListGroupsForMember(c.Request.Context(), member.ID)
+		if err != nil {
+			log.Printf("Warning: Failed to load groups of member %s for forward auth token: %v", member.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue identity token"})
+			return
+		}
+		groupIDs := make([]string, len(groups))
+		for i, group := range groups {
+			groupIDs[i] = group.ID
+		}
+
+		if !h.issueIdentityToken(c, types.ForwardAuthIdentity{
+			MemberID:       member.ID,
+			Email:          member.Email,
+			OrganizationID: member.OrganizationID,
+			Role:           member.Role.String(),
+			Groups:         groupIDs,
+			AppID:          appID,
+		}) {
+			return
+		}
+	}
+
 	c.Status(http.StatusOK)
 }
 
+// issueIdentityToken sets the signed identity token when enabled. The token
+// is only issued for requests with a forwarded host, which becomes its
+// audience. It reports false after answering with an error, so requests are
+// never let through without the token.
+func (h *ForwardAuthHandler) issueIdentityToken(c *gin.Context, identity types.ForwardAuthIdentity) bool {
+	identity.Host = getForwardedValue(c.GetHeader("x-forwarded-host"))
+	if h.tokenIssuer == nil || identity.Host == "" {
+		return true
+	}
+
+	token, err := h.tokenIssuer.Issue(c.Request.Context(), identity)
+	if err != nil {
+		subject := "member " + identity.MemberID
+		if identity.ClientID != "" {
+			subject = "client " + identity.ClientID
+		}
+		log.Printf("Warning: Failed to sign forward auth token for %s: %v", subject, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue identity token"})
+		return false
+	}
+	c.Header(forwardauth.HeaderName, token)
+	return true
+}
+
 func (h *ForwardAuthHandler) handleNoSession(c *gin.Context, isBrowserRequest bool) {
 	if isBrowserRequest {
 		loginURL := buildLoginRedirectURL(c, h.authLoginURL)
@@ -358,6 +553,17 @@ func (h *ForwardAuthHandler) handleAppNotAllowed(c *gin.Context, isBrowserReques
 	}
 }
 
+// handleMFARequired sends browsers through login again, which challenges
+// enrolled members and sends the others to enrollment.
+func (h *ForwardAuthHandler) handleMFARequired(c *gin.Context, isBrowserRequest bool) {
+	if isBrowserRequest {
+		loginURL := buildLoginRedirectURL(c, h.authLoginURL)
+		c.Redirect(http.StatusFound, loginURL)
+	} else {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your organization requires multi-factor authentication"})
+	}
+}
+
 func (h *ForwardAuthHandler) handleCountryBlocked(c *gin.Context, isBrowserRequest bool) {
 	if isBrowserRequest {
 		errorURL := buildErrorRedirectURL(h.errorLoginURL, "403/app_country_blocked")
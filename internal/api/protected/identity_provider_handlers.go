@@ -0,0 +1,221 @@
+package protected
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type IdentityProviderHandler struct {
+	providerService types.IdentityProviderService
+}
+
+func NewIdentityProviderHandler(providerService types.IdentityProviderService) *IdentityProviderHandler {
+	return &IdentityProviderHandler{
+		providerService: providerService,
+	}
+}
+
+type identityProviderRequest struct {
+	Slug             string            `json:"slug" binding:"required"`
+	Type             string            `json:"type" binding:"required"`
+	Issuer           string            `json:"issuer"`
+	ClientID         string            `json:"client_id" binding:"required"`
+	ClientSecret     *string           `json:"client_secret"`
+	AllowedTenantIDs []string          `json:"allowed_tenant_ids"`
+	ClaimMappings    map[string]string `json:"claim_mappings"`
+	Enabled          *bool             `json:"enabled"`
+}
+
+type identityProviderResponse struct {
+	ID               string            `json:"id"`
+	OrganizationID   string            `json:"organization_id"`
+	Slug             string            `json:"slug"`
+	Type             string            `json:"type"`
+	Issuer           string            `json:"issuer"`
+	ClientID         string            `json:"client_id"`
+	AllowedTenantIDs []string          `json:"allowed_tenant_ids"`
+	ClaimMappings    map[string]string `json:"claim_mappings"`
+	Enabled          bool              `json:"enabled"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+func (r *identityProviderRequest) toInput() *types.IdentityProviderInput {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	return &types.IdentityProviderInput{
+		Slug:             r.Slug,
+		Type:             core.IdentityProviderType(r.Type),
+		Issuer:           r.Issuer,
+		ClientID:         r.ClientID,
+		ClientSecret:     r.ClientSecret,
+		AllowedTenantIDs: r.AllowedTenantIDs,
+		ClaimMappings:    r.ClaimMappings,
+		Enabled:          enabled,
+	}
+}
+
+func toIdentityProviderResponse(provider *types.IdentityProviderConfig) identityProviderResponse {
+	tenantIDs := provider.AllowedTenantIDs
+	if tenantIDs == nil {
+		tenantIDs = []string{}
+	}
+	mappings := provider.ClaimMappings
+	if mappings == nil {
+		mappings = map[string]string{}
+	}
+	return identityProviderResponse{
+		ID:               provider.ID,
+		OrganizationID:   provider.OrganizationID,
+		Slug:             provider.Slug,
+		Type:             provider.Type.String(),
+		Issuer:           provider.Issuer,
+		ClientID:         provider.ClientID,
+		AllowedTenantIDs: tenantIDs,
+		ClaimMappings:    mappings,
+		Enabled:          provider.Enabled,
+		CreatedAt:        provider.CreatedAt,
+		UpdatedAt:        provider.UpdatedAt,
+	}
+}
+
+func writeServiceError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, core.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+	case errors.Is(err, core.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Resource already exists"})
+	case errors.Is(err, core.ErrBadRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// List godoc
+// @Summary List identity providers
+// @Description List the identity providers configured for an organization. Client secrets are never returned.
+// @Tags identity-providers
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Success 200 {array} identityProviderResponse
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/identity-providers [get]
+func (h *IdentityProviderHandler) List(c *gin.Context) {
+	providers, err := h.providerService.List(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	response := make([]identityProviderResponse, len(providers))
+	for i, provider := range providers {
+		response[i] = toIdentityProviderResponse(provider)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Get godoc
+// @Summary Get identity provider
+// @Description Get a single identity provider of an organization
+// @Tags identity-providers
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param provider_id path string true "Identity provider ID"
+// @Success 200 {object} identityProviderResponse
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/organizations/{org_id}/identity-providers/{provider_id} [get]
+func (h *IdentityProviderHandler) Get(c *gin.Context) {
+	provider, err := h.providerService.Get(c.Request.Context(), c.Param("org_id"), c.Param("provider_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toIdentityProviderResponse(provider))
+}
+
+// Create godoc
+// @Summary Create identity provider
+// @Description Register an Entra ID tenant or OIDC provider (e.g. a Keycloak realm) for an organization. Members log in through /auth/{slug}/login.
+// @Tags identity-providers
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param provider body identityProviderRequest true "Identity provider"
+// @Success 201 {object} identityProviderResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Failure 409 {object} map[string]string "Slug already in use"
+// @Router /api/v1/organizations/{org_id}/identity-providers [post]
+func (h *IdentityProviderHandler) Create(c *gin.Context) {
+	var req identityProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := h.providerService.Create(c.Request.Context(), c.Param("org_id"), req.toInput())
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toIdentityProviderResponse(provider))
+}
+
+// Update godoc
+// @Summary Update identity provider
+// @Description Replace the configuration of an identity provider. The stored client secret is kept when client_secret is omitted.
+// @Tags identity-providers
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param provider_id path string true "Identity provider ID"
+// @Param provider body identityProviderRequest true "Identity provider"
+// @Success 200 {object} identityProviderResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 409 {object} map[string]string "Slug already in use"
+// @Router /api/v1/organizations/{org_id}/identity-providers/{provider_id} [put]
+func (h *IdentityProviderHandler) Update(c *gin.Context) {
+	var req identityProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	provider, err := h.providerService.Update(c.Request.Context(), c.Param("org_id"), c.Param("provider_id"), req.toInput())
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toIdentityProviderResponse(provider))
+}
+
+// Delete godoc
+// @Summary Delete identity provider
+// @Description Remove an identity provider from an organization
+// @Tags identity-providers
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param provider_id path string true "Identity provider ID"
+// @Success 204 "Deleted"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/organizations/{org_id}/identity-providers/{provider_id} [delete]
+func (h *IdentityProviderHandler) Delete(c *gin.Context) {
+	if err := h.providerService.Delete(c.Request.Context(), c.Param("org_id"), c.Param("provider_id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,91 @@
+package protected
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type MFAHandler struct {
+	mfaService types.MFAPolicyService
+}
+
+func NewMFAHandler(mfaService types.MFAPolicyService) *MFAHandler {
+	return &MFAHandler{
+		mfaService: mfaService,
+	}
+}
+
+type mfaPolicyRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+type mfaPolicyResponse struct {
+	OrganizationID string `json:"organization_id"`
+	Required       bool   `json:"required"`
+}
+
+// GetPolicy godoc
+// @Summary Get MFA policy
+// @Description Whether members of the organization must use a TOTP authenticator
+// @Tags mfa
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} mfaPolicyResponse
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/mfa-policy [get]
+func (h *MFAHandler) GetPolicy(c *gin.Context) {
+	required, err := h.mfaService.GetOrganizationPolicy(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mfaPolicyResponse{OrganizationID: c.Param("org_id"), Required: required})
+}
+
+// SetPolicy godoc
+// @Summary Set MFA policy
+// @Description Require or stop requiring MFA for the organization. Once required, sessions without completed MFA are rejected by forward auth and members without an authenticator are sent to enrollment.
+// @Tags mfa
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param policy body mfaPolicyRequest true "MFA policy"
+// @Success 200 {object} mfaPolicyResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/mfa-policy [put]
+func (h *MFAHandler) SetPolicy(c *gin.Context) {
+	var req mfaPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.mfaService.SetOrganizationPolicy(c.Request.Context(), c.Param("org_id"), *req.Required); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, mfaPolicyResponse{OrganizationID: c.Param("org_id"), Required: *req.Required})
+}
+
+// ResetMember godoc
+// @Summary Reset member MFA
+// @Description Remove a member's authenticator, recovery codes and passkeys, e.g. after a lost device. The member enrolls again on next login if the organization requires MFA.
+// @Tags mfa
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param member_id path string true "Member ID"
+// @Success 204 "Reset"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/organizations/{org_id}/members/{member_id}/mfa [delete]
+func (h *MFAHandler) ResetMember(c *gin.Context) {
+	if err := h.mfaService.ResetMember(c.Request.Context(), c.Param("org_id"), c.Param("member_id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,139 @@
+package protected
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type OrganizationDomainHandler struct {
+	domainService types.OrganizationDomainService
+}
+
+func NewOrganizationDomainHandler(domainService types.OrganizationDomainService) *OrganizationDomainHandler {
+	return &OrganizationDomainHandler{
+		domainService: domainService,
+	}
+}
+
+type organizationDomainRequest struct {
+	Domain string `json:"domain" binding:"required"`
+}
+
+type organizationDomainResponse struct {
+	ID                string     `json:"id"`
+	OrganizationID    string     `json:"organization_id"`
+	Domain            string     `json:"domain"`
+	Verified          bool       `json:"verified"`
+	VerifiedAt        *time.Time `json:"verified_at"`
+	VerificationName  string     `json:"verification_record_name"`
+	VerificationValue string     `json:"verification_record_value"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+func toOrganizationDomainResponse(domain *types.OrganizationDomain) organizationDomainResponse {
+	return organizationDomainResponse{
+		ID:                domain.ID,
+		OrganizationID:    domain.OrganizationID,
+		Domain:            domain.Domain,
+		Verified:          domain.VerifiedAt != nil,
+		VerifiedAt:        domain.VerifiedAt,
+		VerificationName:  domain.VerificationName,
+		VerificationValue: domain.VerificationValue,
+		CreatedAt:         domain.CreatedAt,
+	}
+}
+
+// List godoc
+// @Summary List organization domains
+// @Description List the email domains claimed by an organization and their verification status
+// @Tags organization-domains
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Success 200 {array} organizationDomainResponse
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/domains [get]
+func (h *OrganizationDomainHandler) List(c *gin.Context) {
+	domains, err := h.domainService.List(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	response := make([]organizationDomainResponse, len(domains))
+	for i, domain := range domains {
+		response[i] = toOrganizationDomainResponse(domain)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Create godoc
+// @Summary Claim organization domain
+// @Description Claim an email domain for home realm discovery. Publish the returned TXT record and call verify before the domain is used.
+// @Tags organization-domains
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param domain body organizationDomainRequest true "Domain"
+// @Success 201 {object} organizationDomainResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Failure 409 {object} map[string]string "Domain already claimed"
+// @Router /api/v1/organizations/{org_id}/domains [post]
+func (h *OrganizationDomainHandler) Create(c *gin.Context) {
+	var req organizationDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	domain, err := h.domainService.Create(c.Request.Context(), c.Param("org_id"), req.Domain)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toOrganizationDomainResponse(domain))
+}
+
+// Verify godoc
+// @Summary Verify organization domain
+// @Description Check the domain's verification TXT record and mark the domain verified
+// @Tags organization-domains
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param domain_id path string true "Domain ID"
+// @Success 200 {object} organizationDomainResponse
+// @Failure 400 {object} map[string]string "Verification record missing or wrong"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/organizations/{org_id}/domains/{domain_id}/verify [post]
+func (h *OrganizationDomainHandler) Verify(c *gin.Context) {
+	domain, err := h.domainService.Verify(c.Request.Context(), c.Param("org_id"), c.Param("domain_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toOrganizationDomainResponse(domain))
+}
+
+// Delete godoc
+// @Summary Delete organization domain
+// @Description Release an email domain claimed by an organization
+// @Tags organization-domains
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param domain_id path string true "Domain ID"
+// @Success 204 "Deleted"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/organizations/{org_id}/domains/{domain_id} [delete]
+func (h *OrganizationDomainHandler) Delete(c *gin.Context) {
+	if err := h.domainService.Delete(c.Request.Context(), c.Param("org_id"), c.Param("domain_id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
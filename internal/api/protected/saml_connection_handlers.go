@@ -0,0 +1,194 @@
+package protected
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type SAMLConnectionHandler struct {
+	connectionService types.SAMLConnectionService
+}
+
+func NewSAMLConnectionHandler(connectionService types.SAMLConnectionService) *SAMLConnectionHandler {
+	return &SAMLConnectionHandler{
+		connectionService: connectionService,
+	}
+}
+
+type samlConnectionRequest struct {
+	Slug              string            `json:"slug" binding:"required"`
+	IDPMetadataXML    string            `json:"idp_metadata_xml"`
+	IDPMetadataURL    string            `json:"idp_metadata_url"`
+	AttributeMappings map[string]string `json:"attribute_mappings"`
+	Enabled           *bool             `json:"enabled"`
+}
+
+type samlConnectionResponse struct {
+	ID                string            `json:"id"`
+	OrganizationID    string            `json:"organization_id"`
+	Slug              string            `json:"slug"`
+	IDPEntityID       string            `json:"idp_entity_id"`
+	AttributeMappings map[string]string `json:"attribute_mappings"`
+	Enabled           bool              `json:"enabled"`
+	SPEntityID        string            `json:"sp_entity_id"`
+	SPACSURL          string            `json:"sp_acs_url"`
+	CreatedAt         time.Time         `json:"created_at"`
+	UpdatedAt         time.Time         `json:"updated_at"`
+}
+
+func (r *samlConnectionRequest) toInput() *types.SAMLConnectionInput {
+	enabled := true
+	if r.Enabled != nil {
+		enabled = *r.Enabled
+	}
+	return &types.SAMLConnectionInput{
+		Slug:              r.Slug,
+		MetadataXML:       r.IDPMetadataXML,
+		MetadataURL:       r.IDPMetadataURL,
+		AttributeMappings: r.AttributeMappings,
+		Enabled:           enabled,
+	}
+}
+
+func toSAMLConnectionResponse(connection *types.SAMLConnection) samlConnectionResponse {
+	mappings := connection.AttributeMappings
+	if mappings == nil {
+		mappings = map[string]string{}
+	}
+	return samlConnectionResponse{
+		ID:                connection.ID,
+		OrganizationID:    connection.OrganizationID,
+		Slug:              connection.Slug,
+		IDPEntityID:       connection.IDPEntityID,
+		AttributeMappings: mappings,
+		Enabled:           connection.Enabled,
+		SPEntityID:        connection.SPEntityID,
+		SPACSURL:          connection.SPACSURL,
+		CreatedAt:         connection.CreatedAt,
+		UpdatedAt:         connection.UpdatedAt,
+	}
+}
+
+// List godoc
+// @Summary List SAML connections
+// @Description List the SAML identity provider connections of an organization
+// @Tags saml-connections
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Success 200 {array} samlConnectionResponse
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/saml-connections [get]
+func (h *SAMLConnectionHandler) List(c *gin.Context) {
+	connections, err := h.connectionService.List(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	response := make([]samlConnectionResponse, len(connections))
+	for i, connection := range connections {
+		response[i] = toSAMLConnectionResponse(connection)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Get godoc
+// @Summary Get SAML connection
+// @Description Get a single SAML connection of an organization
+// @Tags saml-connections
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param connection_id path string true "SAML connection ID"
+// @Success 200 {object} samlConnectionResponse
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/organizations/{org_id}/saml-connections/{connection_id} [get]
+func (h *SAMLConnectionHandler) Get(c *gin.Context) {
+	connection, err := h.connectionService.Get(c.Request.Context(), c.Param("org_id"), c.Param("connection_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toSAMLConnectionResponse(connection))
+}
+
+// Create godoc
+// @Summary Create SAML connection
+// @Description Import IdP metadata (inline XML or an https URL fetched once) for an organization. Members log in through /auth/saml/{slug}/login; the IdP must be given the SP metadata at /auth/saml/{slug}/metadata.
+// @Tags saml-connections
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param connection body samlConnectionRequest true "SAML connection"
+// @Success 201 {object} samlConnectionResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Failure 409 {object} map[string]string "Slug already in use"
+// @Router /api/v1/organizations/{org_id}/saml-connections [post]
+func (h *SAMLConnectionHandler) Create(c *gin.Context) {
+	var req samlConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connection, err := h.connectionService.Create(c.Request.Context(), c.Param("org_id"), req.toInput())
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, toSAMLConnectionResponse(connection))
+}
+
+// Update godoc
+// @Summary Update SAML connection
+// @Description Update a SAML connection. The stored IdP metadata is kept when neither idp_metadata_xml nor idp_metadata_url is given.
+// @Tags saml-connections
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param connection_id path string true "SAML connection ID"
+// @Param connection body samlConnectionRequest true "SAML connection"
+// @Success 200 {object} samlConnectionResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 409 {object} map[string]string "Slug already in use"
+// @Router /api/v1/organizations/{org_id}/saml-connections/{connection_id} [put]
+func (h *SAMLConnectionHandler) Update(c *gin.Context) {
+	var req samlConnectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connection, err := h.connectionService.Update(c.Request.Context(), c.Param("org_id"), c.Param("connection_id"), req.toInput())
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toSAMLConnectionResponse(connection))
+}
+
+// Delete godoc
+// @Summary Delete SAML connection
+// @Description Remove a SAML connection from an organization
+// @Tags saml-connections
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param connection_id path string true "SAML connection ID"
+// @Success 204 "Deleted"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/v1/organizations/{org_id}/saml-connections/{connection_id} [delete]
+func (h *SAMLConnectionHandler) Delete(c *gin.Context) {
+	if err := h.connectionService.Delete(c.Request.Context(), c.Param("org_id"), c.Param("connection_id")); err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
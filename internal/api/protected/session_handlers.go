@@ -0,0 +1,133 @@
+package protected
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type SessionHandler struct {
+	revocationService types.SessionRevocationService
+}
+
+func NewSessionHandler(revocationService types.SessionRevocationService) *SessionHandler {
+	return &SessionHandler{
+		revocationService: revocationService,
+	}
+}
+
+type sessionRevocationResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+type memberSessionResponse struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	ClientID   string    `json:"client_id,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Device     string    `json:"device,omitempty"`
+	Browser    string    `json:"browser,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ListMemberSessions godoc
+// @Summary List member sessions
+// @Description The member's active sessions, most recently used first: browser sessions and clients such as CLIs, with the device, IP address and country they were last used from
+// @Tags sessions
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param member_id path string true "Member ID"
+// @Success 200 {array} memberSessionResponse
+// @Failure 404 {object} map[string]string "Member not found"
+// @Router /api/v1/organizations/{org_id}/members/{member_id}/sessions [get]
+func (h *SessionHandler) ListMemberSessions(c *gin.Context) {
+	sessions, err := h.revocationService.ListMemberSessions(c.Request.Context(), c.Param("org_id"), c.Param("member_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+
+	response := make([]memberSessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = memberSessionResponse{
+			ID:         session.ID,
+			Kind:       session.Kind,
+			ClientID:   session.ClientID,
+			UserAgent:  session.UserAgent,
+			Device:     session.Device,
+			Browser:    session.Browser,
+			IP:         session.IP,
+			Country:    session.Country,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeMemberSession godoc
+// @Summary Revoke a member session
+// @Description End one session of a member by the ID from the session list. Revoking a client also revokes its refresh token.
+// @Tags sessions
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param member_id path string true "Member ID"
+// @Param session_id path string true "Session ID"
+// @Success 204 "Revoked"
+// @Failure 404 {object} map[string]string "Member or session not found"
+// @Router /api/v1/organizations/{org_id}/members/{member_id}/sessions/{session_id} [delete]
+func (h *SessionHandler) RevokeMemberSession(c *gin.Context) {
+	err := h.revocationService.RevokeMemberSession(c.Request.Context(), c.Param("org_id"), c.Param("member_id"), c.Param("session_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeMemberSessions godoc
+// @Summary Revoke member sessions
+// @Description End every session of a member, including tokens held by device clients, e.g. after a compromised account
+// @Tags sessions
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param member_id path string true "Member ID"
+// @Success 200 {object} sessionRevocationResponse
+// @Failure 404 {object} map[string]string "Member not found"
+// @Router /api/v1/organizations/{org_id}/members/{member_id}/sessions [delete]
+func (h *SessionHandler) RevokeMemberSessions(c *gin.Context) {
+	revoked, err := h.revocationService.RevokeMemberSessions(c.Request.Context(), c.Param("org_id"), c.Param("member_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sessionRevocationResponse{Revoked: revoked})
+}
+
+// RevokeOrganizationSessions godoc
+// @Summary Revoke organization sessions
+// @Description End every session of every member of the organization
+// @Tags sessions
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} sessionRevocationResponse
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/sessions [delete]
+func (h *SessionHandler) RevokeOrganizationSessions(c *gin.Context) {
+	revoked, err := h.revocationService.RevokeOrganizationSessions(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sessionRevocationResponse{Revoked: revoked})
+}
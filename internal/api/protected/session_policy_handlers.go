@@ -0,0 +1,88 @@
+package protected
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type SessionPolicyHandler struct {
+	policyService types.SessionPolicyService
+}
+
+func NewSessionPolicyHandler(policyService types.SessionPolicyService) *SessionPolicyHandler {
+	return &SessionPolicyHandler{
+		policyService: policyService,
+	}
+}
+
+type sessionPolicyRequest struct {
+	IdleTimeoutMinutes *int `json:"idle_timeout_minutes"`
+	LifetimeDays       *int `json:"lifetime_days"`
+}
+
+type sessionPolicyResponse struct {
+	OrganizationID              string `json:"organization_id"`
+	IdleTimeoutMinutes          *int   `json:"idle_timeout_minutes"`
+	LifetimeDays                *int   `json:"lifetime_days"`
+	EffectiveIdleTimeoutMinutes int    `json:"effective_idle_timeout_minutes"`
+	EffectiveLifetimeDays       int    `json:"effective_lifetime_days"`
+}
+
+// GetPolicy godoc
+// @Summary Get session policy
+// @Description The organization's overrides of the session idle timeout and lifetime, null when the global default applies, and the values in effect
+// @Tags sessions
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Success 200 {object} sessionPolicyResponse
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/session-policy [get]
+func (h *SessionPolicyHandler) GetPolicy(c *gin.Context) {
+	policy, err := h.policyService.GetOrganizationPolicy(c.Request.Context(), c.Param("org_id"))
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toSessionPolicyResponse(c.Param("org_id"), policy))
+}
+
+// SetPolicy godoc
+// @Summary Set session policy
+// @Description Override how long browser sessions of the organization's members last. Sessions end after idle_timeout_minutes without use (at least 15) and at the latest lifetime_days after login; null restores the global default. Applies to sessions started afterwards.
+// @Tags sessions
+// @Accept  json
+// @Produce  json
+// @Security AdminToken
+// @Param org_id path string true "Organization ID"
+// @Param policy body sessionPolicyRequest true "Session policy"
+// @Success 200 {object} sessionPolicyResponse
+// @Failure 400 {object} map[string]string "Bad request"
+// @Failure 404 {object} map[string]string "Organization not found"
+// @Router /api/v1/organizations/{org_id}/session-policy [put]
+func (h *SessionPolicyHandler) SetPolicy(c *gin.Context) {
+	var req sessionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	policy, err := h.policyService.SetOrganizationPolicy(c.Request.Context(), c.Param("org_id"), req.IdleTimeoutMinutes, req.LifetimeDays)
+	if err != nil {
+		writeServiceError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, toSessionPolicyResponse(c.Param("org_id"), policy))
+}
+
+func toSessionPolicyResponse(orgID string, policy *types.SessionPolicy) sessionPolicyResponse {
+	return sessionPolicyResponse{
+		OrganizationID:              orgID,
+		IdleTimeoutMinutes:          policy.IdleTimeoutMinutes,
+		LifetimeDays:                policy.LifetimeDays,
+		EffectiveIdleTimeoutMinutes: int(policy.EffectiveIdleTimeout.Minutes()),
+		EffectiveLifetimeDays:       int(policy.EffectiveLifetime.Hours() / 24),
+	}
+}
@@ -0,0 +1,73 @@
+package protected
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type stepUpPolicyResponse struct {
+	RequiredAuthMethod string `json:"required_auth_method,omitempty"`
+	RequireMFA         bool   `json:"require_mfa"`
+	MaxAuthAgeSeconds  int    `json:"max_auth_age_seconds"`
+}
+
+type stepUpRequiredResponse struct {
+	Error     string               `json:"error"`
+	Code      string               `json:"code"`
+	Unmet     []string             `json:"unmet"`
+	Policy    stepUpPolicyResponse `json:"policy"`
+	StepUpURL string               `json:"step_up_url,omitempty"`
+}
+
+// buildStepUpURL points at the public step-up endpoint, which re-authenticates
+// the member and returns them to the original request.
+func buildStepUpURL(c *gin.Context, authLoginURL string, policy types.AppAssurancePolicy) string {
+	originalURL := buildOriginalRequestURL(c)
+	if originalURL == "" {
+		return ""
+	}
+	query := url.Values{"return_to": {originalURL}}
+	if policy.RequiredAuthMethod != "" {
+		query.Set("method", policy.RequiredAuthMethod.String())
+	}
+	if policy.RequireMFA {
+		query.Set("mfa", "1")
+	}
+	return authLoginURL + "/auth/step-up?" + query.Encode()
+}
+
+// handleStepUpRequired sends browsers to step-up and tells API clients what
+// is missing, with a WWW-Authenticate challenge in the style of RFC 9470.
+func (h *ForwardAuthHandler) handleStepUpRequired(c *gin.Context, isBrowserRequest bool, policy types.AppAssurancePolicy, unmet []string) {
+	stepUpURL := buildStepUpURL(c, h.authLoginURL, policy)
+	if isBrowserRequest {
+		if stepUpURL == "" {
+			stepUpURL = buildLoginRedirectURL(c, h.authLoginURL)
+		}
+		c.Redirect(http.StatusFound, stepUpURL)
+		return
+	}
+
+	maxAuthAge := int(policy.MaxAuthAge / time.Second)
+	challenge := `Bearer error="insufficient_user_authentication", error_description="A more recent or stronger authentication is required"`
+	if maxAuthAge > 0 {
+		challenge += fmt.Sprintf(", max_age=%d", maxAuthAge)
+	}
+	c.Header("WWW-Authenticate", challenge)
+	c.JSON(http.StatusUnauthorized, stepUpRequiredResponse{
+		Error: "This application requires a more recent or stronger authentication",
+		Code:  "step_up_required",
+		Unmet: unmet,
+		Policy: stepUpPolicyResponse{
+			RequiredAuthMethod: policy.RequiredAuthMethod.String(),
+			RequireMFA:         policy.RequireMFA,
+			MaxAuthAgeSeconds:  maxAuthAge,
+		},
+		StepUpURL: stepUpURL,
+	})
+}
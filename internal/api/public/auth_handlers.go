@@ -1,163 +1,164 @@
 package public
 
 import (
-	"context"
-	"crypto/rand"
-	"encoding/base64"
+	"errors"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/vondr/identity-go/docs"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
 	"github.com/vondr/identity-go/internal/core/oauth"
 )
 
-type AuthHandler struct {
-	oauthClient    *oauth.MicrosoftOAuthClient
-	callbackURL    string
-	postLoginURL   string
-	cookieDomain   string
-	cookieSecure   bool
-	cookieSameSite http.SameSite
-	sessionTTL     int
-	sessionManager SessionManager
-	memberService  MemberService
-	orgService     OrganizationService
-	sessionService SessionService
-	systemEmails   map[string]bool
-	defaultOrgID   string
-	defaultOrgName string
-}
-
-type SessionManager interface {
-	CreateSession(ctx context.Context, memberID, email, orgID, microsoftID string) (string, error)
-	GetSession(ctx context.Context, token string) (*SessionData, error)
-	DeleteSession(ctx context.Context, token string) error
-}
-
-type SessionData struct {
-	MemberID       string
-	Email          string
-	OrganizationID string
-	MicrosoftID    string
-}
-
-type MemberService interface {
-	GetByMicrosoftID(ctx context.Context, microsoftID string) (*Member, error)
-	GetByEmail(ctx context.Context, email string) (*Member, error)
-	LinkMicrosoftAccount(ctx context.Context, email, microsoftID, firstName, lastName string) (*Member, error)
-	CreateSystemMember(ctx context.Context, orgID, orgName, email, microsoftID, firstName, lastName string) (*Member, error)
-	GetByID(ctx context.Context, memberID string) (*Member, error)
-}
-
-type OrganizationService interface {
-	GetByID(ctx context.Context, orgID string) (*Organization, error)
-}
-
-type SessionService interface {
-	RecordLogin(ctx context.Context, microsoftID, email, orgID string) error
-}
+const sessionCookieName = "session_token"
 
-type Member struct {
-	ID             string
-	Email          string
-	FirstName      *string
-	LastName       *string
-	MicrosoftID    *string
-	OrganizationID string
-	Role           string
-}
-
-type Organization struct {
-	ID       string
-	Name     string
-	Hostname *string
+type AuthHandler struct {
+	providers          map[string]oauth.IdentityProvider
+	orgProviders       types.IdentityProviderResolver
+	samlProviders      types.SAMLServiceProviderResolver
+	homeRealms         types.HomeRealmResolver
+	magicLinks         types.MagicLinkService
+	mfa                types.MFAService
+	passkeys           types.WebAuthnService
+	devices            types.DeviceAuthorizationService
+	oidc               types.OIDCProviderService
+	clientCredentials  types.ClientCredentialsService
+	postLoginURL       string
+	errorLoginURL      string
+	cookieDomain       string
+	cookieSecure       bool
+	cookieSameSite     http.SameSite
+	sessionManager     types.SessionManager
+	loginTxStore       types.LoginTransactionStore
+	memberService      types.MemberService
+	orgService         types.OrganizationService
+	appService         types.AppService
+	sessionService     types.SessionService
+	groupService       types.UserGroupService
+	systemEmails       map[string]bool
+	defaultOrgID       string
+	defaultOrgName     string
+	allowedReturnHosts map[string]bool
 }
 
 func NewAuthHandler(
-	oauthClient *oauth.MicrosoftOAuthClient,
-	callbackURL string,
+	providers []oauth.IdentityProvider,
+	orgProviders types.IdentityProviderResolver,
+	samlProviders types.SAMLServiceProviderResolver,
+	homeRealms types.HomeRealmResolver,
+	magicLinks types.MagicLinkService,
+	mfa types.MFAService,
+	passkeys types.WebAuthnService,
+	devices types.DeviceAuthorizationService,
+	oidc types.OIDCProviderService,
+	clientCredentials types.ClientCredentialsService,
 	postLoginURL string,
+	errorLoginURL string,
 	cookieDomain string,
 	cookieSecure bool,
 	cookieSameSite http.SameSite,
-	sessionTTL int,
-	sessionManager SessionManager,
-	memberService MemberService,
-	orgService OrganizationService,
-	sessionService SessionService,
+	sessionManager types.SessionManager,
+	loginTxStore types.LoginTransactionStore,
+	memberService types.MemberService,
+	orgService types.OrganizationService,
+	appService types.AppService,
+	sessionService types.SessionService,
+	groupService types.UserGroupService,
 	systemEmails []string,
 	defaultOrgID string,
 	defaultOrgName string,
+	allowedReturnHosts []string,
 ) *AuthHandler {
 	systemEmailsMap := make(map[string]bool)
 	for _, email := range systemEmails {
 		systemEmailsMap[strings.ToLower(email)] = true
 	}
-	return &AuthHandler{
-		oauthClient:    oauthClient,
-		callbackURL:    callbackURL,
-		postLoginURL:   postLoginURL,
-		cookieDomain:   cookieDomain,
-		cookieSecure:   cookieSecure,
-		cookieSameSite: cookieSameSite,
-		sessionTTL:     sessionTTL,
+	providersMap := make(map[string]oauth.IdentityProvider)
+	for _, provider := range providers {
+		providersMap[provider.Name()] = provider
 	}
-}
-
-func generateState() (string, error) {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", err
+	allowedReturnHostsMap := make(map[string]bool)
+	for _, host := range allowedReturnHosts {
+		allowedReturnHostsMap[strings.ToLower(host)] = true
+	}
+	return &AuthHandler{
+		providers:          providersMap,
+		orgProviders:       orgProviders,
+		samlProviders:      samlProviders,
+		homeRealms:         homeRealms,
+		magicLinks:         magicLinks,
+		mfa:                mfa,
+		passkeys:           passkeys,
+		devices:            devices,
+		oidc:               oidc,
+		clientCredentials:  clientCredentials,
+		postLoginURL:       postLoginURL,
+		errorLoginURL:      errorLoginURL,
+		cookieDomain:       cookieDomain,
+		cookieSecure:       cookieSecure,
+		cookieSameSite:     cookieSameSite,
+		sessionManager:     sessionManager,
+		loginTxStore:       loginTxStore,
+		memberService:      memberService,
+		orgService:         orgService,
+		appService:         appService,
+		sessionService:     sessionService,
+		groupService:       groupService,
+		systemEmails:       systemEmailsMap,
+		defaultOrgID:       defaultOrgID,
+		defaultOrgName:     defaultOrgName,
+		allowedReturnHosts: allowedReturnHostsMap,
 	}
-	return base64.URLEncoding.EncodeToString(b), nil
 }
 
-// MicrosoftLogin godoc
-// @Summary Microsoft OAuth login
-// @Description Redirect user to Microsoft for authentication
-// @Tags auth
-// @Accept  json
-// @Produce  json
-// @Param return_to query string false "URL to redirect to after successful login"
-// @Success 302 {string} string "Redirect to Microsoft"
-// @Router /auth/microsoft/login [get]
-// MicrosoftLogin godoc
-// @Summary Microsoft OAuth login
-// @Description Redirect user to Microsoft for authentication
+// Login godoc
+// @Summary Identity provider login
+// @Description Redirect user to the selected identity provider for authentication
 // @Tags auth
 // @Accept  json
 // @Produce  json
+// @Param provider path string true "Identity provider (e.g. microsoft, relatics)"
 // @Param return_to query string false "URL to redirect to after successful login"
-// @Success 302 {string} string "Redirect to Microsoft"
-// @Router /auth/microsoft/login [get]
-func (h *AuthHandler) MicrosoftLogin(c *gin.Context) {
-	returnTo := c.Query("return_to")
-
-	state, err := generateState()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate state"})
+// @Param login_hint query string false "Email address to pre-fill at the identity provider"
+// @Param domain_hint query string false "Domain hint for Entra ID tenant selection"
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 404 {object} map[string]string "Unknown identity provider"
+// @Router /auth/{provider}/login [get]
+func (h *AuthHandler) Login(c *gin.Context) {
+	provider, _, ok := h.lookupProvider(c)
+	if !ok {
 		return
 	}
 
-	authURL := h.oauthClient.GetAuthURL(h.callbackURL, state, returnTo)
-	c.Redirect(http.StatusFound, authURL)
+	h.beginLogin(c, provider, "")
 }
 
-// MicrosoftCallback godoc
-// @Summary Microsoft OAuth callback
-// @Description Handle OAuth callback from Microsoft - exchanges code for user info and returns it
+// Callback godoc
+// @Summary Identity provider callback
+// @Description Handle the authorization code callback - validates the identity, links or provisions the member, creates a session and redirects
 // @Tags auth
 // @Accept  json
 // @Produce  json
-// @Param code query string true "Authorization code from Microsoft"
-// @Param state query string false "OAuth state parameter"
-// @Success 200 {object} map[string]string "User info returned"
+// @Param provider path string true "Identity provider (e.g. microsoft, relatics)"
+// @Param code query string true "Authorization code from the identity provider"
+// @Param state query string true "OAuth state parameter"
+// @Success 302 {string} string "Redirect to return_to or the post-login URL"
 // @Failure 400 {object} map[string]string "Bad request"
-// @Router /auth/microsoft/callback [get]
-func (h *AuthHandler) MicrosoftCallback(c *gin.Context) {
+// @Failure 404 {object} map[string]string "Unknown identity provider"
+// @Failure 500 {object} map[string]string "Internal server error"
+// @Router /auth/{provider}/callback [get]
+func (h *AuthHandler) Callback(c *gin.Context) {
 	ctx := c.Request.Context()
 
+	provider, orgID, ok := h.lookupProvider(c)
+	if !ok {
+		return
+	}
+
 	code := c.Query("code")
 
 	if code == "" {
@@ -165,70 +166,285 @@ func (h *AuthHandler) MicrosoftCallback(c *gin.Context) {
 		return
 	}
 
-	userInfo, err := h.oauthClient.ExchangeCode(ctx, code)
+	transaction, ok := h.consumeLogin(c, c.Query("state"), provider.Name(), http.SameSiteLaxMode)
+	if !ok {
+		return
+	}
+
+	claims, err := provider.Exchange(ctx, code, transaction.CodeVerifier, transaction.Nonce)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to exchange authorization code: " + err.Error()})
 		return
 	}
 
-	email := strings.ToLower(userInfo.Email)
-	if email == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email not provided by Microsoft"})
-		return
+	method := core.AuthMethod(provider.Name())
+	if orgID != "" {
+		method = core.AuthMethodOIDC
+	}
+
+	h.completeLogin(c, transaction, &externalIdentity{
+		Provider:       provider.Name(),
+		Method:         method,
+		OrganizationID: orgID,
+		Issuer:         claims.Issuer,
+		Subject:        claims.Subject,
+		Email:          claims.Email,
+		FirstName:      claims.GivenName,
+		LastName:       claims.FamilyName,
+	})
+}
+
+// lookupProvider resolves the provider named in the route. Built-in providers
+// take precedence; any other slug is looked up in the organization-configured
+// providers, in which case the owning organization ID is returned as well.
+func (h *AuthHandler) lookupProvider(c *gin.Context) (oauth.IdentityProvider, string, bool) {
+	slug := c.Param("provider")
+	if provider, ok := h.providers[slug]; ok {
+		return provider, "", true
+	}
+
+	if h.orgProviders != nil {
+		provider, orgID, err := h.orgProviders.Resolve(c.Request.Context(), slug)
+		if err == nil {
+			return provider, orgID, true
+		}
+		if !errors.Is(err, core.ErrNotFound) {
+			log.Printf("Warning: Failed to resolve identity provider %s: %v", slug, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load identity provider"})
+			return nil, "", false
+		}
 	}
 
-	microsoftID := userInfo.ID
+	c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+	return nil, "", false
+}
+
+func (h *AuthHandler) setSessionCookie(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(h.cookieSameSite)
+	c.SetCookie(
+		sessionCookieName,
+		value,
+		maxAge,
+		"/",
+		h.cookieDomain,
+		h.cookieSecure,
+		true,
+	)
+}
+
+// currentSession returns the session of the current browser and its token.
+// Access tokens issued to device or OpenID Connect clients are not browser
+// sessions, even when placed in the session cookie.
+func (h *AuthHandler) currentSession(c *gin.Context) (*types.SessionData, string, bool) {
+	token, err := c.Cookie(sessionCookieName)
+	if err != nil || token == "" {
+		return nil, "", false
+	}
+	session, err := h.sessionManager.GetSession(c.Request.Context(), token)
+	if err != nil {
+		return nil, "", false
+	}
+	if session.ClientID != "" || session.FamilyID != "" {
+		log.Printf("Security: access token of client %s presented as the session of member %s", session.ClientID, session.MemberID)
+		return nil, "", false
+	}
+	return session, token, true
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "OAuth callback received", "email": email, "microsoft_id": microsoftID})
+// requireSession is currentSession for endpoints that need a signed-in
+// member, answering 401 when there is none.
+func (h *AuthHandler) requireSession(c *gin.Context) (*types.SessionData, string, bool) {
+	session, token, ok := h.currentSession(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, "", false
+	}
+	return session, token, true
 }
 
 // Logout godoc
 // @Summary Logout user
-// @Description Logout user by deleting session and clearing cookie
+// @Description Logout user by deleting session and clearing cookie. With all=1 every session of the member is ended, including device clients.
 // @Tags auth
 // @Accept  json
 // @Produce  json
-// @Success 200 {object} map[string]string "Logged out"
+// @Param all query string false "Set to 1 to log out everywhere"
+// @Success 200 {object} map[string]interface{} "Logged out"
 // @Router /auth/logout [post]
 func (h *AuthHandler) Logout(c *gin.Context) {
 	ctx := c.Request.Context()
 
-	sessionToken, err := c.Cookie("session_token")
-	if err == nil && sessionToken != "" {
-		_ = h.sessionManager.DeleteSession(ctx, sessionToken)
+	sessionToken, err := c.Cookie(sessionCookieName)
+	if err != nil || sessionToken == "" {
+		h.setSessionCookie(c, "", -1)
+		c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
+		return
 	}
 
-	c.SetCookie(
-		"session_token",
-		"",
-		-1,
-		"/",
-		h.cookieDomain,
-		h.cookieSecure,
-		h.cookieSameSite == http.SameSiteNoneMode,
-	)
+	if c.Query("all") == "1" {
+		if session, _, ok := h.currentSession(c); ok {
+			revoked, err := h.sessionManager.DeleteAllSessionsForMember(ctx, session.MemberID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to end sessions"})
+				return
+			}
+			log.Printf("Security: member %s logged out everywhere, %d sessions ended", session.MemberID, revoked)
+			h.setSessionCookie(c, "", -1)
+			c.JSON(http.StatusOK, gin.H{"status": "logged_out", "sessions_revoked": revoked})
+			return
+		}
+	}
+
+	_ = h.sessionManager.DeleteSession(ctx, sessionToken)
+	h.setSessionCookie(c, "", -1)
 
 	c.JSON(http.StatusOK, gin.H{"status": "logged_out"})
 }
 
+type meOrganizationResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Hostname string `json:"hostname"`
+}
+
+type meGroupResponse struct {
+	ID          string  `json:"id"`
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+type meAppResponse struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Domains       []string `json:"domains"`
+	IsPlatformApp bool     `json:"is_platform_app"`
+}
+
+type meSessionResponse struct {
+	CreatedAt    *time.Time `json:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	AuthTime     *time.Time `json:"auth_time"`
+	AuthMethods  []string   `json:"amr"`
+	MFASatisfied bool       `json:"mfa_satisfied"`
+	ClientID     string     `json:"client_id,omitempty"`
+}
+
+type meResponse struct {
+	ID           string                 `json:"id"`
+	Email        string                 `json:"email"`
+	FirstName    *string                `json:"first_name"`
+	LastName     *string                `json:"last_name"`
+	Role         string                 `json:"role"`
+	Organization meOrganizationResponse `json:"organization"`
+	Groups       []meGroupResponse      `json:"groups"`
+	Apps         []meAppResponse        `json:"apps"`
+	Session      meSessionResponse      `json:"session"`
+}
+
 // Me godoc
 // @Summary Get current user
-// @Description Get current authenticated user information
+// @Description Resolve the session cookie to the member's profile, organization, groups, reachable apps and session details
 // @Tags auth
 // @Accept  json
 // @Produce  json
 // @Security SessionToken
-// @Success 200 {object} map[string]string "User information"
+// @Success 200 {object} meResponse
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Router /auth/me [get]
 func (h *AuthHandler) Me(c *gin.Context) {
-	userID, exists := c.Get("user_id")
-	if !exists {
+	ctx := c.Request.Context()
+
+	session, _, ok := h.requireSession(c)
+	if !ok {
+		return
+	}
+
+	member, err := h.memberService.GetByID(ctx, session.MemberID)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"id": userID,
+	org, err := h.orgService.GetByID(ctx, member.OrganizationID)
+	if err != nil {
+		log.Printf("Warning: Failed to load organization %s for member %s: %v", member.OrganizationID, member.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization"})
+		return
+	}
+
+	groups := []meGroupResponse{}
+	if h.groupService != nil {
+		memberGroups, err := h.groupService.ListGroupsForMember(ctx, member.ID)
+		if err != nil {
+			log.Printf("Warning: Failed to load groups for member %s: %v", member.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load groups"})
+			return
+		}
+		for _, group := range memberGroups {
+			groups = append(groups, meGroupResponse{ID: group.ID, Name: group.Name, Description: group.Description})
+		}
+	}
+
+	apps, err := h.appService.ListByOrganization(ctx, member.OrganizationID)
+	if err != nil {
+		log.Printf("Warning: Failed to load apps for organization %s: %v", member.OrganizationID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load apps"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, meResponse{
+		ID:        member.ID,
+		Email:     member.Email,
+		FirstName: member.FirstName,
+		LastName:  member.LastName,
+		Role:      member.Role.String(),
+		Organization: meOrganizationResponse{
+			ID:       org.ID,
+			Name:     org.Name,
+			Hostname: org.Hostname,
+		},
+		Groups: groups,
+		Apps:   toMeApps(apps, org.Hostname),
+		Session: meSessionResponse{
+			CreatedAt:    optionalTime(session.CreatedAt),
+			ExpiresAt:    optionalTime(session.ExpiresAt),
+			AuthTime:     optionalTime(session.AuthTime),
+			AuthMethods:  session.AuthMethods,
+			MFASatisfied: session.MFASatisfied,
+			ClientID:     session.ClientID,
+		},
 	})
 }
+
+// toMeApps lists apps with the URL of their main label under the organization
+// hostname. Without a hostname apps have no reachable URL.
+func toMeApps(apps []*types.App, hostname string) []meAppResponse {
+	result := make([]meAppResponse, 0, len(apps))
+	for _, app := range apps {
+		response := meAppResponse{
+			ID:            app.ID,
+			Name:          app.Name,
+			Domains:       []string{},
+			IsPlatformApp: app.IsPlatformApp,
+		}
+		if hostname != "" {
+			response.URL = "https://" + app.MainLabel + "." + hostname
+			for _, label := range app.SubdomainLabels {
+				response.Domains = append(response.Domains, label+"."+hostname)
+			}
+		}
+		result = append(result, response)
+	}
+	return result
+}
+
+// optionalTime maps times that were never recorded, such as on sessions
+// created before the field existed, to nil.
+func optionalTime(t time.Time) *time.Time {
+	if t.Unix() <= 0 {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,115 @@
+package public
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+const (
+	testBrowserToken = "browser-session-token"
+	testDerivedToken = "derived-access-token"
+	testMemberID     = "member-1"
+)
+
+// fakeSessionManager knows a browser session and an access token derived
+// from it for a client. Methods the tests do not reach are left to the
+// embedded nil interface.
+type fakeSessionManager struct {
+	types.SessionManager
+
+	deleted    []string
+	deletedAll []string
+}
+
+func (m *fakeSessionManager) GetSession(_ context.Context, token string) (*types.SessionData, error) {
+	switch token {
+	case testBrowserToken:
+		return &types.SessionData{MemberID: testMemberID, AuthMethods: []string{"pwd"}}, nil
+	case testDerivedToken:
+		return &types.SessionData{MemberID: testMemberID, AuthMethods: []string{"pwd"}, ClientID: "relying-party", FamilyID: "family-1"}, nil
+	}
+	return nil, core.ErrInvalidSession
+}
+
+func (m *fakeSessionManager) DeleteSession(_ context.Context, token string) error {
+	m.deleted = append(m.deleted, token)
+	return nil
+}
+
+func (m *fakeSessionManager) DeleteAllSessionsForMember(_ context.Context, memberID string) (int, error) {
+	m.deletedAll = append(m.deletedAll, memberID)
+	return 1, nil
+}
+
+// serveWithSession calls handler for a request carrying token in the session
+// cookie.
+func serveWithSession(handler gin.HandlerFunc, method, target, token string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(method, target, nil)
+	c.Request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: token})
+	handler(c)
+	return recorder
+}
+
+func TestCurrentSessionRejectsDerivedAccessTokens(t *testing.T) {
+	h := &AuthHandler{sessionManager: &fakeSessionManager{}}
+
+	tests := []struct {
+		name    string
+		handler gin.HandlerFunc
+		method  string
+		target  string
+	}{
+		{name: "me", handler: h.Me, method: http.MethodGet, target: "/auth/me"},
+		{name: "session list", handler: h.SessionList, method: http.MethodGet, target: "/auth/sessions"},
+		{name: "identity list", handler: h.IdentityList, method: http.MethodGet, target: "/auth/identities"},
+		{name: "passkey list", handler: h.PasskeyList, method: http.MethodGet, target: "/auth/webauthn/credentials"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serveWithSession(tt.handler, tt.method, tt.target, testDerivedToken)
+			if recorder.Code != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", recorder.Code, http.StatusUnauthorized)
+			}
+		})
+	}
+}
+
+func TestCurrentSessionAcceptsBrowserSession(t *testing.T) {
+	h := &AuthHandler{sessionManager: &fakeSessionManager{}}
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/me", nil)
+	c.Request.AddCookie(&http.Cookie{Name: sessionCookieName, Value: testBrowserToken})
+
+	session, token, ok := h.currentSession(c)
+	if !ok || token != testBrowserToken || session.MemberID != testMemberID {
+		t.Fatalf("currentSession() = %+v, %q, %v", session, token, ok)
+	}
+}
+
+func TestLogoutEverywhereIgnoresDerivedAccessTokens(t *testing.T) {
+	sessions := &fakeSessionManager{}
+	h := &AuthHandler{sessionManager: sessions}
+
+	recorder := serveWithSession(h.Logout, http.MethodPost, "/auth/logout?all=1", testDerivedToken)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", recorder.Code, http.StatusOK)
+	}
+	if len(sessions.deletedAll) != 0 {
+		t.Fatalf("ended all sessions of %v", sessions.deletedAll)
+	}
+	if len(sessions.deleted) != 1 || sessions.deleted[0] != testDerivedToken {
+		t.Fatalf("deleted = %v, want only the presented token", sessions.deleted)
+	}
+}
@@ -0,0 +1,326 @@
+package public
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+type deviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type deviceTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type oauthErrorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description,omitempty"`
+}
+
+type devicePageData struct {
+	UserCode      string
+	ClientID      string
+	DecisionToken string
+	Message  string
+	Error    string
+}
+
+var devicePage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="referrer" content="no-referrer">
+<title>Connect a device</title>
+</head>
+<body>
+{{if .Message}}<p>{{.Message}}</p>
+{{else if .ClientID}}<form method="post" action="/auth/device">
+<p><strong>{{.ClientID}}</strong> is asking to sign in as you. Only continue if you started this on your own device and it shows the code <strong>{{.UserCode}}</strong>.</p>
+<input type="hidden" name="user_code" value="{{.UserCode}}">
+<input type="hidden" name="decision_token" value="{{.DecisionToken}}">
+<button type="submit" name="action" value="approve">Allow</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+{{else}}<form method="get" action="/auth/device">
+<label for="user_code">Enter the code shown on your device</label>
+<input id="user_code" name="user_code" value="{{.UserCode}}" autocomplete="off" autocapitalize="characters" autofocus required>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<button type="submit">Continue</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// DeviceCode godoc
+// @Summary Device authorization request
+// @Description Start the OAuth 2.0 device authorization grant (RFC 8628) for a command-line client
+// @Tags device
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param client_id formData string true "Client ID listed in DEVICE_CLIENT_IDS"
+// @Success 200 {object} deviceCodeResponse
+// @Failure 401 {object} oauthErrorResponse "invalid_client"
+// @Router /auth/device/code [post]
+func (h *AuthHandler) DeviceCode(c *gin.Context) {
+	if h.devices == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device login is not enabled"})
+		return
+	}
+
+	grant, err := h.devices.Start(c.Request.Context(), c.PostForm("client_id"))
+	if err != nil {
+		if errors.Is(err, core.ErrUnauthorized) {
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Unknown client_id")
+			return
+		}
+		log.Printf("Warning: Failed to start device authorization: %v", err)
+		writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, deviceCodeResponse{
+		DeviceCode:              grant.DeviceCode,
+		UserCode:                grant.UserCode,
+		VerificationURI:         grant.VerificationURI,
+		VerificationURIComplete: grant.VerificationURIComplete,
+		ExpiresIn:               grant.ExpiresIn,
+		Interval:                grant.Interval,
+	})
+}
+
+// DeviceToken godoc
+// @Summary Device access token request
+// @Description Poll for the outcome of a device authorization, or redeem a refresh token. The access token is a short-lived session token for the approving member, accepted by forward auth as an Authorization Bearer token. The refresh token is rotated on every use; presenting a rotated refresh token again revokes every token descending from the same authorization.
+// @Tags device
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param grant_type formData string true "urn:ietf:params:oauth:grant-type:device_code or refresh_token"
+// @Param device_code formData string false "Device code"
+// @Param refresh_token formData string false "Refresh token"
+// @Param client_id formData string true "Client ID"
+// @Success 200 {object} deviceTokenResponse
+// @Failure 400 {object} oauthErrorResponse "authorization_pending, slow_down, access_denied, expired_token or invalid_grant"
+// @Failure 401 {object} oauthErrorResponse "invalid_client"
+// @Router /auth/device/token [post]
+func (h *AuthHandler) DeviceToken(c *gin.Context) {
+	if h.devices == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device login is not enabled"})
+		return
+	}
+
+	switch c.PostForm("grant_type") {
+	case deviceCodeGrantType:
+	case refreshTokenGrantType:
+		h.deviceRefreshToken(c)
+		return
+	default:
+		writeOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	token, err := h.devices.Poll(c.Request.Context(), c.PostForm("client_id"), c.PostForm("device_code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrAuthorizationPending):
+			writeOAuthError(c, http.StatusBadRequest, "authorization_pending", "")
+		case errors.Is(err, core.ErrSlowDown):
+			writeOAuthError(c, http.StatusBadRequest, "slow_down", "")
+		case errors.Is(err, core.ErrAccessDenied):
+			writeOAuthError(c, http.StatusBadRequest, "access_denied", "The request was denied")
+		case errors.Is(err, core.ErrExpiredToken):
+			writeOAuthError(c, http.StatusBadRequest, "expired_token", "The device code has expired")
+		case errors.Is(err, core.ErrInvalidToken):
+			writeOAuthError(c, http.StatusBadRequest, "invalid_grant", "Unknown device code")
+		default:
+			log.Printf("Warning: Failed to poll device authorization: %v", err)
+			writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	writeDeviceToken(c, token)
+}
+
+func (h *AuthHandler) deviceRefreshToken(c *gin.Context) {
+	clientID := c.PostForm("client_id")
+	token, err := h.devices.Refresh(c.Request.Context(), clientID, c.PostForm("refresh_token"))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrUnauthorized):
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Unknown client_id")
+		case errors.Is(err, core.ErrInvalidToken):
+			writeOAuthError(c, http.StatusBadRequest, "invalid_grant", "The refresh token is invalid or has expired")
+		default:
+			log.Printf("Warning: Failed to refresh device tokens for client %s: %v", clientID, err)
+			writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	writeDeviceToken(c, token)
+}
+
+func writeDeviceToken(c *gin.Context, token *types.DeviceAccessToken) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, deviceTokenResponse{
+		AccessToken:  token.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    token.ExpiresIn,
+		RefreshToken: token.RefreshToken,
+	})
+}
+
+// DevicePage godoc
+// @Summary Device verification page
+// @Description Page where a signed-in member enters the user code shown by a device and approves or denies it. Members without a session are sent to login first.
+// @Tags device
+// @Produce  html
+// @Param user_code query string false "User code shown on the device"
+// @Success 200 {string} string "Verification page"
+// @Success 302 {string} string "Redirect to login"
+// @Router /auth/device [get]
+func (h *AuthHandler) DevicePage(c *gin.Context) {
+	if h.devices == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device login is not enabled"})
+		return
+	}
+
+	userCode := c.Query("user_code")
+	if !h.hasDeviceSession(c, userCode) {
+		return
+	}
+
+	if userCode == "" {
+		h.renderDevicePage(c, http.StatusOK, devicePageData{})
+		return
+	}
+
+	request, err := h.devices.Lookup(c.Request.Context(), userCode)
+	if err != nil {
+		if !errors.Is(err, core.ErrInvalidToken) {
+			log.Printf("Warning: Failed to look up device authorization: %v", err)
+		}
+		h.renderDevicePage(c, http.StatusOK, devicePageData{UserCode: userCode, Error: "That code is not valid or has expired."})
+		return
+	}
+	token, _ := c.Cookie(sessionCookieName)
+	h.renderDevicePage(c, http.StatusOK, devicePageData{
+		UserCode:      userCode,
+		ClientID:      request.ClientID,
+		DecisionToken: deviceDecisionToken(token, userCode),
+	})
+}
+
+// DeviceDecide godoc
+// @Summary Approve or deny a device
+// @Description Approve or deny the device authorization behind a user code with the current session
+// @Tags device
+// @Accept  x-www-form-urlencoded
+// @Produce  html
+// @Param user_code formData string true "User code"
+// @Param action formData string true "approve or deny"
+// @Param decision_token formData string true "Token from the verification page"
+// @Success 200 {string} string "Result page"
+// @Failure 403 {object} map[string]string "Missing or invalid decision token"
+// @Router /auth/device [post]
+func (h *AuthHandler) DeviceDecide(c *gin.Context) {
+	ctx := c.Request.Context()
+	if h.devices == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device login is not enabled"})
+		return
+	}
+
+	userCode := c.PostForm("user_code")
+	if !h.hasDeviceSession(c, userCode) {
+		return
+	}
+	token, _ := c.Cookie(sessionCookieName)
+	expected := deviceDecisionToken(token, userCode)
+	if subtle.ConstantTimeCompare([]byte(c.PostForm("decision_token")), []byte(expected)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid decision token"})
+		return
+	}
+
+	var err error
+	message := "Done. You can return to your device."
+	switch c.PostForm("action") {
+	case "approve":
+		err = h.devices.Approve(ctx, userCode, token)
+	case "deny":
+		err = h.devices.Deny(ctx, userCode)
+		message = "The request was denied."
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action must be approve or deny"})
+		return
+	}
+	if err != nil {
+		if !errors.Is(err, core.ErrInvalidToken) {
+			log.Printf("Warning: Failed to decide device authorization: %v", err)
+		}
+		h.renderDevicePage(c, http.StatusOK, devicePageData{UserCode: userCode, Error: "That code is not valid or has expired."})
+		return
+	}
+	h.renderDevicePage(c, http.StatusOK, devicePageData{Message: message})
+}
+
+// deviceDecisionToken ties the verification page's form to the session that
+// loaded it and the user code it shows, so other sites cannot post a decision
+// with the member's cookie.
+func deviceDecisionToken(sessionToken, userCode string) string {
+	mac := hmac.New(sha256.New, []byte(sessionToken))
+	mac.Write([]byte("device_decision:" + userCode))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hasDeviceSession sends members without a valid session to login, returning
+// to the verification page with the user code afterwards.
+func (h *AuthHandler) hasDeviceSession(c *gin.Context, userCode string) bool {
+	if _, _, ok := h.currentSession(c); ok {
+		return true
+	}
+
+	returnTo := h.devices.VerificationURI()
+	if userCode != "" {
+		returnTo += "?user_code=" + url.QueryEscape(userCode)
+	}
+	h.redirectToLoginPage(c, returnTo)
+	return false
+}
+
+func (h *AuthHandler) renderDevicePage(c *gin.Context, status int, data devicePageData) {
+	c.Header("Cache-Control", "no-store")
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := devicePage.Execute(c.Writer, data); err != nil {
+		log.Printf("Warning: Failed to render device page: %v", err)
+	}
+}
+
+func writeOAuthError(c *gin.Context, status int, code, description string) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, oauthErrorResponse{Error: code, ErrorDescription: description})
+}
@@ -0,0 +1,144 @@
+package public
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type emailStartRequest struct {
+	Email    string `json:"email" binding:"required"`
+	ReturnTo string `json:"return_to"`
+}
+
+// emailVerifyPage asks the user to confirm the sign-in with a POST, so that
+// mail scanners following the link do not burn the single-use token.
+var emailVerifyPage = template.Must(template.New("email_verify").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="referrer" content="no-referrer">
+<title>Sign in to Vondr</title>
+</head>
+<body>
+<form method="post" action="/auth/email/verify">
+<input type="hidden" name="token" value="{{.}}">
+<button type="submit">Continue signing in</button>
+</form>
+</body>
+</html>
+`))
+
+// EmailStart godoc
+// @Summary Request email login link
+// @Description Email a single-use sign-in link to a member. The link only works in the browser that requested it. The response is the same whether or not the address belongs to a member.
+// @Tags auth
+// @Accept  json
+// @Produce  json
+// @Param request body emailStartRequest true "Email address and optional return_to"
+// @Success 202 {object} map[string]string "Link sent if the address belongs to a member"
+// @Failure 400 {object} map[string]string "Invalid email"
+// @Failure 429 {object} map[string]string "Too many requests"
+// @Router /auth/email/start [post]
+func (h *AuthHandler) EmailStart(c *gin.Context) {
+	if h.magicLinks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email login is not enabled"})
+		return
+	}
+
+	var req emailStartRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	binding, err := h.bindLogin(c, http.SameSiteLaxMode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send login link"})
+		return
+	}
+
+	if err := h.magicLinks.Start(c.Request.Context(), req.Email, req.ReturnTo, binding); err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidEmail):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email"})
+		case errors.Is(err, core.ErrTooManyRequests):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many login links requested, try again later"})
+		default:
+			log.Printf("Warning: Failed to send login link: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send login link"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "sent"})
+}
+
+// EmailVerifyPage godoc
+// @Summary Email login link landing page
+// @Description Target of the emailed link. Renders a confirmation page that submits the token to POST /auth/email/verify.
+// @Tags auth
+// @Produce  html
+// @Param token query string true "Login link token"
+// @Success 200 {string} string "Confirmation page"
+// @Router /auth/email/verify [get]
+func (h *AuthHandler) EmailVerifyPage(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/401/invalid_link")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := emailVerifyPage.Execute(c.Writer, token); err != nil {
+		log.Printf("Warning: Failed to render email verify page: %v", err)
+	}
+}
+
+// EmailVerify godoc
+// @Summary Redeem email login link
+// @Description Redeem a single-use login link token, sign in the member and redirect
+// @Tags auth
+// @Accept  x-www-form-urlencoded
+// @Param token formData string true "Login link token"
+// @Success 302 {string} string "Redirect to return_to or the post-login URL"
+// @Router /auth/email/verify [post]
+func (h *AuthHandler) EmailVerify(c *gin.Context) {
+	if h.magicLinks == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email login is not enabled"})
+		return
+	}
+
+	link, err := h.magicLinks.Verify(c.Request.Context(), c.PostForm("token"))
+	if err != nil {
+		if !errors.Is(err, core.ErrInvalidToken) {
+			log.Printf("Warning: Failed to redeem login link: %v", err)
+		}
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/401/invalid_link")
+		return
+	}
+
+	if !h.checkLoginBinding(c, link.Binding, http.SameSiteLaxMode) {
+		log.Printf("Security: login link for %s redeemed by a browser that did not request it", link.Email)
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/401/invalid_link")
+		return
+	}
+
+	h.completeLogin(c, &types.LoginTransaction{
+		Provider: providerEmail,
+		ReturnTo: link.ReturnTo,
+		Host:     c.Request.Host,
+	}, &externalIdentity{
+		Provider: providerEmail,
+		Method:   core.AuthMethodEmail,
+		Email:    link.Email,
+	})
+}
@@ -0,0 +1,67 @@
+package public
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// Discover godoc
+// @Summary Home realm discovery
+// @Description Resolve the organization from the verified domain of an email address and redirect to that organization's login provider with login_hint and domain_hint pre-filled
+// @Tags auth
+// @Param email query string false "Email address of the user (or use login_hint)"
+// @Param login_hint query string false "Email address of the user"
+// @Param return_to query string false "URL to redirect to after successful login"
+// @Success 302 {string} string "Redirect to the organization's login provider"
+// @Failure 400 {object} map[string]string "Missing email"
+// @Router /auth/discover [get]
+func (h *AuthHandler) Discover(c *gin.Context) {
+	email := strings.TrimSpace(c.Query("email"))
+	if email == "" {
+		email = strings.TrimSpace(c.Query("login_hint"))
+	}
+	if email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing email"})
+		return
+	}
+
+	if h.homeRealms == nil {
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/404/unknown_domain")
+		return
+	}
+
+	realm, err := h.homeRealms.Discover(c.Request.Context(), email)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/404/unknown_domain")
+			return
+		}
+		log.Printf("Warning: Home realm discovery failed for %s: %v", email, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to discover organization"})
+		return
+	}
+
+	query := url.Values{}
+	if returnTo := c.Query("return_to"); returnTo != "" {
+		query.Set("return_to", returnTo)
+	}
+
+	if realm.SAML && h.samlProviders != nil {
+		c.Redirect(http.StatusFound, "/auth/saml/"+url.PathEscape(realm.ProviderSlug)+"/login?"+query.Encode())
+		return
+	}
+
+	provider := realm.ProviderSlug
+	if provider == "" || realm.SAML {
+		provider = providerMicrosoft
+	}
+	query.Set("login_hint", email)
+	query.Set("domain_hint", realm.Domain)
+	c.Redirect(http.StatusFound, "/auth/"+url.PathEscape(provider)+"/login?"+query.Encode())
+}
@@ -0,0 +1,229 @@
+package public
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// reauthMaxAge is how recently a member must have authenticated to link or
+// unlink an account.
+const reauthMaxAge = 10 * time.Minute
+
+type identityResponse struct {
+	ID         string     `json:"id"`
+	Provider   string     `json:"provider"`
+	Issuer     string     `json:"issuer"`
+	Subject    string     `json:"subject"`
+	LinkedAt   time.Time  `json:"linked_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// IdentityList godoc
+// @Summary List linked accounts
+// @Description External accounts the member can sign in with. SAML accounts are listed with provider saml:{slug}.
+// @Tags auth
+// @Produce  json
+// @Success 200 {array} identityResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /auth/identities [get]
+func (h *AuthHandler) IdentityList(c *gin.Context) {
+	session, _, ok := h.requireSession(c)
+	if !ok {
+		return
+	}
+
+	identities, err := h.memberService.ListIdentities(c.Request.Context(), session.MemberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load linked accounts"})
+		return
+	}
+
+	response := make([]identityResponse, len(identities))
+	for i, identity := range identities {
+		response[i] = identityResponse{
+			ID:         identity.ID,
+			Provider:   identity.Provider,
+			Issuer:     identity.Issuer,
+			Subject:    identity.Subject,
+			LinkedAt:   identity.LinkedAt,
+			LastUsedAt: identity.LastUsedAt,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// IdentityLink godoc
+// @Summary Link an account
+// @Description Sign in with another identity provider and link that account to the current member instead of starting a new session. Requires a login within the last 10 minutes; otherwise the browser is sent through step-up first.
+// @Tags auth
+// @Param provider path string true "Identity provider (e.g. microsoft, relatics or an organization provider)"
+// @Param return_to query string false "URL to redirect to after linking"
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 404 {object} map[string]string "Unknown identity provider"
+// @Router /auth/identities/link/{provider} [get]
+func (h *AuthHandler) IdentityLink(c *gin.Context) {
+	session, ok := h.requireRecentSession(c)
+	if !ok {
+		return
+	}
+
+	provider, orgID, ok := h.lookupProvider(c)
+	if !ok {
+		return
+	}
+	if orgID != "" && orgID != session.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown identity provider"})
+		return
+	}
+
+	h.beginLogin(c, provider, session.MemberID)
+}
+
+// IdentityLinkSAML godoc
+// @Summary Link a SAML account
+// @Description Sign in with the organization's SAML connection and link that account to the current member. Requires a login within the last 10 minutes; otherwise the browser is sent through step-up first.
+// @Tags auth
+// @Param slug path string true "SAML connection slug"
+// @Param return_to query string false "URL to redirect to after linking"
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 404 {object} map[string]string "Unknown SAML connection"
+// @Router /auth/identities/link/saml/{slug} [get]
+func (h *AuthHandler) IdentityLinkSAML(c *gin.Context) {
+	session, ok := h.requireRecentSession(c)
+	if !ok {
+		return
+	}
+
+	provider, orgID, ok := h.lookupSAMLProvider(c)
+	if !ok {
+		return
+	}
+	if orgID != session.OrganizationID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown SAML connection"})
+		return
+	}
+
+	h.beginSAMLLogin(c, provider, session.MemberID)
+}
+
+// IdentityUnlink godoc
+// @Summary Unlink an account
+// @Description Remove a linked account. The last linked account cannot be removed. Requires a login within the last 10 minutes; otherwise answers 401 with a step_up_url.
+// @Tags auth
+// @Produce  json
+// @Param identity_id path string true "Linked account ID"
+// @Success 204 "Unlinked"
+// @Failure 400 {object} map[string]string "Only linked account"
+// @Failure 401 {object} map[string]string "Reauthentication required"
+// @Failure 404 {object} map[string]string "Linked account not found"
+// @Router /auth/identities/{identity_id} [delete]
+func (h *AuthHandler) IdentityUnlink(c *gin.Context) {
+	session, ok := h.requireRecentSession(c)
+	if !ok {
+		return
+	}
+
+	err := h.memberService.UnlinkIdentity(c.Request.Context(), session.MemberID, c.Param("identity_id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Linked account not found"})
+		case errors.Is(err, core.ErrBadRequest):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The only linked account cannot be removed"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink account"})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// completeLink links the account from a login started by IdentityLink to the
+// member who started it, provided the browser still holds their session.
+func (h *AuthHandler) completeLink(c *gin.Context, transaction *types.LoginTransaction, identity *externalIdentity) {
+	ctx := c.Request.Context()
+
+	session, _, ok := h.currentSession(c)
+	if !ok || session.MemberID != transaction.LinkMemberID {
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/401/session_expired")
+		return
+	}
+	if identity.OrganizationID != "" && identity.OrganizationID != session.OrganizationID {
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/403/provider_not_allowed")
+		return
+	}
+
+	if _, err := h.memberService.LinkAccount(ctx, session.MemberID, identity.account()); err != nil {
+		if errors.Is(err, core.ErrConflict) {
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/409/account_already_linked")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link account"})
+		return
+	}
+	log.Printf("Security: member %s linked %s account %s", session.MemberID, identity.Provider, identity.Subject)
+
+	member, err := h.memberService.GetByID(ctx, session.MemberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load member"})
+		return
+	}
+	c.Redirect(http.StatusFound, h.resolveReturnTo(ctx, transaction.ReturnTo, member))
+}
+
+// requireRecentSession is requireSession for changes to linked
+// accounts, which need a login within reauthMaxAge. Browser navigations are
+// sent through step-up and back; other requests get a 401 with the step-up
+// URL.
+func (h *AuthHandler) requireRecentSession(c *gin.Context) (*types.SessionData, bool) {
+	session, _, ok := h.currentSession(c)
+	if !ok {
+		if c.Request.Method == http.MethodGet {
+			h.redirectToLoginPage(c, h.currentURL(c))
+			return nil, false
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, false
+	}
+
+	if session.AuthTime.Unix() > 0 && time.Since(session.AuthTime) <= reauthMaxAge {
+		return session, true
+	}
+
+	returnTo := h.currentURL(c)
+	if c.Request.Method != http.MethodGet {
+		returnTo = c.GetHeader("Referer")
+	}
+	stepUpURL := "/auth/step-up"
+	if returnTo != "" {
+		stepUpURL += "?return_to=" + url.QueryEscape(returnTo)
+	}
+
+	if c.Request.Method == http.MethodGet {
+		c.Redirect(http.StatusFound, stepUpURL)
+		return nil, false
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"error":       "Recent authentication required",
+		"code":        "reauthentication_required",
+		"step_up_url": stepUpURL,
+	})
+	return nil, false
+}
+
+// currentURL is the absolute URL of the request, usable as a return_to on
+// this host.
+func (h *AuthHandler) currentURL(c *gin.Context) string {
+	scheme := "http"
+	if h.cookieSecure {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + c.Request.URL.RequestURI()
+}
@@ -0,0 +1,35 @@
+package public
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+type JWKSHandler struct {
+	keys types.SigningKeyService
+}
+
+func NewJWKSHandler(keys types.SigningKeyService) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+// JWKS godoc
+// @Summary Token signing keys
+// @Description Public keys that verify tokens signed by identity-go: the active key, the next key before it takes over and retiring keys until tokens they signed have expired. Select a key by the kid of the token header.
+// @Tags keys
+// @Produce  json
+// @Success 200 {object} map[string]interface{}
+// @Router /.well-known/jwks.json [get]
+func (h *JWKSHandler) JWKS(c *gin.Context) {
+	keySet, err := h.keys.KeySet(c.Request.Context())
+	if err != nil {
+		log.Printf("Warning: Failed to load signing keys: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signing keys"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, keySet)
+}
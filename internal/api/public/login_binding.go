@@ -0,0 +1,72 @@
+package public
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	loginBindingCookieName = "login_binding"
+	loginBindingMaxAge     = 15 * 60
+)
+
+// bindLogin ties a login to the browser that starts it. The browser keeps a
+// random nonce in an HttpOnly cookie and the login transaction stores its
+// hash, so a code, RelayState or login link started by someone else cannot
+// be completed in this browser. The SAML ACS is a cross-site POST and needs
+// SameSite=None to receive the cookie.
+func (h *AuthHandler) bindLogin(c *gin.Context, sameSite http.SameSite) (string, error) {
+	nonce, err := c.Cookie(loginBindingCookieName)
+	if err != nil || nonce == "" {
+		nonce, err = generateState()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	h.setLoginBindingCookie(c, nonce, loginBindingMaxAge, sameSite)
+	return hashLoginBinding(nonce), nil
+}
+
+// checkLoginBinding reports whether the browser holds the nonce a login was
+// bound to, and clears the cookie.
+func (h *AuthHandler) checkLoginBinding(c *gin.Context, binding string, sameSite http.SameSite) bool {
+	nonce, err := c.Cookie(loginBindingCookieName)
+	h.setLoginBindingCookie(c, "", -1, sameSite)
+	if err != nil || nonce == "" || binding == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashLoginBinding(nonce)), []byte(binding)) == 1
+}
+
+func (h *AuthHandler) setLoginBindingCookie(c *gin.Context, value string, maxAge int, sameSite http.SameSite) {
+	c.SetSameSite(sameSite)
+	c.SetCookie(
+		loginBindingCookieName,
+		value,
+		maxAge,
+		"/auth",
+		"",
+		h.cookieSecure,
+		true,
+	)
+}
+
+// samlBindingSameSite is the SameSite mode for logins completed by a SAML
+// HTTP-POST. Browsers only accept SameSite=None on secure cookies, so plain
+// HTTP development setups fall back to Lax.
+func (h *AuthHandler) samlBindingSameSite() http.SameSite {
+	if h.cookieSecure {
+		return http.SameSiteNoneMode
+	}
+	return http.SameSiteLaxMode
+}
+
+func hashLoginBinding(nonce string) string {
+	sum := sha256.Sum256([]byte(nonce))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,337 @@
+package public
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/oauth"
+	"golang.org/x/oauth2"
+)
+
+const (
+	providerMicrosoft = "microsoft"
+	providerRelatics  = "relatics"
+	providerEmail     = "email"
+)
+
+// externalIdentity is the provider-neutral result of a successful upstream
+// login that feeds member resolution and session creation. OrganizationID is
+// set when the provider is configured by an organization rather than built in.
+// Method is recorded in the session for app assurance policies. Issuer and
+// Subject identify the linked account; email logins have neither.
+type externalIdentity struct {
+	Provider       string
+	Method         core.AuthMethod
+	OrganizationID string
+	Issuer         string
+	Subject        string
+	Email          string
+	FirstName      string
+	LastName       string
+}
+
+func generateState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// beginLogin redirects to the provider. A non-empty linkMemberID links the
+// account to that member on callback instead of signing in.
+func (h *AuthHandler) beginLogin(c *gin.Context, provider oauth.IdentityProvider, linkMemberID string) {
+	ctx := c.Request.Context()
+	returnTo := c.Query("return_to")
+
+	state, err := generateState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate state"})
+		return
+	}
+
+	nonce, err := generateState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate nonce"})
+		return
+	}
+
+	binding, err := h.bindLogin(c, http.SameSiteLaxMode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate state"})
+		return
+	}
+
+	transaction := &types.LoginTransaction{
+		Provider:     provider.Name(),
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		ReturnTo:     returnTo,
+		Host:         c.Request.Host,
+		LinkMemberID: linkMemberID,
+		Binding:      binding,
+	}
+	if err := h.loginTxStore.Save(ctx, state, transaction); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store login state"})
+		return
+	}
+
+	var opts []oauth2.AuthCodeOption
+	if loginHint := c.Query("login_hint"); loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+	if domainHint := c.Query("domain_hint"); domainHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("domain_hint", domainHint))
+	}
+
+	authURL, err := provider.AuthURL(ctx, state, transaction.Nonce, transaction.CodeVerifier, opts...)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// consumeLogin redeems the login transaction for state. It must have been
+// started by this browser for provider on this host.
+func (h *AuthHandler) consumeLogin(c *gin.Context, state, provider string, sameSite http.SameSite) (*types.LoginTransaction, bool) {
+	transaction, err := h.loginTxStore.Consume(c.Request.Context(), state)
+	if err != nil {
+		h.setLoginBindingCookie(c, "", -1, sameSite)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired OAuth state"})
+		return nil, false
+	}
+
+	if !h.checkLoginBinding(c, transaction.Binding, sameSite) {
+		log.Printf("Security: %s login state presented by a browser that did not start it", provider)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OAuth state was issued to a different browser"})
+		return nil, false
+	}
+
+	if transaction.Provider != provider {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OAuth state was issued for a different provider"})
+		return nil, false
+	}
+
+	if transaction.Host != c.Request.Host {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "OAuth state was issued for a different host"})
+		return nil, false
+	}
+
+	return transaction, true
+}
+
+func (h *AuthHandler) completeLogin(c *gin.Context, transaction *types.LoginTransaction, identity *externalIdentity) {
+	ctx := c.Request.Context()
+
+	identity.Email = strings.ToLower(identity.Email)
+	if identity.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email not provided by identity provider"})
+		return
+	}
+
+	if transaction.LinkMemberID != "" {
+		h.completeLink(c, transaction, identity)
+		return
+	}
+
+	member, err := h.resolveMember(ctx, identity)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/403/no_account")
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve member"})
+		return
+	}
+
+	authMethods := []string{identity.Method.String()}
+
+	if h.mfa != nil {
+		status, err := h.mfa.Status(ctx, member.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load MFA status"})
+			return
+		}
+
+		if status.Enrolled {
+			challenge, err := h.mfa.BeginChallenge(ctx, member.ID, transaction.ReturnTo, c.Request.Host, authMethods)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start MFA challenge"})
+				return
+			}
+			c.Redirect(http.StatusFound, "/auth/mfa?challenge="+url.QueryEscape(challenge))
+			return
+		}
+
+		if status.Required {
+			// The session is only good for enrolling an authenticator until
+			// mfa_satisfied is set; forward auth rejects it before then.
+			if !h.issueSession(c, member, authMethods, false) {
+				return
+			}
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/403/mfa_enrollment_required")
+			return
+		}
+	}
+
+	if !h.issueSession(c, member, authMethods, false) {
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.resolveReturnTo(ctx, transaction.ReturnTo, member))
+}
+
+// issueSession creates a session for member authenticated with authMethods,
+// records the login and sets the session cookie, replacing any session the
+// browser already had. It writes an error response and returns false on
+// failure.
+func (h *AuthHandler) issueSession(c *gin.Context, member *types.Member, authMethods []string, mfaSatisfied bool) bool {
+	ctx := c.Request.Context()
+
+	microsoftID := h.microsoftSubject(ctx, member.ID)
+
+	client := types.SessionClient{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()}
+	sessionToken, expiresAt, err := h.sessionManager.CreateSession(ctx, member.ID, member.Email, member.OrganizationID, microsoftID, authMethods, mfaSatisfied, client)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+		return false
+	}
+
+	if previous, err := c.Cookie(sessionCookieName); err == nil && previous != "" {
+		_ = h.sessionManager.DeleteSession(ctx, previous)
+	}
+
+	if err := h.sessionService.RecordLogin(ctx, member.ID, member.Email, member.OrganizationID, microsoftID); err != nil {
+		log.Printf("Warning: Failed to record login for member %s: %v", member.ID, err)
+	}
+
+	h.setSessionCookie(c, sessionToken, int(time.Until(expiresAt).Seconds()))
+	return true
+}
+
+// resolveMember finds the member for an external identity, linking the account
+// by email on first login and provisioning system members for SYSTEM_EMAILS.
+func (h *AuthHandler) resolveMember(ctx context.Context, identity *externalIdentity) (*types.Member, error) {
+	if identity.OrganizationID != "" {
+		return h.resolveOrganizationMember(ctx, identity)
+	}
+
+	member, err := h.findMemberByIdentity(ctx, identity)
+	if err == nil {
+		return member, nil
+	}
+	if !errors.Is(err, core.ErrNotFound) {
+		return nil, err
+	}
+
+	member, err = h.linkMemberIdentity(ctx, identity)
+	if err == nil {
+		return member, nil
+	}
+	if !errors.Is(err, core.ErrNotFound) {
+		return nil, err
+	}
+
+	if !h.systemEmails[identity.Email] {
+		return nil, core.ErrNotFound
+	}
+
+	var account *types.ExternalAccount
+	if identity.Subject != "" {
+		linked := identity.account()
+		account = &linked
+	}
+
+	return h.memberService.CreateSystemMember(
+		ctx,
+		h.defaultOrgID,
+		h.defaultOrgName,
+		identity.Email,
+		account,
+		identity.FirstName,
+		identity.LastName,
+	)
+}
+
+func (h *AuthHandler) findMemberByIdentity(ctx context.Context, identity *externalIdentity) (*types.Member, error) {
+	if identity.Provider == providerEmail {
+		return h.memberService.GetByEmail(ctx, identity.Email)
+	}
+	return h.memberService.GetByExternalAccount(ctx, identity.account())
+}
+
+// linkMemberIdentity links an account of a built-in provider to the member
+// with the same email on its first login.
+func (h *AuthHandler) linkMemberIdentity(ctx context.Context, identity *externalIdentity) (*types.Member, error) {
+	switch identity.Provider {
+	case providerMicrosoft, providerRelatics:
+		return h.memberService.LinkAccountByEmail(ctx, identity.Email, identity.account(), identity.FirstName, identity.LastName)
+	}
+	return nil, core.ErrNotFound
+}
+
+// microsoftSubject returns the member's linked Microsoft account, which
+// sessions and login records still carry as microsoft_id.
+func (h *AuthHandler) microsoftSubject(ctx context.Context, memberID string) string {
+	identities, err := h.memberService.ListIdentities(ctx, memberID)
+	if err != nil {
+		log.Printf("Warning: Failed to load linked accounts of member %s: %v", memberID, err)
+		return ""
+	}
+	for _, identity := range identities {
+		if identity.Provider == providerMicrosoft {
+			return identity.Subject
+		}
+	}
+	return ""
+}
+
+func (identity *externalIdentity) account() types.ExternalAccount {
+	return types.ExternalAccount{
+		Provider: identity.Provider,
+		Issuer:   identity.Issuer,
+		Subject:  identity.Subject,
+	}
+}
+
+// resolveOrganizationMember matches an identity from an organization-configured
+// provider by its linked account, or by email on first login, after which the
+// account is linked. Such providers may only sign in members of the owning
+// organization and never provision new members.
+func (h *AuthHandler) resolveOrganizationMember(ctx context.Context, identity *externalIdentity) (*types.Member, error) {
+	member, err := h.memberService.GetByExternalAccount(ctx, identity.account())
+	if err != nil && !errors.Is(err, core.ErrNotFound) {
+		return nil, err
+	}
+	linked := err == nil
+
+	if !linked {
+		member, err = h.memberService.GetByEmail(ctx, identity.Email)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if member.OrganizationID != identity.OrganizationID {
+		log.Printf("Security: identity provider %s asserted %s, who belongs to another organization", identity.Provider, identity.Email)
+		return nil, core.ErrNotFound
+	}
+
+	if !linked {
+		if _, err := h.memberService.LinkAccount(ctx, member.ID, identity.account()); err != nil {
+			log.Printf("Warning: Failed to link %s account of member %s: %v", identity.Provider, member.ID, err)
+		}
+	}
+	return member, nil
+}
@@ -0,0 +1,424 @@
+package public
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type mfaCodeRequest struct {
+	Code string `json:"code" form:"code" binding:"required"`
+}
+
+type mfaStatusResponse struct {
+	Enrolled               bool `json:"enrolled"`
+	Required               bool `json:"required"`
+	MFASatisfied           bool `json:"mfa_satisfied"`
+	TOTP                   bool `json:"totp"`
+	Passkeys               int  `json:"passkeys"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+type mfaEnrollResponse struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+type mfaRecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type mfaChallengePageData struct {
+	Challenge string
+	Error     string
+}
+
+var mfaChallengePage = template.Must(template.New("mfa_challenge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="referrer" content="no-referrer">
+<title>Two-factor authentication</title>
+</head>
+<body>
+<form method="post" action="/auth/mfa/verify">
+<input type="hidden" name="challenge" value="{{.Challenge}}">
+<label for="code">Enter the code from your authenticator app or a recovery code</label>
+<input id="code" name="code" autocomplete="one-time-code" autofocus required>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<button type="submit">Verify</button>
+</form>
+<button type="button" id="passkey">Use a passkey</button>
+<p role="alert" id="passkey-error"></p>
+<script>
+(function () {
+  var button = document.getElementById("passkey");
+  if (!window.PublicKeyCredential) {
+    button.hidden = true;
+    return;
+  }
+  var challenge = {{.Challenge}};
+  function decode(value) {
+    value = value.replace(/-/g, "+").replace(/_/g, "/");
+    while (value.length % 4) value += "=";
+    return Uint8Array.from(atob(value), function (c) { return c.charCodeAt(0); });
+  }
+  function encode(buffer) {
+    return btoa(String.fromCharCode.apply(null, new Uint8Array(buffer))).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+  }
+  function post(url, body) {
+    return fetch(url, {
+      method: "POST",
+      headers: {"Content-Type": "application/json"},
+      credentials: "same-origin",
+      body: JSON.stringify(body)
+    }).then(function (response) {
+      return response.json().then(function (data) {
+        if (!response.ok) throw new Error(data.error);
+        return data;
+      });
+    });
+  }
+  button.addEventListener("click", function () {
+    post("/auth/webauthn/mfa/begin", {challenge: challenge}).then(function (begin) {
+      var options = begin.options.publicKey;
+      options.challenge = decode(options.challenge);
+      (options.allowCredentials || []).forEach(function (credential) { credential.id = decode(credential.id); });
+      return navigator.credentials.get({publicKey: options}).then(function (credential) {
+        var response = {
+          authenticatorData: encode(credential.response.authenticatorData),
+          clientDataJSON: encode(credential.response.clientDataJSON),
+          signature: encode(credential.response.signature)
+        };
+        if (credential.response.userHandle) response.userHandle = encode(credential.response.userHandle);
+        return post("/auth/webauthn/mfa/finish", {
+          challenge: challenge,
+          ceremony: begin.ceremony,
+          credential: {id: credential.id, rawId: encode(credential.rawId), type: credential.type, response: response}
+        });
+      });
+    }).then(function (done) {
+      window.location.assign(done.redirect_to);
+    }).catch(function (err) {
+      document.getElementById("passkey-error").textContent = err.message;
+    });
+  });
+})();
+</script>
+</body>
+</html>
+`))
+
+// MFAChallengePage godoc
+// @Summary MFA challenge page
+// @Description Page asking a member with an enrolled authenticator for their TOTP or recovery code after the upstream login
+// @Tags mfa
+// @Produce  html
+// @Param challenge query string true "MFA challenge token"
+// @Success 200 {string} string "Challenge page"
+// @Router /auth/mfa [get]
+func (h *AuthHandler) MFAChallengePage(c *gin.Context) {
+	challenge := c.Query("challenge")
+	if challenge == "" {
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/401/invalid_mfa_challenge")
+		return
+	}
+	h.renderMFAChallenge(c, http.StatusOK, challenge, "")
+}
+
+// MFAVerify godoc
+// @Summary Complete MFA challenge
+// @Description Check the TOTP or recovery code for a pending login, then issue a session with mfa_satisfied set and redirect
+// @Tags mfa
+// @Accept  x-www-form-urlencoded
+// @Param challenge formData string true "MFA challenge token"
+// @Param code formData string true "TOTP or recovery code"
+// @Success 302 {string} string "Redirect to return_to or the post-login URL"
+// @Failure 401 {string} string "Challenge page with an error"
+// @Router /auth/mfa/verify [post]
+func (h *AuthHandler) MFAVerify(c *gin.Context) {
+	ctx := c.Request.Context()
+	if h.mfa == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "MFA is not enabled"})
+		return
+	}
+
+	token := c.PostForm("challenge")
+	challenge, err := h.mfa.CompleteChallenge(ctx, token, c.PostForm("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidMFACode):
+			h.renderMFAChallenge(c, http.StatusUnauthorized, token, "That code is not valid. Try again.")
+		case errors.Is(err, core.ErrInvalidToken):
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/401/invalid_mfa_challenge")
+		case errors.Is(err, core.ErrTooManyRequests):
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/429/mfa_locked")
+		default:
+			log.Printf("Warning: Failed to complete MFA challenge: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		}
+		return
+	}
+
+	if challenge.Host != c.Request.Host {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA challenge was issued for a different host"})
+		return
+	}
+
+	member, err := h.memberService.GetByID(ctx, challenge.MemberID)
+	if err != nil {
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/403/no_account")
+		return
+	}
+
+	if !h.issueSession(c, member, core.AppendAuthMethod(challenge.AuthMethods, core.AuthMethodOTP), true) {
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.resolveReturnTo(ctx, challenge.ReturnTo, member))
+}
+
+// MFAStatus godoc
+// @Summary MFA status
+// @Description Whether the current member has an authenticator, whether their organization requires one and whether this session completed MFA
+// @Tags mfa
+// @Produce  json
+// @Security SessionToken
+// @Success 200 {object} mfaStatusResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /auth/mfa/status [get]
+func (h *AuthHandler) MFAStatus(c *gin.Context) {
+	if !h.requireMFAEnabled(c) {
+		return
+	}
+	session, _, ok := h.requireSession(c)
+	if !ok {
+		return
+	}
+
+	status, err := h.mfa.Status(c.Request.Context(), session.MemberID)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, mfaStatusResponse{
+		Enrolled:               status.Enrolled,
+		Required:               status.Required,
+		MFASatisfied:           session.MFASatisfied,
+		TOTP:                   status.TOTP,
+		Passkeys:               status.Passkeys,
+		RecoveryCodesRemaining: status.RecoveryCodesRemaining,
+	})
+}
+
+// MFAEnroll godoc
+// @Summary Start TOTP enrollment
+// @Description Generate a new TOTP secret for the current member. Show otpauth_uri as a QR code and confirm with a code from the app.
+// @Tags mfa
+// @Produce  json
+// @Security SessionToken
+// @Success 200 {object} mfaEnrollResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Member has a passkey and the session has not completed MFA"
+// @Failure 409 {object} map[string]string "Authenticator already enrolled"
+// @Router /auth/mfa/totp/enroll [post]
+func (h *AuthHandler) MFAEnroll(c *gin.Context) {
+	session, _, ok := h.requireEnrollmentSession(c)
+	if !ok {
+		return
+	}
+
+	secret, uri, err := h.mfa.Enroll(c.Request.Context(), session.MemberID)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, mfaEnrollResponse{
+		Secret:     secret,
+		OTPAuthURI: uri,
+	})
+}
+
+// MFAConfirm godoc
+// @Summary Confirm TOTP enrollment
+// @Description Activate the pending authenticator with a code from the app. Returns recovery codes, shown only once, and marks the current session as having completed MFA.
+// @Tags mfa
+// @Accept  json
+// @Produce  json
+// @Security SessionToken
+// @Param request body mfaCodeRequest true "TOTP code"
+// @Success 200 {object} mfaRecoveryCodesResponse
+// @Failure 400 {object} map[string]string "Enrollment not started"
+// @Failure 401 {object} map[string]string "Unauthorized or invalid code"
+// @Failure 409 {object} map[string]string "Authenticator already enrolled"
+// @Failure 429 {object} map[string]string "Too many failed attempts"
+// @Router /auth/mfa/totp/confirm [post]
+func (h *AuthHandler) MFAConfirm(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	if !h.requireMFAEnabled(c) {
+		return
+	}
+	session, token, ok := h.requireSession(c)
+	if !ok {
+		return
+	}
+
+	var req mfaCodeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	codes, err := h.mfa.ConfirmEnrollment(ctx, session.MemberID, req.Code)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	if err := h.sessionManager.MarkMFASatisfied(ctx, token, core.AuthMethodOTP); err != nil {
+		log.Printf("Warning: Failed to mark session MFA satisfied for member %s: %v", session.MemberID, err)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, mfaRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// MFARegenerateRecoveryCodes godoc
+// @Summary Regenerate recovery codes
+// @Description Replace all recovery codes of the current member. Requires a session that completed MFA.
+// @Tags mfa
+// @Produce  json
+// @Security SessionToken
+// @Success 200 {object} mfaRecoveryCodesResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Session has not completed MFA"
+// @Router /auth/mfa/recovery-codes [post]
+func (h *AuthHandler) MFARegenerateRecoveryCodes(c *gin.Context) {
+	session, _, ok := h.requireMFASession(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.mfa.RegenerateRecoveryCodes(c.Request.Context(), session.MemberID)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, mfaRecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// MFADisable godoc
+// @Summary Remove authenticator
+// @Description Remove the current member's authenticator and recovery codes. Requires a session that completed MFA and is refused when the organization requires MFA.
+// @Tags mfa
+// @Security SessionToken
+// @Success 204 "Removed"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "MFA required by the organization or session has not completed MFA"
+// @Router /auth/mfa/totp [delete]
+func (h *AuthHandler) MFADisable(c *gin.Context) {
+	session, _, ok := h.requireMFASession(c)
+	if !ok {
+		return
+	}
+
+	if err := h.mfa.Disable(c.Request.Context(), session.MemberID); err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// requireMFAEnabled answers 404 when MFA is not configured.
+func (h *AuthHandler) requireMFAEnabled(c *gin.Context) bool {
+	if h.mfa == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "MFA is not enabled"})
+		return false
+	}
+	return true
+}
+
+// requireMFASession is requireSession for operations that change an enrolled
+// authenticator and therefore need a session that completed MFA.
+func (h *AuthHandler) requireMFASession(c *gin.Context) (*types.SessionData, string, bool) {
+	if !h.requireMFAEnabled(c) {
+		return nil, "", false
+	}
+	session, token, ok := h.requireSession(c)
+	if !ok {
+		return nil, "", false
+	}
+	if !session.MFASatisfied {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sign in with your authenticator first"})
+		return nil, "", false
+	}
+	return session, token, true
+}
+
+// requireEnrollmentSession is requireSession for adding a second factor. The
+// first one can be added with any session; further ones need a session that
+// completed MFA with an existing factor.
+func (h *AuthHandler) requireEnrollmentSession(c *gin.Context) (*types.SessionData, string, bool) {
+	if !h.requireMFAEnabled(c) {
+		return nil, "", false
+	}
+	session, token, ok := h.requireSession(c)
+	if !ok {
+		return nil, "", false
+	}
+	if session.MFASatisfied {
+		return session, token, true
+	}
+
+	status, err := h.mfa.Status(c.Request.Context(), session.MemberID)
+	if err != nil {
+		writeMFAError(c, err)
+		return nil, "", false
+	}
+	if status.Enrolled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Sign in with your authenticator first"})
+		return nil, "", false
+	}
+	return session, token, true
+}
+
+func (h *AuthHandler) renderMFAChallenge(c *gin.Context, status int, challenge, message string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := mfaChallengePage.Execute(c.Writer, mfaChallengePageData{Challenge: challenge, Error: message}); err != nil {
+		log.Printf("Warning: Failed to render MFA challenge page: %v", err)
+	}
+}
+
+func writeMFAError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, core.ErrInvalidMFACode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid code"})
+	case errors.Is(err, core.ErrTooManyRequests):
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed attempts, try again later"})
+	case errors.Is(err, core.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "An authenticator is already enrolled"})
+	case errors.Is(err, core.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Your organization requires MFA"})
+	case errors.Is(err, core.ErrBadRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, core.ErrNotFound):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	default:
+		log.Printf("Warning: MFA request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "MFA request failed"})
+	}
+}
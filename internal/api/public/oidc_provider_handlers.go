@@ -0,0 +1,512 @@
+package public
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/tokens"
+)
+
+const (
+	oidcAuthorizationCodeGrantType = "authorization_code"
+	oidcClientCredentialsGrantType = "client_credentials"
+	refreshTokenGrantType          = "refresh_token"
+)
+
+// confidentialClientAuthMethods are the ways apps registered for client
+// credentials authenticate.
+var confidentialClientAuthMethods = []string{"client_secret_basic", "client_secret_post", "private_key_jwt"}
+
+type oidcDiscoveryResponse struct {
+	Issuer                                 string   `json:"issuer"`
+	AuthorizationEndpoint                  string   `json:"authorization_endpoint"`
+	TokenEndpoint                          string   `json:"token_endpoint"`
+	UserInfoEndpoint                       string   `json:"userinfo_endpoint"`
+	JWKSURI                                string   `json:"jwks_uri"`
+	ResponseTypesSupported                 []string `json:"response_types_supported"`
+	SubjectTypesSupported                  []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported       []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                        []string `json:"scopes_supported"`
+	ClaimsSupported                        []string `json:"claims_supported"`
+	TokenEndpointAuthMethodsSupported      []string `json:"token_endpoint_auth_methods_supported"`
+	TokenEndpointAuthSigningAlgsSupported  []string `json:"token_endpoint_auth_signing_alg_values_supported"`
+	IntrospectionEndpoint                  string   `json:"introspection_endpoint"`
+	IntrospectionEndpointAuthMethods       []string `json:"introspection_endpoint_auth_methods_supported"`
+	RevocationEndpoint                     string   `json:"revocation_endpoint"`
+	RevocationEndpointAuthMethods          []string `json:"revocation_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported          []string `json:"code_challenge_methods_supported"`
+	GrantTypesSupported                    []string `json:"grant_types_supported"`
+	AuthorizationResponseISSParamSupported bool     `json:"authorization_response_iss_parameter_supported"`
+}
+
+type oidcTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+type clientCredentialsTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+type oidcUserInfoResponse struct {
+	Subject        string `json:"sub"`
+	Email          string `json:"email,omitempty"`
+	EmailVerified  *bool  `json:"email_verified,omitempty"`
+	Name           string `json:"name,omitempty"`
+	GivenName      string `json:"given_name,omitempty"`
+	FamilyName     string `json:"family_name,omitempty"`
+	OrganizationID string `json:"organization_id,omitempty"`
+}
+
+// OIDCDiscovery godoc
+// @Summary OpenID Connect discovery
+// @Description Provider metadata for apps signing members in with OpenID Connect
+// @Tags oidc
+// @Produce  json
+// @Success 200 {object} oidcDiscoveryResponse
+// @Failure 404 {object} map[string]string "OpenID provider is not enabled"
+// @Router /.well-known/openid-configuration [get]
+func (h *AuthHandler) OIDCDiscovery(c *gin.Context) {
+	if !h.requireOIDC(c) {
+		return
+	}
+
+	issuer := h.oidc.Issuer()
+	signingAlgorithms := make([]string, len(tokens.Algorithms))
+	for i, algorithm := range tokens.Algorithms {
+		signingAlgorithms[i] = string(algorithm)
+	}
+	c.JSON(http.StatusOK, oidcDiscoveryResponse{
+		Issuer:                                 issuer,
+		AuthorizationEndpoint:                  issuer + "/oidc/authorize",
+		TokenEndpoint:                          issuer + "/oidc/token",
+		UserInfoEndpoint:                       issuer + "/oidc/userinfo",
+		JWKSURI:                                issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:                 []string{"code"},
+		SubjectTypesSupported:                  []string{"public"},
+		IDTokenSigningAlgValuesSupported:       signingAlgorithms,
+		ScopesSupported:                        []string{"openid", "email", "profile"},
+		ClaimsSupported:                        []string{"sub", "email", "email_verified", "name", "given_name", "family_name", "organization_id", "auth_time", "amr", "nonce"},
+		TokenEndpointAuthMethodsSupported:      []string{"client_secret_basic", "client_secret_post", "private_key_jwt", "none"},
+		TokenEndpointAuthSigningAlgsSupported:  signingAlgorithms,
+		IntrospectionEndpoint:                  issuer + "/oidc/introspect",
+		IntrospectionEndpointAuthMethods:       confidentialClientAuthMethods,
+		RevocationEndpoint:                     issuer + "/oidc/revoke",
+		RevocationEndpointAuthMethods:          confidentialClientAuthMethods,
+		CodeChallengeMethodsSupported:          []string{"S256"},
+		GrantTypesSupported:                    []string{oidcAuthorizationCodeGrantType, refreshTokenGrantType, oidcClientCredentialsGrantType},
+		AuthorizationResponseISSParamSupported: true,
+	})
+}
+
+// OIDCAuthorize godoc
+// @Summary OpenID Connect authorization endpoint
+// @Description Authorization code flow for apps registered as OpenID Connect clients. The member's session is the single sign-on session: members without one are sent to login, and sessions that do not meet the app's assurance policy go through step-up first. Public clients must use PKCE with S256.
+// @Tags oidc
+// @Param client_id query string true "App ID"
+// @Param redirect_uri query string true "Registered redirect URI"
+// @Param response_type query string true "code"
+// @Param scope query string true "Space separated scopes, including openid"
+// @Param state query string false "Opaque value returned to the client"
+// @Param nonce query string false "Value echoed in the ID token"
+// @Param code_challenge query string false "PKCE code challenge"
+// @Param code_challenge_method query string false "S256"
+// @Param prompt query string false "none or login"
+// @Param max_age query int false "Maximum seconds since the member last authenticated"
+// @Success 302 {string} string "Redirect to the client with a code, or to login"
+// @Failure 400 {object} map[string]string "Unknown client or redirect URI"
+// @Router /oidc/authorize [get]
+func (h *AuthHandler) OIDCAuthorize(c *gin.Context) {
+	if !h.requireOIDC(c) {
+		return
+	}
+	ctx := c.Request.Context()
+
+	clientID := c.Request.FormValue("client_id")
+	redirectURI := c.Request.FormValue("redirect_uri")
+	app, err := h.oidc.GetClient(ctx, clientID, redirectURI)
+	if err != nil {
+		// Without a verified redirect URI the error cannot be sent to the
+		// client, so it is shown to the member instead.
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown client_id or redirect_uri"})
+		return
+	}
+
+	state := c.Request.FormValue("state")
+	scope := c.Request.FormValue("scope")
+	codeChallenge := c.Request.FormValue("code_challenge")
+	prompts := strings.Fields(c.Request.FormValue("prompt"))
+
+	if c.Request.FormValue("response_type") != "code" {
+		h.redirectAuthorizationError(c, redirectURI, state, "unsupported_response_type", "Only the code response type is supported")
+		return
+	}
+	if !slices.Contains(strings.Fields(scope), "openid") {
+		h.redirectAuthorizationError(c, redirectURI, state, "invalid_scope", "The openid scope is required")
+		return
+	}
+	if codeChallenge != "" && c.Request.FormValue("code_challenge_method") != "S256" {
+		h.redirectAuthorizationError(c, redirectURI, state, "invalid_request", "code_challenge_method must be S256")
+		return
+	}
+	if codeChallenge == "" && !app.OIDC.Confidential {
+		h.redirectAuthorizationError(c, redirectURI, state, "invalid_request", "PKCE is required for public clients")
+		return
+	}
+
+	maxAge := -1
+	if raw := c.Request.FormValue("max_age"); raw != "" {
+		maxAge, err = strconv.Atoi(raw)
+		if err != nil || maxAge < 0 {
+			h.redirectAuthorizationError(c, redirectURI, state, "invalid_request", "max_age must be a non-negative integer")
+			return
+		}
+	}
+
+	session, token, ok := h.currentSession(c)
+	loginRequired := !ok || slices.Contains(prompts, "login") ||
+		(maxAge >= 0 && (session.AuthTime.Unix() <= 0 || time.Since(session.AuthTime) > time.Duration(maxAge)*time.Second))
+	if loginRequired {
+		if slices.Contains(prompts, "none") {
+			h.redirectAuthorizationError(c, redirectURI, state, "login_required", "")
+			return
+		}
+		h.redirectToLoginPage(c, h.authorizeReturnURL(c))
+		return
+	}
+
+	if unmet := app.Assurance.Unmet(session, time.Now()); len(unmet) > 0 {
+		if slices.Contains(prompts, "none") {
+			h.redirectAuthorizationError(c, redirectURI, state, "interaction_required", "")
+			return
+		}
+		query := url.Values{"return_to": {h.authorizeReturnURL(c)}}
+		if app.Assurance.RequiredAuthMethod != "" {
+			query.Set("method", app.Assurance.RequiredAuthMethod.String())
+		}
+		if app.Assurance.RequireMFA {
+			query.Set("mfa", "1")
+		}
+		c.Redirect(http.StatusFound, "/auth/step-up?"+query.Encode())
+		return
+	}
+
+	code, err := h.oidc.Authorize(ctx, types.OIDCAuthorizationRequest{
+		ClientID:      clientID,
+		RedirectURI:   redirectURI,
+		Scope:         scope,
+		Nonce:         c.Request.FormValue("nonce"),
+		CodeChallenge: codeChallenge,
+	}, token)
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrInvalidSession):
+			h.redirectToLoginPage(c, h.authorizeReturnURL(c))
+		case errors.Is(err, core.ErrAccessDenied):
+			log.Printf("Security: member %s denied OIDC access to app %s", session.MemberID, clientID)
+			h.redirectAuthorizationError(c, redirectURI, state, "access_denied", "The member may not sign in to this app")
+		case errors.Is(err, core.ErrForbidden):
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/403/mfa_enrollment_required")
+		case errors.Is(err, core.ErrBadRequest):
+			h.redirectAuthorizationError(c, redirectURI, state, "invalid_request", "")
+		default:
+			log.Printf("Warning: Failed to issue OIDC authorization code for app %s: %v", clientID, err)
+			h.redirectAuthorizationError(c, redirectURI, state, "server_error", "")
+		}
+		return
+	}
+
+	query := url.Values{"code": {code}, "iss": {h.oidc.Issuer()}}
+	if state != "" {
+		query.Set("state", state)
+	}
+	c.Redirect(http.StatusFound, appendQuery(redirectURI, query))
+}
+
+// OIDCToken godoc
+// @Summary OpenID Connect token endpoint
+// @Description Redeem an authorization code or refresh token, or obtain an access token for the app itself with the client credentials grant. Confidential clients authenticate with HTTP Basic or client_secret in the body; public clients send client_id and code_verifier. The authorization code access token is a short-lived session token accepted by forward auth and the userinfo endpoint; it comes with a refresh token that is rotated on every use. Presenting a rotated refresh token again revokes every token descending from the same authorization. Client credentials clients authenticate with their client secret or a private_key_jwt client assertion; their access token is accepted by forward auth as a bearer token.
+// @Tags oidc
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param grant_type formData string true "authorization_code, refresh_token or client_credentials"
+// @Param code formData string false "Authorization code"
+// @Param refresh_token formData string false "Refresh token"
+// @Param redirect_uri formData string false "Redirect URI of the authorization request"
+// @Param client_id formData string false "App ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param client_assertion_type formData string false "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+// @Param client_assertion formData string false "private_key_jwt client assertion"
+// @Param scope formData string false "Space separated scopes for client credentials; defaults to all scopes registered for the app"
+// @Success 200 {object} oidcTokenResponse
+// @Failure 400 {object} oauthErrorResponse "invalid_grant, invalid_scope or unsupported_grant_type"
+// @Failure 401 {object} oauthErrorResponse "invalid_client"
+// @Router /oidc/token [post]
+func (h *AuthHandler) OIDCToken(c *gin.Context) {
+	if !h.requireOIDC(c) {
+		return
+	}
+
+	grantType := c.PostForm("grant_type")
+	switch grantType {
+	case oidcClientCredentialsGrantType:
+		h.clientCredentialsToken(c)
+		return
+	case refreshTokenGrantType:
+		h.oidcRefreshToken(c)
+		return
+	}
+	if grantType != oidcAuthorizationCodeGrantType {
+		writeOAuthError(c, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	clientID, clientSecret, ok := oauthClientCredentials(c)
+	if !ok {
+		writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Malformed client credentials")
+		return
+	}
+
+	tokens, err := h.oidc.Exchange(c.Request.Context(), types.OIDCTokenRequest{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Code:         c.PostForm("code"),
+		RedirectURI:  c.PostForm("redirect_uri"),
+		CodeVerifier: c.PostForm("code_verifier"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrUnauthorized):
+			c.Header("WWW-Authenticate", `Basic realm="oidc"`)
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		case errors.Is(err, core.ErrInvalidToken):
+			writeOAuthError(c, http.StatusBadRequest, "invalid_grant", "The authorization code is invalid or has expired")
+		default:
+			log.Printf("Warning: Failed to redeem OIDC authorization code for client %s: %v", clientID, err)
+			writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	writeOIDCTokens(c, tokens)
+}
+
+// oidcRefreshToken redeems a refresh token (RFC 6749 section 6). Clients
+// authenticate as for the authorization code grant.
+func (h *AuthHandler) oidcRefreshToken(c *gin.Context) {
+	clientID, clientSecret, ok := oauthClientCredentials(c)
+	if !ok {
+		writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Malformed client credentials")
+		return
+	}
+
+	tokens, err := h.oidc.Refresh(c.Request.Context(), types.OIDCRefreshRequest{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RefreshToken: c.PostForm("refresh_token"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrUnauthorized):
+			c.Header("WWW-Authenticate", `Basic realm="oidc"`)
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		case errors.Is(err, core.ErrInvalidToken):
+			writeOAuthError(c, http.StatusBadRequest, "invalid_grant", "The refresh token is invalid or has expired")
+		default:
+			log.Printf("Warning: Failed to refresh OIDC tokens for client %s: %v", clientID, err)
+			writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	writeOIDCTokens(c, tokens)
+}
+
+func writeOIDCTokens(c *gin.Context, tokens *types.OIDCTokens) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, oidcTokenResponse{
+		AccessToken:  tokens.AccessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    tokens.ExpiresIn,
+		RefreshToken: tokens.RefreshToken,
+		IDToken:      tokens.IDToken,
+		Scope:        tokens.Scope,
+	})
+}
+
+// clientCredentialsToken issues an access token for the app itself (RFC 6749
+// section 4.4). Apps authenticate with their client secret or, without one,
+// with a private_key_jwt assertion (RFC 7523).
+func (h *AuthHandler) clientCredentialsToken(c *gin.Context) {
+	caller, ok := oauthClientAuthentication(c)
+	if !ok {
+		writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Malformed client credentials")
+		return
+	}
+
+	token, err := h.clientCredentials.Issue(c.Request.Context(), types.ClientCredentialsRequest{
+		ClientAuthentication: caller,
+		Scope:                c.PostForm("scope"),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, core.ErrUnauthorized):
+			c.Header("WWW-Authenticate", `Basic realm="oidc"`)
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		case errors.Is(err, core.ErrBadRequest):
+			writeOAuthError(c, http.StatusBadRequest, "invalid_scope", "The requested scope is not registered for the client")
+		default:
+			log.Printf("Warning: Failed to issue client credentials token for client %s: %v", caller.ClientID, err)
+			writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, clientCredentialsTokenResponse{
+		AccessToken: token.AccessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   token.ExpiresIn,
+		Scope:       token.Scope,
+	})
+}
+
+// OIDCUserInfo godoc
+// @Summary OpenID Connect userinfo endpoint
+// @Description Claims of the member an access token was issued to
+// @Tags oidc
+// @Produce  json
+// @Param Authorization header string true "Bearer access token"
+// @Success 200 {object} oidcUserInfoResponse
+// @Failure 401 {object} oauthErrorResponse "invalid_token"
+// @Router /oidc/userinfo [get]
+func (h *AuthHandler) OIDCUserInfo(c *gin.Context) {
+	if !h.requireOIDC(c) {
+		return
+	}
+
+	accessToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || accessToken == "" {
+		c.Header("WWW-Authenticate", `Bearer`)
+		writeOAuthError(c, http.StatusUnauthorized, "invalid_request", "Missing bearer token")
+		return
+	}
+
+	info, err := h.oidc.UserInfo(c.Request.Context(), accessToken)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidToken) {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			writeOAuthError(c, http.StatusUnauthorized, "invalid_token", "")
+			return
+		}
+		log.Printf("Warning: Failed to load OIDC userinfo: %v", err)
+		writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, oidcUserInfoResponse{
+		Subject:        info.Subject,
+		Email:          info.Email,
+		EmailVerified:  info.EmailVerified,
+		Name:           info.Name,
+		GivenName:      info.GivenName,
+		FamilyName:     info.FamilyName,
+		OrganizationID: info.OrganizationID,
+	})
+}
+
+func (h *AuthHandler) requireOIDC(c *gin.Context) bool {
+	if h.oidc == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "OpenID provider is not enabled"})
+		return false
+	}
+	return true
+}
+
+// authorizeReturnURL is the authorization request as a GET URL to come back
+// to after login or step-up. prompt and max_age are dropped: the member has
+// just authenticated, and keeping them would send prompt=login or max_age=0
+// requests back to login forever.
+func (h *AuthHandler) authorizeReturnURL(c *gin.Context) string {
+	query := url.Values{}
+	for name, values := range c.Request.Form {
+		if name != "prompt" && name != "max_age" {
+			query[name] = values
+		}
+	}
+	return h.oidc.Issuer() + "/oidc/authorize?" + query.Encode()
+}
+
+// redirectAuthorizationError sends an authorization error response (RFC 6749
+// section 4.1.2.1) to a verified redirect URI.
+func (h *AuthHandler) redirectAuthorizationError(c *gin.Context, redirectURI, state, code, description string) {
+	query := url.Values{"error": {code}, "iss": {h.oidc.Issuer()}}
+	if description != "" {
+		query.Set("error_description", description)
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	c.Redirect(http.StatusFound, appendQuery(redirectURI, query))
+}
+
+// oauthClientCredentials reads client credentials from HTTP Basic
+// authentication, whose parts are form-encoded (RFC 6749 section 2.3.1), or
+// from the request body.
+func oauthClientCredentials(c *gin.Context) (string, string, bool) {
+	username, password, ok := c.Request.BasicAuth()
+	if !ok {
+		return c.PostForm("client_id"), c.PostForm("client_secret"), true
+	}
+	clientID, err := url.QueryUnescape(username)
+	if err != nil {
+		return "", "", false
+	}
+	clientSecret, err := url.QueryUnescape(password)
+	if err != nil {
+		return "", "", false
+	}
+	return clientID, clientSecret, true
+}
+
+// oauthClientAuthentication reads the credentials of a confidential client:
+// a client secret as accepted by oauthClientCredentials, or a
+// private_key_jwt client assertion (RFC 7523).
+func oauthClientAuthentication(c *gin.Context) (types.ClientAuthentication, bool) {
+	clientID, clientSecret, ok := oauthClientCredentials(c)
+	if !ok {
+		return types.ClientAuthentication{}, false
+	}
+	return types.ClientAuthentication{
+		ClientID:            clientID,
+		ClientSecret:        clientSecret,
+		ClientAssertionType: c.PostForm("client_assertion_type"),
+		ClientAssertion:     c.PostForm("client_assertion"),
+	}, true
+}
+
+func appendQuery(rawURL string, query url.Values) string {
+	separator := "?"
+	if strings.Contains(rawURL, "?") {
+		separator = "&"
+	}
+	return rawURL + separator + query.Encode()
+}
@@ -0,0 +1,69 @@
+package public
+
+import (
+	"context"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+// resolveReturnTo returns the URL to send the member to after login. The
+// requested return_to is only honoured when it points at a host served for the
+// member's organization or at a globally allowed host; anything else falls
+// back to the post-login URL.
+func (h *AuthHandler) resolveReturnTo(ctx context.Context, returnTo string, member *types.Member) string {
+	if returnTo == "" {
+		return h.postLoginURL
+	}
+
+	host, ok := parseReturnToHost(returnTo)
+	if ok && h.isAllowedReturnHost(ctx, host, member) {
+		return returnTo
+	}
+
+	log.Printf("Security: rejected return_to %q for member %s (organization %s)", returnTo, member.ID, member.OrganizationID)
+	return h.postLoginURL
+}
+
+func (h *AuthHandler) isAllowedReturnHost(ctx context.Context, host string, member *types.Member) bool {
+	if h.allowedReturnHosts[host] {
+		return true
+	}
+
+	org, err := h.orgService.GetByID(ctx, member.OrganizationID)
+	if err != nil {
+		return false
+	}
+
+	allowedDomains, err := h.appService.GetAllowedDomainsForOrg(ctx, member.OrganizationID, org.Hostname)
+	if err != nil {
+		return false
+	}
+
+	for _, domain := range allowedDomains {
+		if strings.ToLower(domain) == host {
+			return true
+		}
+	}
+	return false
+}
+
+func parseReturnToHost(returnTo string) (string, bool) {
+	u, err := url.Parse(returnTo)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", false
+	}
+	if u.User != nil {
+		return "", false
+	}
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return "", false
+	}
+	return host, true
+}
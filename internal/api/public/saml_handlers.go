@@ -0,0 +1,168 @@
+package public
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/saml"
+)
+
+// samlTransactionProvider namespaces SAML login transactions so a RelayState
+// can never be replayed against an OAuth callback with the same slug.
+func samlTransactionProvider(slug string) string {
+	return "saml:" + slug
+}
+
+// SAMLMetadata godoc
+// @Summary SAML service provider metadata
+// @Description SP metadata (entity ID, ACS endpoint and signing certificate) to import into the organization's SAML identity provider
+// @Tags auth
+// @Produce  xml
+// @Param slug path string true "SAML connection slug"
+// @Success 200 {string} string "SP metadata"
+// @Failure 404 {object} map[string]string "Unknown SAML connection"
+// @Router /auth/saml/{slug}/metadata [get]
+func (h *AuthHandler) SAMLMetadata(c *gin.Context) {
+	provider, _, ok := h.lookupSAMLProvider(c)
+	if !ok {
+		return
+	}
+
+	metadata, err := provider.Metadata()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build SAML metadata"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/samlmetadata+xml", metadata)
+}
+
+// SAMLLogin godoc
+// @Summary SAML login
+// @Description Redirect user to the organization's SAML identity provider with a signed AuthnRequest
+// @Tags auth
+// @Param slug path string true "SAML connection slug"
+// @Param return_to query string false "URL to redirect to after successful login"
+// @Success 302 {string} string "Redirect to the identity provider"
+// @Failure 404 {object} map[string]string "Unknown SAML connection"
+// @Router /auth/saml/{slug}/login [get]
+func (h *AuthHandler) SAMLLogin(c *gin.Context) {
+	provider, _, ok := h.lookupSAMLProvider(c)
+	if !ok {
+		return
+	}
+
+	h.beginSAMLLogin(c, provider, "")
+}
+
+// beginSAMLLogin redirects to the identity provider with a signed AuthnRequest.
+// A non-empty linkMemberID links the account to that member on the ACS
+// instead of signing in.
+func (h *AuthHandler) beginSAMLLogin(c *gin.Context, provider *saml.ServiceProvider, linkMemberID string) {
+	ctx := c.Request.Context()
+
+	state, err := generateState()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate state"})
+		return
+	}
+
+	authURL, requestID, err := provider.AuthURL(state)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build SAML request"})
+		return
+	}
+
+	binding, err := h.bindLogin(c, h.samlBindingSameSite())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate state"})
+		return
+	}
+
+	transaction := &types.LoginTransaction{
+		Provider:     samlTransactionProvider(provider.Name()),
+		Nonce:        requestID,
+		ReturnTo:     c.Query("return_to"),
+		Host:         c.Request.Host,
+		LinkMemberID: linkMemberID,
+		Binding:      binding,
+	}
+	if err := h.loginTxStore.Save(ctx, state, transaction); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store login state"})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// SAMLACS godoc
+// @Summary SAML assertion consumer service
+// @Description Receive the identity provider's SAMLResponse (HTTP-POST binding), validate signature, audience and request ID, then sign in the member and redirect
+// @Tags auth
+// @Accept  x-www-form-urlencoded
+// @Param slug path string true "SAML connection slug"
+// @Param SAMLResponse formData string true "Base64 encoded SAML response"
+// @Param RelayState formData string true "Relay state issued with the AuthnRequest"
+// @Success 302 {string} string "Redirect to return_to or the post-login URL"
+// @Failure 400 {object} map[string]string "Invalid SAML response"
+// @Failure 404 {object} map[string]string "Unknown SAML connection"
+// @Router /auth/saml/{slug}/acs [post]
+func (h *AuthHandler) SAMLACS(c *gin.Context) {
+	provider, orgID, ok := h.lookupSAMLProvider(c)
+	if !ok {
+		return
+	}
+
+	samlResponse := c.PostForm("SAMLResponse")
+	if samlResponse == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing SAML response"})
+		return
+	}
+
+	transaction, ok := h.consumeLogin(c, c.PostForm("RelayState"), samlTransactionProvider(provider.Name()), h.samlBindingSameSite())
+	if !ok {
+		return
+	}
+
+	identity, err := provider.ParseResponse(samlResponse, transaction.Nonce)
+	if err != nil {
+		log.Printf("Security: rejected SAML response for %s: %v", provider.Name(), err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid SAML response"})
+		return
+	}
+
+	h.completeLogin(c, transaction, &externalIdentity{
+		Provider:       samlTransactionProvider(provider.Name()),
+		Method:         core.AuthMethodSAML,
+		OrganizationID: orgID,
+		Issuer:         identity.Issuer,
+		Subject:        identity.Subject,
+		Email:          identity.Email,
+		FirstName:      identity.GivenName,
+		LastName:       identity.FamilyName,
+	})
+}
+
+func (h *AuthHandler) lookupSAMLProvider(c *gin.Context) (*saml.ServiceProvider, string, bool) {
+	if h.samlProviders == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown SAML connection"})
+		return nil, "", false
+	}
+
+	slug := c.Param("slug")
+	provider, orgID, err := h.samlProviders.Resolve(c.Request.Context(), slug)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Unknown SAML connection"})
+			return nil, "", false
+		}
+		log.Printf("Warning: Failed to resolve SAML connection %s: %v", slug, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load SAML connection"})
+		return nil, "", false
+	}
+	return provider, orgID, true
+}
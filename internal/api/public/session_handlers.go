@@ -0,0 +1,98 @@
+package public
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type memberSessionResponse struct {
+	ID         string    `json:"id"`
+	Kind       string    `json:"kind"`
+	ClientID   string    `json:"client_id,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+	Device     string    `json:"device,omitempty"`
+	Browser    string    `json:"browser,omitempty"`
+	IP         string    `json:"ip,omitempty"`
+	Country    string    `json:"country,omitempty"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// SessionList godoc
+// @Summary List sessions
+// @Description The member's active sessions, most recently used first: browser sessions and clients such as CLIs, with the device, IP address and country they were last used from. The session of the current browser is marked current.
+// @Tags auth
+// @Produce  json
+// @Success 200 {array} memberSessionResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /auth/sessions [get]
+func (h *AuthHandler) SessionList(c *gin.Context) {
+	session, token, ok := h.requireSession(c)
+	if !ok {
+		return
+	}
+
+	sessions, err := h.sessionManager.ListSessions(c.Request.Context(), session.MemberID, token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sessions"})
+		return
+	}
+
+	response := make([]memberSessionResponse, len(sessions))
+	for i, memberSession := range sessions {
+		response[i] = memberSessionResponse{
+			ID:         memberSession.ID,
+			Kind:       memberSession.Kind,
+			ClientID:   memberSession.ClientID,
+			UserAgent:  memberSession.UserAgent,
+			Device:     memberSession.Device,
+			Browser:    memberSession.Browser,
+			IP:         memberSession.IP,
+			Country:    memberSession.Country,
+			Current:    memberSession.Current,
+			CreatedAt:  memberSession.CreatedAt,
+			LastSeenAt: memberSession.LastSeenAt,
+			ExpiresAt:  memberSession.ExpiresAt,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// SessionRevoke godoc
+// @Summary Revoke a session
+// @Description End one of the member's sessions, e.g. on a lost phone. Revoking a client also revokes its refresh token. Revoking the current session logs the browser out.
+// @Tags auth
+// @Produce  json
+// @Param session_id path string true "Session ID"
+// @Success 204 "Revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Session not found"
+// @Router /auth/sessions/{session_id} [delete]
+func (h *AuthHandler) SessionRevoke(c *gin.Context) {
+	session, _, ok := h.requireSession(c)
+	if !ok {
+		return
+	}
+
+	if err := h.sessionManager.RevokeSession(c.Request.Context(), session.MemberID, c.Param("session_id")); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	log.Printf("Security: member %s revoked session %s", session.MemberID, c.Param("session_id"))
+
+	if _, _, ok := h.currentSession(c); !ok {
+		h.setSessionCookie(c, "", -1)
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,98 @@
+package public
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// StepUp godoc
+// @Summary Step-up authentication
+// @Description Re-authenticate the current member for an app whose assurance policy the session does not meet; forward auth sends browsers here. Members with a second factor answer an MFA challenge, which issues a fresh session keeping the methods used so far. A required primary method, or a member without a second factor, goes through login again.
+// @Tags auth
+// @Param return_to query string false "URL to return to afterwards"
+// @Param method query string false "Auth method the app requires"
+// @Param mfa query string false "Set to 1 when the app requires MFA"
+// @Success 302 {string} string "Redirect to the MFA challenge or login"
+// @Failure 400 {object} map[string]string "Unknown auth method"
+// @Router /auth/step-up [get]
+func (h *AuthHandler) StepUp(c *gin.Context) {
+	ctx := c.Request.Context()
+	returnTo := c.Query("return_to")
+
+	method := core.AuthMethod(c.Query("method"))
+	if method != "" && !method.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown auth method"})
+		return
+	}
+	requireSecondFactor := c.Query("mfa") == "1" || method.IsSecondFactor()
+
+	session, _, ok := h.currentSession(c)
+	if !ok {
+		h.redirectToLoginPage(c, returnTo)
+		return
+	}
+
+	if method != "" && !method.IsSecondFactor() {
+		h.redirectToPrimaryLogin(c, method, returnTo)
+		return
+	}
+
+	if h.mfa == nil {
+		h.redirectToLoginPage(c, returnTo)
+		return
+	}
+	status, err := h.mfa.Status(ctx, session.MemberID)
+	if err != nil {
+		log.Printf("Warning: Failed to load MFA status for step-up of member %s: %v", session.MemberID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load MFA status"})
+		return
+	}
+
+	if !status.Enrolled {
+		if requireSecondFactor {
+			c.Redirect(http.StatusFound, h.errorLoginURL+"/403/mfa_enrollment_required")
+			return
+		}
+		h.redirectToLoginPage(c, returnTo)
+		return
+	}
+	if (method == core.AuthMethodPasskey && status.Passkeys == 0) || (method == core.AuthMethodOTP && !status.TOTP) {
+		c.Redirect(http.StatusFound, h.errorLoginURL+"/403/step_up_method_unavailable")
+		return
+	}
+
+	challenge, err := h.mfa.BeginChallenge(ctx, session.MemberID, returnTo, c.Request.Host, session.AuthMethods)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start MFA challenge"})
+		return
+	}
+	c.Redirect(http.StatusFound, "/auth/mfa?challenge="+url.QueryEscape(challenge))
+}
+
+// redirectToLoginPage sends the browser to the login page served on this host.
+func (h *AuthHandler) redirectToLoginPage(c *gin.Context, returnTo string) {
+	target := "/?auto=1"
+	if returnTo != "" {
+		target += "&return_to=" + url.QueryEscape(returnTo)
+	}
+	c.Redirect(http.StatusFound, target)
+}
+
+// redirectToPrimaryLogin starts a new login with a built-in provider, or lets
+// the member pick on the login page for methods that depend on their
+// organization.
+func (h *AuthHandler) redirectToPrimaryLogin(c *gin.Context, method core.AuthMethod, returnTo string) {
+	if _, ok := h.providers[method.String()]; ok {
+		c.Redirect(http.StatusFound, "/auth/"+method.String()+"/login?return_to="+url.QueryEscape(returnTo))
+		return
+	}
+	query := url.Values{"method": {method.String()}}
+	if returnTo != "" {
+		query.Set("return_to", returnTo)
+	}
+	c.Redirect(http.StatusFound, "/?"+query.Encode())
+}
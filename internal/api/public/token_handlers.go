@@ -0,0 +1,133 @@
+package public
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/api/types"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type TokenHandler struct {
+	tokens types.TokenService
+}
+
+func NewTokenHandler(tokens types.TokenService) *TokenHandler {
+	return &TokenHandler{tokens: tokens}
+}
+
+type tokenIntrospectionResponse struct {
+	Active         bool   `json:"active"`
+	Issuer         string `json:"iss,omitempty"`
+	Subject        string `json:"sub,omitempty"`
+	Username       string `json:"username,omitempty"`
+	ClientID       string `json:"client_id,omitempty"`
+	OrganizationID string `json:"organization_id,omitempty"`
+	Scope          string `json:"scope,omitempty"`
+	TokenType      string `json:"token_type,omitempty"`
+	IssuedAt       int64  `json:"iat,omitempty"`
+	ExpiresAt      int64  `json:"exp,omitempty"`
+}
+
+// Introspect godoc
+// @Summary Token introspection
+// @Description RFC 7662 introspection of session tokens, access tokens derived from them, refresh tokens and client credentials access tokens. The caller authenticates as an app registered for client credentials and only learns about tokens issued to itself; platform apps see every token. Tokens that are unknown, expired, revoked or out of scope are reported as {"active": false}.
+// @Tags oidc
+// @Accept  x-www-form-urlencoded
+// @Produce  json
+// @Param token formData string true "Token to introspect"
+// @Param token_type_hint formData string false "access_token or refresh_token; tokens are found regardless"
+// @Param client_id formData string false "App ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Param client_assertion_type formData string false "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+// @Param client_assertion formData string false "private_key_jwt client assertion"
+// @Success 200 {object} tokenIntrospectionResponse
+// @Failure 400 {object} oauthErrorResponse "invalid_request"
+// @Failure 401 {object} oauthErrorResponse "invalid_client"
+// @Router /oidc/introspect [post]
+func (h *TokenHandler) Introspect(c *gin.Context) {
+	caller, token, ok := h.tokenRequest(c)
+	if !ok {
+		return
+	}
+
+	introspection, err := h.tokens.Introspect(c.Request.Context(), caller, token)
+	if err != nil {
+		h.writeError(c, caller, "introspect", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if !introspection.Active {
+		c.JSON(http.StatusOK, tokenIntrospectionResponse{})
+		return
+	}
+	c.JSON(http.StatusOK, tokenIntrospectionResponse{
+		Active:         true,
+		Issuer:         introspection.Issuer,
+		Subject:        introspection.Subject,
+		Username:       introspection.Username,
+		ClientID:       introspection.ClientID,
+		OrganizationID: introspection.OrganizationID,
+		Scope:          introspection.Scope,
+		TokenType:      "Bearer",
+		IssuedAt:       introspection.IssuedAt.Unix(),
+		ExpiresAt:      introspection.ExpiresAt.Unix(),
+	})
+}
+
+// Revoke godoc
+// @Summary Token revocation
+// @Description RFC 7009 revocation of session tokens, access tokens derived from them, refresh tokens and client credentials access tokens. Revoking a refresh token revokes every refresh token descending from the same authorization. The caller authenticates as an app registered for client credentials and can only revoke tokens issued to itself; platform apps can revoke every token. Unknown and out of scope tokens are answered with 200 as well. Revoked tokens are remembered until they would have expired.
+// @Tags oidc
+// @Accept  x-www-form-urlencoded
+// @Param token formData string true "Token to revoke"
+// @Param token_type_hint formData string false "access_token or refresh_token; tokens are found regardless"
+// @Param client_id formData string false "App ID, unless sent with HTTP Basic"
+// @Param client_secret formData string false "Client secret, unless sent with HTTP Basic"
+// @Param client_assertion_type formData string false "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+// @Param client_assertion formData string false "private_key_jwt client assertion"
+// @Success 200 "Token revoked or not found"
+// @Failure 400 {object} oauthErrorResponse "invalid_request"
+// @Failure 401 {object} oauthErrorResponse "invalid_client"
+// @Router /oidc/revoke [post]
+func (h *TokenHandler) Revoke(c *gin.Context) {
+	caller, token, ok := h.tokenRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.tokens.Revoke(c.Request.Context(), caller, token); err != nil {
+		h.writeError(c, caller, "revoke", err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+func (h *TokenHandler) tokenRequest(c *gin.Context) (types.ClientAuthentication, string, bool) {
+	caller, ok := oauthClientAuthentication(c)
+	if !ok {
+		writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Malformed client credentials")
+		return types.ClientAuthentication{}, "", false
+	}
+	token := c.PostForm("token")
+	if token == "" {
+		writeOAuthError(c, http.StatusBadRequest, "invalid_request", "token is required")
+		return types.ClientAuthentication{}, "", false
+	}
+	return caller, token, true
+}
+
+func (h *TokenHandler) writeError(c *gin.Context, caller types.ClientAuthentication, action string, err error) {
+	if errors.Is(err, core.ErrUnauthorized) {
+		c.Header("WWW-Authenticate", `Basic realm="oidc"`)
+		writeOAuthError(c, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+	log.Printf("Warning: Failed to %s token for client %s: %v", action, caller.ClientID, err)
+	writeOAuthError(c, http.StatusInternalServerError, "server_error", "")
+}
@@ -0,0 +1,400 @@
+package public
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type passkeyCeremonyResponse struct {
+	Ceremony string          `json:"ceremony"`
+	Options  json.RawMessage `json:"options"`
+}
+
+type passkeyRegisterRequest struct {
+	Ceremony   string          `json:"ceremony" binding:"required"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type passkeyLoginBeginRequest struct {
+	ReturnTo string `json:"return_to"`
+}
+
+type passkeyLoginFinishRequest struct {
+	Ceremony   string          `json:"ceremony" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type passkeyMFABeginRequest struct {
+	Challenge string `json:"challenge" binding:"required"`
+}
+
+type passkeyMFAFinishRequest struct {
+	Challenge  string          `json:"challenge" binding:"required"`
+	Ceremony   string          `json:"ceremony" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+}
+
+type passkeyRedirectResponse struct {
+	RedirectTo string `json:"redirect_to"`
+}
+
+type passkeyResponse struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	Transports   []string   `json:"transports"`
+	CloneWarning bool       `json:"clone_warning"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// PasskeyList godoc
+// @Summary List passkeys
+// @Description List the passkeys registered by the current member
+// @Tags passkeys
+// @Produce  json
+// @Security SessionToken
+// @Success 200 {array} passkeyResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /auth/webauthn/credentials [get]
+func (h *AuthHandler) PasskeyList(c *gin.Context) {
+	session, _, ok := h.requireSession(c)
+	if !ok {
+		return
+	}
+	if h.passkeys == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	credentials, err := h.passkeys.List(c.Request.Context(), session.MemberID)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+
+	response := make([]passkeyResponse, len(credentials))
+	for i, credential := range credentials {
+		transports := credential.Transports
+		if transports == nil {
+			transports = []string{}
+		}
+		response[i] = passkeyResponse{
+			ID:           credential.ID,
+			Name:         credential.Name,
+			Transports:   transports,
+			CloneWarning: credential.CloneWarning,
+			LastUsedAt:   credential.LastUsedAt,
+			CreatedAt:    credential.CreatedAt,
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// PasskeyDelete godoc
+// @Summary Remove passkey
+// @Description Remove one of the current member's passkeys. Requires a session that completed MFA; the last second factor cannot be removed when the organization requires MFA.
+// @Tags passkeys
+// @Security SessionToken
+// @Param credential_id path string true "Passkey ID"
+// @Success 204 "Removed"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Router /auth/webauthn/credentials/{credential_id} [delete]
+func (h *AuthHandler) PasskeyDelete(c *gin.Context) {
+	session, _, ok := h.requireMFASession(c)
+	if !ok {
+		return
+	}
+	if h.passkeys == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	if err := h.passkeys.Delete(c.Request.Context(), session.MemberID, c.Param("credential_id")); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Passkey not found"})
+			return
+		}
+		writeMFAError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// PasskeyRegisterBegin godoc
+// @Summary Start passkey registration
+// @Description Returns options for navigator.credentials.create and the ceremony token to finish with. Members who already have a second factor need a session that completed MFA.
+// @Tags passkeys
+// @Produce  json
+// @Security SessionToken
+// @Success 200 {object} passkeyCeremonyResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Session has not completed MFA"
+// @Router /auth/webauthn/register/begin [post]
+func (h *AuthHandler) PasskeyRegisterBegin(c *gin.Context) {
+	session, _, ok := h.requireEnrollmentSession(c)
+	if !ok {
+		return
+	}
+	if h.passkeys == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	options, ceremony, err := h.passkeys.BeginRegistration(c.Request.Context(), session.MemberID)
+	if err != nil {
+		writeMFAError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, passkeyCeremonyResponse{Ceremony: ceremony, Options: options})
+}
+
+// PasskeyRegisterFinish godoc
+// @Summary Finish passkey registration
+// @Description Verify the authenticator's attestation and store the passkey. The current session is marked as having completed MFA.
+// @Tags passkeys
+// @Accept  json
+// @Produce  json
+// @Security SessionToken
+// @Param request body passkeyRegisterRequest true "Ceremony token, passkey name and the PublicKeyCredential from navigator.credentials.create"
+// @Success 201 {object} passkeyResponse
+// @Failure 400 {object} map[string]string "Invalid registration"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /auth/webauthn/register/finish [post]
+func (h *AuthHandler) PasskeyRegisterFinish(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	session, token, ok := h.requireEnrollmentSession(c)
+	if !ok {
+		return
+	}
+	if h.passkeys == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	var req passkeyRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	credential, err := h.passkeys.FinishRegistration(ctx, session.MemberID, req.Ceremony, req.Name, req.Credential)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired registration"})
+			return
+		}
+		writeMFAError(c, err)
+		return
+	}
+
+	if !session.MFASatisfied {
+		if err := h.sessionManager.MarkMFASatisfied(ctx, token, core.AuthMethodPasskey); err != nil {
+			log.Printf("Warning: Failed to mark session MFA satisfied for member %s: %v", session.MemberID, err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, passkeyResponse{
+		ID:           credential.ID,
+		Name:         credential.Name,
+		Transports:   credential.Transports,
+		CloneWarning: credential.CloneWarning,
+		LastUsedAt:   credential.LastUsedAt,
+		CreatedAt:    credential.CreatedAt,
+	})
+}
+
+// PasskeyLoginBegin godoc
+// @Summary Start passkey login
+// @Description Returns options for navigator.credentials.get to sign in with a passkey without entering an email address
+// @Tags passkeys
+// @Accept  json
+// @Produce  json
+// @Param request body passkeyLoginBeginRequest false "Optional return_to"
+// @Success 200 {object} passkeyCeremonyResponse
+// @Router /auth/webauthn/login/begin [post]
+func (h *AuthHandler) PasskeyLoginBegin(c *gin.Context) {
+	if h.passkeys == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	var req passkeyLoginBeginRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	options, ceremony, err := h.passkeys.BeginLogin(c.Request.Context(), req.ReturnTo, c.Request.Host)
+	if err != nil {
+		log.Printf("Warning: Failed to start passkey login: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start passkey login"})
+		return
+	}
+	c.JSON(http.StatusOK, passkeyCeremonyResponse{Ceremony: ceremony, Options: options})
+}
+
+// PasskeyLoginFinish godoc
+// @Summary Finish passkey login
+// @Description Verify the passkey assertion (user verification required) and sign in the member with mfa_satisfied set. Responds with the URL to continue to instead of redirecting, since it is called from script.
+// @Tags passkeys
+// @Accept  json
+// @Produce  json
+// @Param request body passkeyLoginFinishRequest true "Ceremony token and the PublicKeyCredential from navigator.credentials.get"
+// @Success 200 {object} passkeyRedirectResponse
+// @Failure 400 {object} map[string]string "Invalid assertion"
+// @Failure 403 {object} map[string]string "Passkey refused"
+// @Router /auth/webauthn/login/finish [post]
+func (h *AuthHandler) PasskeyLoginFinish(c *gin.Context) {
+	ctx := c.Request.Context()
+	if h.passkeys == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	var req passkeyLoginFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	login, err := h.passkeys.FinishLogin(ctx, req.Ceremony, req.Credential)
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	if login.Host != c.Request.Host {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Passkey login was started on a different host"})
+		return
+	}
+
+	member, err := h.memberService.GetByID(ctx, login.MemberID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No account"})
+		return
+	}
+
+	if !h.issueSession(c, member, []string{core.AuthMethodPasskey.String()}, true) {
+		return
+	}
+	c.JSON(http.StatusOK, passkeyRedirectResponse{RedirectTo: h.resolveReturnTo(ctx, login.ReturnTo, member)})
+}
+
+// PasskeyMFABegin godoc
+// @Summary Start passkey second factor
+// @Description Returns options for navigator.credentials.get limited to the passkeys of the member behind a pending MFA challenge
+// @Tags passkeys
+// @Accept  json
+// @Produce  json
+// @Param request body passkeyMFABeginRequest true "MFA challenge token"
+// @Success 200 {object} passkeyCeremonyResponse
+// @Failure 400 {object} map[string]string "Invalid challenge or no passkeys"
+// @Router /auth/webauthn/mfa/begin [post]
+func (h *AuthHandler) PasskeyMFABegin(c *gin.Context) {
+	ctx := c.Request.Context()
+	if h.passkeys == nil || h.mfa == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	var req passkeyMFABeginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := h.mfa.PendingChallenge(ctx, req.Challenge)
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	options, ceremony, err := h.passkeys.BeginSecondFactor(ctx, challenge.MemberID)
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, passkeyCeremonyResponse{Ceremony: ceremony, Options: options})
+}
+
+// PasskeyMFAFinish godoc
+// @Summary Finish passkey second factor
+// @Description Verify the passkey assertion for a pending MFA challenge and sign in the member with mfa_satisfied set
+// @Tags passkeys
+// @Accept  json
+// @Produce  json
+// @Param request body passkeyMFAFinishRequest true "MFA challenge, ceremony token and the PublicKeyCredential from navigator.credentials.get"
+// @Success 200 {object} passkeyRedirectResponse
+// @Failure 400 {object} map[string]string "Invalid challenge or assertion"
+// @Failure 403 {object} map[string]string "Passkey refused"
+// @Router /auth/webauthn/mfa/finish [post]
+func (h *AuthHandler) PasskeyMFAFinish(c *gin.Context) {
+	ctx := c.Request.Context()
+	if h.passkeys == nil || h.mfa == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Passkeys are not enabled"})
+		return
+	}
+
+	var req passkeyMFAFinishRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	challenge, err := h.mfa.PendingChallenge(ctx, req.Challenge)
+	if err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+	if challenge.Host != c.Request.Host {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "MFA challenge was issued for a different host"})
+		return
+	}
+
+	if err := h.passkeys.FinishSecondFactor(ctx, challenge.MemberID, req.Ceremony, req.Credential); err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	// Resolving can only fail if the challenge expired or was used meanwhile.
+	if _, err := h.mfa.ResolveChallenge(ctx, req.Challenge); err != nil {
+		writePasskeyError(c, err)
+		return
+	}
+
+	member, err := h.memberService.GetByID(ctx, challenge.MemberID)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No account"})
+		return
+	}
+
+	if !h.issueSession(c, member, core.AppendAuthMethod(challenge.AuthMethods, core.AuthMethodPasskey), true) {
+		return
+	}
+	c.JSON(http.StatusOK, passkeyRedirectResponse{RedirectTo: h.resolveReturnTo(ctx, challenge.ReturnTo, member)})
+}
+
+func writePasskeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, core.ErrInvalidToken):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired passkey request"})
+	case errors.Is(err, core.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "This passkey can no longer be used"})
+	case errors.Is(err, core.ErrBadRequest):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Warning: Passkey request failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Passkey request failed"})
+	}
+}
@@ -0,0 +1,58 @@
+package public
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/vondr/identity-go/internal/api/types"
+)
+
+// fakeMFAService reports every member as not enrolled, so any session may add
+// a first factor.
+type fakeMFAService struct {
+	types.MFAService
+}
+
+func (fakeMFAService) Status(context.Context, string) (*types.MFAStatus, error) {
+	return &types.MFAStatus{}, nil
+}
+
+// fakeWebAuthnService records the members for which registration started.
+type fakeWebAuthnService struct {
+	types.WebAuthnService
+
+	registering []string
+}
+
+func (s *fakeWebAuthnService) BeginRegistration(_ context.Context, memberID string) (json.RawMessage, string, error) {
+	s.registering = append(s.registering, memberID)
+	return json.RawMessage(`{}`), "ceremony", nil
+}
+
+func TestPasskeyRegisterBeginRequiresBrowserSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		wantStatus int
+	}{
+		{name: "browser session", token: testBrowserToken, wantStatus: http.StatusOK},
+		{name: "derived access token", token: testDerivedToken, wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			passkeys := &fakeWebAuthnService{}
+			h := &AuthHandler{sessionManager: &fakeSessionManager{}, mfa: fakeMFAService{}, passkeys: passkeys}
+
+			recorder := serveWithSession(h.PasskeyRegisterBegin, http.MethodPost, "/auth/webauthn/register/begin", tt.token)
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if started := len(passkeys.registering) > 0; started != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("registration started = %v", started)
+			}
+		})
+	}
+}
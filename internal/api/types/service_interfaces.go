@@ -2,15 +2,46 @@ package types
 
 import (
 	"context"
+	"encoding/json"
+	"slices"
 	"time"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/oauth"
+	"github.com/vondr/identity-go/internal/core/saml"
 )
 
 type SessionManager interface {
-	CreateSession(ctx context.Context, memberID, email, orgID, microsoftID string) (string, error)
+	CreateSession(ctx context.Context, memberID, email, orgID, microsoftID string, authMethods []string, mfaSatisfied bool, client SessionClient) (string, time.Time, error)
 	GetSession(ctx context.Context, token string) (*SessionData, error)
+	VerifySession(ctx context.Context, token string, client SessionClient) (*SessionData, error)
+	MarkMFASatisfied(ctx context.Context, token string, method core.AuthMethod) error
 	DeleteSession(ctx context.Context, token string) error
+	DeleteAllSessionsForMember(ctx context.Context, memberID string) (int, error)
+	ListSessions(ctx context.Context, memberID, currentToken string) ([]MemberSession, error)
+	RevokeSession(ctx context.Context, memberID, sessionID string) error
+}
+
+// SessionRevocationService ends sessions on behalf of an administrator.
+type SessionRevocationService interface {
+	ListMemberSessions(ctx context.Context, orgID, memberID string) ([]MemberSession, error)
+	RevokeMemberSession(ctx context.Context, orgID, memberID, sessionID string) error
+	RevokeMemberSessions(ctx context.Context, orgID, memberID string) (int, error)
+	RevokeOrganizationSessions(ctx context.Context, orgID string) (int, error)
+}
+
+// SessionPolicyService manages an organization's override of the global
+// session idle timeout and lifetime.
+type SessionPolicyService interface {
+	GetOrganizationPolicy(ctx context.Context, orgID string) (*SessionPolicy, error)
+	SetOrganizationPolicy(ctx context.Context, orgID string, idleTimeoutMinutes, lifetimeDays *int) (*SessionPolicy, error)
+}
+
+// SessionClient is the client a session is used from.
+type SessionClient struct {
+	UserAgent string
+	IP        string
 }
 
 type SessionData struct {
@@ -18,14 +49,89 @@ type SessionData struct {
 	Email          string
 	OrganizationID string
 	MicrosoftID    string
+	MFASatisfied   bool
+	AuthTime       time.Time
+	AuthMethods    []string
+	ClientID       string
+	FamilyID       string
+	Audience       string
+	UserAgent      string
+	Device         string
+	Browser        string
+	IP             string
+	Country        string
+	CreatedAt      time.Time
+	LastSeenAt     time.Time
+	ExpiresAt      time.Time
+}
+
+// SessionPolicy holds an organization's session lifetime overrides, nil when
+// the global default applies, and the lifetimes in effect.
+type SessionPolicy struct {
+	IdleTimeoutMinutes   *int
+	LifetimeDays         *int
+	EffectiveIdleTimeout time.Duration
+	EffectiveLifetime    time.Duration
+}
+
+// MemberSession is an entry in a member's session list. Clients holding
+// refresh tokens are listed once, by the ID of their refresh token family.
+type MemberSession struct {
+	ID         string
+	Kind       string
+	ClientID   string
+	UserAgent  string
+	Device     string
+	Browser    string
+	IP         string
+	Country    string
+	Current    bool
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+}
+
+type LoginTransactionStore interface {
+	Save(ctx context.Context, state string, transaction *LoginTransaction) error
+	Consume(ctx context.Context, state string) (*LoginTransaction, error)
+}
+
+type LoginTransaction struct {
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	ReturnTo     string
+	Host         string
+	LinkMemberID string
+	Binding      string
 }
 
 type MemberService interface {
-	GetByMicrosoftID(ctx context.Context, microsoftID string) (*Member, error)
 	GetByEmail(ctx context.Context, email string) (*Member, error)
 	GetByID(ctx context.Context, memberID string) (*Member, error)
-	LinkMicrosoftAccount(ctx context.Context, email, microsoftID, firstName, lastName string) (*Member, error)
-	CreateSystemMember(ctx context.Context, orgID, orgName, email, microsoftID, firstName, lastName string) (*Member, error)
+	GetByExternalAccount(ctx context.Context, account ExternalAccount) (*Member, error)
+	LinkAccountByEmail(ctx context.Context, email string, account ExternalAccount, firstName, lastName string) (*Member, error)
+	LinkAccount(ctx context.Context, memberID string, account ExternalAccount) (*MemberIdentity, error)
+	ListIdentities(ctx context.Context, memberID string) ([]*MemberIdentity, error)
+	UnlinkIdentity(ctx context.Context, memberID, identityID string) error
+	CreateSystemMember(ctx context.Context, orgID, orgName, email string, account *ExternalAccount, firstName, lastName string) (*Member, error)
+}
+
+// ExternalAccount identifies an account at an upstream identity provider.
+type ExternalAccount struct {
+	Provider string
+	Issuer   string
+	Subject  string
+}
+
+// MemberIdentity is an external account linked to a member.
+type MemberIdentity struct {
+	ID         string
+	Provider   string
+	Issuer     string
+	Subject    string
+	LinkedAt   time.Time
+	LastUsedAt *time.Time
 }
 
 type OrganizationService interface {
@@ -37,6 +143,34 @@ type AppService interface {
 	GetByID(ctx context.Context, appID string) (*App, error)
 	GetAllowedDomainsForOrg(ctx context.Context, orgID, hostname string) ([]string, error)
 	GetDomainAppMap(ctx context.Context, orgID, hostname string) (map[string]*App, error)
+	ListByOrganization(ctx context.Context, orgID string) ([]*App, error)
+}
+
+type UserGroupService interface {
+	ListGroupsForMember(ctx context.Context, memberID string) ([]*UserGroup, error)
+}
+
+// AppAssuranceService manages the step-up policy of an organization's apps.
+type AppAssuranceService interface {
+	GetForOrganization(ctx context.Context, orgID, appID string) (*App, error)
+	SetAssurancePolicy(ctx context.Context, orgID, appID string, policy AppAssurancePolicy) (*App, error)
+}
+
+// AppOIDCClientService registers an organization's apps as OpenID Connect
+// clients of the built-in provider.
+type AppOIDCClientService interface {
+	GetForOrganization(ctx context.Context, orgID, appID string) (*App, error)
+	SetOIDCClient(ctx context.Context, orgID, appID string, redirectURIs []string) (*App, error)
+	RotateOIDCClientSecret(ctx context.Context, orgID, appID string) (*App, string, error)
+}
+
+// AppClientCredentialsService registers an organization's apps for the
+// client credentials grant.
+type AppClientCredentialsService interface {
+	GetForOrganization(ctx context.Context, orgID, appID string) (*App, error)
+	SetClientCredentials(ctx context.Context, orgID, appID string, scopes []string, jwks *string) (*App, error)
+	RotateClientSecret(ctx context.Context, orgID, appID string) (*App, string, error)
+	DisableClientCredentials(ctx context.Context, orgID, appID string) (*App, error)
 }
 
 type AppAllowedCountryService interface {
@@ -48,8 +182,141 @@ type GeoIPService interface {
 	IsEnabled() bool
 }
 
+type IdentityProviderService interface {
+	List(ctx context.Context, orgID string) ([]*IdentityProviderConfig, error)
+	Get(ctx context.Context, orgID, providerID string) (*IdentityProviderConfig, error)
+	Create(ctx context.Context, orgID string, input *IdentityProviderInput) (*IdentityProviderConfig, error)
+	Update(ctx context.Context, orgID, providerID string, input *IdentityProviderInput) (*IdentityProviderConfig, error)
+	Delete(ctx context.Context, orgID, providerID string) error
+}
+
+// IdentityProviderResolver looks up organization-configured login providers
+// by slug and returns the provider along with the owning organization ID.
+type IdentityProviderResolver interface {
+	Resolve(ctx context.Context, slug string) (oauth.IdentityProvider, string, error)
+}
+
+type SAMLConnectionService interface {
+	List(ctx context.Context, orgID string) ([]*SAMLConnection, error)
+	Get(ctx context.Context, orgID, connectionID string) (*SAMLConnection, error)
+	Create(ctx context.Context, orgID string, input *SAMLConnectionInput) (*SAMLConnection, error)
+	Update(ctx context.Context, orgID, connectionID string, input *SAMLConnectionInput) (*SAMLConnection, error)
+	Delete(ctx context.Context, orgID, connectionID string) error
+}
+
+// SAMLServiceProviderResolver looks up organization SAML connections by slug
+// and returns the service provider along with the owning organization ID.
+type SAMLServiceProviderResolver interface {
+	Resolve(ctx context.Context, slug string) (*saml.ServiceProvider, string, error)
+}
+
+type OrganizationDomainService interface {
+	List(ctx context.Context, orgID string) ([]*OrganizationDomain, error)
+	Create(ctx context.Context, orgID, domain string) (*OrganizationDomain, error)
+	Verify(ctx context.Context, orgID, domainID string) (*OrganizationDomain, error)
+	Delete(ctx context.Context, orgID, domainID string) error
+}
+
+// HomeRealmResolver finds the organization and login provider for the
+// verified domain of an email address.
+type HomeRealmResolver interface {
+	Discover(ctx context.Context, emailOrDomain string) (*HomeRealm, error)
+}
+
+// MagicLinkService issues and redeems single-use email login links.
+type MagicLinkService interface {
+	Start(ctx context.Context, email, returnTo, binding string) error
+	Verify(ctx context.Context, token string) (*MagicLink, error)
+}
+
+// MFAService manages a member's TOTP authenticator, login challenges and
+// recovery codes.
+type MFAService interface {
+	Status(ctx context.Context, memberID string) (*MFAStatus, error)
+	BeginChallenge(ctx context.Context, memberID, returnTo, host string, authMethods []string) (string, error)
+	CompleteChallenge(ctx context.Context, token, code string) (*MFAChallenge, error)
+	PendingChallenge(ctx context.Context, token string) (*MFAChallenge, error)
+	ResolveChallenge(ctx context.Context, token string) (*MFAChallenge, error)
+	Enroll(ctx context.Context, memberID string) (secret string, uri string, err error)
+	ConfirmEnrollment(ctx context.Context, memberID, code string) ([]string, error)
+	RegenerateRecoveryCodes(ctx context.Context, memberID string) ([]string, error)
+	Disable(ctx context.Context, memberID string) error
+}
+
+// DeviceAuthorizationService implements the RFC 8628 device authorization
+// grant. Approved clients receive an access token bound to the approving
+// member and a refresh token.
+type DeviceAuthorizationService interface {
+	VerificationURI() string
+	Start(ctx context.Context, clientID string) (*DeviceAuthorizationGrant, error)
+	Lookup(ctx context.Context, userCode string) (*DeviceAuthorizationRequest, error)
+	Approve(ctx context.Context, userCode, sessionToken string) error
+	Deny(ctx context.Context, userCode string) error
+	Poll(ctx context.Context, clientID, deviceCode string) (*DeviceAccessToken, error)
+	Refresh(ctx context.Context, clientID, refreshToken string) (*DeviceAccessToken, error)
+}
+
+// OIDCProviderService is the built-in OpenID Connect provider. Apps are its
+// clients and the member's session is the single sign-on session.
+type OIDCProviderService interface {
+	Issuer() string
+	GetClient(ctx context.Context, clientID, redirectURI string) (*App, error)
+	Authorize(ctx context.Context, request OIDCAuthorizationRequest, sessionToken string) (string, error)
+	Exchange(ctx context.Context, request OIDCTokenRequest) (*OIDCTokens, error)
+	Refresh(ctx context.Context, request OIDCRefreshRequest) (*OIDCTokens, error)
+	UserInfo(ctx context.Context, accessToken string) (*OIDCUserInfo, error)
+}
+
+// ClientCredentialsService issues access tokens to apps authenticating as
+// confidential clients, and validates them for forward auth.
+type ClientCredentialsService interface {
+	Issue(ctx context.Context, request ClientCredentialsRequest) (*ClientCredentialsToken, error)
+	Validate(ctx context.Context, accessToken string) (*ClientAccessToken, error)
+}
+
+// TokenService answers introspection (RFC 7662) and revocation (RFC 7009)
+// requests from apps for tokens of their organization.
+type TokenService interface {
+	Introspect(ctx context.Context, caller ClientAuthentication, token string) (*TokenIntrospection, error)
+	Revoke(ctx context.Context, caller ClientAuthentication, token string) error
+}
+
+// ForwardAuthTokenIssuer signs the identity token forward auth passes to
+// upstreams alongside the x-vondr-* headers.
+type ForwardAuthTokenIssuer interface {
+	Issue(ctx context.Context, identity ForwardAuthIdentity) (string, error)
+}
+
+// SigningKeyService publishes the public keys of the keys identity-go signs
+// tokens with.
+type SigningKeyService interface {
+	KeySet(ctx context.Context) (jose.JSONWebKeySet, error)
+}
+
+// WebAuthnService runs passkey ceremonies. Options are the JSON the browser
+// passes to navigator.credentials, and responses the JSON serialized
+// PublicKeyCredential it returns.
+type WebAuthnService interface {
+	List(ctx context.Context, memberID string) ([]*WebAuthnCredential, error)
+	Delete(ctx context.Context, memberID, credentialID string) error
+	BeginRegistration(ctx context.Context, memberID string) (json.RawMessage, string, error)
+	FinishRegistration(ctx context.Context, memberID, ceremony, name string, response []byte) (*WebAuthnCredential, error)
+	BeginLogin(ctx context.Context, returnTo, host string) (json.RawMessage, string, error)
+	FinishLogin(ctx context.Context, ceremony string, response []byte) (*PasskeyLogin, error)
+	BeginSecondFactor(ctx context.Context, memberID string) (json.RawMessage, string, error)
+	FinishSecondFactor(ctx context.Context, memberID, ceremony string, response []byte) error
+}
+
+// MFAPolicyService is the administrative side of MFA: the organization policy
+// and resetting a member's lost authenticator.
+type MFAPolicyService interface {
+	GetOrganizationPolicy(ctx context.Context, orgID string) (bool, error)
+	SetOrganizationPolicy(ctx context.Context, orgID string, required bool) error
+	ResetMember(ctx context.Context, orgID, memberID string) error
+}
+
 type SessionService interface {
-	RecordLogin(ctx context.Context, microsoftID, email, orgID string) error
+	RecordLogin(ctx context.Context, memberID, email, orgID, microsoftID string) error
 	GetLastLoginForMember(ctx context.Context, memberID string) (*time.Time, error)
 	GetLastLoginsBatch(ctx context.Context, memberIDs []string) (map[string]*time.Time, error)
 }
@@ -59,15 +326,21 @@ type Member struct {
 	Email          string
 	FirstName      *string
 	LastName       *string
-	MicrosoftID    *string
 	OrganizationID string
 	Role           core.MemberRole
 }
 
 type Organization struct {
-	ID       string
-	Name     string
-	Hostname string
+	ID          string
+	Name        string
+	Hostname    string
+	MFARequired bool
+}
+
+type UserGroup struct {
+	ID          string
+	Name        string
+	Description *string
 }
 
 type App struct {
@@ -77,4 +350,276 @@ type App struct {
 	SubdomainLabels []string
 	MainLabel       string
 	IsPlatformApp   bool
+	Assurance       AppAssurancePolicy
+	OIDC            AppOIDCClient
+	Client          AppClientCredentials
+}
+
+// AppOIDCClient is an app's registration as an OpenID Connect client. Apps
+// without redirect URIs are not clients; apps without a secret are public
+// clients and must use PKCE.
+type AppOIDCClient struct {
+	RedirectURIs []string
+	Confidential bool
+}
+
+// AppClientCredentials is an app's registration for the client credentials
+// grant. Apps with neither a secret nor keys cannot use it.
+type AppClientCredentials struct {
+	Scopes    []string
+	HasSecret bool
+	JWKS      *string
+}
+
+// AppAssurancePolicy is how strongly a session must be authenticated to reach
+// an app. Zero values impose no requirement.
+type AppAssurancePolicy struct {
+	RequiredAuthMethod core.AuthMethod
+	RequireMFA         bool
+	MaxAuthAge         time.Duration
+}
+
+// Requirements of an app assurance policy a session can fall short of.
+const (
+	AssuranceAuthMethod = "auth_method"
+	AssuranceMFA        = "mfa"
+	AssuranceMaxAuthAge = "max_auth_age"
+)
+
+// Unmet lists the requirements of the policy that the session does not
+// satisfy at now. Sessions created before auth times were recorded never
+// satisfy a maximum age.
+func (p AppAssurancePolicy) Unmet(session *SessionData, now time.Time) []string {
+	var unmet []string
+	if p.RequiredAuthMethod != "" && !slices.Contains(session.AuthMethods, p.RequiredAuthMethod.String()) {
+		unmet = append(unmet, AssuranceAuthMethod)
+	}
+	if p.RequireMFA && !session.MFASatisfied {
+		unmet = append(unmet, AssuranceMFA)
+	}
+	if p.MaxAuthAge > 0 && (session.AuthTime.Unix() <= 0 || now.Sub(session.AuthTime) > p.MaxAuthAge) {
+		unmet = append(unmet, AssuranceMaxAuthAge)
+	}
+	return unmet
+}
+
+type IdentityProviderConfig struct {
+	ID               string
+	OrganizationID   string
+	Slug             string
+	Type             core.IdentityProviderType
+	Issuer           string
+	ClientID         string
+	AllowedTenantIDs []string
+	ClaimMappings    map[string]string
+	Enabled          bool
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+type IdentityProviderInput struct {
+	Slug             string
+	Type             core.IdentityProviderType
+	Issuer           string
+	ClientID         string
+	ClientSecret     *string
+	AllowedTenantIDs []string
+	ClaimMappings    map[string]string
+	Enabled          bool
+}
+
+type SAMLConnection struct {
+	ID                string
+	OrganizationID    string
+	Slug              string
+	IDPEntityID       string
+	AttributeMappings map[string]string
+	Enabled           bool
+	SPEntityID        string
+	SPACSURL          string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+}
+
+type SAMLConnectionInput struct {
+	Slug              string
+	MetadataXML       string
+	MetadataURL       string
+	AttributeMappings map[string]string
+	Enabled           bool
+}
+
+type OrganizationDomain struct {
+	ID                string
+	OrganizationID    string
+	Domain            string
+	VerificationName  string
+	VerificationValue string
+	VerifiedAt        *time.Time
+	CreatedAt         time.Time
+}
+
+// HomeRealm is the result of home realm discovery. ProviderSlug is empty when
+// the built-in Microsoft provider should be used.
+type HomeRealm struct {
+	OrganizationID string
+	Domain         string
+	ProviderSlug   string
+	SAML           bool
+}
+
+type MagicLink struct {
+	Email    string
+	ReturnTo string
+	Binding  string
+}
+
+type MFAStatus struct {
+	Enrolled               bool
+	Required               bool
+	TOTP                   bool
+	Passkeys               int
+	RecoveryCodesRemaining int
+}
+
+type MFAChallenge struct {
+	MemberID    string
+	ReturnTo    string
+	Host        string
+	AuthMethods []string
+}
+
+type WebAuthnCredential struct {
+	ID           string
+	Name         string
+	Transports   []string
+	CloneWarning bool
+	LastUsedAt   *time.Time
+	CreatedAt    time.Time
+}
+
+type PasskeyLogin struct {
+	MemberID string
+	ReturnTo string
+	Host     string
+}
+
+type DeviceAuthorizationGrant struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               int
+	Interval                int
+}
+
+type DeviceAuthorizationRequest struct {
+	ClientID string
+	UserCode string
+}
+
+type DeviceAccessToken struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int
+}
+
+type OIDCAuthorizationRequest struct {
+	ClientID      string
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	CodeChallenge string
+}
+
+type OIDCTokenRequest struct {
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+type OIDCRefreshRequest struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+}
+
+type OIDCTokens struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Scope        string
+	ExpiresIn    int
+}
+
+type OIDCUserInfo struct {
+	Subject        string
+	Email          string
+	EmailVerified  *bool
+	Name           string
+	GivenName      string
+	FamilyName     string
+	OrganizationID string
+}
+
+// ClientAuthentication is how an app authenticates as a confidential
+// client: a client secret or a private_key_jwt client assertion.
+type ClientAuthentication struct {
+	ClientID            string
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+}
+
+type ClientCredentialsRequest struct {
+	ClientAuthentication
+	Scope string
+}
+
+type ClientCredentialsToken struct {
+	AccessToken string
+	Scope       string
+	ExpiresIn   int
+}
+
+// ClientAccessToken is an access token issued to an app with the client
+// credentials grant.
+type ClientAccessToken struct {
+	ClientID       string
+	OrganizationID string
+	Scope          string
+	IssuedAt       time.Time
+	ExpiresAt      time.Time
+}
+
+// TokenIntrospection describes a token to the app that asked about it.
+// Inactive tokens only have Active unset.
+type TokenIntrospection struct {
+	Active         bool
+	Issuer         string
+	Subject        string
+	Username       string
+	ClientID       string
+	OrganizationID string
+	Scope          string
+	IssuedAt       time.Time
+	ExpiresAt      time.Time
+}
+
+// ForwardAuthIdentity is the member or client forward auth let through.
+// Clients using the client credentials grant have a ClientID and Scope
+// instead of a MemberID. Host is the forwarded host and becomes the token
+// audience.
+type ForwardAuthIdentity struct {
+	MemberID       string
+	ClientID       string
+	Email          string
+	OrganizationID string
+	Role           string
+	Groups         []string
+	Scope          string
+	AppID          string
+	Host           string
 }
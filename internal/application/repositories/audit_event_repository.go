@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+)
+
+type AuditEventRepository interface {
+	Create(ctx context.Context, event *models.AuditEvent) error
+}
+
+type GormAuditEventRepository struct {
+	db *gorm.DB
+}
+
+func NewGormAuditEventRepository(db *gorm.DB) *GormAuditEventRepository {
+	return &GormAuditEventRepository{db: db}
+}
+
+func (r *GormAuditEventRepository) Create(ctx context.Context, event *models.AuditEvent) error {
+	return r.db.WithContext(ctx).Create(event).Error
+}
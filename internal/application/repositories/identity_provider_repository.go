@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+)
+
+type IdentityProviderRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.IdentityProvider, error)
+	GetBySlug(ctx context.Context, slug string) (*models.IdentityProvider, error)
+	ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.IdentityProvider, error)
+	Create(ctx context.Context, provider *models.IdentityProvider) error
+	Update(ctx context.Context, provider *models.IdentityProvider) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type GormIdentityProviderRepository struct {
+	db *gorm.DB
+}
+
+func NewGormIdentityProviderRepository(db *gorm.DB) *GormIdentityProviderRepository {
+	return &GormIdentityProviderRepository{db: db}
+}
+
+func (r *GormIdentityProviderRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.IdentityProvider, error) {
+	var provider models.IdentityProvider
+	err := r.db.WithContext(ctx).First(&provider, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &provider, nil
+}
+
+func (r *GormIdentityProviderRepository) GetBySlug(ctx context.Context, slug string) (*models.IdentityProvider, error) {
+	var provider models.IdentityProvider
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&provider).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &provider, nil
+}
+
+func (r *GormIdentityProviderRepository) ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.IdentityProvider, error) {
+	var providers []*models.IdentityProvider
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("slug").
+		Find(&providers).Error
+	if err != nil {
+		return nil, err
+	}
+	return providers, nil
+}
+
+func (r *GormIdentityProviderRepository) Create(ctx context.Context, provider *models.IdentityProvider) error {
+	return r.db.WithContext(ctx).Create(provider).Error
+}
+
+func (r *GormIdentityProviderRepository) Update(ctx context.Context, provider *models.IdentityProvider) error {
+	return r.db.WithContext(ctx).Save(provider).Error
+}
+
+func (r *GormIdentityProviderRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.IdentityProvider{}, "id = ?", id).Error
+}
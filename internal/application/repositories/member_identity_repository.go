@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+)
+
+type MemberIdentityRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.MemberIdentity, error)
+	GetByAccount(ctx context.Context, provider, issuer, subject string) (*models.MemberIdentity, error)
+	ListByMemberID(ctx context.Context, memberID uuid.UUID) ([]*models.MemberIdentity, error)
+	CountByMemberID(ctx context.Context, memberID uuid.UUID) (int64, error)
+	Create(ctx context.Context, identity *models.MemberIdentity) error
+	Update(ctx context.Context, identity *models.MemberIdentity) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type GormMemberIdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewGormMemberIdentityRepository(db *gorm.DB) *GormMemberIdentityRepository {
+	return &GormMemberIdentityRepository{db: db}
+}
+
+func (r *GormMemberIdentityRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.MemberIdentity, error) {
+	var identity models.MemberIdentity
+	err := r.db.WithContext(ctx).First(&identity, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *GormMemberIdentityRepository) GetByAccount(ctx context.Context, provider, issuer, subject string) (*models.MemberIdentity, error) {
+	var identity models.MemberIdentity
+	err := r.db.WithContext(ctx).
+		Where("provider = ? AND issuer = ? AND subject = ?", provider, issuer, subject).
+		First(&identity).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *GormMemberIdentityRepository) ListByMemberID(ctx context.Context, memberID uuid.UUID) ([]*models.MemberIdentity, error) {
+	var identities []*models.MemberIdentity
+	err := r.db.WithContext(ctx).Where("member_id = ?", memberID).Order("linked_at").Find(&identities).Error
+	return identities, err
+}
+
+func (r *GormMemberIdentityRepository) CountByMemberID(ctx context.Context, memberID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.MemberIdentity{}).Where("member_id = ?", memberID).Count(&count).Error
+	return count, err
+}
+
+func (r *GormMemberIdentityRepository) Create(ctx context.Context, identity *models.MemberIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *GormMemberIdentityRepository) Update(ctx context.Context, identity *models.MemberIdentity) error {
+	return r.db.WithContext(ctx).Save(identity).Error
+}
+
+func (r *GormMemberIdentityRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.MemberIdentity{}, "id = ?", id).Error
+}
@@ -12,10 +12,10 @@ import (
 type MemberRepository interface {
 	GetByID(ctx context.Context, id uuid.UUID) (*models.OrganizationMember, error)
 	GetByEmail(ctx context.Context, email string) (*models.OrganizationMember, error)
-	GetByMicrosoftID(ctx context.Context, microsoftID string) (*models.OrganizationMember, error)
 	ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.OrganizationMember, error)
 	Create(ctx context.Context, member *models.OrganizationMember) error
 	Update(ctx context.Context, member *models.OrganizationMember) error
+	AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error)
 	Delete(ctx context.Context, id uuid.UUID) error
 }
 
@@ -51,18 +51,6 @@ func (r *GormMemberRepository) GetByEmail(ctx context.Context, email string) (*m
 	return &member, nil
 }
 
-func (r *GormMemberRepository) GetByMicrosoftID(ctx context.Context, microsoftID string) (*models.OrganizationMember, error) {
-	var member models.OrganizationMember
-	err := r.db.WithContext(ctx).Where("microsoft_id = ?", microsoftID).First(&member).Error
-	if err != nil {
-		if err == gorm.ErrRecordNotFound {
-			return nil, core.ErrNotFound
-		}
-		return nil, err
-	}
-	return &member, nil
-}
-
 func (r *GormMemberRepository) ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.OrganizationMember, error) {
 	var members []*models.OrganizationMember
 	err := r.db.WithContext(ctx).Where("organization_id = ?", organizationID).Find(&members).Error
@@ -80,6 +68,15 @@ func (r *GormMemberRepository) Update(ctx context.Context, member *models.Organi
 	return r.db.WithContext(ctx).Save(member).Error
 }
 
+// AdvanceTOTPStep records step as the member's last used TOTP time step if it
+// is later than the stored one, and reports whether it was.
+func (r *GormMemberRepository) AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.OrganizationMember{}).
+		Where("id = ? AND totp_last_step < ?", id, step).
+		Update("totp_last_step", step)
+	return result.RowsAffected > 0, result.Error
+}
+
 func (r *GormMemberRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	return r.db.WithContext(ctx).Delete(&models.OrganizationMember{}, "id = ?", id).Error
 }
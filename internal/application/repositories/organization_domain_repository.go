@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+)
+
+type OrganizationDomainRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.OrganizationDomain, error)
+	GetByDomain(ctx context.Context, domain string) (*models.OrganizationDomain, error)
+	ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.OrganizationDomain, error)
+	Create(ctx context.Context, domain *models.OrganizationDomain) error
+	Update(ctx context.Context, domain *models.OrganizationDomain) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type GormOrganizationDomainRepository struct {
+	db *gorm.DB
+}
+
+func NewGormOrganizationDomainRepository(db *gorm.DB) *GormOrganizationDomainRepository {
+	return &GormOrganizationDomainRepository{db: db}
+}
+
+func (r *GormOrganizationDomainRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.OrganizationDomain, error) {
+	var domain models.OrganizationDomain
+	err := r.db.WithContext(ctx).First(&domain, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &domain, nil
+}
+
+func (r *GormOrganizationDomainRepository) GetByDomain(ctx context.Context, domain string) (*models.OrganizationDomain, error) {
+	var record models.OrganizationDomain
+	err := r.db.WithContext(ctx).Where("domain = ?", domain).First(&record).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *GormOrganizationDomainRepository) ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.OrganizationDomain, error) {
+	var domains []*models.OrganizationDomain
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("domain").
+		Find(&domains).Error
+	if err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+func (r *GormOrganizationDomainRepository) Create(ctx context.Context, domain *models.OrganizationDomain) error {
+	return r.db.WithContext(ctx).Create(domain).Error
+}
+
+func (r *GormOrganizationDomainRepository) Update(ctx context.Context, domain *models.OrganizationDomain) error {
+	return r.db.WithContext(ctx).Save(domain).Error
+}
+
+func (r *GormOrganizationDomainRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.OrganizationDomain{}, "id = ?", id).Error
+}
@@ -0,0 +1,113 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RefreshTokenRepository interface {
+	// GetByTokenHash returns the token with its family. Within Transaction
+	// the token row stays locked until the transaction ends.
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	GetFamilyByID(ctx context.Context, id uuid.UUID) (*models.RefreshTokenFamily, error)
+	// ListActiveFamiliesByMemberID returns the member's families that are
+	// neither revoked nor expired.
+	ListActiveFamiliesByMemberID(ctx context.Context, memberID uuid.UUID) ([]*models.RefreshTokenFamily, error)
+	CreateFamily(ctx context.Context, family *models.RefreshTokenFamily, token *models.RefreshToken) error
+	Create(ctx context.Context, token *models.RefreshToken) error
+	Update(ctx context.Context, token *models.RefreshToken) error
+	UpdateFamily(ctx context.Context, family *models.RefreshTokenFamily) error
+	// RevokeFamiliesByMemberID revokes the active families of the member and
+	// returns how many there were.
+	RevokeFamiliesByMemberID(ctx context.Context, memberID uuid.UUID, reason core.RefreshTokenRevocation) (int, error)
+	// Transaction runs fn in a transaction, so that a token presented twice
+	// at the same time is rotated only once.
+	Transaction(ctx context.Context, fn func(repo RefreshTokenRepository) error) error
+}
+
+type GormRefreshTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewGormRefreshTokenRepository(db *gorm.DB) *GormRefreshTokenRepository {
+	return &GormRefreshTokenRepository{db: db}
+}
+
+func (r *GormRefreshTokenRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Preload("Family").
+		Where("token_hash = ?", tokenHash).
+		First(&token).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *GormRefreshTokenRepository) GetFamilyByID(ctx context.Context, id uuid.UUID) (*models.RefreshTokenFamily, error) {
+	var family models.RefreshTokenFamily
+	err := r.db.WithContext(ctx).First(&family, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &family, nil
+}
+
+func (r *GormRefreshTokenRepository) ListActiveFamiliesByMemberID(ctx context.Context, memberID uuid.UUID) ([]*models.RefreshTokenFamily, error) {
+	var families []*models.RefreshTokenFamily
+	err := r.db.WithContext(ctx).
+		Where("member_id = ? AND revoked_at IS NULL AND expires_at > ?", memberID, time.Now()).
+		Order("created_at").
+		Find(&families).Error
+	return families, err
+}
+
+func (r *GormRefreshTokenRepository) CreateFamily(ctx context.Context, family *models.RefreshTokenFamily, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(family).Error; err != nil {
+			return err
+		}
+		token.FamilyID = family.ID
+		return tx.Create(token).Error
+	})
+}
+
+func (r *GormRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *GormRefreshTokenRepository) Update(ctx context.Context, token *models.RefreshToken) error {
+	return r.db.WithContext(ctx).Omit("Family").Save(token).Error
+}
+
+func (r *GormRefreshTokenRepository) UpdateFamily(ctx context.Context, family *models.RefreshTokenFamily) error {
+	return r.db.WithContext(ctx).Save(family).Error
+}
+
+func (r *GormRefreshTokenRepository) RevokeFamiliesByMemberID(ctx context.Context, memberID uuid.UUID, reason core.RefreshTokenRevocation) (int, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.RefreshTokenFamily{}).
+		Where("member_id = ? AND revoked_at IS NULL AND expires_at > ?", memberID, now).
+		Updates(map[string]interface{}{"revoked_at": now, "revoked_reason": reason.String()})
+	return int(result.RowsAffected), result.Error
+}
+
+func (r *GormRefreshTokenRepository) Transaction(ctx context.Context, fn func(repo RefreshTokenRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&GormRefreshTokenRepository{db: tx})
+	})
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+)
+
+type SAMLConnectionRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.SAMLConnection, error)
+	GetBySlug(ctx context.Context, slug string) (*models.SAMLConnection, error)
+	ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.SAMLConnection, error)
+	Create(ctx context.Context, connection *models.SAMLConnection) error
+	Update(ctx context.Context, connection *models.SAMLConnection) error
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+type GormSAMLConnectionRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSAMLConnectionRepository(db *gorm.DB) *GormSAMLConnectionRepository {
+	return &GormSAMLConnectionRepository{db: db}
+}
+
+func (r *GormSAMLConnectionRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.SAMLConnection, error) {
+	var connection models.SAMLConnection
+	err := r.db.WithContext(ctx).First(&connection, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &connection, nil
+}
+
+func (r *GormSAMLConnectionRepository) GetBySlug(ctx context.Context, slug string) (*models.SAMLConnection, error) {
+	var connection models.SAMLConnection
+	err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&connection).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &connection, nil
+}
+
+func (r *GormSAMLConnectionRepository) ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.SAMLConnection, error) {
+	var connections []*models.SAMLConnection
+	err := r.db.WithContext(ctx).
+		Where("organization_id = ?", organizationID).
+		Order("slug").
+		Find(&connections).Error
+	if err != nil {
+		return nil, err
+	}
+	return connections, nil
+}
+
+func (r *GormSAMLConnectionRepository) Create(ctx context.Context, connection *models.SAMLConnection) error {
+	return r.db.WithContext(ctx).Create(connection).Error
+}
+
+func (r *GormSAMLConnectionRepository) Update(ctx context.Context, connection *models.SAMLConnection) error {
+	return r.db.WithContext(ctx).Save(connection).Error
+}
+
+func (r *GormSAMLConnectionRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.SAMLConnection{}, "id = ?", id).Error
+}
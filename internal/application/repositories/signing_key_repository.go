@@ -0,0 +1,73 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+)
+
+type SigningKeyRepository interface {
+	GetByKeyID(ctx context.Context, keyID string) (*models.SigningKey, error)
+	List(ctx context.Context) ([]*models.SigningKey, error)
+	ListByStatus(ctx context.Context, statuses ...core.SigningKeyStatus) ([]*models.SigningKey, error)
+	Create(ctx context.Context, key *models.SigningKey) error
+	Update(ctx context.Context, key *models.SigningKey) error
+	// Transaction runs fn in a transaction holding an advisory lock, so that
+	// instances rotating keys at the same time do not both promote a key.
+	Transaction(ctx context.Context, fn func(repo SigningKeyRepository) error) error
+}
+
+type GormSigningKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewGormSigningKeyRepository(db *gorm.DB) *GormSigningKeyRepository {
+	return &GormSigningKeyRepository{db: db}
+}
+
+func (r *GormSigningKeyRepository) GetByKeyID(ctx context.Context, keyID string) (*models.SigningKey, error) {
+	var key models.SigningKey
+	err := r.db.WithContext(ctx).Where("key_id = ?", keyID).First(&key).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (r *GormSigningKeyRepository) List(ctx context.Context) ([]*models.SigningKey, error) {
+	var keys []*models.SigningKey
+	err := r.db.WithContext(ctx).Order("created_at").Find(&keys).Error
+	return keys, err
+}
+
+func (r *GormSigningKeyRepository) ListByStatus(ctx context.Context, statuses ...core.SigningKeyStatus) ([]*models.SigningKey, error) {
+	values := make([]string, len(statuses))
+	for i, status := range statuses {
+		values[i] = status.String()
+	}
+	var keys []*models.SigningKey
+	err := r.db.WithContext(ctx).Where("status IN ?", values).Order("created_at").Find(&keys).Error
+	return keys, err
+}
+
+func (r *GormSigningKeyRepository) Create(ctx context.Context, key *models.SigningKey) error {
+	return r.db.WithContext(ctx).Create(key).Error
+}
+
+func (r *GormSigningKeyRepository) Update(ctx context.Context, key *models.SigningKey) error {
+	return r.db.WithContext(ctx).Save(key).Error
+}
+
+func (r *GormSigningKeyRepository) Transaction(ctx context.Context, fn func(repo SigningKeyRepository) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext('signing_keys'))").Error; err != nil {
+			return err
+		}
+		return fn(&GormSigningKeyRepository{db: tx})
+	})
+}
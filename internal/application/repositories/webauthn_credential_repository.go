@@ -0,0 +1,81 @@
+package repositories
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"gorm.io/gorm"
+)
+
+type WebAuthnCredentialRepository interface {
+	GetByID(ctx context.Context, id uuid.UUID) (*models.WebAuthnCredential, error)
+	GetByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error)
+	ListByMemberID(ctx context.Context, memberID uuid.UUID) ([]*models.WebAuthnCredential, error)
+	CountByMemberID(ctx context.Context, memberID uuid.UUID) (int64, error)
+	Create(ctx context.Context, credential *models.WebAuthnCredential) error
+	Update(ctx context.Context, credential *models.WebAuthnCredential) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	DeleteByMemberID(ctx context.Context, memberID uuid.UUID) error
+}
+
+type GormWebAuthnCredentialRepository struct {
+	db *gorm.DB
+}
+
+func NewGormWebAuthnCredentialRepository(db *gorm.DB) *GormWebAuthnCredentialRepository {
+	return &GormWebAuthnCredentialRepository{db: db}
+}
+
+func (r *GormWebAuthnCredentialRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.WithContext(ctx).First(&credential, "id = ?", id).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *GormWebAuthnCredentialRepository) GetByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	var credential models.WebAuthnCredential
+	err := r.db.WithContext(ctx).Where("credential_id = ?", credentialID).First(&credential).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, core.ErrNotFound
+		}
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *GormWebAuthnCredentialRepository) ListByMemberID(ctx context.Context, memberID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	var credentials []*models.WebAuthnCredential
+	err := r.db.WithContext(ctx).Where("member_id = ?", memberID).Order("created_at").Find(&credentials).Error
+	return credentials, err
+}
+
+func (r *GormWebAuthnCredentialRepository) CountByMemberID(ctx context.Context, memberID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&models.WebAuthnCredential{}).Where("member_id = ?", memberID).Count(&count).Error
+	return count, err
+}
+
+func (r *GormWebAuthnCredentialRepository) Create(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Create(credential).Error
+}
+
+func (r *GormWebAuthnCredentialRepository) Update(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.db.WithContext(ctx).Save(credential).Error
+}
+
+func (r *GormWebAuthnCredentialRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.WebAuthnCredential{}, "id = ?", id).Error
+}
+
+func (r *GormWebAuthnCredentialRepository) DeleteByMemberID(ctx context.Context, memberID uuid.UUID) error {
+	return r.db.WithContext(ctx).Delete(&models.WebAuthnCredential{}, "member_id = ?", memberID).Error
+}
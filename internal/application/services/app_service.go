@@ -2,13 +2,36 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net/url"
 
+	"github.com/go-jose/go-jose/v4"
 	"github.com/google/uuid"
 	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
 	"github.com/vondr/identity-go/internal/infrastructure/database/models"
 )
 
+// AppAssuranceInput is the step-up policy enforced by forward auth for an app.
+type AppAssuranceInput struct {
+	RequiredAuthMethod core.AuthMethod
+	RequireMFA         bool
+	MaxAuthAgeSeconds  int
+}
+
+// AppClientCredentialsInput is an app's registration for the client
+// credentials grant. JWKS holds the public keys of private_key_jwt client
+// assertions; nil removes them.
+type AppClientCredentialsInput struct {
+	Scopes []string
+	JWKS   *string
+}
+
 type AppService struct {
 	appRepo repositories.AppRepository
 	orgRepo repositories.OrganizationRepository
@@ -45,6 +68,239 @@ func (s *AppService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.appRepo.Delete(ctx, id)
 }
 
+// GetForOrganization returns an app owned by the organization.
+func (s *AppService) GetForOrganization(ctx context.Context, organizationID, id uuid.UUID) (*models.App, error) {
+	app, err := s.appRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if app.OrganizationID != organizationID {
+		return nil, core.ErrNotFound
+	}
+	return app, nil
+}
+
+// SetAssurancePolicy replaces the step-up policy of an app owned by the
+// organization.
+func (s *AppService) SetAssurancePolicy(ctx context.Context, organizationID, id uuid.UUID, input AppAssuranceInput) (*models.App, error) {
+	if input.RequiredAuthMethod != "" && !input.RequiredAuthMethod.IsValid() {
+		return nil, fmt.Errorf("%w: unknown auth method %q", core.ErrBadRequest, input.RequiredAuthMethod)
+	}
+	if input.MaxAuthAgeSeconds < 0 {
+		return nil, fmt.Errorf("%w: max_auth_age_seconds must not be negative", core.ErrBadRequest)
+	}
+
+	app, err := s.GetForOrganization(ctx, organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	app.RequiredAuthMethod = nil
+	if input.RequiredAuthMethod != "" {
+		method := input.RequiredAuthMethod.String()
+		app.RequiredAuthMethod = &method
+	}
+	app.RequireMFA = input.RequireMFA
+	app.MaxAuthAgeSeconds = input.MaxAuthAgeSeconds
+
+	if err := s.appRepo.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// SetOIDCClient replaces the redirect URIs of an app owned by the
+// organization. An app with redirect URIs can sign members in as an OpenID
+// Connect client; an empty list unregisters it.
+func (s *AppService) SetOIDCClient(ctx context.Context, organizationID, id uuid.UUID, redirectURIs []string) (*models.App, error) {
+	for _, redirectURI := range redirectURIs {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			return nil, err
+		}
+	}
+
+	app, err := s.GetForOrganization(ctx, organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	app.OIDCRedirectURIs = redirectURIs
+	if len(redirectURIs) == 0 {
+		app.OIDCClientSecretHash = nil
+	}
+
+	if err := s.appRepo.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// RotateOIDCClientSecret issues a new client secret for an app registered as
+// an OpenID Connect client, making it a confidential client. Only a hash is
+// stored; the secret is returned once.
+func (s *AppService) RotateOIDCClientSecret(ctx context.Context, organizationID, id uuid.UUID) (*models.App, string, error) {
+	app, err := s.GetForOrganization(ctx, organizationID, id)
+	if err != nil {
+		return nil, "", err
+	}
+	if !app.IsOIDCClient() {
+		return nil, "", fmt.Errorf("%w: app has no redirect URIs", core.ErrBadRequest)
+	}
+
+	secret, err := generateClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	hash := hashClientSecret(secret)
+	app.OIDCClientSecretHash = &hash
+
+	if err := s.appRepo.Update(ctx, app); err != nil {
+		return nil, "", err
+	}
+	return app, secret, nil
+}
+
+// SetClientCredentials replaces the scopes an app may request with the
+// client credentials grant and the public keys it signs client assertions
+// with.
+func (s *AppService) SetClientCredentials(ctx context.Context, organizationID, id uuid.UUID, input AppClientCredentialsInput) (*models.App, error) {
+	for _, scope := range input.Scopes {
+		if err := validateScope(scope); err != nil {
+			return nil, err
+		}
+	}
+	var jwks *string
+	if input.JWKS != nil {
+		normalized, err := normalizeClientJWKS(*input.JWKS)
+		if err != nil {
+			return nil, err
+		}
+		jwks = &normalized
+	}
+
+	app, err := s.GetForOrganization(ctx, organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	app.ClientScopes = input.Scopes
+	app.ClientJWKS = jwks
+
+	if err := s.appRepo.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// RotateClientSecret issues a new secret for the client credentials grant.
+// Only a hash is stored; the secret is returned once.
+func (s *AppService) RotateClientSecret(ctx context.Context, organizationID, id uuid.UUID) (*models.App, string, error) {
+	app, err := s.GetForOrganization(ctx, organizationID, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, err := generateClientSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	hash := hashClientSecret(secret)
+	app.ClientSecretHash = &hash
+
+	if err := s.appRepo.Update(ctx, app); err != nil {
+		return nil, "", err
+	}
+	return app, secret, nil
+}
+
+// DisableClientCredentials discards the app's client secret, keys and
+// scopes. Access tokens it already obtained stop working.
+func (s *AppService) DisableClientCredentials(ctx context.Context, organizationID, id uuid.UUID) (*models.App, error) {
+	app, err := s.GetForOrganization(ctx, organizationID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	app.ClientScopes = models.StringArray{}
+	app.ClientSecretHash = nil
+	app.ClientJWKS = nil
+
+	if err := s.appRepo.Update(ctx, app); err != nil {
+		return nil, err
+	}
+	return app, nil
+}
+
+// validateScope accepts scope tokens as defined by RFC 6749 section 3.3.
+func validateScope(scope string) error {
+	if scope == "" {
+		return fmt.Errorf("%w: empty scope", core.ErrBadRequest)
+	}
+	for _, r := range scope {
+		if r < 0x21 || r > 0x7e || r == '"' || r == '\\' {
+			return fmt.Errorf("%w: invalid scope %q", core.ErrBadRequest, scope)
+		}
+	}
+	return nil
+}
+
+// normalizeClientJWKS checks that a JSON Web Key Set holds only valid public
+// keys and returns it re-encoded.
+func normalizeClientJWKS(raw string) (string, error) {
+	var keySet jose.JSONWebKeySet
+	if err := json.Unmarshal([]byte(raw), &keySet); err != nil {
+		return "", fmt.Errorf("%w: invalid JWKS: %v", core.ErrBadRequest, err)
+	}
+	if len(keySet.Keys) == 0 {
+		return "", fmt.Errorf("%w: JWKS has no keys", core.ErrBadRequest)
+	}
+	for _, key := range keySet.Keys {
+		if !key.Valid() || !key.IsPublic() {
+			return "", fmt.Errorf("%w: JWKS key %q is not a valid public key", core.ErrBadRequest, key.KeyID)
+		}
+		if key.Use != "" && key.Use != "sig" {
+			return "", fmt.Errorf("%w: JWKS key %q is not a signing key", core.ErrBadRequest, key.KeyID)
+		}
+	}
+
+	data, err := json.Marshal(keySet)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// validateRedirectURI accepts absolute https URIs without a fragment, and
+// http only for loopback hosts used by native apps and local development.
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return fmt.Errorf("%w: invalid redirect URI %q", core.ErrBadRequest, redirectURI)
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		if host := u.Hostname(); host == "localhost" || host == "127.0.0.1" || host == "::1" {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: redirect URI %q must use https", core.ErrBadRequest, redirectURI)
+}
+
+func generateClientSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashClientSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
 func (s *AppService) GetAllowedDomainsForOrganization(ctx context.Context, organizationID uuid.UUID, hostname *string) ([]string, error) {
 	apps, err := s.appRepo.ListByOrganizationID(ctx, organizationID)
 	if err != nil {
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/tokens"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+// ClientAssertionType is the client_assertion_type of private_key_jwt client
+// authentication (RFC 7523).
+const ClientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+const (
+	clientAssertionLeeway      = 30 * time.Second
+	clientAssertionMaxLifetime = time.Hour
+)
+
+// ClientAuthentication is how an app proves its identity at the token,
+// introspection and revocation endpoints: with either its client secret or
+// a client assertion signed with one of its registered keys.
+type ClientAuthentication struct {
+	ClientID            string
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+}
+
+// ClientCredentialsRequest asks for an access token for the app itself.
+type ClientCredentialsRequest struct {
+	ClientAuthentication
+	Scope string
+}
+
+type ClientCredentialsToken struct {
+	AccessToken string
+	Scope       string
+	ExpiresIn   int
+}
+
+// ClientCredentialsService issues short-lived access tokens to apps acting
+// as confidential clients (RFC 6749 section 4.4), replacing the static
+// x-vondr-auth token. Tokens are opaque and kept in Redis; forward auth
+// accepts them as bearer tokens.
+type ClientCredentialsService struct {
+	appRepo       repositories.AppRepository
+	tokenRepo     cache.ClientAccessTokenRepository
+	issuer        string
+	tokenEndpoint string
+	ttl           time.Duration
+}
+
+func NewClientCredentialsService(
+	appRepo repositories.AppRepository,
+	tokenRepo cache.ClientAccessTokenRepository,
+	issuer string,
+	ttl time.Duration,
+) *ClientCredentialsService {
+	issuer = strings.TrimSuffix(issuer, "/")
+	return &ClientCredentialsService{
+		appRepo:       appRepo,
+		tokenRepo:     tokenRepo,
+		issuer:        issuer,
+		tokenEndpoint: issuer + "/oidc/token",
+		ttl:           ttl,
+	}
+}
+
+// Issue authenticates the app and issues an access token for the requested
+// scopes, or for all scopes the app may use when none are requested.
+func (s *ClientCredentialsService) Issue(ctx context.Context, request ClientCredentialsRequest) (*ClientCredentialsToken, error) {
+	app, err := s.Authenticate(ctx, request.ClientAuthentication)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := grantedClientScope(app, request.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := generateClientAccessToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = s.tokenRepo.SaveToken(ctx, hashClientAccessToken(accessToken), cache.ClientAccessToken{
+		ClientID:       app.ID.String(),
+		OrganizationID: app.OrganizationID.String(),
+		Scope:          scope,
+		IssuedAt:       now.Unix(),
+		ExpiresAt:      now.Add(s.ttl).Unix(),
+	}, s.ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ClientCredentialsToken{
+		AccessToken: accessToken,
+		Scope:       scope,
+		ExpiresIn:   int(s.ttl.Seconds()),
+	}, nil
+}
+
+// Validate returns the token an access token stands for. Tokens stop
+// working as soon as their app no longer accepts client credentials.
+func (s *ClientCredentialsService) Validate(ctx context.Context, accessToken string) (*cache.ClientAccessToken, error) {
+	token, err := s.tokenRepo.GetToken(ctx, hashClientAccessToken(accessToken))
+	if err != nil {
+		return nil, err
+	}
+
+	app, err := s.lookupClient(ctx, token.ClientID)
+	if err != nil {
+		if errors.Is(err, core.ErrUnauthorized) {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if app.OrganizationID.String() != token.OrganizationID {
+		return nil, core.ErrInvalidToken
+	}
+	return token, nil
+}
+
+// Revoke deletes an access token. Unknown tokens are ignored.
+func (s *ClientCredentialsService) Revoke(ctx context.Context, accessToken string) error {
+	return s.tokenRepo.DeleteToken(ctx, hashClientAccessToken(accessToken))
+}
+
+// Authenticate returns the app that authenticated as a confidential client.
+func (s *ClientCredentialsService) Authenticate(ctx context.Context, request ClientAuthentication) (*models.App, error) {
+	if request.ClientAssertion == "" && request.ClientAssertionType == "" {
+		app, err := s.lookupClient(ctx, request.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if app.ClientSecretHash == nil || request.ClientSecret == "" ||
+			subtle.ConstantTimeCompare([]byte(hashClientSecret(request.ClientSecret)), []byte(*app.ClientSecretHash)) != 1 {
+			log.Printf("Security: invalid client secret for client credentials client %s", request.ClientID)
+			return nil, core.ErrUnauthorized
+		}
+		return app, nil
+	}
+
+	if request.ClientSecret != "" || request.ClientAssertionType != ClientAssertionType {
+		return nil, core.ErrUnauthorized
+	}
+	return s.authenticateAssertion(ctx, request.ClientID, request.ClientAssertion)
+}
+
+// authenticateAssertion checks a private_key_jwt client assertion: signed
+// with one of the app's registered keys, issued by and about the client, for
+// this token endpoint, short-lived and used once.
+func (s *ClientCredentialsService) authenticateAssertion(ctx context.Context, clientID, assertion string) (*models.App, error) {
+	token, err := jwt.ParseSigned(assertion, tokens.Algorithms)
+	if err != nil {
+		return nil, core.ErrUnauthorized
+	}
+
+	var unverified jwt.Claims
+	if err := token.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return nil, core.ErrUnauthorized
+	}
+	if clientID == "" {
+		clientID = unverified.Issuer
+	}
+
+	app, err := s.lookupClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if app.ClientJWKS == nil {
+		return nil, core.ErrUnauthorized
+	}
+	var keySet jose.JSONWebKeySet
+	if err := json.Unmarshal([]byte(*app.ClientJWKS), &keySet); err != nil {
+		return nil, err
+	}
+
+	keys := keySet.Keys
+	if keyID := token.Headers[0].KeyID; keyID != "" {
+		keys = keySet.Key(keyID)
+	}
+	var claims jwt.Claims
+	verified := false
+	for _, key := range keys {
+		if err := token.Claims(key.Key, &claims); err == nil {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		log.Printf("Security: client assertion of client %s does not verify with its registered keys", clientID)
+		return nil, core.ErrUnauthorized
+	}
+
+	now := time.Now()
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      clientID,
+		Subject:     clientID,
+		AnyAudience: jwt.Audience{s.tokenEndpoint, s.issuer},
+		Time:        now,
+	}, clientAssertionLeeway)
+	if err != nil || claims.Expiry == nil || claims.ID == "" {
+		return nil, core.ErrUnauthorized
+	}
+	lifetime := claims.Expiry.Time().Sub(now)
+	if lifetime > clientAssertionMaxLifetime {
+		return nil, core.ErrUnauthorized
+	}
+
+	claimed, err := s.tokenRepo.ClaimAssertion(ctx, clientID, claims.ID, lifetime+clientAssertionLeeway)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		log.Printf("Security: replayed client assertion %s for client %s", claims.ID, clientID)
+		return nil, core.ErrUnauthorized
+	}
+	return app, nil
+}
+
+func (s *ClientCredentialsService) lookupClient(ctx context.Context, clientID string) (*models.App, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, core.ErrUnauthorized
+	}
+	app, err := s.appRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrUnauthorized
+		}
+		return nil, err
+	}
+	if !app.AcceptsClientCredentials() {
+		return nil, core.ErrUnauthorized
+	}
+	return app, nil
+}
+
+// grantedClientScope checks the requested scopes against the scopes
+// registered for the app.
+func grantedClientScope(app *models.App, requested string) (string, error) {
+	scopes := strings.Fields(requested)
+	if len(scopes) == 0 {
+		return strings.Join(app.ClientScopes, " "), nil
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(app.ClientScopes, scope) {
+			return "", fmt.Errorf("%w: scope %q is not allowed for the client", core.ErrBadRequest, scope)
+		}
+	}
+	return strings.Join(scopes, " "), nil
+}
+
+func generateClientAccessToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashClientAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
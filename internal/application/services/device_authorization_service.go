@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+)
+
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	deviceCodeGrace    = 5 * time.Minute
+	devicePollInterval = 5
+	deviceSlowDownStep = 5
+	// userCodeAlphabet leaves out vowels and look-alike characters, as
+	// suggested by RFC 8628 section 6.1.
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// DeviceAuthorizationGrant is the device authorization response of RFC 8628
+// section 3.2.
+type DeviceAuthorizationGrant struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               int
+	Interval                int
+}
+
+// DeviceAuthorizationService implements the device authorization grant for
+// clients that cannot receive a browser callback. A member approves the
+// request with their browser session, and the client receives an access
+// token derived from it and a refresh token. Only a SHA-256 hash of each
+// device code is stored.
+type DeviceAuthorizationService struct {
+	repo            cache.DeviceAuthorizationRepository
+	sessionManager  *SessionManager
+	refreshTokens   *RefreshTokenService
+	clientIDs       map[string]bool
+	verificationURI string
+}
+
+func NewDeviceAuthorizationService(
+	repo cache.DeviceAuthorizationRepository,
+	sessionManager *SessionManager,
+	refreshTokens *RefreshTokenService,
+	clientIDs []string,
+	baseURL string,
+) *DeviceAuthorizationService {
+	clientIDsMap := make(map[string]bool)
+	for _, clientID := range clientIDs {
+		clientIDsMap[clientID] = true
+	}
+	return &DeviceAuthorizationService{
+		repo:            repo,
+		sessionManager:  sessionManager,
+		refreshTokens:   refreshTokens,
+		clientIDs:       clientIDsMap,
+		verificationURI: strings.TrimSuffix(baseURL, "/") + "/auth/device",
+	}
+}
+
+// VerificationURI is the page where members enter user codes.
+func (s *DeviceAuthorizationService) VerificationURI() string {
+	return s.verificationURI
+}
+
+// Start issues a device code and user code for clientID.
+func (s *DeviceAuthorizationService) Start(ctx context.Context, clientID string) (*DeviceAuthorizationGrant, error) {
+	if !s.clientIDs[clientID] {
+		return nil, fmt.Errorf("%w: unknown client_id", core.ErrUnauthorized)
+	}
+
+	deviceCode, err := generateDeviceCode()
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		userCode, err := generateUserCode()
+		if err != nil {
+			return nil, err
+		}
+
+		authorization := cache.DeviceAuthorization{
+			ClientID:  clientID,
+			UserCode:  userCode,
+			ExpiresAt: time.Now().Add(deviceCodeTTL).Unix(),
+			Interval:  devicePollInterval,
+		}
+		err = s.repo.SaveAuthorization(ctx, hashDeviceCode(deviceCode), authorization, deviceCodeTTL+deviceCodeGrace)
+		if errors.Is(err, core.ErrConflict) && attempt < 3 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		display := formatUserCode(userCode)
+		return &DeviceAuthorizationGrant{
+			DeviceCode:              deviceCode,
+			UserCode:                display,
+			VerificationURI:         s.verificationURI,
+			VerificationURIComplete: s.verificationURI + "?user_code=" + url.QueryEscape(display),
+			ExpiresIn:               int(deviceCodeTTL.Seconds()),
+			Interval:                devicePollInterval,
+		}, nil
+	}
+}
+
+// Lookup returns the undecided authorization for a user code as entered by
+// the member.
+func (s *DeviceAuthorizationService) Lookup(ctx context.Context, userCode string) (*cache.DeviceAuthorization, error) {
+	_, authorization, err := s.lookupPending(ctx, userCode)
+	return authorization, err
+}
+
+// Approve lets the client behind userCode sign in as the member of the
+// session identified by sessionToken, which must be a browser session; access
+// tokens of other clients cannot approve devices.
+func (s *DeviceAuthorizationService) Approve(ctx context.Context, userCode, sessionToken string) error {
+	deviceCodeHash, authorization, err := s.lookupPending(ctx, userCode)
+	if err != nil {
+		return err
+	}
+
+	session, err := s.sessionManager.GetSession(ctx, sessionToken)
+	if err != nil {
+		return err
+	}
+	if !isBrowserSession(session) {
+		return core.ErrInvalidSession
+	}
+	authorization.Session = session
+
+	return s.repo.UpdateAuthorization(ctx, deviceCodeHash, *authorization)
+}
+
+// Deny rejects the authorization behind userCode.
+func (s *DeviceAuthorizationService) Deny(ctx context.Context, userCode string) error {
+	deviceCodeHash, authorization, err := s.lookupPending(ctx, userCode)
+	if err != nil {
+		return err
+	}
+	authorization.Denied = true
+	return s.repo.UpdateAuthorization(ctx, deviceCodeHash, *authorization)
+}
+
+// Poll answers a device access token request. Until the member decides it
+// returns core.ErrAuthorizationPending, or core.ErrSlowDown when the client
+// polls faster than its interval, which is then raised by five seconds.
+func (s *DeviceAuthorizationService) Poll(ctx context.Context, clientID, deviceCode string) (*MemberTokens, error) {
+	if deviceCode == "" {
+		return nil, core.ErrInvalidToken
+	}
+	deviceCodeHash := hashDeviceCode(deviceCode)
+
+	authorization, err := s.repo.GetAuthorization(ctx, deviceCodeHash)
+	if err != nil {
+		return nil, err
+	}
+	if authorization.ClientID != clientID {
+		return nil, core.ErrInvalidToken
+	}
+
+	now := time.Now()
+	switch {
+	case now.Unix() > authorization.ExpiresAt:
+		_, _ = s.repo.ConsumeAuthorization(ctx, deviceCodeHash)
+		return nil, core.ErrExpiredToken
+	case authorization.Denied:
+		_, _ = s.repo.ConsumeAuthorization(ctx, deviceCodeHash)
+		return nil, core.ErrAccessDenied
+	case authorization.Session != nil:
+		approved, err := s.repo.ConsumeAuthorization(ctx, deviceCodeHash)
+		if err != nil {
+			return nil, err
+		}
+		return s.refreshTokens.Grant(ctx, *approved.Session, clientID, "", "")
+	}
+
+	tooFast, err := s.repo.RecordPoll(ctx, deviceCodeHash, now, authorization.Interval, deviceSlowDownStep, deviceCodeTTL+deviceCodeGrace)
+	if err != nil {
+		return nil, err
+	}
+	if tooFast {
+		return nil, core.ErrSlowDown
+	}
+	return nil, core.ErrAuthorizationPending
+}
+
+// Refresh redeems a refresh token issued to clientID for a new access token
+// and refresh token.
+func (s *DeviceAuthorizationService) Refresh(ctx context.Context, clientID, refreshToken string) (*MemberTokens, error) {
+	if !s.clientIDs[clientID] {
+		return nil, fmt.Errorf("%w: unknown client_id", core.ErrUnauthorized)
+	}
+	return s.refreshTokens.Refresh(ctx, clientID, refreshToken)
+}
+
+// lookupPending finds the authorization for a user code that has neither
+// expired nor been decided.
+func (s *DeviceAuthorizationService) lookupPending(ctx context.Context, userCode string) (string, *cache.DeviceAuthorization, error) {
+	userCode = normalizeUserCode(userCode)
+	if len(userCode) != userCodeLength {
+		return "", nil, core.ErrInvalidToken
+	}
+
+	deviceCodeHash, err := s.repo.GetDeviceCodeHash(ctx, userCode)
+	if err != nil {
+		return "", nil, err
+	}
+	authorization, err := s.repo.GetAuthorization(ctx, deviceCodeHash)
+	if err != nil {
+		return "", nil, err
+	}
+	if time.Now().Unix() > authorization.ExpiresAt || authorization.Denied || authorization.Session != nil {
+		return "", nil, core.ErrInvalidToken
+	}
+	return deviceCodeHash, authorization, nil
+}
+
+func generateDeviceCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func generateUserCode() (string, error) {
+	code := make([]byte, userCodeLength)
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode accepts codes typed in lower case or with the separator
+// and whitespace.
+func normalizeUserCode(userCode string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(strings.TrimSpace(userCode)))
+}
+
+func formatUserCode(userCode string) string {
+	return userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+}
+
+func hashDeviceCode(deviceCode string) string {
+	sum := sha256.Sum256([]byte(deviceCode))
+	return hex.EncodeToString(sum[:])
+}
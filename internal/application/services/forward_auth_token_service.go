@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core/tokens"
+	"github.com/vondr/identity-go/pkg/forwardauth"
+)
+
+// ForwardAuthIdentity is what forward auth vouches for to an upstream:
+// a member, or an app identified by ClientID.
+type ForwardAuthIdentity struct {
+	MemberID       string
+	ClientID       string
+	Email          string
+	OrganizationID string
+	Role           string
+	Groups         []string
+	Scope          string
+	AppID          string
+	Host           string
+}
+
+// ForwardAuthTokenService signs the short-lived JWT forward auth passes to
+// upstreams in the forwardauth.HeaderName header. Upstreams check it with
+// the forwardauth package.
+type ForwardAuthTokenService struct {
+	signer *tokens.Signer
+	issuer string
+	ttl    time.Duration
+}
+
+func NewForwardAuthTokenService(signer *tokens.Signer, issuer string, ttl time.Duration) *ForwardAuthTokenService {
+	return &ForwardAuthTokenService{
+		signer: signer,
+		issuer: strings.TrimSuffix(issuer, "/"),
+		ttl:    ttl,
+	}
+}
+
+// Issue signs a token for identity whose audience is the host the request
+// was addressed to.
+func (s *ForwardAuthTokenService) Issue(ctx context.Context, identity ForwardAuthIdentity) (string, error) {
+	now := time.Now()
+	groups := identity.Groups
+	if groups == nil {
+		groups = []string{}
+	}
+	subject := identity.MemberID
+	if identity.ClientID != "" {
+		subject = identity.ClientID
+	}
+	return s.signer.Sign(ctx, forwardauth.Claims{
+		Claims: jwt.Claims{
+			Issuer:    s.issuer,
+			Subject:   subject,
+			Audience:  jwt.Audience{audienceHost(identity.Host)},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Expiry:    jwt.NewNumericDate(now.Add(s.ttl)),
+			ID:        uuid.NewString(),
+		},
+		Email:          identity.Email,
+		OrganizationID: identity.OrganizationID,
+		Role:           identity.Role,
+		Groups:         groups,
+		AppID:          identity.AppID,
+		ClientID:       identity.ClientID,
+		Scope:          identity.Scope,
+	})
+}
+
+// audienceHost drops any port from a forwarded host, matching what the
+// verifier compares against.
+func audienceHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.ToLower(host)
+}
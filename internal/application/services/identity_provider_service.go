@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/oauth"
+	"github.com/vondr/identity-go/internal/core/secrets"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+// IdentityProviderSecretsPurpose derives the key that encrypts provider client secrets.
+const IdentityProviderSecretsPurpose = "identity-provider-secrets"
+
+var identityProviderSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+// reservedProviderSlugs are built-in providers and other /auth routes that a
+// provider slug would otherwise shadow.
+var reservedProviderSlugs = map[string]bool{
+	"microsoft":  true,
+	"relatics":   true,
+	"saml":       true,
+	"discover":   true,
+	"logout":     true,
+	"me":         true,
+	"email":      true,
+	"mfa":        true,
+	"webauthn":   true,
+	"step-up":    true,
+	"device":     true,
+	"identities": true,
+}
+
+type IdentityProviderInput struct {
+	Slug             string
+	Type             core.IdentityProviderType
+	Issuer           string
+	ClientID         string
+	ClientSecret     *string
+	AllowedTenantIDs []string
+	ClaimMappings    map[string]string
+	Enabled          bool
+}
+
+type cachedIdentityProvider struct {
+	provider  oauth.IdentityProvider
+	updatedAt time.Time
+}
+
+type IdentityProviderService struct {
+	providerRepo    repositories.IdentityProviderRepository
+	orgRepo         repositories.OrganizationRepository
+	encryptionKey   []byte
+	callbackBaseURL string
+
+	mu        sync.Mutex
+	providers map[uuid.UUID]cachedIdentityProvider
+}
+
+func NewIdentityProviderService(
+	providerRepo repositories.IdentityProviderRepository,
+	orgRepo repositories.OrganizationRepository,
+	encryptionKey []byte,
+	callbackBaseURL string,
+) *IdentityProviderService {
+	return &IdentityProviderService{
+		providerRepo:    providerRepo,
+		orgRepo:         orgRepo,
+		encryptionKey:   encryptionKey,
+		callbackBaseURL: strings.TrimSuffix(callbackBaseURL, "/"),
+		providers:       make(map[uuid.UUID]cachedIdentityProvider),
+	}
+}
+
+func (s *IdentityProviderService) List(ctx context.Context, organizationID uuid.UUID) ([]*models.IdentityProvider, error) {
+	if _, err := s.orgRepo.GetByID(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	return s.providerRepo.ListByOrganizationID(ctx, organizationID)
+}
+
+func (s *IdentityProviderService) Get(ctx context.Context, organizationID, providerID uuid.UUID) (*models.IdentityProvider, error) {
+	provider, err := s.providerRepo.GetByID(ctx, providerID)
+	if err != nil {
+		return nil, err
+	}
+	if provider.OrganizationID != organizationID {
+		return nil, core.ErrNotFound
+	}
+	return provider, nil
+}
+
+func (s *IdentityProviderService) Create(ctx context.Context, organizationID uuid.UUID, input *IdentityProviderInput) (*models.IdentityProvider, error) {
+	if _, err := s.orgRepo.GetByID(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	if input.ClientSecret == nil || *input.ClientSecret == "" {
+		return nil, fmt.Errorf("%w: client secret is required", core.ErrBadRequest)
+	}
+
+	provider := &models.IdentityProvider{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+	}
+	if err := s.apply(ctx, provider, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.providerRepo.Create(ctx, provider); err != nil {
+		return nil, err
+	}
+	return provider, nil
+}
+
+func (s *IdentityProviderService) Update(ctx context.Context, organizationID, providerID uuid.UUID, input *IdentityProviderInput) (*models.IdentityProvider, error) {
+	provider, err := s.Get(ctx, organizationID, providerID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apply(ctx, provider, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.providerRepo.Update(ctx, provider); err != nil {
+		return nil, err
+	}
+	s.forget(provider.ID)
+	return provider, nil
+}
+
+func (s *IdentityProviderService) Delete(ctx context.Context, organizationID, providerID uuid.UUID) error {
+	if _, err := s.Get(ctx, organizationID, providerID); err != nil {
+		return err
+	}
+	if err := s.providerRepo.Delete(ctx, providerID); err != nil {
+		return err
+	}
+	s.forget(providerID)
+	return nil
+}
+
+// Resolve returns a ready-to-use login provider for an enabled provider slug
+// together with its stored configuration. Built providers are cached until the
+// record changes so OIDC discovery and signing keys are reused across logins.
+func (s *IdentityProviderService) Resolve(ctx context.Context, slug string) (oauth.IdentityProvider, *models.IdentityProvider, error) {
+	record, err := s.providerRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !record.Enabled {
+		return nil, nil, core.ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.providers[record.ID]; ok && cached.updatedAt.Equal(record.UpdatedAt) {
+		return cached.provider, record, nil
+	}
+
+	clientSecret, err := secrets.Decrypt(s.encryptionKey, record.ClientSecretEncrypted)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt client secret for provider %s: %w", record.Slug, err)
+	}
+
+	providerConfig := oauth.OIDCProviderConfig{
+		Name:             record.Slug,
+		Issuer:           s.issuerFor(record),
+		ClientID:         record.ClientID,
+		ClientSecret:     clientSecret,
+		RedirectURL:      s.callbackBaseURL + "/auth/" + record.Slug + "/callback",
+		AllowedTenantIDs: record.AllowedTenantIDs,
+		ClaimMappings:    record.ClaimMappings,
+	}
+	if record.Type == core.IdentityProviderTypeMicrosoft {
+		providerConfig = oauth.WithMicrosoftClaims(providerConfig)
+	}
+	provider := oauth.NewOIDCProvider(providerConfig)
+	s.providers[record.ID] = cachedIdentityProvider{provider: provider, updatedAt: record.UpdatedAt}
+
+	return provider, record, nil
+}
+
+func (s *IdentityProviderService) issuerFor(record *models.IdentityProvider) string {
+	if record.Issuer != "" || record.Type != core.IdentityProviderTypeMicrosoft {
+		return record.Issuer
+	}
+	if len(record.AllowedTenantIDs) == 1 {
+		return oauth.MicrosoftIssuer(record.AllowedTenantIDs[0])
+	}
+	return oauth.MicrosoftIssuer("organizations")
+}
+
+func (s *IdentityProviderService) forget(providerID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.providers, providerID)
+}
+
+func (s *IdentityProviderService) apply(ctx context.Context, provider *models.IdentityProvider, input *IdentityProviderInput) error {
+	slug := strings.ToLower(strings.TrimSpace(input.Slug))
+	if !identityProviderSlugPattern.MatchString(slug) {
+		return fmt.Errorf("%w: slug must be 3-63 lowercase letters, digits or dashes", core.ErrBadRequest)
+	}
+	if reservedProviderSlugs[slug] {
+		return fmt.Errorf("%w: slug %q is reserved", core.ErrBadRequest, slug)
+	}
+	if existing, err := s.providerRepo.GetBySlug(ctx, slug); err == nil && existing.ID != provider.ID {
+		return core.ErrConflict
+	} else if err != nil && err != core.ErrNotFound {
+		return err
+	}
+
+	if !input.Type.IsValid() {
+		return fmt.Errorf("%w: type must be one of microsoft, oidc", core.ErrBadRequest)
+	}
+
+	issuer := strings.TrimSuffix(strings.TrimSpace(input.Issuer), "/")
+	if issuer == "" && input.Type != core.IdentityProviderTypeMicrosoft {
+		return fmt.Errorf("%w: issuer is required", core.ErrBadRequest)
+	}
+	if issuer != "" && !strings.HasPrefix(issuer, "https://") {
+		return fmt.Errorf("%w: issuer must be an https URL", core.ErrBadRequest)
+	}
+
+	clientID := strings.TrimSpace(input.ClientID)
+	if clientID == "" {
+		return fmt.Errorf("%w: client ID is required", core.ErrBadRequest)
+	}
+
+	tenantIDs := make([]string, 0, len(input.AllowedTenantIDs))
+	for _, tenantID := range input.AllowedTenantIDs {
+		if trimmed := strings.TrimSpace(tenantID); trimmed != "" {
+			tenantIDs = append(tenantIDs, strings.ToLower(trimmed))
+		}
+	}
+
+	mappings := make(map[string]string, len(input.ClaimMappings))
+	for field, claimName := range input.ClaimMappings {
+		if !oauth.IsMappableClaim(field) {
+			return fmt.Errorf("%w: claim %q cannot be mapped", core.ErrBadRequest, field)
+		}
+		if claimName = strings.TrimSpace(claimName); claimName != "" {
+			mappings[field] = claimName
+		}
+	}
+
+	if input.ClientSecret != nil && *input.ClientSecret != "" {
+		encrypted, err := secrets.Encrypt(s.encryptionKey, *input.ClientSecret)
+		if err != nil {
+			return err
+		}
+		provider.ClientSecretEncrypted = encrypted
+	}
+
+	provider.Slug = slug
+	provider.Type = input.Type
+	provider.Issuer = issuer
+	provider.ClientID = clientID
+	provider.AllowedTenantIDs = tenantIDs
+	provider.ClaimMappings = mappings
+	provider.Enabled = input.Enabled
+	return nil
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+)
+
+const loginTransactionTTL = 10 * time.Minute
+
+type LoginTransactionManager struct {
+	transactionRepo cache.LoginTransactionRepository
+}
+
+func NewLoginTransactionManager(transactionRepo cache.LoginTransactionRepository) *LoginTransactionManager {
+	return &LoginTransactionManager{
+		transactionRepo: transactionRepo,
+	}
+}
+
+func (m *LoginTransactionManager) Save(ctx context.Context, state string, transaction cache.LoginTransaction) error {
+	return m.transactionRepo.SaveTransaction(ctx, state, transaction, loginTransactionTTL)
+}
+
+func (m *LoginTransactionManager) Consume(ctx context.Context, state string) (*cache.LoginTransaction, error) {
+	if state == "" {
+		return nil, core.ErrInvalidState
+	}
+	return m.transactionRepo.ConsumeTransaction(ctx, state)
+}
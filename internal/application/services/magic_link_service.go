@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	netmail "net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/mail"
+)
+
+const (
+	magicLinkRateWindow  = 15 * time.Minute
+	magicLinkRateLimit   = 5
+	magicLinkSendTimeout = 30 * time.Second
+)
+
+// MagicLinkService issues and redeems passwordless email login links. Only a
+// SHA-256 hash of each token is stored, and a token can be redeemed once.
+type MagicLinkService struct {
+	linkRepo   cache.MagicLinkRepository
+	memberRepo repositories.MemberRepository
+	mailer     mail.Mailer
+	verifyURL  string
+	ttl        time.Duration
+}
+
+func NewMagicLinkService(
+	linkRepo cache.MagicLinkRepository,
+	memberRepo repositories.MemberRepository,
+	mailer mail.Mailer,
+	baseURL string,
+	ttl time.Duration,
+) *MagicLinkService {
+	return &MagicLinkService{
+		linkRepo:   linkRepo,
+		memberRepo: memberRepo,
+		mailer:     mailer,
+		verifyURL:  strings.TrimSuffix(baseURL, "/") + "/auth/email/verify",
+		ttl:        ttl,
+	}
+}
+
+// Start mails a login link to email if it belongs to a member. Unknown
+// addresses are accepted silently so the endpoint cannot be used to find out
+// who has an account. binding identifies the browser that asked for the link;
+// it is stored with the link and checked again on redemption. The member
+// lookup and delivery run in the background so the response time is the same
+// for members and unknown addresses.
+func (s *MagicLinkService) Start(ctx context.Context, email, returnTo, binding string) error {
+	address, err := netmail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || address.Address != strings.TrimSpace(email) {
+		return core.ErrInvalidEmail
+	}
+	email = strings.ToLower(address.Address)
+
+	count, err := s.linkRepo.CountRequest(ctx, email, magicLinkRateWindow)
+	if err != nil {
+		return err
+	}
+	if count > magicLinkRateLimit {
+		return core.ErrTooManyRequests
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), magicLinkSendTimeout)
+		defer cancel()
+
+		if err := s.send(ctx, email, returnTo, binding); err != nil {
+			log.Printf("Warning: Failed to send login link to %s: %v", email, err)
+		}
+	}()
+	return nil
+}
+
+func (s *MagicLinkService) send(ctx context.Context, email, returnTo, binding string) error {
+	if _, err := s.memberRepo.GetByEmail(ctx, email); err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			log.Printf("Security: magic link requested for unknown email %s", email)
+			return nil
+		}
+		return err
+	}
+
+	token, err := generateMagicLinkToken()
+	if err != nil {
+		return err
+	}
+
+	link := cache.MagicLink{Email: email, ReturnTo: returnTo, Binding: binding}
+	if err := s.linkRepo.SaveLink(ctx, hashMagicLinkToken(token), link, s.ttl); err != nil {
+		return err
+	}
+
+	loginURL := s.verifyURL + "?token=" + url.QueryEscape(token)
+	minutes := int(s.ttl.Minutes())
+	return s.mailer.Send(ctx, &mail.Message{
+		To:      email,
+		Subject: "Your Vondr sign-in link",
+		TextBody: fmt.Sprintf(
+			"Use the link below to sign in to Vondr. It expires in %d minutes and can be used once.\n\n%s\n\nIf you did not request this email you can ignore it.\n",
+			minutes, loginURL,
+		),
+		HTMLBody: fmt.Sprintf(
+			`<p>Use the link below to sign in to Vondr. It expires in %d minutes and can be used once.</p><p><a href="%s">Sign in to Vondr</a></p><p>If you did not request this email you can ignore it.</p>`,
+			minutes, html.EscapeString(loginURL),
+		),
+	})
+}
+
+// Verify redeems a login link token and returns the link it was issued for.
+func (s *MagicLinkService) Verify(ctx context.Context, token string) (*cache.MagicLink, error) {
+	if token == "" {
+		return nil, core.ErrInvalidToken
+	}
+	return s.linkRepo.ConsumeLink(ctx, hashMagicLinkToken(token))
+}
+
+func generateMagicLinkToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashMagicLinkToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -2,7 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/vondr/identity-go/internal/application/repositories"
@@ -11,17 +15,30 @@ import (
 )
 
 type MemberService struct {
-	memberRepo repositories.MemberRepository
-	orgRepo    repositories.OrganizationRepository
+	memberRepo   repositories.MemberRepository
+	orgRepo      repositories.OrganizationRepository
+	identityRepo repositories.MemberIdentityRepository
 }
 
-func NewMemberService(memberRepo repositories.MemberRepository, orgRepo repositories.OrganizationRepository) *MemberService {
+func NewMemberService(
+	memberRepo repositories.MemberRepository,
+	orgRepo repositories.OrganizationRepository,
+	identityRepo repositories.MemberIdentityRepository,
+) *MemberService {
 	return &MemberService{
-		memberRepo: memberRepo,
-		orgRepo:    orgRepo,
+		memberRepo:   memberRepo,
+		orgRepo:      orgRepo,
+		identityRepo: identityRepo,
 	}
 }
 
+// ExternalAccount identifies an account at an upstream identity provider.
+type ExternalAccount struct {
+	Provider string
+	Issuer   string
+	Subject  string
+}
+
 func (s *MemberService) GetByID(ctx context.Context, id uuid.UUID) (*models.OrganizationMember, error) {
 	return s.memberRepo.GetByID(ctx, id)
 }
@@ -30,10 +47,22 @@ func (s *MemberService) GetByEmail(ctx context.Context, email string) (*models.O
 	return s.memberRepo.GetByEmail(ctx, email)
 }
 
-func (s *MemberService) GetByMicrosoftID(ctx context.Context, microsoftID string) (*models.OrganizationMember, error) {
-	return s.memberRepo.GetByMicrosoftID(ctx, microsoftID)
-}
+// GetByExternalAccount returns the member the account is linked to and
+// records that the account was used.
+func (s *MemberService) GetByExternalAccount(ctx context.Context, account ExternalAccount) (*models.OrganizationMember, error) {
+	identity, err := s.findIdentity(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := s.memberRepo.GetByID(ctx, identity.MemberID)
+	if err != nil {
+		return nil, err
+	}
 
+	s.touchIdentity(ctx, identity)
+	return member, nil
+}
 func (s *MemberService) ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.OrganizationMember, error) {
 	return s.memberRepo.ListByOrganizationID(ctx, organizationID)
 }
@@ -50,7 +79,7 @@ func (s *MemberService) Delete(ctx context.Context, id uuid.UUID) error {
 	return s.memberRepo.Delete(ctx, id)
 }
 
-func (s *MemberService) CreateSystem(ctx context.Context, orgID uuid.UUID, orgName, email, microsoftID string, firstName, lastName *string) (*models.OrganizationMember, error) {
+func (s *MemberService) CreateSystem(ctx context.Context, orgID uuid.UUID, orgName, email string, account *ExternalAccount, firstName, lastName *string) (*models.OrganizationMember, error) {
 	org, err := s.orgRepo.GetByID(ctx, orgID)
 	if err != nil && err != core.ErrNotFound {
 		return nil, err
@@ -69,7 +98,6 @@ func (s *MemberService) CreateSystem(ctx context.Context, orgID uuid.UUID, orgNa
 
 	member := &models.OrganizationMember{
 		OrganizationID: orgID,
-		MicrosoftID:    &microsoftID,
 		Email:          strings.ToLower(email),
 		FirstName:      firstName,
 		LastName:       lastName,
@@ -80,26 +108,128 @@ func (s *MemberService) CreateSystem(ctx context.Context, orgID uuid.UUID, orgNa
 		return nil, err
 	}
 
+	if account != nil {
+		if _, err := s.LinkAccount(ctx, member.ID, *account); err != nil {
+			return nil, err
+		}
+	}
+
 	return member, nil
 }
 
-func (s *MemberService) LinkMicrosoftAccount(ctx context.Context, email, microsoftID string, firstName, lastName *string) (*models.OrganizationMember, error) {
+// LinkAccountByEmail links the account to the member with the given email on
+// their first login with it, filling in names the member does not have yet.
+func (s *MemberService) LinkAccountByEmail(ctx context.Context, email string, account ExternalAccount, firstName, lastName *string) (*models.OrganizationMember, error) {
 	member, err := s.memberRepo.GetByEmail(ctx, email)
 	if err != nil {
 		return nil, core.ErrNotFound
 	}
 
-	member.MicrosoftID = &microsoftID
-	if firstName != nil && member.FirstName == nil {
-		member.FirstName = firstName
+	if _, err := s.LinkAccount(ctx, member.ID, account); err != nil {
+		return nil, err
+	}
+
+	if (firstName != nil && member.FirstName == nil) || (lastName != nil && member.LastName == nil) {
+		if member.FirstName == nil {
+			member.FirstName = firstName
+		}
+		if member.LastName == nil {
+			member.LastName = lastName
+		}
+		if err := s.memberRepo.Update(ctx, member); err != nil {
+			return nil, err
+		}
+	}
+
+	return member, nil
+}
+
+// LinkAccount links the account to the member. Linking an account that
+// already belongs to the member only records that it was used; an account
+// linked to another member is a conflict.
+func (s *MemberService) LinkAccount(ctx context.Context, memberID uuid.UUID, account ExternalAccount) (*models.MemberIdentity, error) {
+	if account.Provider == "" || account.Subject == "" {
+		return nil, fmt.Errorf("%w: provider and subject are required", core.ErrBadRequest)
+	}
+
+	identity, err := s.findIdentity(ctx, account)
+	if err == nil {
+		if identity.MemberID != memberID {
+			log.Printf("Security: %s account %s is already linked to member %s, refused for member %s", account.Provider, account.Subject, identity.MemberID, memberID)
+			return nil, fmt.Errorf("%w: account is linked to another member", core.ErrConflict)
+		}
+		s.touchIdentity(ctx, identity)
+		return identity, nil
 	}
-	if lastName != nil && member.LastName == nil {
-		member.LastName = lastName
+	if !errors.Is(err, core.ErrNotFound) {
+		return nil, err
 	}
 
-	if err := s.memberRepo.Update(ctx, member); err != nil {
+	now := time.Now()
+	identity = &models.MemberIdentity{
+		MemberID:   memberID,
+		Provider:   account.Provider,
+		Issuer:     account.Issuer,
+		Subject:    account.Subject,
+		LastUsedAt: &now,
+	}
+	if err := s.identityRepo.Create(ctx, identity); err != nil {
 		return nil, err
 	}
+	return identity, nil
+}
 
-	return member, nil
+func (s *MemberService) ListIdentities(ctx context.Context, memberID uuid.UUID) ([]*models.MemberIdentity, error) {
+	return s.identityRepo.ListByMemberID(ctx, memberID)
+}
+
+// UnlinkIdentity removes a linked account of the member. The last linked
+// account cannot be removed, so the member keeps a way to sign in.
+func (s *MemberService) UnlinkIdentity(ctx context.Context, memberID, identityID uuid.UUID) error {
+	identity, err := s.identityRepo.GetByID(ctx, identityID)
+	if err != nil {
+		return err
+	}
+	if identity.MemberID != memberID {
+		return core.ErrNotFound
+	}
+
+	count, err := s.identityRepo.CountByMemberID(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return fmt.Errorf("%w: cannot unlink the only linked account", core.ErrBadRequest)
+	}
+
+	if err := s.identityRepo.Delete(ctx, identityID); err != nil {
+		return err
+	}
+	log.Printf("Security: member %s unlinked %s account %s", memberID, identity.Provider, identity.Subject)
+	return nil
+}
+
+// findIdentity looks up a linked account. Accounts migrated from the former
+// microsoft_id and relatics_id columns have no issuer yet; they match any
+// issuer of their provider and adopt the first one seen.
+func (s *MemberService) findIdentity(ctx context.Context, account ExternalAccount) (*models.MemberIdentity, error) {
+	identity, err := s.identityRepo.GetByAccount(ctx, account.Provider, account.Issuer, account.Subject)
+	if err == nil || !errors.Is(err, core.ErrNotFound) || account.Issuer == "" {
+		return identity, err
+	}
+
+	identity, err = s.identityRepo.GetByAccount(ctx, account.Provider, "", account.Subject)
+	if err != nil {
+		return nil, err
+	}
+	identity.Issuer = account.Issuer
+	return identity, nil
+}
+
+func (s *MemberService) touchIdentity(ctx context.Context, identity *models.MemberIdentity) {
+	now := time.Now()
+	identity.LastUsedAt = &now
+	if err := s.identityRepo.Update(ctx, identity); err != nil {
+		log.Printf("Warning: Failed to update linked account %s: %v", identity.ID, err)
+	}
 }
@@ -0,0 +1,443 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/secrets"
+	"github.com/vondr/identity-go/internal/core/totp"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+const MFASecretsPurpose = "mfa-secrets"
+
+const (
+	mfaIssuer         = "Vondr"
+	mfaChallengeTTL   = 10 * time.Minute
+	mfaFailureWindow  = 15 * time.Minute
+	mfaFailureLimit   = 10
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAStatus describes a member's second factor setup. Enrolled is true when
+// the member has a TOTP authenticator or a passkey, and Required when the
+// member's organization enforces MFA.
+type MFAStatus struct {
+	Enrolled               bool
+	Required               bool
+	TOTP                   bool
+	Passkeys               int
+	RecoveryCodesRemaining int
+}
+
+// MFAService manages TOTP enrollment, login challenges and recovery codes.
+// TOTP secrets are stored encrypted and recovery codes as SHA-256 hashes.
+type MFAService struct {
+	memberRepo     repositories.MemberRepository
+	orgRepo        repositories.OrganizationRepository
+	credentialRepo repositories.WebAuthnCredentialRepository
+	challengeRepo  cache.MFAChallengeRepository
+	encryptionKey  []byte
+}
+
+func NewMFAService(
+	memberRepo repositories.MemberRepository,
+	orgRepo repositories.OrganizationRepository,
+	credentialRepo repositories.WebAuthnCredentialRepository,
+	challengeRepo cache.MFAChallengeRepository,
+	encryptionKey []byte,
+) *MFAService {
+	return &MFAService{
+		memberRepo:     memberRepo,
+		orgRepo:        orgRepo,
+		credentialRepo: credentialRepo,
+		challengeRepo:  challengeRepo,
+		encryptionKey:  encryptionKey,
+	}
+}
+
+func (s *MFAService) Status(ctx context.Context, memberID uuid.UUID) (*MFAStatus, error) {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+	org, err := s.orgRepo.GetByID(ctx, member.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	passkeys, err := s.credentialRepo.CountByMemberID(ctx, member.ID)
+	if err != nil {
+		return nil, err
+	}
+	status := &MFAStatus{
+		Enrolled: member.HasTOTP() || passkeys > 0,
+		Required: org.MFARequired,
+		TOTP:     member.HasTOTP(),
+		Passkeys: int(passkeys),
+	}
+	if status.TOTP {
+		status.RecoveryCodesRemaining = len(member.RecoveryCodes)
+	}
+	return status, nil
+}
+
+// BeginChallenge parks a login that still needs the member's second factor and
+// returns the token that identifies it. authMethods are the methods the member
+// already authenticated with, carried over into the session.
+func (s *MFAService) BeginChallenge(ctx context.Context, memberID uuid.UUID, returnTo, host string, authMethods []string) (string, error) {
+	token, err := generateMFAToken()
+	if err != nil {
+		return "", err
+	}
+	challenge := cache.MFAChallenge{
+		MemberID:    memberID.String(),
+		ReturnTo:    returnTo,
+		Host:        host,
+		AuthMethods: authMethods,
+	}
+	if err := s.challengeRepo.SaveChallenge(ctx, token, challenge, mfaChallengeTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// CompleteChallenge checks a TOTP or recovery code against a pending login and
+// returns the login once the code is accepted. A challenge survives wrong codes
+// until the member's failure limit is reached.
+func (s *MFAService) CompleteChallenge(ctx context.Context, token, code string) (*cache.MFAChallenge, error) {
+	if token == "" {
+		return nil, core.ErrInvalidToken
+	}
+	challenge, err := s.challengeRepo.GetChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	memberID, err := uuid.Parse(challenge.MemberID)
+	if err != nil {
+		return nil, core.ErrInvalidToken
+	}
+
+	if err := s.VerifyCode(ctx, memberID, code); err != nil {
+		if errors.Is(err, core.ErrTooManyRequests) {
+			_ = s.challengeRepo.DeleteChallenge(ctx, token)
+		}
+		return nil, err
+	}
+
+	if err := s.challengeRepo.DeleteChallenge(ctx, token); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// PendingChallenge returns a pending login without resolving it, for second
+// factors verified elsewhere such as passkeys.
+func (s *MFAService) PendingChallenge(ctx context.Context, token string) (*cache.MFAChallenge, error) {
+	if token == "" {
+		return nil, core.ErrInvalidToken
+	}
+	return s.challengeRepo.GetChallenge(ctx, token)
+}
+
+// ResolveChallenge ends a pending login whose second factor was verified
+// elsewhere and returns it.
+func (s *MFAService) ResolveChallenge(ctx context.Context, token string) (*cache.MFAChallenge, error) {
+	challenge, err := s.PendingChallenge(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.challengeRepo.DeleteChallenge(ctx, token); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// VerifyCode accepts a current TOTP code or consumes one of the member's
+// recovery codes. Once the member failed too often no code is checked until
+// the failure window ends.
+func (s *MFAService) VerifyCode(ctx context.Context, memberID uuid.UUID, code string) error {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	if err := s.checkFailures(ctx, member); err != nil {
+		return err
+	}
+
+	ok, err := s.checkCode(ctx, member, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.recordFailure(ctx, member)
+	}
+	return nil
+}
+
+// Enroll starts (or restarts) TOTP enrollment and returns the secret and its
+// otpauth:// URI. The authenticator is not used until ConfirmEnrollment.
+func (s *MFAService) Enroll(ctx context.Context, memberID uuid.UUID) (string, string, error) {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return "", "", err
+	}
+	if member.HasTOTP() {
+		return "", "", core.ErrConflict
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return "", "", err
+	}
+	encrypted, err := secrets.Encrypt(s.encryptionKey, secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	member.TOTPSecret = &encrypted
+	member.TOTPConfirmedAt = nil
+	member.TOTPLastStep = 0
+	if err := s.memberRepo.Update(ctx, member); err != nil {
+		return "", "", err
+	}
+	return secret, totp.URI(secret, mfaIssuer, member.Email), nil
+}
+
+// ConfirmEnrollment activates a pending authenticator once the member proves
+// it works, and returns a fresh set of recovery codes.
+func (s *MFAService) ConfirmEnrollment(ctx context.Context, memberID uuid.UUID, code string) ([]string, error) {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if member.HasTOTP() {
+		return nil, core.ErrConflict
+	}
+	if member.TOTPSecret == nil {
+		return nil, fmt.Errorf("%w: enrollment has not been started", core.ErrBadRequest)
+	}
+	if err := s.checkFailures(ctx, member); err != nil {
+		return nil, err
+	}
+
+	step, ok, err := s.checkTOTP(ctx, member, code)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, s.recordFailure(ctx, member)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	member.TOTPConfirmedAt = &now
+	member.TOTPLastStep = step
+	member.RecoveryCodes = hashes
+	if err := s.memberRepo.Update(ctx, member); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of an enrolled member.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, memberID uuid.UUID) ([]string, error) {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if !member.HasTOTP() {
+		return nil, fmt.Errorf("%w: no authenticator enrolled", core.ErrBadRequest)
+	}
+
+	codes, hashes, err := generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	member.RecoveryCodes = hashes
+	if err := s.memberRepo.Update(ctx, member); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable removes the member's authenticator and recovery codes. Members of an
+// organization that requires MFA cannot remove it themselves unless they have
+// a passkey left; enforce is false for administrators resetting a lost device.
+func (s *MFAService) Disable(ctx context.Context, memberID uuid.UUID, enforce bool) error {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	if enforce {
+		org, err := s.orgRepo.GetByID(ctx, member.OrganizationID)
+		if err != nil {
+			return err
+		}
+		passkeys, err := s.credentialRepo.CountByMemberID(ctx, memberID)
+		if err != nil {
+			return err
+		}
+		if org.MFARequired && passkeys == 0 {
+			return core.ErrForbidden
+		}
+	}
+
+	member.TOTPSecret = nil
+	member.TOTPConfirmedAt = nil
+	member.TOTPLastStep = 0
+	member.RecoveryCodes = models.StringArray{}
+	return s.memberRepo.Update(ctx, member)
+}
+
+// ResetMember lets an administrator remove the authenticator and passkeys of a
+// member of their organization.
+func (s *MFAService) ResetMember(ctx context.Context, organizationID, memberID uuid.UUID) error {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	if member.OrganizationID != organizationID {
+		return core.ErrNotFound
+	}
+	log.Printf("Security: MFA reset for member %s by administrator", memberID)
+	if err := s.credentialRepo.DeleteByMemberID(ctx, memberID); err != nil {
+		return err
+	}
+	return s.Disable(ctx, memberID, false)
+}
+
+func (s *MFAService) GetOrganizationPolicy(ctx context.Context, organizationID uuid.UUID) (bool, error) {
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return false, err
+	}
+	return org.MFARequired, nil
+}
+
+func (s *MFAService) SetOrganizationPolicy(ctx context.Context, organizationID uuid.UUID, required bool) error {
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return err
+	}
+	org.MFARequired = required
+	return s.orgRepo.Update(ctx, org)
+}
+
+// checkCode tries the code as a TOTP code first and then as a recovery code,
+// persisting the consumed recovery code.
+func (s *MFAService) checkCode(ctx context.Context, member *models.OrganizationMember, code string) (bool, error) {
+	if member.HasTOTP() {
+		_, ok, err := s.checkTOTP(ctx, member, code)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+
+	hash := hashRecoveryCode(code)
+	for i, stored := range member.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+			member.RecoveryCodes = append(member.RecoveryCodes[:i:i], member.RecoveryCodes[i+1:]...)
+			log.Printf("Security: recovery code used by member %s, %d remaining", member.ID, len(member.RecoveryCodes))
+			return true, s.memberRepo.Update(ctx, member)
+		}
+	}
+	return false, nil
+}
+
+// checkTOTP validates a TOTP code and records its time step, rejecting codes
+// from a step that was already used. The step only advances if no concurrent
+// request used it first, so a code is accepted at most once.
+func (s *MFAService) checkTOTP(ctx context.Context, member *models.OrganizationMember, code string) (int64, bool, error) {
+	secret, err := secrets.Decrypt(s.encryptionKey, *member.TOTPSecret)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to decrypt TOTP secret for member %s: %w", member.ID, err)
+	}
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok || step <= member.TOTPLastStep {
+		return 0, false, nil
+	}
+	advanced, err := s.memberRepo.AdvanceTOTPStep(ctx, member.ID, step)
+	if err != nil || !advanced {
+		return 0, false, err
+	}
+	member.TOTPLastStep = step
+	return step, true, nil
+}
+
+// checkFailures returns core.ErrTooManyRequests while the member is locked
+// out after too many failed attempts.
+func (s *MFAService) checkFailures(ctx context.Context, member *models.OrganizationMember) error {
+	count, err := s.challengeRepo.FailureCount(ctx, member.ID.String())
+	if err != nil {
+		return err
+	}
+	if count >= mfaFailureLimit {
+		return core.ErrTooManyRequests
+	}
+	return nil
+}
+
+func (s *MFAService) recordFailure(ctx context.Context, member *models.OrganizationMember) error {
+	count, err := s.challengeRepo.CountFailure(ctx, member.ID.String(), mfaFailureWindow)
+	if err != nil {
+		return err
+	}
+	if count >= mfaFailureLimit {
+		log.Printf("Security: too many failed MFA attempts for member %s", member.ID)
+		return core.ErrTooManyRequests
+	}
+	return core.ErrInvalidMFACode
+}
+
+func generateMFAToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// generateRecoveryCodes returns codes formatted as xxxxx-xxxxx for display and
+// their hashes for storage.
+func generateRecoveryCodes() ([]string, models.StringArray, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make(models.StringArray, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, nil, err
+		}
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:10]
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(strings.TrimSpace(code)))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
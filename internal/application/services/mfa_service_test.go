@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/secrets"
+	"github.com/vondr/identity-go/internal/core/totp"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+type fakeMFAChallengeRepo struct {
+	failures map[string]int64
+}
+
+func (r *fakeMFAChallengeRepo) SaveChallenge(ctx context.Context, token string, challenge cache.MFAChallenge, ttl time.Duration) error {
+	return nil
+}
+
+func (r *fakeMFAChallengeRepo) GetChallenge(ctx context.Context, token string) (*cache.MFAChallenge, error) {
+	return nil, core.ErrInvalidToken
+}
+
+func (r *fakeMFAChallengeRepo) DeleteChallenge(ctx context.Context, token string) error {
+	return nil
+}
+
+func (r *fakeMFAChallengeRepo) CountFailure(ctx context.Context, memberID string, window time.Duration) (int64, error) {
+	r.failures[memberID]++
+	return r.failures[memberID], nil
+}
+
+func (r *fakeMFAChallengeRepo) FailureCount(ctx context.Context, memberID string) (int64, error) {
+	return r.failures[memberID], nil
+}
+
+// currentTOTPCode computes the code an authenticator app shows for secret
+// right now.
+func currentTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(totp.Step(time.Now())))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func newTestMFAService(t *testing.T) (*MFAService, *fakeMFAChallengeRepo, *models.OrganizationMember, string) {
+	t.Helper()
+
+	key := secrets.DeriveKey("test-encryption-key", MFASecretsPurpose)
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatalf("generate secret: %v", err)
+	}
+	encrypted, err := secrets.Encrypt(key, secret)
+	if err != nil {
+		t.Fatalf("encrypt secret: %v", err)
+	}
+
+	confirmedAt := time.Now()
+	member := &models.OrganizationMember{
+		ID:              uuid.New(),
+		OrganizationID:  uuid.New(),
+		Email:           "jane@example.com",
+		TOTPSecret:      &encrypted,
+		TOTPConfirmedAt: &confirmedAt,
+		RecoveryCodes:   models.StringArray{hashRecoveryCode("abcde-fghij")},
+	}
+	challenges := &fakeMFAChallengeRepo{failures: map[string]int64{}}
+	service := NewMFAService(
+		&fakeMemberRepo{members: map[uuid.UUID]*models.OrganizationMember{member.ID: member}},
+		nil,
+		nil,
+		challenges,
+		key,
+	)
+	return service, challenges, member, secret
+}
+
+func TestMFAVerifyCodeRejectsReplayedTOTPCode(t *testing.T) {
+	service, _, member, secret := newTestMFAService(t)
+	ctx := context.Background()
+	code := currentTOTPCode(t, secret)
+
+	if err := service.VerifyCode(ctx, member.ID, code); err != nil {
+		t.Fatalf("VerifyCode() error = %v", err)
+	}
+	if err := service.VerifyCode(ctx, member.ID, code); !errors.Is(err, core.ErrInvalidMFACode) {
+		t.Fatalf("VerifyCode() replay error = %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestMFAVerifyCodeChecksNothingWhileLockedOut(t *testing.T) {
+	service, challenges, member, secret := newTestMFAService(t)
+	ctx := context.Background()
+	challenges.failures[member.ID.String()] = mfaFailureLimit
+
+	for _, code := range []string{currentTOTPCode(t, secret), "abcde-fghij"} {
+		if err := service.VerifyCode(ctx, member.ID, code); !errors.Is(err, core.ErrTooManyRequests) {
+			t.Fatalf("VerifyCode(%q) error = %v, want ErrTooManyRequests", code, err)
+		}
+	}
+	if member.TOTPLastStep != 0 || len(member.RecoveryCodes) != 1 {
+		t.Fatalf("locked out member's codes were used: last step %d, %d recovery codes", member.TOTPLastStep, len(member.RecoveryCodes))
+	}
+}
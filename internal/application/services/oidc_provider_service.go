@@ -0,0 +1,389 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/tokens"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+const (
+	oidcCodeTTL    = time.Minute
+	oidcIDTokenTTL = time.Hour
+)
+
+// OpenID Connect scopes that release claims.
+const (
+	OIDCScopeOpenID  = "openid"
+	OIDCScopeEmail   = "email"
+	OIDCScopeProfile = "profile"
+)
+
+// OIDCAuthorizationRequest is an authorization code request from an app
+// acting as an OpenID Connect client.
+type OIDCAuthorizationRequest struct {
+	ClientID      string
+	RedirectURI   string
+	Scope         string
+	Nonce         string
+	CodeChallenge string
+}
+
+// OIDCTokenRequest redeems an authorization code. ClientSecret is empty for
+// public clients, which prove possession with CodeVerifier instead.
+type OIDCTokenRequest struct {
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+}
+
+// OIDCRefreshRequest redeems a refresh token. ClientSecret is empty for
+// public clients.
+type OIDCRefreshRequest struct {
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+}
+
+// OIDCTokens are the tokens issued by the token endpoint. Refreshing does
+// not issue a new ID token.
+type OIDCTokens struct {
+	AccessToken  string
+	RefreshToken string
+	IDToken      string
+	Scope        string
+	ExpiresIn    int
+}
+
+// OIDCUserInfo holds the claims released for the scopes a token was issued
+// with. Empty fields are left out of responses.
+type OIDCUserInfo struct {
+	Subject        string `json:"sub"`
+	Email          string `json:"email,omitempty"`
+	EmailVerified  *bool  `json:"email_verified,omitempty"`
+	Name           string `json:"name,omitempty"`
+	GivenName      string `json:"given_name,omitempty"`
+	FamilyName     string `json:"family_name,omitempty"`
+	OrganizationID string `json:"organization_id,omitempty"`
+}
+
+// oidcIDTokenClaims lists the registered claims itself rather than
+// embedding jwt.Claims, whose sub would collide with OIDCUserInfo's.
+type oidcIDTokenClaims struct {
+	OIDCUserInfo
+	Issuer   string           `json:"iss"`
+	Audience jwt.Audience     `json:"aud"`
+	IssuedAt *jwt.NumericDate `json:"iat"`
+	Expiry   *jwt.NumericDate `json:"exp"`
+	Nonce    string           `json:"nonce,omitempty"`
+	AuthTime int64            `json:"auth_time,omitempty"`
+	AMR      []string         `json:"amr,omitempty"`
+}
+
+// OIDCProviderService lets apps sign members in with OpenID Connect, using
+// the member's session as the single sign-on session. Apps are the clients;
+// their ID is the client ID. Access tokens are sessions derived from the
+// approving session, so forward auth accepts them as bearer tokens; they come
+// with a refresh token.
+type OIDCProviderService struct {
+	appRepo        repositories.AppRepository
+	memberRepo     repositories.MemberRepository
+	orgRepo        repositories.OrganizationRepository
+	codeRepo       cache.OIDCAuthorizationCodeRepository
+	sessionManager *SessionManager
+	refreshTokens  *RefreshTokenService
+	signer         *tokens.Signer
+	issuer         string
+}
+
+func NewOIDCProviderService(
+	appRepo repositories.AppRepository,
+	memberRepo repositories.MemberRepository,
+	orgRepo repositories.OrganizationRepository,
+	codeRepo cache.OIDCAuthorizationCodeRepository,
+	sessionManager *SessionManager,
+	refreshTokens *RefreshTokenService,
+	signer *tokens.Signer,
+	issuer string,
+) *OIDCProviderService {
+	return &OIDCProviderService{
+		appRepo:        appRepo,
+		memberRepo:     memberRepo,
+		orgRepo:        orgRepo,
+		codeRepo:       codeRepo,
+		sessionManager: sessionManager,
+		refreshTokens:  refreshTokens,
+		signer:         signer,
+		issuer:         strings.TrimSuffix(issuer, "/"),
+	}
+}
+
+func (s *OIDCProviderService) Issuer() string {
+	return s.issuer
+}
+
+// GetClient returns the app registered as a client under clientID after
+// checking that redirectURI is one of its redirect URIs.
+func (s *OIDCProviderService) GetClient(ctx context.Context, clientID, redirectURI string) (*models.App, error) {
+	app, err := s.lookupClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(app.OIDCRedirectURIs, redirectURI) {
+		return nil, fmt.Errorf("%w: redirect_uri is not registered for the client", core.ErrBadRequest)
+	}
+	return app, nil
+}
+
+// Authorize issues an authorization code for the member of the session. The
+// member must belong to the app's organization, or the app must be a
+// platform app, and the session must meet the organization's MFA policy. Only
+// browser sessions act as the provider's session; access tokens issued to
+// clients cannot authorize other clients.
+func (s *OIDCProviderService) Authorize(ctx context.Context, request OIDCAuthorizationRequest, sessionToken string) (string, error) {
+	app, err := s.GetClient(ctx, request.ClientID, request.RedirectURI)
+	if err != nil {
+		return "", err
+	}
+	if app.OIDCClientSecretHash == nil && request.CodeChallenge == "" {
+		return "", fmt.Errorf("%w: public clients must use PKCE", core.ErrBadRequest)
+	}
+
+	session, err := s.sessionManager.GetSession(ctx, sessionToken)
+	if err != nil || !isBrowserSession(session) {
+		return "", core.ErrInvalidSession
+	}
+	if err := s.checkAccess(ctx, app, session); err != nil {
+		return "", err
+	}
+
+	code, err := generateAuthorizationCode()
+	if err != nil {
+		return "", err
+	}
+	err = s.codeRepo.SaveCode(ctx, hashOIDCCode(code), cache.OIDCAuthorizationCode{
+		ClientID:      request.ClientID,
+		RedirectURI:   request.RedirectURI,
+		Scope:         request.Scope,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+		Session:       *session,
+	}, oidcCodeTTL)
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// Exchange redeems an authorization code for an access token, refresh token
+// and ID token.
+func (s *OIDCProviderService) Exchange(ctx context.Context, request OIDCTokenRequest) (*OIDCTokens, error) {
+	app, err := s.authenticateClient(ctx, request.ClientID, request.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := s.codeRepo.ConsumeCode(ctx, hashOIDCCode(request.Code))
+	if err != nil {
+		return nil, err
+	}
+	if code.ClientID != request.ClientID || code.RedirectURI != request.RedirectURI {
+		log.Printf("Security: authorization code of client %s redeemed by client %s or with another redirect_uri", code.ClientID, request.ClientID)
+		return nil, core.ErrInvalidToken
+	}
+	if code.CodeChallenge != "" && !verifyCodeChallenge(code.CodeChallenge, request.CodeVerifier) {
+		return nil, core.ErrInvalidToken
+	}
+
+	member, err := s.sessionMember(ctx, &code.Session)
+	if err != nil {
+		return nil, core.ErrInvalidToken
+	}
+
+	memberTokens, err := s.refreshTokens.Grant(ctx, code.Session, app.ID.String(), app.ID.String(), code.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	idToken, err := s.signer.Sign(ctx, oidcIDTokenClaims{
+		OIDCUserInfo: userInfoForScope(member, code.Scope),
+		Issuer:       s.issuer,
+		Audience:     jwt.Audience{app.ID.String()},
+		IssuedAt:     jwt.NewNumericDate(now),
+		Expiry:       jwt.NewNumericDate(now.Add(oidcIDTokenTTL)),
+		Nonce:        code.Nonce,
+		AuthTime:     code.Session.AuthTime,
+		AMR:          code.Session.AuthMethods,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &OIDCTokens{
+		AccessToken:  memberTokens.AccessToken,
+		RefreshToken: memberTokens.RefreshToken,
+		IDToken:      idToken,
+		Scope:        memberTokens.Scope,
+		ExpiresIn:    memberTokens.ExpiresIn,
+	}, nil
+}
+
+// Refresh redeems a refresh token for a new access token and refresh token.
+func (s *OIDCProviderService) Refresh(ctx context.Context, request OIDCRefreshRequest) (*OIDCTokens, error) {
+	app, err := s.authenticateClient(ctx, request.ClientID, request.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	memberTokens, err := s.refreshTokens.Refresh(ctx, app.ID.String(), request.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return &OIDCTokens{
+		AccessToken:  memberTokens.AccessToken,
+		RefreshToken: memberTokens.RefreshToken,
+		Scope:        memberTokens.Scope,
+		ExpiresIn:    memberTokens.ExpiresIn,
+	}, nil
+}
+
+// UserInfo returns the claims of the member an access token was issued to,
+// limited to the scopes the member granted, which the token carries from its
+// refresh token family.
+func (s *OIDCProviderService) UserInfo(ctx context.Context, accessToken string) (*OIDCUserInfo, error) {
+	session, err := s.sessionManager.GetSession(ctx, accessToken)
+	if err != nil || session.ClientID == "" {
+		return nil, core.ErrInvalidToken
+	}
+	member, err := s.sessionMember(ctx, session)
+	if err != nil {
+		return nil, core.ErrInvalidToken
+	}
+	info := userInfoForScope(member, session.Scope)
+	return &info, nil
+}
+
+func (s *OIDCProviderService) lookupClient(ctx context.Context, clientID string) (*models.App, error) {
+	id, err := uuid.Parse(clientID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown client_id", core.ErrNotFound)
+	}
+	app, err := s.appRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !app.IsOIDCClient() {
+		return nil, fmt.Errorf("%w: unknown client_id", core.ErrNotFound)
+	}
+	return app, nil
+}
+
+// authenticateClient checks the secret of confidential clients. Public
+// clients authenticate with their client ID alone.
+func (s *OIDCProviderService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.App, error) {
+	app, err := s.lookupClient(ctx, clientID)
+	if err != nil {
+		return nil, core.ErrUnauthorized
+	}
+	if app.OIDCClientSecretHash == nil {
+		return app, nil
+	}
+	if subtle.ConstantTimeCompare([]byte(hashClientSecret(clientSecret)), []byte(*app.OIDCClientSecretHash)) != 1 {
+		log.Printf("Security: invalid client secret for OIDC client %s", clientID)
+		return nil, core.ErrUnauthorized
+	}
+	return app, nil
+}
+
+func (s *OIDCProviderService) checkAccess(ctx context.Context, app *models.App, session *cache.SessionData) error {
+	member, err := s.sessionMember(ctx, session)
+	if err != nil {
+		return core.ErrInvalidSession
+	}
+
+	if member.Role != core.MemberRoleSystem && !app.IsPlatformApp && app.OrganizationID != member.OrganizationID {
+		return fmt.Errorf("%w: member does not belong to the app's organization", core.ErrAccessDenied)
+	}
+
+	if !session.MFASatisfied {
+		org, err := s.orgRepo.GetByID(ctx, member.OrganizationID)
+		if err != nil {
+			return err
+		}
+		if org.MFARequired {
+			return fmt.Errorf("%w: organization requires MFA", core.ErrForbidden)
+		}
+	}
+	return nil
+}
+
+func (s *OIDCProviderService) sessionMember(ctx context.Context, session *cache.SessionData) (*models.OrganizationMember, error) {
+	memberID, err := uuid.Parse(session.MemberID)
+	if err != nil {
+		return nil, core.ErrNotFound
+	}
+	return s.memberRepo.GetByID(ctx, memberID)
+}
+
+func userInfoForScope(member *models.OrganizationMember, scope string) OIDCUserInfo {
+	scopes := strings.Fields(scope)
+	info := OIDCUserInfo{Subject: member.ID.String()}
+
+	if slices.Contains(scopes, OIDCScopeEmail) {
+		verified := true
+		info.Email = member.Email
+		info.EmailVerified = &verified
+	}
+	if slices.Contains(scopes, OIDCScopeProfile) {
+		if member.FirstName != nil {
+			info.GivenName = *member.FirstName
+		}
+		if member.LastName != nil {
+			info.FamilyName = *member.LastName
+		}
+		info.Name = strings.TrimSpace(info.GivenName + " " + info.FamilyName)
+		info.OrganizationID = member.OrganizationID.String()
+	}
+	return info
+}
+
+// verifyCodeChallenge checks an S256 PKCE code verifier (RFC 7636).
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if verifier == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+func generateAuthorizationCode() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashOIDCCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
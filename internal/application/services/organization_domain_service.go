@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+// Domains are verified by publishing the verification token as a TXT record
+// at DomainVerificationRecordPrefix + domain.
+const (
+	DomainVerificationRecordPrefix = "_vondr-identity."
+	domainVerificationValuePrefix  = "vondr-identity-verification="
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// HomeRealm is the organization and login provider responsible for an email
+// domain. ProviderSlug is empty when the organization has no provider of its
+// own and the built-in Microsoft provider should be used.
+type HomeRealm struct {
+	OrganizationID uuid.UUID
+	Domain         string
+	ProviderSlug   string
+	SAML           bool
+}
+
+type OrganizationDomainService struct {
+	domainRepo     repositories.OrganizationDomainRepository
+	orgRepo        repositories.OrganizationRepository
+	providerRepo   repositories.IdentityProviderRepository
+	connectionRepo repositories.SAMLConnectionRepository
+}
+
+func NewOrganizationDomainService(
+	domainRepo repositories.OrganizationDomainRepository,
+	orgRepo repositories.OrganizationRepository,
+	providerRepo repositories.IdentityProviderRepository,
+	connectionRepo repositories.SAMLConnectionRepository,
+) *OrganizationDomainService {
+	return &OrganizationDomainService{
+		domainRepo:     domainRepo,
+		orgRepo:        orgRepo,
+		providerRepo:   providerRepo,
+		connectionRepo: connectionRepo,
+	}
+}
+
+func (s *OrganizationDomainService) List(ctx context.Context, organizationID uuid.UUID) ([]*models.OrganizationDomain, error) {
+	if _, err := s.orgRepo.GetByID(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	return s.domainRepo.ListByOrganizationID(ctx, organizationID)
+}
+
+func (s *OrganizationDomainService) Get(ctx context.Context, organizationID, domainID uuid.UUID) (*models.OrganizationDomain, error) {
+	domain, err := s.domainRepo.GetByID(ctx, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if domain.OrganizationID != organizationID {
+		return nil, core.ErrNotFound
+	}
+	return domain, nil
+}
+
+// Create claims an email domain for an organization. The domain is not used
+// for discovery until Verify has found the verification TXT record.
+func (s *OrganizationDomainService) Create(ctx context.Context, organizationID uuid.UUID, domain string) (*models.OrganizationDomain, error) {
+	if _, err := s.orgRepo.GetByID(ctx, organizationID); err != nil {
+		return nil, err
+	}
+
+	domain = normalizeDomain(domain)
+	if !domainPattern.MatchString(domain) {
+		return nil, fmt.Errorf("%w: invalid domain", core.ErrBadRequest)
+	}
+	if _, err := s.domainRepo.GetByDomain(ctx, domain); err == nil {
+		return nil, core.ErrConflict
+	} else if err != core.ErrNotFound {
+		return nil, err
+	}
+
+	record := &models.OrganizationDomain{
+		ID:                uuid.New(),
+		OrganizationID:    organizationID,
+		Domain:            domain,
+		VerificationToken: uuid.New().String(),
+	}
+	if err := s.domainRepo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// Verify looks up the domain's verification TXT record and marks the domain
+// verified when it carries the expected token.
+func (s *OrganizationDomainService) Verify(ctx context.Context, organizationID, domainID uuid.UUID) (*models.OrganizationDomain, error) {
+	domain, err := s.Get(ctx, organizationID, domainID)
+	if err != nil {
+		return nil, err
+	}
+	if domain.IsVerified() {
+		return domain, nil
+	}
+
+	records, err := net.DefaultResolver.LookupTXT(ctx, DomainVerificationRecordPrefix+domain.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("%w: verification record not found for %s", core.ErrBadRequest, domain.Domain)
+	}
+
+	expected := domainVerificationValuePrefix + domain.VerificationToken
+	for _, record := range records {
+		if strings.TrimSpace(record) == expected {
+			now := time.Now()
+			domain.VerifiedAt = &now
+			if err := s.domainRepo.Update(ctx, domain); err != nil {
+				return nil, err
+			}
+			return domain, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: verification record for %s does not contain the expected token", core.ErrBadRequest, domain.Domain)
+}
+
+func (s *OrganizationDomainService) Delete(ctx context.Context, organizationID, domainID uuid.UUID) error {
+	if _, err := s.Get(ctx, organizationID, domainID); err != nil {
+		return err
+	}
+	return s.domainRepo.Delete(ctx, domainID)
+}
+
+// VerificationRecord returns the TXT record name and value that proves
+// ownership of a domain.
+func (s *OrganizationDomainService) VerificationRecord(domain *models.OrganizationDomain) (string, string) {
+	return DomainVerificationRecordPrefix + domain.Domain, domainVerificationValuePrefix + domain.VerificationToken
+}
+
+// Discover resolves the organization owning the verified domain of an email
+// address (or bare domain) and picks its login provider: an enabled OIDC
+// provider first, then an enabled SAML connection, otherwise the built-in
+// Microsoft provider.
+func (s *OrganizationDomainService) Discover(ctx context.Context, emailOrDomain string) (*HomeRealm, error) {
+	domain := emailOrDomain
+	if at := strings.LastIndex(domain, "@"); at >= 0 {
+		domain = domain[at+1:]
+	}
+	domain = normalizeDomain(domain)
+	if !domainPattern.MatchString(domain) {
+		return nil, core.ErrNotFound
+	}
+
+	record, err := s.domainRepo.GetByDomain(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+	if !record.IsVerified() {
+		return nil, core.ErrNotFound
+	}
+
+	realm := &HomeRealm{
+		OrganizationID: record.OrganizationID,
+		Domain:         record.Domain,
+	}
+
+	providers, err := s.providerRepo.ListByOrganizationID(ctx, record.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, provider := range providers {
+		if provider.Enabled {
+			realm.ProviderSlug = provider.Slug
+			return realm, nil
+		}
+	}
+
+	connections, err := s.connectionRepo.ListByOrganizationID(ctx, record.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	for _, connection := range connections {
+		if connection.Enabled {
+			realm.ProviderSlug = connection.Slug
+			realm.SAML = true
+			return realm, nil
+		}
+	}
+
+	return realm, nil
+}
+
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
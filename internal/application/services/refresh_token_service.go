@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+// MemberTokens are the tokens a client receives for a member: a short-lived
+// access token and the refresh token to replace it with.
+type MemberTokens struct {
+	AccessToken  string
+	RefreshToken string
+	Scope        string
+	ExpiresIn    int
+}
+
+// RefreshTokenService issues refresh tokens to OpenID Connect and device
+// clients. Refresh tokens are rotated on every use and belong to a family
+// that ends a fixed time after the member authorized the client. A rotated
+// token presented again means it was copied, so the whole family is revoked
+// and an audit event is raised. Only SHA-256 hashes of the tokens are stored.
+type RefreshTokenService struct {
+	repo           repositories.RefreshTokenRepository
+	auditRepo      repositories.AuditEventRepository
+	memberRepo     repositories.MemberRepository
+	sessionManager *SessionManager
+	ttl            time.Duration
+}
+
+func NewRefreshTokenService(
+	repo repositories.RefreshTokenRepository,
+	auditRepo repositories.AuditEventRepository,
+	memberRepo repositories.MemberRepository,
+	sessionManager *SessionManager,
+	ttl time.Duration,
+) *RefreshTokenService {
+	return &RefreshTokenService{
+		repo:           repo,
+		auditRepo:      auditRepo,
+		memberRepo:     memberRepo,
+		sessionManager: sessionManager,
+		ttl:            ttl,
+	}
+}
+
+// Grant issues an access token and a refresh token starting a new family to
+// clientID for the member of session. A non-empty audience restricts the
+// access tokens to that app; device clients pass none and reach every app of
+// the member's organization.
+func (s *RefreshTokenService) Grant(ctx context.Context, session cache.SessionData, clientID, audience, scope string) (*MemberTokens, error) {
+	memberID, err := uuid.Parse(session.MemberID)
+	if err != nil {
+		return nil, core.ErrInvalidSession
+	}
+	organizationID, err := uuid.Parse(session.OrganizationID)
+	if err != nil {
+		return nil, core.ErrInvalidSession
+	}
+
+	refreshToken, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	family := &models.RefreshTokenFamily{
+		MemberID:       memberID,
+		OrganizationID: organizationID,
+		ClientID:       clientID,
+		Scope:          scope,
+		Audience:       audience,
+		MicrosoftID:    session.MicrosoftID,
+		MFASatisfied:   session.MFASatisfied,
+		AuthTime:       time.Unix(session.AuthTime, 0),
+		AuthMethods:    session.AuthMethods,
+		ExpiresAt:      time.Now().Add(s.ttl),
+	}
+	if err := s.repo.CreateFamily(ctx, family, &models.RefreshToken{TokenHash: hashToken(refreshToken)}); err != nil {
+		return nil, err
+	}
+
+	accessToken, ttl, err := s.sessionManager.CreateDerivedSession(ctx, session, family)
+	if err != nil {
+		return nil, err
+	}
+	return &MemberTokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		Scope:        scope,
+		ExpiresIn:    int(ttl.Seconds()),
+	}, nil
+}
+
+// Refresh rotates refreshToken and issues a new access token with it. Tokens
+// that are unknown, rotated, expired, revoked or issued to another client are
+// rejected with core.ErrInvalidToken.
+func (s *RefreshTokenService) Refresh(ctx context.Context, clientID, refreshToken string) (*MemberTokens, error) {
+	if refreshToken == "" {
+		return nil, core.ErrInvalidToken
+	}
+
+	var tokens *MemberTokens
+	var reused *models.RefreshTokenFamily
+	err := s.repo.Transaction(ctx, func(repo repositories.RefreshTokenRepository) error {
+		token, err := repo.GetByTokenHash(ctx, hashToken(refreshToken))
+		if err != nil {
+			if errors.Is(err, core.ErrNotFound) {
+				return core.ErrInvalidToken
+			}
+			return err
+		}
+		family := token.Family
+		if family.ClientID != clientID {
+			log.Printf("Security: refresh token of client %s presented by client %s", family.ClientID, clientID)
+			return core.ErrInvalidToken
+		}
+
+		now := time.Now()
+		if !family.IsActive(now) {
+			return core.ErrInvalidToken
+		}
+		if token.RotatedAt != nil {
+			reused = family
+			return revokeRefreshTokenFamily(ctx, repo, family, core.RefreshTokenRevokedReuse)
+		}
+
+		member, err := s.memberRepo.GetByID(ctx, family.MemberID)
+		if err != nil && !errors.Is(err, core.ErrNotFound) {
+			return err
+		}
+		if member == nil || member.OrganizationID != family.OrganizationID {
+			return revokeRefreshTokenFamily(ctx, repo, family, core.RefreshTokenRevokedMemberGone)
+		}
+
+		token.RotatedAt = &now
+		if err := repo.Update(ctx, token); err != nil {
+			return err
+		}
+		family.LastUsedAt = &now
+		if err := repo.UpdateFamily(ctx, family); err != nil {
+			return err
+		}
+		next, err := generateRefreshToken()
+		if err != nil {
+			return err
+		}
+		if err := repo.Create(ctx, &models.RefreshToken{FamilyID: family.ID, TokenHash: hashToken(next)}); err != nil {
+			return err
+		}
+
+		// The access token is created before the rotation commits, so a
+		// failure here leaves the presented refresh token usable.
+		accessToken, ttl, err := s.sessionManager.CreateDerivedSession(ctx, refreshedSession(family, member), family)
+		if err != nil {
+			return err
+		}
+		tokens = &MemberTokens{
+			AccessToken:  accessToken,
+			RefreshToken: next,
+			Scope:        family.Scope,
+			ExpiresIn:    int(ttl.Seconds()),
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if reused != nil {
+		s.raiseReuse(ctx, reused)
+		return nil, core.ErrInvalidToken
+	}
+	if tokens == nil {
+		return nil, core.ErrInvalidToken
+	}
+	return tokens, nil
+}
+
+// Lookup returns refreshToken with its family if it can still be used.
+func (s *RefreshTokenService) Lookup(ctx context.Context, refreshToken string) (*models.RefreshToken, error) {
+	token, err := s.repo.GetByTokenHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+	if token.RotatedAt != nil || !token.Family.IsActive(time.Now()) {
+		return nil, core.ErrInvalidToken
+	}
+	return token, nil
+}
+
+// RevokeFamily revokes family so none of its tokens can be refreshed, and
+// ends the access tokens issued from it.
+func (s *RefreshTokenService) RevokeFamily(ctx context.Context, family *models.RefreshTokenFamily, reason core.RefreshTokenRevocation) error {
+	if err := revokeRefreshTokenFamily(ctx, s.repo, family, reason); err != nil {
+		return err
+	}
+	return s.sessionManager.DeleteFamilySessions(ctx, family.MemberID, family.ID)
+}
+
+// raiseReuse ends the access tokens of a family revoked for reuse of a
+// rotated refresh token and records the reuse. Failing either does not undo
+// the revocation.
+func (s *RefreshTokenService) raiseReuse(ctx context.Context, family *models.RefreshTokenFamily) {
+	log.Printf("Security: rotated refresh token of member %s reused by client %s, family %s revoked", family.MemberID, family.ClientID, family.ID)
+	if err := s.sessionManager.DeleteFamilySessions(ctx, family.MemberID, family.ID); err != nil {
+		log.Printf("Warning: Failed to end access tokens of refresh token family %s: %v", family.ID, err)
+	}
+
+	memberID := family.MemberID
+	event := &models.AuditEvent{
+		OrganizationID: family.OrganizationID,
+		MemberID:       &memberID,
+		Type:           core.AuditEventRefreshTokenReuse.String(),
+		Details: models.StringMap{
+			"client_id": family.ClientID,
+			"family_id": family.ID.String(),
+		},
+	}
+	if err := s.auditRepo.Create(ctx, event); err != nil {
+		log.Printf("Warning: Failed to record %s audit event for member %s: %v", event.Type, family.MemberID, err)
+	}
+}
+
+func revokeRefreshTokenFamily(ctx context.Context, repo repositories.RefreshTokenRepository, family *models.RefreshTokenFamily, reason core.RefreshTokenRevocation) error {
+	now := time.Now()
+	revokedReason := reason.String()
+	family.RevokedAt = &now
+	family.RevokedReason = &revokedReason
+	return repo.UpdateFamily(ctx, family)
+}
+
+// refreshedSession rebuilds the session an access token is derived from out
+// of what the family kept of the authorizing session and the member's
+// current email.
+func refreshedSession(family *models.RefreshTokenFamily, member *models.OrganizationMember) cache.SessionData {
+	return cache.SessionData{
+		MemberID:       family.MemberID.String(),
+		Email:          member.Email,
+		OrganizationID: family.OrganizationID.String(),
+		MicrosoftID:    family.MicrosoftID,
+		MFASatisfied:   family.MFASatisfied,
+		AuthTime:       family.AuthTime.Unix(),
+		AuthMethods:    family.AuthMethods,
+	}
+}
+
+func generateRefreshToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/saml"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+const maxIDPMetadataSize = 1 << 20
+
+// ErrSAMLNotConfigured is returned when SAML logins are attempted without an
+// SP signing key pair.
+var ErrSAMLNotConfigured = errors.New("SAML service provider key pair is not configured")
+
+type SAMLConnectionInput struct {
+	Slug              string
+	MetadataXML       string
+	MetadataURL       string
+	AttributeMappings map[string]string
+	Enabled           bool
+}
+
+type cachedServiceProvider struct {
+	provider  *saml.ServiceProvider
+	updatedAt time.Time
+}
+
+type SAMLConnectionService struct {
+	connectionRepo repositories.SAMLConnectionRepository
+	orgRepo        repositories.OrganizationRepository
+	key            crypto.Signer
+	certificate    *x509.Certificate
+	baseURL        string
+	httpClient     *http.Client
+
+	mu        sync.Mutex
+	providers map[uuid.UUID]cachedServiceProvider
+}
+
+// NewSAMLConnectionService creates the SAML connection service. key and
+// certificate may be nil when the service is only used for administration.
+func NewSAMLConnectionService(
+	connectionRepo repositories.SAMLConnectionRepository,
+	orgRepo repositories.OrganizationRepository,
+	key crypto.Signer,
+	certificate *x509.Certificate,
+	baseURL string,
+) *SAMLConnectionService {
+	return &SAMLConnectionService{
+		connectionRepo: connectionRepo,
+		orgRepo:        orgRepo,
+		key:            key,
+		certificate:    certificate,
+		baseURL:        strings.TrimSuffix(baseURL, "/"),
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		providers:      make(map[uuid.UUID]cachedServiceProvider),
+	}
+}
+
+// EntityID returns the SP entity ID, which doubles as the metadata URL, for a
+// connection slug.
+func (s *SAMLConnectionService) EntityID(slug string) string {
+	return s.baseURL + "/auth/saml/" + slug + "/metadata"
+}
+
+// ACSURL returns the assertion consumer service URL for a connection slug.
+func (s *SAMLConnectionService) ACSURL(slug string) string {
+	return s.baseURL + "/auth/saml/" + slug + "/acs"
+}
+
+func (s *SAMLConnectionService) List(ctx context.Context, organizationID uuid.UUID) ([]*models.SAMLConnection, error) {
+	if _, err := s.orgRepo.GetByID(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	return s.connectionRepo.ListByOrganizationID(ctx, organizationID)
+}
+
+func (s *SAMLConnectionService) Get(ctx context.Context, organizationID, connectionID uuid.UUID) (*models.SAMLConnection, error) {
+	connection, err := s.connectionRepo.GetByID(ctx, connectionID)
+	if err != nil {
+		return nil, err
+	}
+	if connection.OrganizationID != organizationID {
+		return nil, core.ErrNotFound
+	}
+	return connection, nil
+}
+
+func (s *SAMLConnectionService) Create(ctx context.Context, organizationID uuid.UUID, input *SAMLConnectionInput) (*models.SAMLConnection, error) {
+	if _, err := s.orgRepo.GetByID(ctx, organizationID); err != nil {
+		return nil, err
+	}
+	if input.MetadataXML == "" && input.MetadataURL == "" {
+		return nil, fmt.Errorf("%w: IdP metadata XML or URL is required", core.ErrBadRequest)
+	}
+
+	connection := &models.SAMLConnection{
+		ID:             uuid.New(),
+		OrganizationID: organizationID,
+	}
+	if err := s.apply(ctx, connection, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.connectionRepo.Create(ctx, connection); err != nil {
+		return nil, err
+	}
+	return connection, nil
+}
+
+func (s *SAMLConnectionService) Update(ctx context.Context, organizationID, connectionID uuid.UUID, input *SAMLConnectionInput) (*models.SAMLConnection, error) {
+	connection, err := s.Get(ctx, organizationID, connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apply(ctx, connection, input); err != nil {
+		return nil, err
+	}
+
+	if err := s.connectionRepo.Update(ctx, connection); err != nil {
+		return nil, err
+	}
+	s.forget(connection.ID)
+	return connection, nil
+}
+
+func (s *SAMLConnectionService) Delete(ctx context.Context, organizationID, connectionID uuid.UUID) error {
+	if _, err := s.Get(ctx, organizationID, connectionID); err != nil {
+		return err
+	}
+	if err := s.connectionRepo.Delete(ctx, connectionID); err != nil {
+		return err
+	}
+	s.forget(connectionID)
+	return nil
+}
+
+// Resolve returns the service provider for an enabled connection slug together
+// with its stored configuration.
+func (s *SAMLConnectionService) Resolve(ctx context.Context, slug string) (*saml.ServiceProvider, *models.SAMLConnection, error) {
+	if s.key == nil || s.certificate == nil {
+		return nil, nil, ErrSAMLNotConfigured
+	}
+
+	connection, err := s.connectionRepo.GetBySlug(ctx, slug)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !connection.Enabled {
+		return nil, nil, core.ErrNotFound
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached, ok := s.providers[connection.ID]; ok && cached.updatedAt.Equal(connection.UpdatedAt) {
+		return cached.provider, connection, nil
+	}
+
+	provider, err := saml.NewServiceProvider(saml.ServiceProviderConfig{
+		Name:              connection.Slug,
+		EntityID:          s.EntityID(connection.Slug),
+		ACSURL:            s.ACSURL(connection.Slug),
+		Key:               s.key,
+		Certificate:       s.certificate,
+		IDPMetadata:       []byte(connection.IDPMetadataXML),
+		AttributeMappings: connection.AttributeMappings,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build SAML service provider %s: %w", connection.Slug, err)
+	}
+	s.providers[connection.ID] = cachedServiceProvider{provider: provider, updatedAt: connection.UpdatedAt}
+
+	return provider, connection, nil
+}
+
+func (s *SAMLConnectionService) forget(connectionID uuid.UUID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.providers, connectionID)
+}
+
+func (s *SAMLConnectionService) apply(ctx context.Context, connection *models.SAMLConnection, input *SAMLConnectionInput) error {
+	slug := strings.ToLower(strings.TrimSpace(input.Slug))
+	if !identityProviderSlugPattern.MatchString(slug) {
+		return fmt.Errorf("%w: slug must be 3-63 lowercase letters, digits or dashes", core.ErrBadRequest)
+	}
+	if existing, err := s.connectionRepo.GetBySlug(ctx, slug); err == nil && existing.ID != connection.ID {
+		return core.ErrConflict
+	} else if err != nil && err != core.ErrNotFound {
+		return err
+	}
+
+	mappings := make(map[string]string, len(input.AttributeMappings))
+	for field, attributeName := range input.AttributeMappings {
+		if !saml.IsMappableAttribute(field) {
+			return fmt.Errorf("%w: attribute %q cannot be mapped", core.ErrBadRequest, field)
+		}
+		if attributeName = strings.TrimSpace(attributeName); attributeName != "" {
+			mappings[field] = attributeName
+		}
+	}
+
+	metadataXML := strings.TrimSpace(input.MetadataXML)
+	if metadataXML == "" && input.MetadataURL != "" {
+		fetched, err := s.fetchMetadata(ctx, strings.TrimSpace(input.MetadataURL))
+		if err != nil {
+			return err
+		}
+		metadataXML = fetched
+	}
+	if metadataXML != "" {
+		descriptor, err := saml.ParseIDPMetadata([]byte(metadataXML))
+		if err != nil {
+			return fmt.Errorf("%w: %v", core.ErrBadRequest, err)
+		}
+		connection.IDPEntityID = descriptor.EntityID
+		connection.IDPMetadataXML = metadataXML
+	}
+
+	connection.Slug = slug
+	connection.AttributeMappings = mappings
+	connection.Enabled = input.Enabled
+	return nil
+}
+
+func (s *SAMLConnectionService) fetchMetadata(ctx context.Context, metadataURL string) (string, error) {
+	if !strings.HasPrefix(metadataURL, "https://") {
+		return "", fmt.Errorf("%w: metadata URL must be an https URL", core.ErrBadRequest)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid metadata URL", core.ErrBadRequest)
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to fetch IdP metadata: %v", core.ErrBadRequest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%w: IdP metadata URL returned status %d", core.ErrBadRequest, resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIDPMetadataSize))
+	if err != nil {
+		return "", fmt.Errorf("%w: failed to read IdP metadata: %v", core.ErrBadRequest, err)
+	}
+	return string(body), nil
+}
@@ -2,44 +2,405 @@ package services
 
 import (
 	"context"
+	"errors"
+	"log"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
 	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+	"github.com/vondr/identity-go/internal/infrastructure/geoip"
 )
 
+// sessionTouchInterval is how often VerifySession records that a session is
+// still in use.
+const sessionTouchInterval = 5 * time.Minute
+
+// Kinds of sessions in a member's session list.
+const (
+	SessionKindBrowser = "browser"
+	SessionKindClient  = "client"
+)
+
+// SessionClient is the client a session is used from.
+type SessionClient struct {
+	UserAgent string
+	IP        string
+}
+
+// SessionInfo describes one of a member's sessions without revealing its
+// token. Clients holding refresh tokens are listed once per refresh token
+// family, with the ID of the family, and show where their latest access
+// token was used.
+type SessionInfo struct {
+	ID         string
+	Kind       string
+	ClientID   string
+	UserAgent  string
+	Device     string
+	Browser    string
+	IP         string
+	Country    string
+	Current    bool
+	CreatedAt  time.Time
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+}
+
 type SessionManager struct {
-	sessionRepo cache.SessionRepository
+	sessionRepo      cache.SessionRepository
+	refreshTokenRepo repositories.RefreshTokenRepository
+	sessionPolicy    *SessionPolicyService
+	geoip            *geoip.GeoIPService
+	accessTokenTTL   time.Duration
 }
 
-func NewSessionManager(sessionRepo cache.SessionRepository) *SessionManager {
+func NewSessionManager(
+	sessionRepo cache.SessionRepository,
+	refreshTokenRepo repositories.RefreshTokenRepository,
+	sessionPolicy *SessionPolicyService,
+	geoipService *geoip.GeoIPService,
+	accessTokenTTL time.Duration,
+) *SessionManager {
 	return &SessionManager{
-		sessionRepo: sessionRepo,
+		sessionRepo:      sessionRepo,
+		refreshTokenRepo: refreshTokenRepo,
+		sessionPolicy:    sessionPolicy,
+		geoip:            geoipService,
+		accessTokenTTL:   accessTokenTTL,
 	}
 }
 
-func (s *SessionManager) CreateSession(ctx context.Context, memberID uuid.UUID, email string, organizationID uuid.UUID, microsoftID string) (string, error) {
+// CreateSession starts a browser session with the lifetimes of the member's
+// organization and returns its token and when it ends at the latest. The
+// session ends earlier when it is not used for the idle timeout.
+func (s *SessionManager) CreateSession(ctx context.Context, memberID uuid.UUID, email string, organizationID uuid.UUID, microsoftID string, authMethods []string, mfaSatisfied bool, client SessionClient) (string, time.Time, error) {
+	lifetimes, err := s.sessionPolicy.Resolve(ctx, organizationID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
 	token := uuid.New().String()
-	ttl := time.Duration(7*24) * time.Hour
+	now := time.Now()
+	expiresAt := now.Add(lifetimes.Lifetime)
 
 	sessionData := cache.SessionData{
 		MemberID:       memberID.String(),
 		Email:          email,
 		OrganizationID: organizationID.String(),
 		MicrosoftID:    microsoftID,
+		MFASatisfied:   mfaSatisfied,
+		AuthTime:       now.Unix(),
+		AuthMethods:    authMethods,
+		CreatedAt:      now.Unix(),
+		IdleTimeout:    int64(lifetimes.IdleTimeout.Seconds()),
+		ExpiresAt:      expiresAt.Unix(),
+	}
+	s.recordClient(&sessionData, client, now)
+
+	if err := s.sessionRepo.CreateSession(ctx, token, sessionData, lifetimes.IdleTimeout); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return token, expiresAt, nil
+}
+
+// CreateDerivedSession creates an access token for a client such as a CLI,
+// carrying over the member, auth time and methods of the session that
+// authorized it, and the client, audience and scope of family. Access tokens
+// are short-lived; clients keep access with their refresh token from family.
+func (s *SessionManager) CreateDerivedSession(ctx context.Context, parent cache.SessionData, family *models.RefreshTokenFamily) (string, time.Duration, error) {
+	token := uuid.New().String()
+
+	now := time.Now()
+
+	sessionData := cache.SessionData{
+		MemberID:       parent.MemberID,
+		Email:          parent.Email,
+		OrganizationID: parent.OrganizationID,
+		MicrosoftID:    parent.MicrosoftID,
+		MFASatisfied:   parent.MFASatisfied,
+		AuthTime:       parent.AuthTime,
+		AuthMethods:    parent.AuthMethods,
+		ClientID:       family.ClientID,
+		FamilyID:       family.ID.String(),
+		Audience:       family.Audience,
+		Scope:          family.Scope,
+		CreatedAt:      now.Unix(),
+		ExpiresAt:      now.Add(s.accessTokenTTL).Unix(),
 	}
 
-	if err := s.sessionRepo.CreateSession(ctx, token, sessionData, ttl); err != nil {
-		return "", err
+	if err := s.sessionRepo.CreateSession(ctx, token, sessionData, s.accessTokenTTL); err != nil {
+		return "", 0, err
 	}
 
-	return token, nil
+	return token, s.accessTokenTTL, nil
 }
 
 func (s *SessionManager) GetSession(ctx context.Context, token string) (*cache.SessionData, error) {
 	return s.sessionRepo.GetSession(ctx, token)
 }
 
+// VerifySession returns the session of token and records that it is in use
+// from client, extending sessions with an idle timeout by that timeout, up to
+// their expiry. To keep writes down the record is updated on first use and
+// then at most every sessionTouchInterval; failing to update it does not
+// fail verification.
+func (s *SessionManager) VerifySession(ctx context.Context, token string, client SessionClient) (*cache.SessionData, error) {
+	sessionData, err := s.sessionRepo.GetSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if sessionData.LastSeenAt != 0 && now.Sub(time.Unix(sessionData.LastSeenAt, 0)) < sessionTouchInterval {
+		return sessionData, nil
+	}
+	touched := *sessionData
+	s.recordClient(&touched, client, now)
+	if err := s.touchSession(ctx, token, touched, now); err != nil && !errors.Is(err, core.ErrInvalidSession) {
+		log.Printf("Warning: Failed to record use of session of member %s: %v", sessionData.MemberID, err)
+	}
+	return &touched, nil
+}
+
+// touchSession stores the usage of the session and slides its expiry if it
+// has an idle timeout. Sessions without one, such as access tokens, keep
+// their expiry.
+func (s *SessionManager) touchSession(ctx context.Context, token string, sessionData cache.SessionData, now time.Time) error {
+	usage := cache.SessionUsage{
+		UserAgent:  sessionData.UserAgent,
+		Device:     sessionData.Device,
+		Browser:    sessionData.Browser,
+		IP:         sessionData.IP,
+		Country:    sessionData.Country,
+		LastSeenAt: sessionData.LastSeenAt,
+	}
+	if sessionData.IdleTimeout == 0 {
+		return s.sessionRepo.TouchSession(ctx, token, usage, 0)
+	}
+	ttl := min(time.Duration(sessionData.IdleTimeout)*time.Second, time.Unix(sessionData.ExpiresAt, 0).Sub(now))
+	if ttl <= 0 {
+		return s.sessionRepo.DeleteSession(ctx, token)
+	}
+	return s.sessionRepo.TouchSession(ctx, token, usage, ttl)
+}
+
+// MarkMFASatisfied records on an existing session that the member completed
+// their second factor using method.
+func (s *SessionManager) MarkMFASatisfied(ctx context.Context, token string, method core.AuthMethod) error {
+	return s.sessionRepo.UpdateSession(ctx, token, func(sessionData *cache.SessionData) {
+		sessionData.MFASatisfied = true
+		sessionData.AuthMethods = core.AppendAuthMethod(sessionData.AuthMethods, method)
+	})
+}
+
 func (s *SessionManager) DeleteSession(ctx context.Context, token string) error {
 	return s.sessionRepo.DeleteSession(ctx, token)
 }
+
+// DeleteAllSessionsForMember ends every session of the member, including
+// access tokens held by clients, revokes their refresh tokens and returns how
+// many sessions and refresh token families were active.
+func (s *SessionManager) DeleteAllSessionsForMember(ctx context.Context, memberID uuid.UUID) (int, error) {
+	families, err := s.refreshTokenRepo.RevokeFamiliesByMemberID(ctx, memberID, core.RefreshTokenRevokedLogout)
+	if err != nil {
+		return 0, err
+	}
+	sessions, err := s.sessionRepo.DeleteAllSessionsForMember(ctx, memberID.String())
+	if err != nil {
+		return families, err
+	}
+	return sessions + families, nil
+}
+
+// ListSessions returns the member's active sessions, most recently used
+// first. The session of currentToken, if any, is marked as current.
+func (s *SessionManager) ListSessions(ctx context.Context, memberID uuid.UUID, currentToken string) ([]SessionInfo, error) {
+	sessions, err := s.sessionRepo.ListSessionsForMember(ctx, memberID.String())
+	if err != nil {
+		return nil, err
+	}
+	families, err := s.refreshTokenRepo.ListActiveFamiliesByMemberID(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	byFamily := make(map[string]*SessionInfo, len(families))
+	latestUse := make(map[string]time.Time, len(families))
+	infos := make([]*SessionInfo, 0, len(sessions)+len(families))
+	for _, family := range families {
+		info := &SessionInfo{
+			ID:         family.ID.String(),
+			Kind:       SessionKindClient,
+			ClientID:   family.ClientID,
+			CreatedAt:  family.CreatedAt,
+			LastSeenAt: family.CreatedAt,
+			ExpiresAt:  family.ExpiresAt,
+		}
+		if family.LastUsedAt != nil {
+			info.LastSeenAt = *family.LastUsedAt
+		}
+		byFamily[info.ID] = info
+		infos = append(infos, info)
+	}
+
+	for _, session := range sessions {
+		data := session.Data
+		lastSeenAt := time.Unix(max(data.LastSeenAt, data.CreatedAt), 0)
+		current := currentToken != "" && session.Token == currentToken
+
+		if family, ok := byFamily[data.FamilyID]; ok {
+			family.Current = family.Current || current
+			if seen, ok := latestUse[data.FamilyID]; !ok || lastSeenAt.After(seen) {
+				latestUse[data.FamilyID] = lastSeenAt
+				family.UserAgent, family.Device, family.Browser = data.UserAgent, data.Device, data.Browser
+				family.IP, family.Country = data.IP, data.Country
+				family.LastSeenAt = laterTime(family.LastSeenAt, lastSeenAt)
+			}
+			continue
+		}
+		if data.FamilyID != "" {
+			// The family was revoked or expired; its access tokens are
+			// about to run out and no longer worth listing.
+			continue
+		}
+
+		kind := SessionKindBrowser
+		if data.ClientID != "" {
+			kind = SessionKindClient
+		}
+		infos = append(infos, &SessionInfo{
+			ID:         sessionID(session.Token),
+			Kind:       kind,
+			ClientID:   data.ClientID,
+			UserAgent:  data.UserAgent,
+			Device:     data.Device,
+			Browser:    data.Browser,
+			IP:         data.IP,
+			Country:    data.Country,
+			Current:    current,
+			CreatedAt:  time.Unix(data.CreatedAt, 0),
+			LastSeenAt: lastSeenAt,
+			ExpiresAt:  sessionExpiresAt(data),
+		})
+	}
+
+	slices.SortFunc(infos, func(a, b *SessionInfo) int {
+		return b.LastSeenAt.Compare(a.LastSeenAt)
+	})
+	result := make([]SessionInfo, len(infos))
+	for i, info := range infos {
+		result[i] = *info
+	}
+	return result, nil
+}
+
+// RevokeSession ends one of the member's sessions by the ID ListSessions
+// reported. Revoking a client listed by its refresh token family revokes the
+// family and ends the access tokens issued from it.
+func (s *SessionManager) RevokeSession(ctx context.Context, memberID uuid.UUID, id string) error {
+	sessions, err := s.sessionRepo.ListSessionsForMember(ctx, memberID.String())
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if sessionID(session.Token) == id {
+			return s.sessionRepo.DeleteSession(ctx, session.Token)
+		}
+	}
+
+	familyID, err := uuid.Parse(id)
+	if err != nil {
+		return core.ErrNotFound
+	}
+	family, err := s.refreshTokenRepo.GetFamilyByID(ctx, familyID)
+	if err != nil {
+		return err
+	}
+	if family.MemberID != memberID || !family.IsActive(time.Now()) {
+		return core.ErrNotFound
+	}
+	if err := revokeRefreshTokenFamily(ctx, s.refreshTokenRepo, family, core.RefreshTokenRevokedLogout); err != nil {
+		return err
+	}
+	return s.DeleteFamilySessions(ctx, family.MemberID, family.ID)
+}
+
+// DeleteFamilySessions ends the access tokens issued from a refresh token
+// family.
+func (s *SessionManager) DeleteFamilySessions(ctx context.Context, memberID, familyID uuid.UUID) error {
+	sessions, err := s.sessionRepo.ListSessionsForMember(ctx, memberID.String())
+	if err != nil {
+		return err
+	}
+	for _, session := range sessions {
+		if session.Data.FamilyID != familyID.String() {
+			continue
+		}
+		if err := s.sessionRepo.DeleteSession(ctx, session.Token); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isBrowserSession reports whether the session is a member's own login
+// rather than an access token issued to a client.
+func isBrowserSession(sessionData *cache.SessionData) bool {
+	return sessionData.ClientID == "" && sessionData.FamilyID == "" && sessionData.Audience == ""
+}
+
+// recordClient stores where the session is used from and when.
+func (s *SessionManager) recordClient(sessionData *cache.SessionData, client SessionClient, now time.Time) {
+	sessionData.LastSeenAt = now.Unix()
+	if client.UserAgent != "" {
+		sessionData.UserAgent = client.UserAgent
+		sessionData.Device, sessionData.Browser = describeUserAgent(client.UserAgent)
+	}
+	if client.IP != "" && client.IP != sessionData.IP {
+		sessionData.IP = client.IP
+		sessionData.Country = s.lookupCountry(client.IP)
+	}
+}
+
+func (s *SessionManager) lookupCountry(ip string) string {
+	if !s.geoip.IsEnabled() || s.geoip.IsPrivateIP(ip) {
+		return ""
+	}
+	country, err := s.geoip.LookupCountry(ip)
+	if err != nil {
+		return ""
+	}
+	return country
+}
+
+// sessionExpiresAt is when the session ends unless it is used again.
+func sessionExpiresAt(sessionData cache.SessionData) time.Time {
+	expiresAt := time.Unix(sessionData.ExpiresAt, 0)
+	if sessionData.IdleTimeout == 0 {
+		return expiresAt
+	}
+	lastSeenAt := time.Unix(max(sessionData.LastSeenAt, sessionData.CreatedAt), 0)
+	idleExpiresAt := lastSeenAt.Add(time.Duration(sessionData.IdleTimeout) * time.Second)
+	if idleExpiresAt.Before(expiresAt) {
+		return idleExpiresAt
+	}
+	return expiresAt
+}
+
+// sessionID identifies a session in session lists without revealing its
+// token.
+func sessionID(token string) string {
+	return hashToken(token)[:32]
+}
+
+func laterTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+// minSessionIdleTimeout is the shortest idle timeout an organization can
+// set. Session use is only recorded every sessionTouchInterval, so shorter
+// timeouts would end sessions that are in use.
+const minSessionIdleTimeout = core.MinSessionIdleMinutes * time.Minute
+
+// SessionLifetimes are how long a browser session lasts. A session ends when
+// it has not been used for IdleTimeout, and at the latest Lifetime after
+// login.
+type SessionLifetimes struct {
+	IdleTimeout time.Duration
+	Lifetime    time.Duration
+}
+
+// SessionPolicy is an organization's override of the global session
+// lifetimes, with the lifetimes that apply to its members. Nil overrides use
+// the global defaults.
+type SessionPolicy struct {
+	IdleTimeoutMinutes *int
+	LifetimeDays       *int
+	Effective          SessionLifetimes
+}
+
+// SessionPolicyService resolves the session lifetimes of an organization from
+// the global defaults and the organization's overrides.
+type SessionPolicyService struct {
+	orgRepo  repositories.OrganizationRepository
+	defaults SessionLifetimes
+}
+
+func NewSessionPolicyService(orgRepo repositories.OrganizationRepository, defaults SessionLifetimes) *SessionPolicyService {
+	return &SessionPolicyService{
+		orgRepo:  orgRepo,
+		defaults: defaults,
+	}
+}
+
+// Resolve returns the session lifetimes for members of the organization.
+func (s *SessionPolicyService) Resolve(ctx context.Context, organizationID uuid.UUID) (SessionLifetimes, error) {
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return SessionLifetimes{}, err
+	}
+	return s.lifetimes(org), nil
+}
+
+func (s *SessionPolicyService) GetOrganizationPolicy(ctx context.Context, organizationID uuid.UUID) (*SessionPolicy, error) {
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	return s.policy(org), nil
+}
+
+// SetOrganizationPolicy replaces the organization's overrides. Sessions
+// already issued keep the lifetimes they were created with.
+func (s *SessionPolicyService) SetOrganizationPolicy(ctx context.Context, organizationID uuid.UUID, idleTimeoutMinutes, lifetimeDays *int) (*SessionPolicy, error) {
+	if idleTimeoutMinutes != nil && time.Duration(*idleTimeoutMinutes)*time.Minute < minSessionIdleTimeout {
+		return nil, fmt.Errorf("%w: idle_timeout_minutes must be at least %d", core.ErrBadRequest, int(minSessionIdleTimeout.Minutes()))
+	}
+	if lifetimeDays != nil && *lifetimeDays < 1 {
+		return nil, fmt.Errorf("%w: lifetime_days must be at least 1", core.ErrBadRequest)
+	}
+
+	org, err := s.orgRepo.GetByID(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	org.SessionIdleTimeoutMinutes = idleTimeoutMinutes
+	org.SessionLifetimeDays = lifetimeDays
+	if err := s.orgRepo.Update(ctx, org); err != nil {
+		return nil, err
+	}
+	return s.policy(org), nil
+}
+
+func (s *SessionPolicyService) policy(org *models.Organization) *SessionPolicy {
+	return &SessionPolicy{
+		IdleTimeoutMinutes: org.SessionIdleTimeoutMinutes,
+		LifetimeDays:       org.SessionLifetimeDays,
+		Effective:          s.lifetimes(org),
+	}
+}
+
+// lifetimes applies the organization's overrides to the defaults. The idle
+// timeout never exceeds the lifetime.
+func (s *SessionPolicyService) lifetimes(org *models.Organization) SessionLifetimes {
+	lifetimes := s.defaults
+	if org.SessionIdleTimeoutMinutes != nil {
+		lifetimes.IdleTimeout = time.Duration(*org.SessionIdleTimeoutMinutes) * time.Minute
+	}
+	if org.SessionLifetimeDays != nil {
+		lifetimes.Lifetime = time.Duration(*org.SessionLifetimeDays) * 24 * time.Hour
+	}
+	lifetimes.IdleTimeout = min(lifetimes.IdleTimeout, lifetimes.Lifetime)
+	return lifetimes
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/core/secrets"
+	"github.com/vondr/identity-go/internal/core/tokens"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+// SigningKeySecretsPurpose derives the key that encrypts signing keys at rest.
+const SigningKeySecretsPurpose = "signing-keys"
+
+// signingKeyCacheTTL bounds how long an instance keeps signing with a key
+// another instance has rotated out. Retiring keys stay published far longer,
+// so tokens signed in that window still verify.
+const signingKeyCacheTTL = time.Minute
+
+// SigningKeyService keeps the keys identity-go signs tokens with. Each key
+// moves from next to active to retiring to retired: a new key is published
+// one rotation period before it signs anything, signs for one rotation
+// period, and stays published for the retention period afterwards. It
+// implements tokens.KeySource.
+type SigningKeyService struct {
+	repo           repositories.SigningKeyRepository
+	encryptionKey  []byte
+	algorithm      jose.SignatureAlgorithm
+	rotationPeriod time.Duration
+	retention      time.Duration
+
+	mu        sync.Mutex
+	published []*models.SigningKey
+	active    *tokens.SigningKey
+	loadedAt  time.Time
+}
+
+func NewSigningKeyService(
+	repo repositories.SigningKeyRepository,
+	encryptionKey []byte,
+	algorithm string,
+	rotationPeriod time.Duration,
+	retention time.Duration,
+) (*SigningKeyService, error) {
+	if !tokens.IsSupportedAlgorithm(algorithm) {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if rotationPeriod <= 0 || retention <= 0 {
+		return nil, fmt.Errorf("signing key rotation period and retention must be positive")
+	}
+	return &SigningKeyService{
+		repo:           repo,
+		encryptionKey:  encryptionKey,
+		algorithm:      jose.SignatureAlgorithm(algorithm),
+		rotationPeriod: rotationPeriod,
+		retention:      retention,
+	}, nil
+}
+
+// Rotate advances keys whose time has come: the active key is replaced by
+// the next key once it has signed for a rotation period, retiring keys are
+// retired after the retention period, and missing active or next keys are
+// created. force replaces the active key regardless of its age.
+func (s *SigningKeyService) Rotate(ctx context.Context, force bool) error {
+	err := s.repo.Transaction(ctx, func(repo repositories.SigningKeyRepository) error {
+		keys, err := repo.ListByStatus(ctx, core.SigningKeyStatusNext, core.SigningKeyStatusActive, core.SigningKeyStatusRetiring)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		var active, next *models.SigningKey
+		for _, key := range keys {
+			switch core.SigningKeyStatus(key.Status) {
+			case core.SigningKeyStatusActive:
+				active = key
+			case core.SigningKeyStatusNext:
+				next = key
+			case core.SigningKeyStatusRetiring:
+				if key.RetiringAt != nil && now.Sub(*key.RetiringAt) >= s.retention {
+					if err := s.retire(ctx, repo, key, now); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if active != nil && (force || active.ActivatedAt == nil || now.Sub(*active.ActivatedAt) >= s.rotationPeriod) {
+			active.Status = core.SigningKeyStatusRetiring.String()
+			active.RetiringAt = &now
+			if err := repo.Update(ctx, active); err != nil {
+				return err
+			}
+			log.Printf("Security: signing key %s is retiring", active.KeyID)
+			active = nil
+		}
+
+		if active == nil {
+			if next == nil {
+				if len(keys) > 0 {
+					log.Printf("Warning: no next signing key to promote; activating a key that was not published in advance")
+				}
+				if next, err = s.createKey(ctx, repo, s.algorithm, nil); err != nil {
+					return err
+				}
+			}
+			next.Status = core.SigningKeyStatusActive.String()
+			next.ActivatedAt = &now
+			if err := repo.Update(ctx, next); err != nil {
+				return err
+			}
+			log.Printf("Security: signing key %s (%s) is now active", next.KeyID, next.Algorithm)
+			next = nil
+		}
+
+		if next == nil {
+			if _, err := s.createKey(ctx, repo, s.algorithm, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	s.invalidate()
+	return err
+}
+
+// RunRotation calls Rotate every interval until ctx is done.
+func (s *SigningKeyService) RunRotation(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Rotate(ctx, false); err != nil {
+				log.Printf("Warning: Failed to rotate signing keys: %v", err)
+			}
+		}
+	}
+}
+
+func (s *SigningKeyService) List(ctx context.Context) ([]*models.SigningKey, error) {
+	return s.repo.List(ctx)
+}
+
+// Generate replaces the next key with a new key for algorithm, which becomes
+// active at the next rotation. Use it to change algorithms.
+func (s *SigningKeyService) Generate(ctx context.Context, algorithm string) (*models.SigningKey, error) {
+	if !tokens.IsSupportedAlgorithm(algorithm) {
+		return nil, fmt.Errorf("%w: unsupported signing algorithm %q", core.ErrBadRequest, algorithm)
+	}
+	return s.replaceNext(ctx, jose.SignatureAlgorithm(algorithm), nil)
+}
+
+// Import replaces the next key with an existing PEM encoded private key.
+func (s *SigningKeyService) Import(ctx context.Context, pemData []byte) (*models.SigningKey, error) {
+	key, err := tokens.ParsePrivateKey(pemData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", core.ErrBadRequest, err)
+	}
+	algorithm, err := tokens.AlgorithmForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", core.ErrBadRequest, err)
+	}
+	return s.replaceNext(ctx, algorithm, key)
+}
+
+// Retire withdraws a key immediately, for example after it was exposed.
+// Tokens it signed stop verifying. Retiring the active key promotes the next
+// key in its place.
+func (s *SigningKeyService) Retire(ctx context.Context, keyID string) error {
+	err := s.repo.Transaction(ctx, func(repo repositories.SigningKeyRepository) error {
+		key, err := repo.GetByKeyID(ctx, keyID)
+		if err != nil {
+			return err
+		}
+		if core.SigningKeyStatus(key.Status) == core.SigningKeyStatusRetired {
+			return nil
+		}
+		return s.retire(ctx, repo, key, time.Now())
+	})
+	if err != nil {
+		s.invalidate()
+		return err
+	}
+	// Retiring the active or next key leaves a gap that the regular schedule
+	// fills: an active key is promoted and a new next key is created.
+	return s.Rotate(ctx, false)
+}
+
+// SigningKey returns the active key.
+func (s *SigningKeyService) SigningKey(ctx context.Context) (*tokens.SigningKey, error) {
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == nil {
+		return nil, fmt.Errorf("no active signing key")
+	}
+	return s.active, nil
+}
+
+// VerificationKeys returns the public keys of next, active and retiring keys.
+func (s *SigningKeyService) VerificationKeys(ctx context.Context) ([]jose.JSONWebKey, error) {
+	if err := s.load(ctx); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	published := s.published
+	s.mu.Unlock()
+
+	keys := make([]jose.JSONWebKey, 0, len(published))
+	for _, key := range published {
+		publicKey, err := tokens.ParsePublicKey([]byte(key.PublicKey))
+		if err != nil {
+			log.Printf("Warning: Skipping unreadable signing key %s: %v", key.KeyID, err)
+			continue
+		}
+		keys = append(keys, jose.JSONWebKey{
+			Key:       publicKey,
+			KeyID:     key.KeyID,
+			Algorithm: key.Algorithm,
+			Use:       "sig",
+		})
+	}
+	return keys, nil
+}
+
+func (s *SigningKeyService) KeySet(ctx context.Context) (jose.JSONWebKeySet, error) {
+	keys, err := s.VerificationKeys(ctx)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	return jose.JSONWebKeySet{Keys: keys}, nil
+}
+
+func (s *SigningKeyService) replaceNext(ctx context.Context, algorithm jose.SignatureAlgorithm, privateKey crypto.Signer) (*models.SigningKey, error) {
+	var created *models.SigningKey
+	err := s.repo.Transaction(ctx, func(repo repositories.SigningKeyRepository) error {
+		pending, err := repo.ListByStatus(ctx, core.SigningKeyStatusNext)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, key := range pending {
+			// Next keys never signed anything, so nothing depends on them.
+			if err := s.retire(ctx, repo, key, now); err != nil {
+				return err
+			}
+		}
+		created, err = s.createKey(ctx, repo, algorithm, privateKey)
+		return err
+	})
+	s.invalidate()
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// createKey stores a next key, generating one when privateKey is nil.
+func (s *SigningKeyService) createKey(ctx context.Context, repo repositories.SigningKeyRepository, algorithm jose.SignatureAlgorithm, privateKey crypto.Signer) (*models.SigningKey, error) {
+	key := privateKey
+	if key == nil {
+		generated, err := tokens.GenerateKey(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		key = generated
+	}
+
+	keyID, err := tokens.KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := repo.GetByKeyID(ctx, keyID); err == nil {
+		return nil, fmt.Errorf("%w: signing key %s already exists", core.ErrConflict, keyID)
+	}
+
+	publicPEM, err := tokens.MarshalPublicKey(key.Public())
+	if err != nil {
+		return nil, err
+	}
+	privatePEM, err := tokens.MarshalPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := secrets.Encrypt(s.encryptionKey, privatePEM)
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.SigningKey{
+		KeyID:               keyID,
+		Algorithm:           string(algorithm),
+		Status:              core.SigningKeyStatusNext.String(),
+		PublicKey:           publicPEM,
+		EncryptedPrivateKey: &encrypted,
+	}
+	if err := repo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	log.Printf("Security: created next signing key %s (%s)", record.KeyID, record.Algorithm)
+	return record, nil
+}
+
+// retire unpublishes a key and discards its private key.
+func (s *SigningKeyService) retire(ctx context.Context, repo repositories.SigningKeyRepository, key *models.SigningKey, now time.Time) error {
+	key.Status = core.SigningKeyStatusRetired.String()
+	key.RetiredAt = &now
+	key.EncryptedPrivateKey = nil
+	if err := repo.Update(ctx, key); err != nil {
+		return err
+	}
+	log.Printf("Security: retired signing key %s", key.KeyID)
+	return nil
+}
+
+// load refreshes the published keys and the decrypted active key once they
+// are older than signingKeyCacheTTL.
+func (s *SigningKeyService) load(ctx context.Context) error {
+	s.mu.Lock()
+	fresh := !s.loadedAt.IsZero() && time.Since(s.loadedAt) < signingKeyCacheTTL
+	s.mu.Unlock()
+	if fresh {
+		return nil
+	}
+
+	published, err := s.repo.ListByStatus(ctx, core.SigningKeyStatusNext, core.SigningKeyStatusActive, core.SigningKeyStatusRetiring)
+	if err != nil {
+		return err
+	}
+
+	var active *tokens.SigningKey
+	for _, key := range published {
+		if core.SigningKeyStatus(key.Status) != core.SigningKeyStatusActive || key.EncryptedPrivateKey == nil {
+			continue
+		}
+		privatePEM, err := secrets.Decrypt(s.encryptionKey, *key.EncryptedPrivateKey)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt signing key %s: %w", key.KeyID, err)
+		}
+		privateKey, err := tokens.ParsePrivateKey([]byte(privatePEM))
+		if err != nil {
+			return err
+		}
+		active = &tokens.SigningKey{
+			ID:        key.KeyID,
+			Algorithm: jose.SignatureAlgorithm(key.Algorithm),
+			Key:       privateKey,
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published = published
+	s.active = active
+	s.loadedAt = time.Now()
+	return nil
+}
+
+func (s *SigningKeyService) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published = nil
+	s.active = nil
+	s.loadedAt = time.Time{}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+// TokenIntrospection describes an active token (RFC 7662). Inactive tokens
+// only have Active unset.
+type TokenIntrospection struct {
+	Active         bool
+	Issuer         string
+	Subject        string
+	Username       string
+	ClientID       string
+	OrganizationID string
+	Scope          string
+	IssuedAt       int64
+	ExpiresAt      int64
+}
+
+// issuedToken is a token found by TokenService.lookup together with the
+// means to delete it.
+type issuedToken struct {
+	introspection TokenIntrospection
+	delete        func(ctx context.Context) error
+}
+
+// TokenService lets resource servers introspect (RFC 7662) and revoke
+// (RFC 7009) the tokens identity-go issues: member session tokens,
+// including the access tokens derived from them, refresh tokens and client
+// credentials access tokens. Revoking a refresh token revokes its family.
+// Callers authenticate as client credentials clients. Apps only see tokens
+// issued to themselves; platform apps see every token.
+type TokenService struct {
+	clients        *ClientCredentialsService
+	refreshTokens  *RefreshTokenService
+	sessionManager *SessionManager
+	revokedRepo    cache.RevokedTokenRepository
+	issuer         string
+}
+
+func NewTokenService(
+	clients *ClientCredentialsService,
+	refreshTokens *RefreshTokenService,
+	sessionManager *SessionManager,
+	revokedRepo cache.RevokedTokenRepository,
+	issuer string,
+) *TokenService {
+	return &TokenService{
+		clients:        clients,
+		refreshTokens:  refreshTokens,
+		sessionManager: sessionManager,
+		revokedRepo:    revokedRepo,
+		issuer:         strings.TrimSuffix(issuer, "/"),
+	}
+}
+
+// Introspect reports whether token is active and what it stands for.
+// Tokens that are unknown, expired, revoked or not visible to the caller
+// are all reported as inactive.
+func (s *TokenService) Introspect(ctx context.Context, caller ClientAuthentication, token string) (*TokenIntrospection, error) {
+	app, err := s.clients.Authenticate(ctx, caller)
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := s.lookup(ctx, token)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidToken) {
+			return &TokenIntrospection{}, nil
+		}
+		return nil, err
+	}
+	if !tokenVisibleTo(app, &found.introspection) {
+		return &TokenIntrospection{}, nil
+	}
+	return &found.introspection, nil
+}
+
+// Revoke deletes token and records it as revoked for the rest of its
+// lifetime. As RFC 7009 requires, unknown tokens are not an error; neither
+// are tokens the caller cannot see, which are left alone.
+func (s *TokenService) Revoke(ctx context.Context, caller ClientAuthentication, token string) error {
+	app, err := s.clients.Authenticate(ctx, caller)
+	if err != nil {
+		return err
+	}
+
+	found, err := s.lookup(ctx, token)
+	if err != nil {
+		if errors.Is(err, core.ErrInvalidToken) {
+			return nil
+		}
+		return err
+	}
+	if !tokenVisibleTo(app, &found.introspection) {
+		log.Printf("Security: client %s tried to revoke a token of client %q in organization %s", app.ID, found.introspection.ClientID, found.introspection.OrganizationID)
+		return nil
+	}
+
+	if ttl := time.Until(time.Unix(found.introspection.ExpiresAt, 0)); ttl > 0 {
+		if err := s.revokedRepo.RevokeToken(ctx, hashToken(token), ttl); err != nil {
+			return err
+		}
+	}
+	return found.delete(ctx)
+}
+
+// lookup finds the session, client access token or refresh token a token
+// string stands for. Revoked tokens are reported as invalid even if
+// deleting them failed.
+func (s *TokenService) lookup(ctx context.Context, token string) (*issuedToken, error) {
+	if token == "" {
+		return nil, core.ErrInvalidToken
+	}
+	revoked, err := s.revokedRepo.IsRevoked(ctx, hashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, core.ErrInvalidToken
+	}
+
+	if session, err := s.sessionManager.GetSession(ctx, token); err == nil {
+		return &issuedToken{
+			introspection: TokenIntrospection{
+				Active:         true,
+				Issuer:         s.issuer,
+				Subject:        session.MemberID,
+				Username:       session.Email,
+				ClientID:       session.ClientID,
+				OrganizationID: session.OrganizationID,
+				IssuedAt:       session.CreatedAt,
+				ExpiresAt:      session.ExpiresAt,
+			},
+			delete: func(ctx context.Context) error {
+				return s.sessionManager.DeleteSession(ctx, token)
+			},
+		}, nil
+	} else if !errors.Is(err, core.ErrInvalidSession) {
+		return nil, err
+	}
+
+	clientToken, err := s.clients.Validate(ctx, token)
+	if errors.Is(err, core.ErrInvalidToken) {
+		return s.lookupRefreshToken(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &issuedToken{
+		introspection: TokenIntrospection{
+			Active:         true,
+			Issuer:         s.issuer,
+			Subject:        clientToken.ClientID,
+			ClientID:       clientToken.ClientID,
+			OrganizationID: clientToken.OrganizationID,
+			Scope:          clientToken.Scope,
+			IssuedAt:       clientToken.IssuedAt,
+			ExpiresAt:      clientToken.ExpiresAt,
+		},
+		delete: func(ctx context.Context) error {
+			return s.clients.Revoke(ctx, token)
+		},
+	}, nil
+}
+
+func (s *TokenService) lookupRefreshToken(ctx context.Context, token string) (*issuedToken, error) {
+	refreshToken, err := s.refreshTokens.Lookup(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	family := refreshToken.Family
+	return &issuedToken{
+		introspection: TokenIntrospection{
+			Active:         true,
+			Issuer:         s.issuer,
+			Subject:        family.MemberID.String(),
+			ClientID:       family.ClientID,
+			OrganizationID: family.OrganizationID.String(),
+			Scope:          family.Scope,
+			IssuedAt:       refreshToken.CreatedAt.Unix(),
+			ExpiresAt:      family.ExpiresAt.Unix(),
+		},
+		delete: func(ctx context.Context) error {
+			return s.refreshTokens.RevokeFamily(ctx, family, core.RefreshTokenRevokedByClient)
+		},
+	}, nil
+}
+
+// tokenVisibleTo reports whether app may introspect or revoke a token. Browser
+// sessions and tokens of other clients are only visible to platform apps.
+func tokenVisibleTo(app *models.App, token *TokenIntrospection) bool {
+	return app.IsPlatformApp || token.ClientID == app.ID.String()
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,57 @@
+package services
+
+import "strings"
+
+// userAgentPlatforms and userAgentBrowsers map User-Agent substrings to the
+// names shown in session lists. Order matters: iPads and phones also claim to
+// be Macs and Linux, and most browsers also claim to be Safari or Chrome.
+var (
+	userAgentPlatforms = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"CrOS", "ChromeOS"},
+		{"Windows", "Windows"},
+		{"Macintosh", "macOS"},
+		{"Mac OS X", "macOS"},
+		{"Linux", "Linux"},
+	}
+	userAgentBrowsers = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"EdgiOS/", "Edge"},
+		{"OPR/", "Opera"},
+		{"SamsungBrowser/", "Samsung Internet"},
+		{"Firefox/", "Firefox"},
+		{"FxiOS/", "Firefox"},
+		{"CriOS/", "Chrome"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+	}
+)
+
+// describeUserAgent derives a coarse device and browser name from a
+// User-Agent header, enough for members to recognise their sessions. Clients
+// that are not browsers are named after their product token, e.g. curl.
+func describeUserAgent(userAgent string) (device, browser string) {
+	if userAgent == "" {
+		return "", ""
+	}
+	for _, platform := range userAgentPlatforms {
+		if strings.Contains(userAgent, platform.token) {
+			device = platform.name
+			break
+		}
+	}
+	for _, candidate := range userAgentBrowsers {
+		if strings.Contains(userAgent, candidate.token) {
+			return device, candidate.name
+		}
+	}
+
+	product, _, _ := strings.Cut(userAgent, " ")
+	product, _, _ = strings.Cut(product, "/")
+	if product == "Mozilla" {
+		return device, ""
+	}
+	return device, product
+}
@@ -0,0 +1,500 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/application/repositories"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+const (
+	webAuthnCeremonyTTL = 5 * time.Minute
+
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+	ceremonySecondFactor = "second_factor"
+)
+
+// NewWebAuthnRelyingParty configures the relying party used for passkey
+// ceremonies. Registrations ask for discoverable credentials so a passkey can
+// be used for primary login without entering an email address first.
+func NewWebAuthnRelyingParty(rpID string, origins []string) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: mfaIssuer,
+		RPOrigins:     origins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationPreferred,
+		},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login: webauthn.TimeoutConfig{
+				Enforce: true,
+				Timeout: webAuthnCeremonyTTL,
+			},
+			Registration: webauthn.TimeoutConfig{
+				Enforce: true,
+				Timeout: webAuthnCeremonyTTL,
+			},
+		},
+	})
+}
+
+// PasskeyLogin is the result of a successful passkey login ceremony.
+type PasskeyLogin struct {
+	MemberID uuid.UUID
+	ReturnTo string
+	Host     string
+}
+
+// WebAuthnService runs passkey registration and assertion ceremonies. Passkeys
+// can be used for primary login, which requires user verification, or as a
+// second factor after an upstream login.
+type WebAuthnService struct {
+	credentialRepo repositories.WebAuthnCredentialRepository
+	memberRepo     repositories.MemberRepository
+	orgRepo        repositories.OrganizationRepository
+	ceremonyRepo   cache.WebAuthnCeremonyRepository
+	relyingParty   *webauthn.WebAuthn
+}
+
+func NewWebAuthnService(
+	credentialRepo repositories.WebAuthnCredentialRepository,
+	memberRepo repositories.MemberRepository,
+	orgRepo repositories.OrganizationRepository,
+	ceremonyRepo cache.WebAuthnCeremonyRepository,
+	relyingParty *webauthn.WebAuthn,
+) *WebAuthnService {
+	return &WebAuthnService{
+		credentialRepo: credentialRepo,
+		memberRepo:     memberRepo,
+		orgRepo:        orgRepo,
+		ceremonyRepo:   ceremonyRepo,
+		relyingParty:   relyingParty,
+	}
+}
+
+func (s *WebAuthnService) List(ctx context.Context, memberID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	return s.credentialRepo.ListByMemberID(ctx, memberID)
+}
+
+// Delete removes one of the member's passkeys. The last second factor of a
+// member whose organization requires MFA cannot be removed.
+func (s *WebAuthnService) Delete(ctx context.Context, memberID, credentialID uuid.UUID) error {
+	credential, err := s.credentialRepo.GetByID(ctx, credentialID)
+	if err != nil {
+		return err
+	}
+	if credential.MemberID != memberID {
+		return core.ErrNotFound
+	}
+
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return err
+	}
+	if !member.HasTOTP() {
+		count, err := s.credentialRepo.CountByMemberID(ctx, memberID)
+		if err != nil {
+			return err
+		}
+		if count <= 1 {
+			org, err := s.orgRepo.GetByID(ctx, member.OrganizationID)
+			if err != nil {
+				return err
+			}
+			if org.MFARequired {
+				return core.ErrForbidden
+			}
+		}
+	}
+
+	return s.credentialRepo.Delete(ctx, credentialID)
+}
+
+// BeginRegistration returns the credential creation options for the browser
+// and the ceremony token to finish the registration with.
+func (s *WebAuthnService) BeginRegistration(ctx context.Context, memberID uuid.UUID) (json.RawMessage, string, error) {
+	user, err := s.loadUser(ctx, memberID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	creation, session, err := s.relyingParty.BeginRegistration(
+		user,
+		webauthn.WithExclusions(webauthn.Credentials(user.credentials).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.saveCeremony(ctx, ceremonyRegistration, memberID.String(), "", "", session)
+	if err != nil {
+		return nil, "", err
+	}
+	options, err := json.Marshal(creation)
+	if err != nil {
+		return nil, "", err
+	}
+	return options, token, nil
+}
+
+// FinishRegistration verifies the authenticator's attestation response and
+// stores the new credential under name.
+func (s *WebAuthnService) FinishRegistration(ctx context.Context, memberID uuid.UUID, token, name string, response []byte) (*models.WebAuthnCredential, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Passkey"
+	}
+	if len(name) > 100 {
+		return nil, fmt.Errorf("%w: name must be at most 100 characters", core.ErrBadRequest)
+	}
+
+	ceremony, session, err := s.consumeCeremony(ctx, token, ceremonyRegistration)
+	if err != nil {
+		return nil, err
+	}
+	if ceremony.MemberID != memberID.String() {
+		return nil, core.ErrInvalidToken
+	}
+
+	user, err := s.loadUser(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+	credential, err := s.relyingParty.CreateCredential(user, *session, parsed)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	if _, err := s.credentialRepo.GetByCredentialID(ctx, credential.ID); err == nil {
+		return nil, core.ErrConflict
+	} else if !errors.Is(err, core.ErrNotFound) {
+		return nil, err
+	}
+
+	transports := make(models.StringArray, len(credential.Transport))
+	for i, transport := range credential.Transport {
+		transports[i] = string(transport)
+	}
+
+	record := &models.WebAuthnCredential{
+		ID:              uuid.New(),
+		MemberID:        memberID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		AAGUID:          credential.Authenticator.AAGUID,
+		Transports:      transports,
+		Flags:           int16(credentialFlags(credential.Flags)),
+		SignCount:       int64(credential.Authenticator.SignCount),
+	}
+	if err := s.credentialRepo.Create(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// BeginLogin starts a passkey login for an unknown user. The browser offers
+// the passkeys it holds for this relying party.
+func (s *WebAuthnService) BeginLogin(ctx context.Context, returnTo, host string) (json.RawMessage, string, error) {
+	assertion, session, err := s.relyingParty.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.saveCeremony(ctx, ceremonyLogin, "", returnTo, host, session)
+	if err != nil {
+		return nil, "", err
+	}
+	options, err := json.Marshal(assertion)
+	if err != nil {
+		return nil, "", err
+	}
+	return options, token, nil
+}
+
+// FinishLogin verifies a passkey assertion and returns the member it belongs
+// to. User verification is required, so the login counts as multi-factor.
+func (s *WebAuthnService) FinishLogin(ctx context.Context, token string, response []byte) (*PasskeyLogin, error) {
+	ceremony, session, err := s.consumeCeremony(ctx, token, ceremonyLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+
+	var owner *webAuthnUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		record, err := s.credentialRepo.GetByCredentialID(ctx, rawID)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(record.MemberID[:], userHandle) {
+			return nil, fmt.Errorf("user handle does not match credential owner")
+		}
+		owner, err = s.loadUser(ctx, record.MemberID)
+		if err != nil {
+			return nil, err
+		}
+		return owner, nil
+	}
+
+	_, credential, err := s.relyingParty.ValidatePasskeyLogin(handler, *session, parsed)
+	if err != nil {
+		return nil, webAuthnError(err)
+	}
+	if err := s.recordUse(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	return &PasskeyLogin{
+		MemberID: owner.member.ID,
+		ReturnTo: ceremony.ReturnTo,
+		Host:     ceremony.Host,
+	}, nil
+}
+
+// BeginSecondFactor starts an assertion limited to the member's own passkeys.
+func (s *WebAuthnService) BeginSecondFactor(ctx context.Context, memberID uuid.UUID) (json.RawMessage, string, error) {
+	user, err := s.loadUser(ctx, memberID)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(user.credentials) == 0 {
+		return nil, "", fmt.Errorf("%w: no passkeys registered", core.ErrBadRequest)
+	}
+
+	assertion, session, err := s.relyingParty.BeginLogin(user)
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.saveCeremony(ctx, ceremonySecondFactor, memberID.String(), "", "", session)
+	if err != nil {
+		return nil, "", err
+	}
+	options, err := json.Marshal(assertion)
+	if err != nil {
+		return nil, "", err
+	}
+	return options, token, nil
+}
+
+// FinishSecondFactor verifies a passkey assertion for a known member.
+func (s *WebAuthnService) FinishSecondFactor(ctx context.Context, memberID uuid.UUID, token string, response []byte) error {
+	ceremony, session, err := s.consumeCeremony(ctx, token, ceremonySecondFactor)
+	if err != nil {
+		return err
+	}
+	if ceremony.MemberID != memberID.String() {
+		return core.ErrInvalidToken
+	}
+
+	user, err := s.loadUser(ctx, memberID)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return webAuthnError(err)
+	}
+	credential, err := s.relyingParty.ValidateLogin(user, *session, parsed)
+	if err != nil {
+		return webAuthnError(err)
+	}
+	return s.recordUse(ctx, credential)
+}
+
+// recordUse stores the new signature counter and flags of a credential after
+// an assertion. A counter that did not increase means the private key may have
+// been cloned; the credential is flagged and refused from then on.
+func (s *WebAuthnService) recordUse(ctx context.Context, credential *webauthn.Credential) error {
+	record, err := s.credentialRepo.GetByCredentialID(ctx, credential.ID)
+	if err != nil {
+		return err
+	}
+	if record.CloneWarning {
+		log.Printf("Security: refused passkey %s of member %s flagged as possibly cloned", record.ID, record.MemberID)
+		return core.ErrForbidden
+	}
+
+	now := time.Now()
+	record.LastUsedAt = &now
+	record.Flags = int16(credentialFlags(credential.Flags))
+	if credential.Authenticator.CloneWarning {
+		record.CloneWarning = true
+		log.Printf("Security: signature counter of passkey %s of member %s did not increase (stored %d, received %d); possible cloned authenticator",
+			record.ID, record.MemberID, record.SignCount, credential.Authenticator.SignCount)
+	} else {
+		record.SignCount = int64(credential.Authenticator.SignCount)
+	}
+	if err := s.credentialRepo.Update(ctx, record); err != nil {
+		return err
+	}
+	if record.CloneWarning {
+		return core.ErrForbidden
+	}
+	return nil
+}
+
+func (s *WebAuthnService) saveCeremony(ctx context.Context, kind, memberID, returnTo, host string, session *webauthn.SessionData) (string, error) {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	token, err := generateMFAToken()
+	if err != nil {
+		return "", err
+	}
+	ceremony := cache.WebAuthnCeremony{
+		Kind:     kind,
+		MemberID: memberID,
+		ReturnTo: returnTo,
+		Host:     host,
+		Session:  data,
+	}
+	if err := s.ceremonyRepo.SaveCeremony(ctx, token, ceremony, webAuthnCeremonyTTL); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *WebAuthnService) consumeCeremony(ctx context.Context, token, kind string) (*cache.WebAuthnCeremony, *webauthn.SessionData, error) {
+	if token == "" {
+		return nil, nil, core.ErrInvalidToken
+	}
+	ceremony, err := s.ceremonyRepo.ConsumeCeremony(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ceremony.Kind != kind {
+		return nil, nil, core.ErrInvalidToken
+	}
+	var session webauthn.SessionData
+	if err := json.Unmarshal(ceremony.Session, &session); err != nil {
+		return nil, nil, err
+	}
+	return ceremony, &session, nil
+}
+
+func (s *WebAuthnService) loadUser(ctx context.Context, memberID uuid.UUID) (*webAuthnUser, error) {
+	member, err := s.memberRepo.GetByID(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.credentialRepo.ListByMemberID(ctx, memberID)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &webAuthnUser{member: member, credentials: make([]webauthn.Credential, len(records))}
+	for i, record := range records {
+		transports := make([]protocol.AuthenticatorTransport, len(record.Transports))
+		for j, transport := range record.Transports {
+			transports[j] = protocol.AuthenticatorTransport(transport)
+		}
+		user.credentials[i] = webauthn.Credential{
+			ID:              record.CredentialID,
+			PublicKey:       record.PublicKey,
+			AttestationType: record.AttestationType,
+			Transport:       transports,
+			Flags:           webauthn.NewCredentialFlags(protocol.AuthenticatorFlags(record.Flags)),
+			Authenticator: webauthn.Authenticator{
+				AAGUID:    record.AAGUID,
+				SignCount: uint32(record.SignCount),
+			},
+		}
+	}
+	return user, nil
+}
+
+// webAuthnUser adapts a member and their stored credentials to the WebAuthn
+// library. The user handle is the member ID, which reveals nothing about the
+// member.
+type webAuthnUser struct {
+	member      *models.OrganizationMember
+	credentials []webauthn.Credential
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return u.member.ID[:]
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.member.Email
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	var parts []string
+	if u.member.FirstName != nil && *u.member.FirstName != "" {
+		parts = append(parts, *u.member.FirstName)
+	}
+	if u.member.LastName != nil && *u.member.LastName != "" {
+		parts = append(parts, *u.member.LastName)
+	}
+	if len(parts) == 0 {
+		return u.member.Email
+	}
+	return strings.Join(parts, " ")
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// credentialFlags rebuilds the raw flags byte from the parsed flags, which the
+// library updates field by field after an assertion.
+func credentialFlags(flags webauthn.CredentialFlags) protocol.AuthenticatorFlags {
+	var raw protocol.AuthenticatorFlags
+	if flags.UserPresent {
+		raw |= protocol.FlagUserPresent
+	}
+	if flags.UserVerified {
+		raw |= protocol.FlagUserVerified
+	}
+	if flags.BackupEligible {
+		raw |= protocol.FlagBackupEligible
+	}
+	if flags.BackupState {
+		raw |= protocol.FlagBackupState
+	}
+	return raw
+}
+
+// webAuthnError reports a failed ceremony as a bad request carrying the
+// library's description of what was wrong with the response.
+func webAuthnError(err error) error {
+	var protocolErr *protocol.Error
+	if errors.As(err, &protocolErr) {
+		return fmt.Errorf("%w: %s", core.ErrBadRequest, protocolErr.Details)
+	}
+	if errors.Is(err, core.ErrNotFound) {
+		return fmt.Errorf("%w: unknown passkey", core.ErrBadRequest)
+	}
+	return fmt.Errorf("%w: %v", core.ErrBadRequest, err)
+}
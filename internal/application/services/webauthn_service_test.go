@@ -0,0 +1,427 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+	"github.com/vondr/identity-go/internal/infrastructure/cache"
+	"github.com/vondr/identity-go/internal/infrastructure/database/models"
+)
+
+const (
+	testRPID     = "id.example.com"
+	testRPOrigin = "https://id.example.com"
+)
+
+// softAuthenticator is a software passkey holding a single ES256 credential.
+// It answers creation and request options the way a browser and platform
+// authenticator would, with "none" attestation and user verification.
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialID []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	credentialID := make([]byte, 32)
+	if _, err := rand.Read(credentialID); err != nil {
+		t.Fatalf("generate credential ID: %v", err)
+	}
+	return &softAuthenticator{key: key, credentialID: credentialID}
+}
+
+// register answers credential creation options with an attestation response.
+func (a *softAuthenticator) register(t *testing.T, options json.RawMessage, userHandle []byte) []byte {
+	t.Helper()
+
+	var creation protocol.CredentialCreation
+	if err := json.Unmarshal(options, &creation); err != nil {
+		t.Fatalf("decode creation options: %v", err)
+	}
+	a.userHandle = userHandle
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatalf("encode public key: %v", err)
+	}
+
+	attested := make([]byte, 16, 16+2+len(a.credentialID)+len(publicKey))
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(a.credentialID)))
+	attested = append(attested, a.credentialID...)
+	attested = append(attested, publicKey...)
+
+	authData := a.authenticatorData(creation.Response.RelyingParty.ID, protocol.FlagAttestedCredentialData)
+	authData = append(authData, attested...)
+
+	attestation, err := webauthncbor.Marshal(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatalf("encode attestation object: %v", err)
+	}
+
+	return a.marshalCredential(t, map[string]string{
+		"clientDataJSON":    encodeSoft(a.clientData(t, "webauthn.create", creation.Response.Challenge)),
+		"attestationObject": encodeSoft(attestation),
+	})
+}
+
+// assert answers credential request options with an assertion signed at the
+// authenticator's current signature counter.
+func (a *softAuthenticator) assert(t *testing.T, options json.RawMessage) []byte {
+	t.Helper()
+
+	var assertion protocol.CredentialAssertion
+	if err := json.Unmarshal(options, &assertion); err != nil {
+		t.Fatalf("decode request options: %v", err)
+	}
+
+	authData := a.authenticatorData(assertion.Response.RelyingPartyID, 0)
+	clientData := a.clientData(t, "webauthn.get", assertion.Response.Challenge)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(authData, clientDataHash[:]...))
+
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatalf("sign assertion: %v", err)
+	}
+
+	return a.marshalCredential(t, map[string]string{
+		"clientDataJSON":    encodeSoft(clientData),
+		"authenticatorData": encodeSoft(authData),
+		"signature":         encodeSoft(signature),
+		"userHandle":        encodeSoft(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) authenticatorData(rpID string, flags protocol.AuthenticatorFlags) []byte {
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, byte(flags|protocol.FlagUserPresent|protocol.FlagUserVerified))
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func (a *softAuthenticator) clientData(t *testing.T, ceremony string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": encodeSoft(challenge),
+		"origin":    testRPOrigin,
+	})
+	if err != nil {
+		t.Fatalf("encode client data: %v", err)
+	}
+	return data
+}
+
+func (a *softAuthenticator) marshalCredential(t *testing.T, response map[string]string) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(map[string]interface{}{
+		"id":       encodeSoft(a.credentialID),
+		"rawId":    encodeSoft(a.credentialID),
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+	return data
+}
+
+func encodeSoft(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+type fakeCredentialRepo struct {
+	credentials map[uuid.UUID]*models.WebAuthnCredential
+}
+
+func (r *fakeCredentialRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.WebAuthnCredential, error) {
+	credential, ok := r.credentials[id]
+	if !ok {
+		return nil, core.ErrNotFound
+	}
+	copied := *credential
+	return &copied, nil
+}
+
+func (r *fakeCredentialRepo) GetByCredentialID(ctx context.Context, credentialID []byte) (*models.WebAuthnCredential, error) {
+	for _, credential := range r.credentials {
+		if string(credential.CredentialID) == string(credentialID) {
+			copied := *credential
+			return &copied, nil
+		}
+	}
+	return nil, core.ErrNotFound
+}
+
+func (r *fakeCredentialRepo) ListByMemberID(ctx context.Context, memberID uuid.UUID) ([]*models.WebAuthnCredential, error) {
+	var credentials []*models.WebAuthnCredential
+	for _, credential := range r.credentials {
+		if credential.MemberID == memberID {
+			copied := *credential
+			credentials = append(credentials, &copied)
+		}
+	}
+	return credentials, nil
+}
+
+func (r *fakeCredentialRepo) CountByMemberID(ctx context.Context, memberID uuid.UUID) (int64, error) {
+	credentials, _ := r.ListByMemberID(ctx, memberID)
+	return int64(len(credentials)), nil
+}
+
+func (r *fakeCredentialRepo) Create(ctx context.Context, credential *models.WebAuthnCredential) error {
+	copied := *credential
+	r.credentials[credential.ID] = &copied
+	return nil
+}
+
+func (r *fakeCredentialRepo) Update(ctx context.Context, credential *models.WebAuthnCredential) error {
+	return r.Create(ctx, credential)
+}
+
+func (r *fakeCredentialRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	delete(r.credentials, id)
+	return nil
+}
+
+func (r *fakeCredentialRepo) DeleteByMemberID(ctx context.Context, memberID uuid.UUID) error {
+	for id, credential := range r.credentials {
+		if credential.MemberID == memberID {
+			delete(r.credentials, id)
+		}
+	}
+	return nil
+}
+
+type fakeMemberRepo struct {
+	members map[uuid.UUID]*models.OrganizationMember
+}
+
+func (r *fakeMemberRepo) GetByID(ctx context.Context, id uuid.UUID) (*models.OrganizationMember, error) {
+	member, ok := r.members[id]
+	if !ok {
+		return nil, core.ErrNotFound
+	}
+	return member, nil
+}
+
+func (r *fakeMemberRepo) GetByEmail(ctx context.Context, email string) (*models.OrganizationMember, error) {
+	for _, member := range r.members {
+		if member.Email == email {
+			return member, nil
+		}
+	}
+	return nil, core.ErrNotFound
+}
+
+func (r *fakeMemberRepo) ListByOrganizationID(ctx context.Context, organizationID uuid.UUID) ([]*models.OrganizationMember, error) {
+	return nil, nil
+}
+
+func (r *fakeMemberRepo) Create(ctx context.Context, member *models.OrganizationMember) error {
+	r.members[member.ID] = member
+	return nil
+}
+
+func (r *fakeMemberRepo) Update(ctx context.Context, member *models.OrganizationMember) error {
+	return r.Create(ctx, member)
+}
+
+func (r *fakeMemberRepo) AdvanceTOTPStep(ctx context.Context, id uuid.UUID, step int64) (bool, error) {
+	member, ok := r.members[id]
+	if !ok || member.TOTPLastStep >= step {
+		return false, nil
+	}
+	member.TOTPLastStep = step
+	return true, nil
+}
+
+func (r *fakeMemberRepo) Delete(ctx context.Context, id uuid.UUID) error {
+	delete(r.members, id)
+	return nil
+}
+
+type fakeCeremonyRepo struct {
+	ceremonies map[string]cache.WebAuthnCeremony
+}
+
+func (r *fakeCeremonyRepo) SaveCeremony(ctx context.Context, token string, ceremony cache.WebAuthnCeremony, ttl time.Duration) error {
+	r.ceremonies[token] = ceremony
+	return nil
+}
+
+func (r *fakeCeremonyRepo) ConsumeCeremony(ctx context.Context, token string) (*cache.WebAuthnCeremony, error) {
+	ceremony, ok := r.ceremonies[token]
+	if !ok {
+		return nil, core.ErrInvalidToken
+	}
+	delete(r.ceremonies, token)
+	return &ceremony, nil
+}
+
+func newTestWebAuthnService(t *testing.T) (*WebAuthnService, *fakeCredentialRepo, *models.OrganizationMember) {
+	t.Helper()
+
+	relyingParty, err := NewWebAuthnRelyingParty(testRPID, []string{testRPOrigin})
+	if err != nil {
+		t.Fatalf("configure relying party: %v", err)
+	}
+
+	member := &models.OrganizationMember{
+		ID:             uuid.New(),
+		OrganizationID: uuid.New(),
+		Email:          "jane@example.com",
+	}
+	credentials := &fakeCredentialRepo{credentials: map[uuid.UUID]*models.WebAuthnCredential{}}
+	service := NewWebAuthnService(
+		credentials,
+		&fakeMemberRepo{members: map[uuid.UUID]*models.OrganizationMember{member.ID: member}},
+		nil,
+		&fakeCeremonyRepo{ceremonies: map[string]cache.WebAuthnCeremony{}},
+		relyingParty,
+	)
+	return service, credentials, member
+}
+
+// registerPasskey runs a full registration ceremony for member.
+func registerPasskey(t *testing.T, service *WebAuthnService, member *models.OrganizationMember, authenticator *softAuthenticator) *models.WebAuthnCredential {
+	t.Helper()
+
+	ctx := context.Background()
+	options, token, err := service.BeginRegistration(ctx, member.ID)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+
+	credential, err := service.FinishRegistration(ctx, member.ID, token, "Laptop", authenticator.register(t, options, member.ID[:]))
+	if err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	return credential
+}
+
+// loginWithPasskey runs a full discoverable login ceremony.
+func loginWithPasskey(t *testing.T, service *WebAuthnService, authenticator *softAuthenticator) (*PasskeyLogin, error) {
+	t.Helper()
+
+	ctx := context.Background()
+	options, token, err := service.BeginLogin(ctx, "https://app.example.com/", "id.example.com")
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	return service.FinishLogin(ctx, token, authenticator.assert(t, options))
+}
+
+func TestWebAuthnRegistrationAndLogin(t *testing.T) {
+	service, credentials, member := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t)
+
+	credential := registerPasskey(t, service, member, authenticator)
+	if credential.Name != "Laptop" || string(credential.CredentialID) != string(authenticator.credentialID) {
+		t.Fatalf("FinishRegistration() credential = %+v", credential)
+	}
+
+	authenticator.signCount = 1
+	login, err := loginWithPasskey(t, service, authenticator)
+	if err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+	if login.MemberID != member.ID || login.ReturnTo != "https://app.example.com/" {
+		t.Fatalf("FinishLogin() = %+v", login)
+	}
+
+	stored := credentials.credentials[credential.ID]
+	if stored.SignCount != 1 || stored.LastUsedAt == nil || stored.CloneWarning {
+		t.Fatalf("stored credential after login = %+v", stored)
+	}
+}
+
+func TestWebAuthnLoginCounterRegressionSetsCloneWarning(t *testing.T) {
+	service, credentials, member := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t)
+	credential := registerPasskey(t, service, member, authenticator)
+
+	authenticator.signCount = 5
+	if _, err := loginWithPasskey(t, service, authenticator); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+
+	authenticator.signCount = 3
+	if _, err := loginWithPasskey(t, service, authenticator); !errors.Is(err, core.ErrForbidden) {
+		t.Fatalf("FinishLogin() with regressed counter error = %v, want ErrForbidden", err)
+	}
+	if !credentials.credentials[credential.ID].CloneWarning {
+		t.Fatal("CloneWarning not set after counter regression")
+	}
+
+	authenticator.signCount = 10
+	if _, err := loginWithPasskey(t, service, authenticator); !errors.Is(err, core.ErrForbidden) {
+		t.Fatalf("FinishLogin() with flagged credential error = %v, want ErrForbidden", err)
+	}
+}
+
+func TestWebAuthnCeremonyTokenReplayIsRejected(t *testing.T) {
+	service, _, member := newTestWebAuthnService(t)
+	authenticator := newSoftAuthenticator(t)
+	ctx := context.Background()
+
+	options, token, err := service.BeginRegistration(ctx, member.ID)
+	if err != nil {
+		t.Fatalf("BeginRegistration() error = %v", err)
+	}
+	response := authenticator.register(t, options, member.ID[:])
+	if _, err := service.FinishRegistration(ctx, member.ID, token, "Laptop", response); err != nil {
+		t.Fatalf("FinishRegistration() error = %v", err)
+	}
+	if _, err := service.FinishRegistration(ctx, member.ID, token, "Laptop", response); !errors.Is(err, core.ErrInvalidToken) {
+		t.Fatalf("replayed FinishRegistration() error = %v, want ErrInvalidToken", err)
+	}
+
+	authenticator.signCount = 1
+	options, token, err = service.BeginLogin(ctx, "", "id.example.com")
+	if err != nil {
+		t.Fatalf("BeginLogin() error = %v", err)
+	}
+	response = authenticator.assert(t, options)
+	if _, err := service.FinishLogin(ctx, token, response); err != nil {
+		t.Fatalf("FinishLogin() error = %v", err)
+	}
+	if _, err := service.FinishLogin(ctx, token, response); !errors.Is(err, core.ErrInvalidToken) {
+		t.Fatalf("replayed FinishLogin() error = %v, want ErrInvalidToken", err)
+	}
+}
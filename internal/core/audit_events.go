@@ -0,0 +1,15 @@
+package core
+
+// AuditEventType names a security-relevant event recorded in the audit log.
+type AuditEventType string
+
+const (
+	// AuditEventRefreshTokenReuse is raised when a refresh token that was
+	// already rotated is presented again, which means it was copied. The
+	// whole token family is revoked.
+	AuditEventRefreshTokenReuse AuditEventType = "refresh_token_reuse"
+)
+
+func (t AuditEventType) String() string {
+	return string(t)
+}
@@ -0,0 +1,46 @@
+package core
+
+// AuthMethod names how a member authenticated in a session. Primary methods
+// are the login itself; AuthMethodOTP and AuthMethodPasskey are also recorded
+// when used as the second factor.
+type AuthMethod string
+
+const (
+	AuthMethodMicrosoft AuthMethod = "microsoft"
+	AuthMethodRelatics  AuthMethod = "relatics"
+	AuthMethodOIDC      AuthMethod = "oidc"
+	AuthMethodSAML      AuthMethod = "saml"
+	AuthMethodEmail     AuthMethod = "email"
+	AuthMethodPasskey   AuthMethod = "passkey"
+	AuthMethodOTP       AuthMethod = "otp"
+)
+
+func (m AuthMethod) IsValid() bool {
+	switch m {
+	case AuthMethodMicrosoft, AuthMethodRelatics, AuthMethodOIDC, AuthMethodSAML,
+		AuthMethodEmail, AuthMethodPasskey, AuthMethodOTP:
+		return true
+	}
+	return false
+}
+
+// IsSecondFactor reports whether the method can be satisfied by an MFA
+// challenge on an existing session rather than a new login.
+func (m AuthMethod) IsSecondFactor() bool {
+	return m == AuthMethodPasskey || m == AuthMethodOTP
+}
+
+func (m AuthMethod) String() string {
+	return string(m)
+}
+
+// AppendAuthMethod adds method to methods unless it is already listed, without
+// modifying the backing array of methods.
+func AppendAuthMethod(methods []string, method AuthMethod) []string {
+	for _, existing := range methods {
+		if existing == method.String() {
+			return methods
+		}
+	}
+	return append(methods[:len(methods):len(methods)], method.String())
+}
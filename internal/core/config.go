@@ -2,6 +2,7 @@ package core
 
 import (
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/spf13/viper"
@@ -15,10 +16,12 @@ type Config struct {
 
 	DatabaseURL string `mapstructure:"DATABASE_URL"`
 	KeyDBURL    string `mapstructure:"KEYDB_URL"`
+	RunDBSetup  bool   `mapstructure:"RUN_DB_SETUP"`
 
 	MicrosoftClientID     string `mapstructure:"MS_CLIENT_ID"`
 	MicrosoftClientSecret string `mapstructure:"MS_CLIENT_SECRET"`
 	MicrosoftTenantID     string `mapstructure:"MS_TENANT_ID"`
+	MicrosoftAuthority    string `mapstructure:"MS_AUTHORITY"`
 
 	RelaticsClientID     string `mapstructure:"RELATICS_CLIENT_ID"`
 	RelaticsClientSecret string `mapstructure:"RELATICS_CLIENT_SECRET"`
@@ -33,20 +36,64 @@ type Config struct {
 	CookieSecure         bool   `mapstructure:"COOKIE_SECURE"`
 	CookieSameSite       string `mapstructure:"COOKIE_SAMESITE"`
 	SessionTTLDays       int    `mapstructure:"SESSION_TTL_DAYS"`
+	SessionIdleMinutes   int    `mapstructure:"SESSION_IDLE_TIMEOUT_MINUTES"`
 	SessionSecretKey     string `mapstructure:"SESSION_SECRET_KEY"`
+	EncryptionKey        string `mapstructure:"ENCRYPTION_KEY"`
 
 	SystemEmailsRaw string `mapstructure:"SYSTEM_EMAILS"`
 
 	CORSOriginsRaw string `mapstructure:"CORS_ORIGINS"`
 
+	ReturnToAllowedHostsRaw string `mapstructure:"RETURN_TO_ALLOWED_HOSTS"`
+
+	SAMLSPCertPath string `mapstructure:"SAML_SP_CERT_PATH"`
+	SAMLSPKeyPath  string `mapstructure:"SAML_SP_KEY_PATH"`
+
 	MicrosoftEmailTenantID     string `mapstructure:"MICROSOFT_EMAIL_TENANT_ID"`
 	MicrosoftEmailClientID     string `mapstructure:"MICROSOFT_EMAIL_CLIENT_ID"`
 	MicrosoftEmailClientSecret string `mapstructure:"MICROSOFT_EMAIL_CLIENT_SECRET"`
 	MicrosoftEmailSender       string `mapstructure:"MICROSOFT_EMAIL_SENDER"`
 
+	MailBackend   string `mapstructure:"MAIL_BACKEND"`
+	MailFrom      string `mapstructure:"MAIL_FROM"`
+	MailOutboxDir string `mapstructure:"MAIL_OUTBOX_DIR"`
+	SMTPHost      string `mapstructure:"SMTP_HOST"`
+	SMTPPort      int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername  string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword  string `mapstructure:"SMTP_PASSWORD"`
+
+	MagicLinkTTLMinutes int `mapstructure:"MAGIC_LINK_TTL_MINUTES"`
+
+	WebAuthnRPID         string `mapstructure:"WEBAUTHN_RP_ID"`
+	WebAuthnRPOriginsRaw string `mapstructure:"WEBAUTHN_RP_ORIGINS"`
+
+	DeviceClientIDsRaw string `mapstructure:"DEVICE_CLIENT_IDS"`
+
+	SigningKeyAlgorithm     string `mapstructure:"SIGNING_KEY_ALGORITHM"`
+	SigningKeyRotationDays  int    `mapstructure:"SIGNING_KEY_ROTATION_DAYS"`
+	SigningKeyRetentionDays int    `mapstructure:"SIGNING_KEY_RETENTION_DAYS"`
+
+	ForwardAuthJWTEnabled    bool `mapstructure:"FORWARD_AUTH_JWT_ENABLED"`
+	ForwardAuthJWTTTLSeconds int  `mapstructure:"FORWARD_AUTH_JWT_TTL_SECONDS"`
+
+	ClientAccessTokenTTLSeconds int  `mapstructure:"CLIENT_ACCESS_TOKEN_TTL_SECONDS"`
+	LegacyAppTokensEnabled      bool `mapstructure:"LEGACY_APP_TOKENS_ENABLED"`
+
+	AccessTokenTTLMinutes int `mapstructure:"ACCESS_TOKEN_TTL_MINUTES"`
+	RefreshTokenTTLDays   int `mapstructure:"REFRESH_TOKEN_TTL_DAYS"`
+
 	GeoIPDBPath string `mapstructure:"GEOIP_DB_PATH"`
 }
 
+// MinSessionIdleMinutes is the shortest session idle timeout, globally or per
+// organization. Session use is only recorded every five minutes, so shorter
+// timeouts would end sessions that are in use.
+const MinSessionIdleMinutes = 15
+
+// defaultEncryptionKey is the ENCRYPTION_KEY of development setups. It is
+// public, so every other environment must configure its own key.
+const defaultEncryptionKey = "insecure-development-encryption-key"
+
 var settings *Config
 
 func LoadConfig() (*Config, error) {
@@ -59,6 +106,9 @@ func LoadConfig() (*Config, error) {
 	viper.SetEnvPrefix("")
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 
+	// Deprecated x-vondr-auth app tokens keep working until turned off.
+	viper.SetDefault("LEGACY_APP_TOKENS_ENABLED", true)
+
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("failed to read config: %w", err)
@@ -66,38 +116,68 @@ func LoadConfig() (*Config, error) {
 	}
 
 	config := &Config{
-		AppName:                    viper.GetString("APP_NAME"),
-		Environment:                viper.GetString("ENVIRONMENT"),
-		RootDomain:                 viper.GetString("ROOT_DOMAIN"),
-		AdminToken:                 viper.GetString("ADMIN_TOKEN"),
-		DatabaseURL:                viper.GetString("DATABASE_URL"),
-		KeyDBURL:                   viper.GetString("KEYDB_URL"),
-		MicrosoftClientID:          viper.GetString("MS_CLIENT_ID"),
-		MicrosoftClientSecret:      viper.GetString("MS_CLIENT_SECRET"),
-		MicrosoftTenantID:          viper.GetString("MS_TENANT_ID"),
-		RelaticsClientID:           viper.GetString("RELATICS_CLIENT_ID"),
-		RelaticsClientSecret:       viper.GetString("RELATICS_CLIENT_SECRET"),
-		RelaticsRedirectURI:        viper.GetString("RELATICS_REDIRECT_URI"),
-		RelaticsRealm:              viper.GetString("RELATICS_REALM"),
-		PostLoginRedirectURL:       viper.GetString("POST_LOGIN_REDIRECT_URL"),
-		ErrorLoginRedirect:         viper.GetString("ERROR_LOGIN_REDIRECT"),
-		OAuthCallbackURL:           viper.GetString("OAUTH_CALLBACK_URL"),
-		AuthLoginURL:               viper.GetString("AUTH_LOGIN_URL"),
-		CookieDomain:               viper.GetString("COOKIE_DOMAIN"),
-		CookieSecure:               viper.GetBool("COOKIE_SECURE"),
-		CookieSameSite:             viper.GetString("COOKIE_SAMESITE"),
-		SessionTTLDays:             viper.GetInt("SESSION_TTL_DAYS"),
-		SessionSecretKey:           viper.GetString("SESSION_SECRET_KEY"),
-		SystemEmailsRaw:            viper.GetString("SYSTEM_EMAILS"),
-		CORSOriginsRaw:             viper.GetString("CORS_ORIGINS"),
-		MicrosoftEmailTenantID:     viper.GetString("MICROSOFT_EMAIL_TENANT_ID"),
-		MicrosoftEmailClientID:     viper.GetString("MICROSOFT_EMAIL_CLIENT_ID"),
-		MicrosoftEmailClientSecret: viper.GetString("MICROSOFT_EMAIL_CLIENT_SECRET"),
-		MicrosoftEmailSender:       viper.GetString("MICROSOFT_EMAIL_SENDER"),
-		GeoIPDBPath:                viper.GetString("GEOIP_DB_PATH"),
+		AppName:                     viper.GetString("APP_NAME"),
+		Environment:                 viper.GetString("ENVIRONMENT"),
+		RootDomain:                  viper.GetString("ROOT_DOMAIN"),
+		AdminToken:                  viper.GetString("ADMIN_TOKEN"),
+		DatabaseURL:                 viper.GetString("DATABASE_URL"),
+		KeyDBURL:                    viper.GetString("KEYDB_URL"),
+		RunDBSetup:                  viper.GetBool("RUN_DB_SETUP"),
+		MicrosoftClientID:           viper.GetString("MS_CLIENT_ID"),
+		MicrosoftClientSecret:       viper.GetString("MS_CLIENT_SECRET"),
+		MicrosoftTenantID:           viper.GetString("MS_TENANT_ID"),
+		MicrosoftAuthority:          viper.GetString("MS_AUTHORITY"),
+		RelaticsClientID:            viper.GetString("RELATICS_CLIENT_ID"),
+		RelaticsClientSecret:        viper.GetString("RELATICS_CLIENT_SECRET"),
+		RelaticsRedirectURI:         viper.GetString("RELATICS_REDIRECT_URI"),
+		RelaticsRealm:               viper.GetString("RELATICS_REALM"),
+		PostLoginRedirectURL:        viper.GetString("POST_LOGIN_REDIRECT_URL"),
+		ErrorLoginRedirect:          viper.GetString("ERROR_LOGIN_REDIRECT"),
+		OAuthCallbackURL:            viper.GetString("OAUTH_CALLBACK_URL"),
+		AuthLoginURL:                viper.GetString("AUTH_LOGIN_URL"),
+		CookieDomain:                viper.GetString("COOKIE_DOMAIN"),
+		CookieSecure:                viper.GetBool("COOKIE_SECURE"),
+		CookieSameSite:              viper.GetString("COOKIE_SAMESITE"),
+		SessionTTLDays:              viper.GetInt("SESSION_TTL_DAYS"),
+		SessionIdleMinutes:          viper.GetInt("SESSION_IDLE_TIMEOUT_MINUTES"),
+		SessionSecretKey:            viper.GetString("SESSION_SECRET_KEY"),
+		EncryptionKey:               viper.GetString("ENCRYPTION_KEY"),
+		SystemEmailsRaw:             viper.GetString("SYSTEM_EMAILS"),
+		CORSOriginsRaw:              viper.GetString("CORS_ORIGINS"),
+		ReturnToAllowedHostsRaw:     viper.GetString("RETURN_TO_ALLOWED_HOSTS"),
+		SAMLSPCertPath:              viper.GetString("SAML_SP_CERT_PATH"),
+		SAMLSPKeyPath:               viper.GetString("SAML_SP_KEY_PATH"),
+		MicrosoftEmailTenantID:      viper.GetString("MICROSOFT_EMAIL_TENANT_ID"),
+		MicrosoftEmailClientID:      viper.GetString("MICROSOFT_EMAIL_CLIENT_ID"),
+		MicrosoftEmailClientSecret:  viper.GetString("MICROSOFT_EMAIL_CLIENT_SECRET"),
+		MicrosoftEmailSender:        viper.GetString("MICROSOFT_EMAIL_SENDER"),
+		MailBackend:                 viper.GetString("MAIL_BACKEND"),
+		MailFrom:                    viper.GetString("MAIL_FROM"),
+		MailOutboxDir:               viper.GetString("MAIL_OUTBOX_DIR"),
+		SMTPHost:                    viper.GetString("SMTP_HOST"),
+		SMTPPort:                    viper.GetInt("SMTP_PORT"),
+		SMTPUsername:                viper.GetString("SMTP_USERNAME"),
+		SMTPPassword:                viper.GetString("SMTP_PASSWORD"),
+		MagicLinkTTLMinutes:         viper.GetInt("MAGIC_LINK_TTL_MINUTES"),
+		WebAuthnRPID:                viper.GetString("WEBAUTHN_RP_ID"),
+		WebAuthnRPOriginsRaw:        viper.GetString("WEBAUTHN_RP_ORIGINS"),
+		DeviceClientIDsRaw:          viper.GetString("DEVICE_CLIENT_IDS"),
+		SigningKeyAlgorithm:         viper.GetString("SIGNING_KEY_ALGORITHM"),
+		SigningKeyRotationDays:      viper.GetInt("SIGNING_KEY_ROTATION_DAYS"),
+		SigningKeyRetentionDays:     viper.GetInt("SIGNING_KEY_RETENTION_DAYS"),
+		ForwardAuthJWTEnabled:       viper.GetBool("FORWARD_AUTH_JWT_ENABLED"),
+		ForwardAuthJWTTTLSeconds:    viper.GetInt("FORWARD_AUTH_JWT_TTL_SECONDS"),
+		ClientAccessTokenTTLSeconds: viper.GetInt("CLIENT_ACCESS_TOKEN_TTL_SECONDS"),
+		LegacyAppTokensEnabled:      viper.GetBool("LEGACY_APP_TOKENS_ENABLED"),
+		AccessTokenTTLMinutes:       viper.GetInt("ACCESS_TOKEN_TTL_MINUTES"),
+		RefreshTokenTTLDays:         viper.GetInt("REFRESH_TOKEN_TTL_DAYS"),
+		GeoIPDBPath:                 viper.GetString("GEOIP_DB_PATH"),
 	}
 
 	config.SetDefaults()
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	settings = config
 	return config, nil
 }
@@ -118,15 +198,84 @@ func (c *Config) SetDefaults() {
 	if c.SessionTTLDays == 0 {
 		c.SessionTTLDays = 7
 	}
+	if c.SessionIdleMinutes == 0 {
+		c.SessionIdleMinutes = 24 * 60
+	}
 	if c.SessionSecretKey == "" {
 		c.SessionSecretKey = "change-me-in-production"
 	}
+	if c.EncryptionKey == "" && c.Environment == "development" {
+		c.EncryptionKey = defaultEncryptionKey
+	}
 	if c.MicrosoftEmailSender == "" {
 		c.MicrosoftEmailSender = "noreply@vondr.ai"
 	}
+	if c.MailFrom == "" {
+		c.MailFrom = c.MicrosoftEmailSender
+	}
+	if c.MailOutboxDir == "" {
+		c.MailOutboxDir = "outbox"
+	}
+	if c.MagicLinkTTLMinutes == 0 {
+		c.MagicLinkTTLMinutes = 15
+	}
+	if c.WebAuthnRPID == "" {
+		if authURL, err := url.Parse(c.AuthLoginURL); err == nil {
+			c.WebAuthnRPID = authURL.Hostname()
+		}
+	}
+	if c.DeviceClientIDsRaw == "" {
+		c.DeviceClientIDsRaw = "vondr-cli"
+	}
 	if c.RelaticsRealm == "" {
 		c.RelaticsRealm = "cpmconsultancy"
 	}
+	if c.SigningKeyAlgorithm == "" {
+		c.SigningKeyAlgorithm = "RS256"
+	}
+	if c.SigningKeyRotationDays == 0 {
+		c.SigningKeyRotationDays = 90
+	}
+	if c.SigningKeyRetentionDays == 0 {
+		c.SigningKeyRetentionDays = 7
+	}
+	if c.ForwardAuthJWTTTLSeconds == 0 {
+		c.ForwardAuthJWTTTLSeconds = 60
+	}
+	if c.ClientAccessTokenTTLSeconds == 0 {
+		c.ClientAccessTokenTTLSeconds = 900
+	}
+	if c.AccessTokenTTLMinutes == 0 {
+		c.AccessTokenTTLMinutes = 60
+	}
+	if c.RefreshTokenTTLDays == 0 {
+		c.RefreshTokenTTLDays = 30
+	}
+}
+
+// Validate rejects settings that would leave the service unusable.
+func (c *Config) Validate() error {
+	if c.SessionIdleMinutes < MinSessionIdleMinutes {
+		return fmt.Errorf("SESSION_IDLE_TIMEOUT_MINUTES must be at least %d, got %d", MinSessionIdleMinutes, c.SessionIdleMinutes)
+	}
+	if c.SessionTTLDays < 1 {
+		return fmt.Errorf("SESSION_TTL_DAYS must be at least 1, got %d", c.SessionTTLDays)
+	}
+	if c.Environment != "development" {
+		if err := c.CheckEncryptionKey(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckEncryptionKey returns an error unless ENCRYPTION_KEY is a key of this
+// deployment rather than empty or the public development default.
+func (c *Config) CheckEncryptionKey() error {
+	if c.EncryptionKey == "" || c.EncryptionKey == defaultEncryptionKey {
+		return fmt.Errorf("ENCRYPTION_KEY must be set to a secret key of this deployment")
+	}
+	return nil
 }
 
 func (c *Config) SystemEmails() []string {
@@ -165,6 +314,70 @@ func (c *Config) CORSOrigins() []string {
 	return result
 }
 
+// ReturnToAllowedHosts returns the hosts return_to may always point at. The
+// host of AUTH_LOGIN_URL is included so logins can resume pages of this
+// service, such as device verification.
+func (c *Config) ReturnToAllowedHosts() []string {
+	result := []string{}
+	if authURL, err := url.Parse(c.AuthLoginURL); err == nil && authURL.Hostname() != "" {
+		result = append(result, strings.ToLower(authURL.Hostname()))
+	}
+	if c.ReturnToAllowedHostsRaw == "" {
+		return result
+	}
+
+	hosts := strings.Split(c.ReturnToAllowedHostsRaw, ",")
+
+	for _, host := range hosts {
+		trimmed := strings.TrimSpace(host)
+		if trimmed != "" {
+			result = append(result, strings.ToLower(trimmed))
+		}
+	}
+
+	return result
+}
+
+// DeviceClientIDs returns the client IDs allowed to use the device
+// authorization grant.
+func (c *Config) DeviceClientIDs() []string {
+	clientIDs := strings.Split(c.DeviceClientIDsRaw, ",")
+	result := make([]string, 0, len(clientIDs))
+
+	for _, clientID := range clientIDs {
+		trimmed := strings.TrimSpace(clientID)
+		if trimmed != "" {
+			result = append(result, trimmed)
+		}
+	}
+
+	return result
+}
+
+// WebAuthnRPOrigins returns the origins passkey ceremonies may come from,
+// defaulting to the origin of AUTH_LOGIN_URL.
+func (c *Config) WebAuthnRPOrigins() []string {
+	if c.WebAuthnRPOriginsRaw == "" {
+		authURL, err := url.Parse(c.AuthLoginURL)
+		if err != nil || authURL.Host == "" {
+			return []string{}
+		}
+		return []string{authURL.Scheme + "://" + authURL.Host}
+	}
+
+	origins := strings.Split(c.WebAuthnRPOriginsRaw, ",")
+	result := make([]string, 0, len(origins))
+
+	for _, origin := range origins {
+		trimmed := strings.TrimSpace(origin)
+		if trimmed != "" {
+			result = append(result, strings.TrimSuffix(trimmed, "/"))
+		}
+	}
+
+	return result
+}
+
 func GetConfig() *Config {
 	return settings
 }
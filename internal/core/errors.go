@@ -11,10 +11,17 @@ var (
 	ErrInvalidToken    = errors.New("invalid token")
 	ErrExpiredToken    = errors.New("expired token")
 	ErrInvalidSession  = errors.New("invalid session")
+	ErrInvalidState    = errors.New("invalid oauth state")
 	ErrNoInvitation    = errors.New("no invitation found")
 	ErrInvalidEmail    = errors.New("invalid email")
 	ErrInvalidDomain   = errors.New("invalid domain")
 	ErrInvalidCountry  = errors.New("invalid country")
 	ErrGeoIPDisabled   = errors.New("geoip not configured")
 	ErrUnableToResolve = errors.New("unable to resolve")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrInvalidMFACode  = errors.New("invalid mfa code")
+
+	ErrAuthorizationPending = errors.New("authorization pending")
+	ErrSlowDown             = errors.New("slow down")
+	ErrAccessDenied         = errors.New("access denied")
 )
@@ -0,0 +1,16 @@
+package core
+
+type IdentityProviderType string
+
+const (
+	IdentityProviderTypeMicrosoft IdentityProviderType = "microsoft"
+	IdentityProviderTypeOIDC      IdentityProviderType = "oidc"
+)
+
+func (t IdentityProviderType) IsValid() bool {
+	return t == IdentityProviderTypeMicrosoft || t == IdentityProviderTypeOIDC
+}
+
+func (t IdentityProviderType) String() string {
+	return string(t)
+}
@@ -1,14 +1,8 @@
 package oauth
 
 import (
-	"context"
-	"encoding/json"
-	"fmt"
-	"io"
 	"net/http"
-
-	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/microsoft"
+	"strings"
 )
 
 type MicrosoftOAuthConfig struct {
@@ -16,67 +10,59 @@ type MicrosoftOAuthConfig struct {
 	ClientSecret string
 	TenantID     string
 	CallbackURL  string
+	// Authority overrides the Entra ID issuer, e.g. to point at a local
+	// stand-in serving discovery and JWKS documents.
+	Authority  string
+	HTTPClient *http.Client
 }
 
-type MicrosoftUserInfo struct {
-	ID         string `json:"sub"`
-	Email      string `json:"email"`
-	GivenName  string `json:"given_name"`
-	FamilyName string `json:"family_name"`
-	Name       string `json:"name"`
+// multiTenantAliases are Entra ID authorities that accept more than one tenant.
+var multiTenantAliases = map[string]bool{
+	"common":        true,
+	"organizations": true,
+	"consumers":     true,
 }
 
-type MicrosoftOAuthClient struct {
-	config     *oauth2.Config
-	httpClient *http.Client
+// MicrosoftIssuer returns the Entra ID v2.0 issuer for a tenant ID or for one
+// of the multi-tenant aliases such as "organizations".
+func MicrosoftIssuer(tenantID string) string {
+	return "https://login.microsoftonline.com/" + tenantID + "/v2.0"
 }
 
-func NewMicrosoftOAuthConfig(cfg *MicrosoftOAuthConfig) *MicrosoftOAuthClient {
-	return &MicrosoftOAuthClient{
-		config: &oauth2.Config{
-			ClientID:     cfg.ClientID,
-			ClientSecret: cfg.ClientSecret,
-			RedirectURL:  cfg.CallbackURL,
-			Scopes:       []string{"openid", "email", "profile"},
-			Endpoint:     microsoft.AzureADEndpoint(cfg.TenantID),
-		},
-		httpClient: http.DefaultClient,
+// WithMicrosoftClaims configures an OIDC provider for Entra ID tokens: the
+// immutable object ID (oid) identifies the member instead of the per-app
+// pairwise sub and the identity is read from the verified ID token without a
+// Graph userinfo call. preferred_username is never used as the email: it is
+// mutable and unverified, so it must not link an account to a member.
+func WithMicrosoftClaims(cfg OIDCProviderConfig) OIDCProviderConfig {
+	mappings := make(map[string]string, len(cfg.ClaimMappings)+1)
+	mappings[ClaimSubject] = "oid"
+	for field, claimName := range cfg.ClaimMappings {
+		mappings[field] = claimName
 	}
+	cfg.ClaimMappings = mappings
+	cfg.SkipUserInfo = true
+	return cfg
 }
 
-func (c *MicrosoftOAuthClient) GetAuthURL(redirectURL, state, returnTo string) string {
-	if redirectURL != "" {
-		c.config.RedirectURL = redirectURL
-	}
-	return c.config.AuthCodeURL(state, oauth2.SetAuthURLParam("return_to", returnTo))
-}
-
-func (c *MicrosoftOAuthClient) ExchangeCode(ctx context.Context, code string) (*MicrosoftUserInfo, error) {
-	if c.config.RedirectURL == "" {
-		return nil, fmt.Errorf("redirect URL not configured")
-	}
-
-	token, err := c.config.Exchange(ctx, code)
-	if err != nil {
-		return nil, fmt.Errorf("failed to exchange code: %w", err)
-	}
-
-	client := c.config.Client(ctx, token)
-	resp, err := client.Get("https://graph.microsoft.com/oidc/userinfo")
-	if err != nil {
-		return nil, fmt.Errorf("failed to get user info: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get user info: status %d, body: %s", resp.StatusCode, string(body))
+func NewMicrosoftProvider(cfg *MicrosoftOAuthConfig) *OIDCProvider {
+	issuer := cfg.Authority
+	if issuer == "" {
+		issuer = MicrosoftIssuer(cfg.TenantID)
 	}
 
-	var userInfo MicrosoftUserInfo
-	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
-		return nil, fmt.Errorf("failed to decode user info: %w", err)
+	var allowedTenantIDs []string
+	if cfg.TenantID != "" && !multiTenantAliases[strings.ToLower(cfg.TenantID)] {
+		allowedTenantIDs = []string{cfg.TenantID}
 	}
 
-	return &userInfo, nil
+	return NewOIDCProvider(WithMicrosoftClaims(OIDCProviderConfig{
+		Name:             "microsoft",
+		Issuer:           strings.TrimSuffix(issuer, "/"),
+		ClientID:         cfg.ClientID,
+		ClientSecret:     cfg.ClientSecret,
+		RedirectURL:      cfg.CallbackURL,
+		AllowedTenantIDs: allowedTenantIDs,
+		HTTPClient:       cfg.HTTPClient,
+	}))
 }
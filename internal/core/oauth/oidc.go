@@ -0,0 +1,232 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// tenantIssuerPlaceholder appears in the discovered issuer of multi-tenant
+// providers such as Entra ID's "organizations" endpoint; every token carries
+// its own tenant-specific issuer instead.
+const tenantIssuerPlaceholder = "{tenantid}"
+
+type OIDCProviderConfig struct {
+	Name             string
+	Issuer           string
+	ClientID         string
+	ClientSecret     string
+	RedirectURL      string
+	Scopes           []string
+	AllowedTenantIDs []string
+	ClaimMappings    map[string]string
+
+	// SkipUserInfo takes the identity from the ID token only, without a
+	// userinfo request when the token carries no email.
+	SkipUserInfo bool
+	// HTTPClient is used for discovery, JWKS and token requests, e.g. to talk
+	// to a local stand-in issuer. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+}
+
+// OIDCProvider is a generic OpenID Connect relying party. Endpoints and signing
+// keys are taken from the issuer's .well-known/openid-configuration document,
+// which is fetched on first use and retried on later requests if it fails.
+// Signing keys are cached and the JWKS is refetched when a token is signed
+// with an unknown key ID, so provider key rotation needs no restart.
+type OIDCProvider struct {
+	cfg OIDCProviderConfig
+
+	mu             sync.Mutex
+	provider       *oidc.Provider
+	oauthConfig    *oauth2.Config
+	verifier       *oidc.IDTokenVerifier
+	issuerTemplate string
+}
+
+func NewOIDCProvider(cfg OIDCProviderConfig) *OIDCProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+	}
+	return &OIDCProvider{cfg: cfg}
+}
+
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+func (p *OIDCProvider) clientContext(ctx context.Context) context.Context {
+	if p.cfg.HTTPClient == nil {
+		return ctx
+	}
+	return oidc.ClientContext(ctx, p.cfg.HTTPClient)
+}
+
+func (p *OIDCProvider) discover(ctx context.Context) error {
+	ctx = p.clientContext(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.provider != nil {
+		return nil
+	}
+
+	issuerTemplate := ""
+	provider, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+	var mismatch *oidc.IssuerMismatchError
+	if errors.As(err, &mismatch) && strings.Contains(mismatch.Discovered, tenantIssuerPlaceholder) {
+		if len(p.cfg.AllowedTenantIDs) == 0 {
+			return fmt.Errorf("%s provider is multi-tenant and requires allowed tenant IDs", p.cfg.Name)
+		}
+		issuerTemplate = mismatch.Discovered
+		provider, err = oidc.NewProvider(oidc.InsecureIssuerURLContext(ctx, p.cfg.Issuer), p.cfg.Issuer)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to discover %s provider: %w", p.cfg.Name, err)
+	}
+
+	p.provider = provider
+	p.issuerTemplate = issuerTemplate
+	p.oauthConfig = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       p.cfg.Scopes,
+		Endpoint:     provider.Endpoint(),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{
+		ClientID:        p.cfg.ClientID,
+		SkipIssuerCheck: issuerTemplate != "",
+	})
+	return nil
+}
+
+func (p *OIDCProvider) AuthURL(ctx context.Context, state, nonce, codeVerifier string, opts ...oauth2.AuthCodeOption) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	opts = append(opts, oidc.Nonce(nonce), oauth2.S256ChallengeOption(codeVerifier))
+	return p.oauthConfig.AuthCodeURL(state, opts...), nil
+}
+
+// Exchange redeems the authorization code and returns the identity from the ID
+// token after checking its signature, issuer, audience, expiry and nonce. When
+// the ID token carries no email the provider's userinfo endpoint is consulted
+// unless SkipUserInfo is set.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+	ctx = p.clientContext(ctx)
+
+	token, err := p.oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response did not contain an id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id token nonce mismatch")
+	}
+
+	var claims Claims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode id token claims: %w", err)
+	}
+
+	if err := p.checkTenant(&claims); err != nil {
+		return nil, err
+	}
+
+	if err := p.applyClaimMappings(idToken, &claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Email == "" && !p.cfg.SkipUserInfo && p.provider.UserInfoEndpoint() != "" {
+		userInfo, err := p.provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get user info: %w", err)
+		}
+		if userInfo.Subject != claims.Subject {
+			return nil, errors.New("user info subject does not match id token")
+		}
+		claims.Email = userInfo.Email
+		verified := userInfo.EmailVerified
+		claims.EmailVerified = &verified
+	}
+
+	if claims.EmailVerified != nil && !*claims.EmailVerified {
+		return nil, errors.New("email address is not verified")
+	}
+
+	return &claims, nil
+}
+
+func (p *OIDCProvider) checkTenant(claims *Claims) error {
+	if p.issuerTemplate != "" {
+		expected := strings.ReplaceAll(p.issuerTemplate, tenantIssuerPlaceholder, claims.TenantID)
+		if claims.TenantID == "" || claims.Issuer != expected {
+			return fmt.Errorf("id token issuer %q does not match tenant %q", claims.Issuer, claims.TenantID)
+		}
+	}
+
+	if len(p.cfg.AllowedTenantIDs) == 0 {
+		return nil
+	}
+	for _, tenantID := range p.cfg.AllowedTenantIDs {
+		if strings.EqualFold(tenantID, claims.TenantID) {
+			return nil
+		}
+	}
+	return fmt.Errorf("tenant %q is not allowed for %s provider", claims.TenantID, p.cfg.Name)
+}
+
+// applyClaimMappings overrides normalized claims with the ID token claims
+// configured for them, for providers that use non-standard claim names.
+func (p *OIDCProvider) applyClaimMappings(idToken *oidc.IDToken, claims *Claims) error {
+	if len(p.cfg.ClaimMappings) == 0 {
+		return nil
+	}
+
+	var raw map[string]interface{}
+	if err := idToken.Claims(&raw); err != nil {
+		return fmt.Errorf("failed to decode id token claims: %w", err)
+	}
+
+	for field, claimName := range p.cfg.ClaimMappings {
+		value, _ := raw[claimName].(string)
+		if value == "" {
+			continue
+		}
+		switch field {
+		case ClaimSubject:
+			claims.Subject = value
+		case ClaimEmail:
+			claims.Email = value
+		case ClaimGivenName:
+			claims.GivenName = value
+		case ClaimFamilyName:
+			claims.FamilyName = value
+		case ClaimName:
+			claims.Name = value
+		}
+	}
+	return nil
+}
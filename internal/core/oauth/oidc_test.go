@@ -0,0 +1,321 @@
+package oauth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	testClientID = "test-client"
+	testNonce    = "test-nonce"
+	testTenantID = "11111111-1111-1111-1111-111111111111"
+)
+
+// testIssuer is a local stand-in for an OpenID Connect provider. It serves
+// discovery and JWKS documents for any issuer path and answers every token
+// request with idToken.
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	// multiTenant makes discovery advertise a {tenantid} issuer like Entra
+	// ID's organizations endpoint.
+	multiTenant bool
+	idToken     string
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	issuer := &testIssuer{key: newTestKey(t)}
+	issuer.server = httptest.NewServer(http.HandlerFunc(issuer.serveHTTP))
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	return key
+}
+
+func (i *testIssuer) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/.well-known/openid-configuration"):
+		issuer := i.server.URL + strings.TrimSuffix(r.URL.Path, "/.well-known/openid-configuration")
+		if i.multiTenant {
+			issuer = i.server.URL + "/" + tenantIssuerPlaceholder + "/v2.0"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer,
+			"authorization_endpoint":                i.server.URL + "/authorize",
+			"token_endpoint":                        i.server.URL + "/token",
+			"jwks_uri":                              i.server.URL + "/keys",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	case r.URL.Path == "/keys":
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+			Key:       &i.key.PublicKey,
+			KeyID:     "test-key",
+			Algorithm: string(jose.RS256),
+			Use:       "sig",
+		}}})
+	case r.URL.Path == "/token":
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     i.idToken,
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (i *testIssuer) sign(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key, KeyID: "test-key"}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		t.Fatalf("create signer: %v", err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatalf("marshal claims: %v", err)
+	}
+
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("sign id token: %v", err)
+	}
+
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatalf("serialize id token: %v", err)
+	}
+	return token
+}
+
+// validClaims are the claims of an ID token that a provider for issuer
+// accepts.
+func validClaims(issuer string) map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            issuer,
+		"sub":            "pairwise-subject",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"nonce":          testNonce,
+		"oid":            "object-id",
+		"tid":            testTenantID,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"given_name":     "Jane",
+		"family_name":    "Doe",
+	}
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuerURL := issuer.server.URL + "/realm"
+
+	tests := []struct {
+		name    string
+		key     *rsa.PrivateKey
+		modify  func(claims map[string]interface{})
+		nonce   string
+		wantErr string
+	}{
+		{
+			name:  "valid token",
+			nonce: testNonce,
+		},
+		{
+			name:    "signed by unknown key",
+			key:     newTestKey(t),
+			nonce:   testNonce,
+			wantErr: "failed to verify signature",
+		},
+		{
+			name:    "wrong issuer",
+			modify:  func(claims map[string]interface{}) { claims["iss"] = issuer.server.URL + "/other" },
+			nonce:   testNonce,
+			wantErr: "issued by a different provider",
+		},
+		{
+			name:    "wrong audience",
+			modify:  func(claims map[string]interface{}) { claims["aud"] = "other-client" },
+			nonce:   testNonce,
+			wantErr: "expected audience",
+		},
+		{
+			name: "expired",
+			modify: func(claims map[string]interface{}) {
+				claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+			nonce:   testNonce,
+			wantErr: "token is expired",
+		},
+		{
+			name:    "nonce mismatch",
+			nonce:   "other-nonce",
+			wantErr: "nonce mismatch",
+		},
+		{
+			name:    "unverified email",
+			modify:  func(claims map[string]interface{}) { claims["email_verified"] = false },
+			nonce:   testNonce,
+			wantErr: "not verified",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := tt.key
+			if key == nil {
+				key = issuer.key
+			}
+			claims := validClaims(issuerURL)
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			issuer.idToken = issuer.sign(t, key, claims)
+
+			provider := NewOIDCProvider(OIDCProviderConfig{
+				Name:       "test",
+				Issuer:     issuerURL,
+				ClientID:   testClientID,
+				HTTPClient: issuer.server.Client(),
+			})
+
+			got, err := provider.Exchange(context.Background(), "code", "verifier", tt.nonce)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Exchange() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Exchange() error = %v", err)
+			}
+			if got.Subject != "pairwise-subject" || got.Email != "jane@example.com" || got.GivenName != "Jane" {
+				t.Fatalf("Exchange() claims = %+v", got)
+			}
+		})
+	}
+}
+
+func TestMicrosoftProviderExchange(t *testing.T) {
+	issuer := newTestIssuer(t)
+	authority := issuer.server.URL + "/" + testTenantID + "/v2.0"
+
+	newProvider := func() *OIDCProvider {
+		return NewMicrosoftProvider(&MicrosoftOAuthConfig{
+			ClientID:   testClientID,
+			TenantID:   testTenantID,
+			Authority:  authority,
+			HTTPClient: issuer.server.Client(),
+		})
+	}
+
+	t.Run("identifies member by oid", func(t *testing.T) {
+		issuer.idToken = issuer.sign(t, issuer.key, validClaims(authority))
+
+		got, err := newProvider().Exchange(context.Background(), "code", "verifier", testNonce)
+		if err != nil {
+			t.Fatalf("Exchange() error = %v", err)
+		}
+		if got.Subject != "object-id" {
+			t.Fatalf("Subject = %q, want oid", got.Subject)
+		}
+	})
+
+	t.Run("rejects other tenant", func(t *testing.T) {
+		claims := validClaims(authority)
+		claims["tid"] = "22222222-2222-2222-2222-222222222222"
+		issuer.idToken = issuer.sign(t, issuer.key, claims)
+
+		if _, err := newProvider().Exchange(context.Background(), "code", "verifier", testNonce); err == nil || !strings.Contains(err.Error(), "is not allowed") {
+			t.Fatalf("Exchange() error = %v, want tenant rejection", err)
+		}
+	})
+
+	t.Run("does not use preferred_username as email", func(t *testing.T) {
+		claims := validClaims(authority)
+		delete(claims, "email")
+		delete(claims, "email_verified")
+		claims["preferred_username"] = "ceo@example.com"
+		issuer.idToken = issuer.sign(t, issuer.key, claims)
+
+		got, err := newProvider().Exchange(context.Background(), "code", "verifier", testNonce)
+		if err != nil {
+			t.Fatalf("Exchange() error = %v", err)
+		}
+		if got.Email != "" {
+			t.Fatalf("Email = %q, want empty", got.Email)
+		}
+	})
+
+	t.Run("rejects unverified email", func(t *testing.T) {
+		claims := validClaims(authority)
+		claims["email_verified"] = false
+		issuer.idToken = issuer.sign(t, issuer.key, claims)
+
+		if _, err := newProvider().Exchange(context.Background(), "code", "verifier", testNonce); err == nil || !strings.Contains(err.Error(), "not verified") {
+			t.Fatalf("Exchange() error = %v, want unverified email rejection", err)
+		}
+	})
+}
+
+func TestMultiTenantProviderChecksTokenIssuer(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.multiTenant = true
+
+	provider := NewOIDCProvider(WithMicrosoftClaims(OIDCProviderConfig{
+		Name:             "microsoft",
+		Issuer:           issuer.server.URL + "/organizations/v2.0",
+		ClientID:         testClientID,
+		AllowedTenantIDs: []string{testTenantID},
+		HTTPClient:       issuer.server.Client(),
+	}))
+
+	tenantIssuer := issuer.server.URL + "/" + testTenantID + "/v2.0"
+
+	t.Run("accepts the token's own tenant issuer", func(t *testing.T) {
+		issuer.idToken = issuer.sign(t, issuer.key, validClaims(tenantIssuer))
+
+		if _, err := provider.Exchange(context.Background(), "code", "verifier", testNonce); err != nil {
+			t.Fatalf("Exchange() error = %v", err)
+		}
+	})
+
+	t.Run("rejects issuer of another tenant", func(t *testing.T) {
+		claims := validClaims(issuer.server.URL + "/22222222-2222-2222-2222-222222222222/v2.0")
+		issuer.idToken = issuer.sign(t, issuer.key, claims)
+
+		if _, err := provider.Exchange(context.Background(), "code", "verifier", testNonce); err == nil || !strings.Contains(err.Error(), "does not match tenant") {
+			t.Fatalf("Exchange() error = %v, want issuer rejection", err)
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package oauth
+
+import (
+	"context"
+
+	"golang.org/x/oauth2"
+)
+
+// Normalized claim names that can be remapped per provider.
+const (
+	ClaimSubject    = "sub"
+	ClaimEmail      = "email"
+	ClaimGivenName  = "given_name"
+	ClaimFamilyName = "family_name"
+	ClaimName       = "name"
+)
+
+// IdentityProvider is an upstream login provider that the public API can
+// redirect members to and accept authorization codes from.
+type IdentityProvider interface {
+	Name() string
+	AuthURL(ctx context.Context, state, nonce, codeVerifier string, opts ...oauth2.AuthCodeOption) (string, error)
+	Exchange(ctx context.Context, code, codeVerifier, nonce string) (*Claims, error)
+}
+
+// Claims is the normalized identity returned by an IdentityProvider after a
+// successful code exchange.
+type Claims struct {
+	Issuer            string `json:"iss"`
+	Subject           string `json:"sub"`
+	ObjectID          string `json:"oid"`
+	TenantID          string `json:"tid"`
+	Email             string `json:"email"`
+	EmailVerified     *bool  `json:"email_verified"`
+	PreferredUsername string `json:"preferred_username"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	Name              string `json:"name"`
+}
+
+func IsMappableClaim(field string) bool {
+	switch field {
+	case ClaimSubject, ClaimEmail, ClaimGivenName, ClaimFamilyName, ClaimName:
+		return true
+	}
+	return false
+}
@@ -1,9 +1,7 @@
 package oauth
 
 import (
-	"context"
-
-	"golang.org/x/oauth2"
+	"github.com/coreos/go-oidc/v3/oidc"
 )
 
 type RelaticsOAuthConfig struct {
@@ -13,26 +11,17 @@ type RelaticsOAuthConfig struct {
 	RedirectURI  string
 }
 
-func NewRelaticsOAuthConfig(cfg *RelaticsOAuthConfig) *oauth2.Config {
-	authURL := "https://authenticate.relatics.com/auth/realms/" + cfg.Realm + "/protocol/openid-connect/auth"
-	tokenURL := "https://authenticate.relatics.com/auth/realms/" + cfg.Realm + "/protocol/openid-connect/token"
+func relaticsIssuer(realm string) string {
+	return "https://authenticate.relatics.com/auth/realms/" + realm
+}
 
-	return &oauth2.Config{
+func NewRelaticsProvider(cfg *RelaticsOAuthConfig) *OIDCProvider {
+	return NewOIDCProvider(OIDCProviderConfig{
+		Name:         "relatics",
+		Issuer:       relaticsIssuer(cfg.Realm),
 		ClientID:     cfg.ClientID,
 		ClientSecret: cfg.ClientSecret,
 		RedirectURL:  cfg.RedirectURI,
-		Scopes:       []string{"openid", "profile", "offline_access"},
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  authURL,
-			TokenURL: tokenURL,
-		},
-	}
-}
-
-func GetRelaticsAuthURL(ctx context.Context, oauthConfig *oauth2.Config, state string) (string, error) {
-	return oauthConfig.AuthCodeURL(state), nil
-}
-
-func ExchangeRelaticsCode(ctx context.Context, oauthConfig *oauth2.Config, code string) (*oauth2.Token, error) {
-	return oauthConfig.Exchange(ctx, code)
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile", "offline_access"},
+	})
 }
@@ -0,0 +1,15 @@
+package core
+
+// RefreshTokenRevocation records why a refresh token family was revoked.
+type RefreshTokenRevocation string
+
+const (
+	RefreshTokenRevokedReuse      RefreshTokenRevocation = "reuse"
+	RefreshTokenRevokedByClient   RefreshTokenRevocation = "revoked"
+	RefreshTokenRevokedLogout     RefreshTokenRevocation = "logout"
+	RefreshTokenRevokedMemberGone RefreshTokenRevocation = "member_removed"
+)
+
+func (r RefreshTokenRevocation) String() string {
+	return string(r)
+}
@@ -0,0 +1,282 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	crewsaml "github.com/crewjam/saml"
+	"github.com/crewjam/saml/samlsp"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// Member fields that can be filled from assertion attributes.
+const (
+	AttributeEmail      = "email"
+	AttributeGivenName  = "given_name"
+	AttributeFamilyName = "family_name"
+)
+
+// defaultAttributeNames lists the attribute names commonly used by Entra ID,
+// ADFS, Okta and Shibboleth (OID form) for each member field. They are tried in
+// order when a connection has no explicit mapping for the field.
+var defaultAttributeNames = map[string][]string{
+	AttributeEmail: {
+		"email",
+		"mail",
+		"emailAddress",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress",
+		"urn:oid:0.9.2342.19200300.100.1.3",
+	},
+	AttributeGivenName: {
+		"given_name",
+		"givenName",
+		"firstName",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname",
+		"urn:oid:2.5.4.42",
+	},
+	AttributeFamilyName: {
+		"family_name",
+		"sn",
+		"surname",
+		"lastName",
+		"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname",
+		"urn:oid:2.5.4.4",
+	},
+}
+
+// IsMappableAttribute reports whether field is a member field that can be
+// mapped to an assertion attribute.
+func IsMappableAttribute(field string) bool {
+	_, ok := defaultAttributeNames[field]
+	return ok
+}
+
+// ErrInvalidResponse is returned when a SAML response fails signature,
+// audience, destination, request ID or validity checks.
+var ErrInvalidResponse = errors.New("invalid SAML response")
+
+type ServiceProviderConfig struct {
+	Name              string
+	EntityID          string
+	ACSURL            string
+	Key               crypto.Signer
+	Certificate       *x509.Certificate
+	IDPMetadata       []byte
+	AttributeMappings map[string]string
+}
+
+// Identity is the member information asserted by the identity provider.
+type Identity struct {
+	Issuer     string
+	Subject    string
+	Email      string
+	GivenName  string
+	FamilyName string
+}
+
+// ServiceProvider is a SAML 2.0 service provider bound to a single identity
+// provider. AuthnRequests use the HTTP-Redirect binding and are signed with
+// the SP key; responses are accepted through the HTTP-POST binding.
+type ServiceProvider struct {
+	name     string
+	sp       *crewsaml.ServiceProvider
+	mappings map[string]string
+}
+
+func NewServiceProvider(cfg ServiceProviderConfig) (*ServiceProvider, error) {
+	idpMetadata, err := ParseIDPMetadata(cfg.IDPMetadata)
+	if err != nil {
+		return nil, err
+	}
+
+	acsURL, err := url.Parse(cfg.ACSURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACS URL: %w", err)
+	}
+	metadataURL, err := url.Parse(cfg.EntityID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entity ID: %w", err)
+	}
+
+	return &ServiceProvider{
+		name: cfg.Name,
+		sp: &crewsaml.ServiceProvider{
+			EntityID:          cfg.EntityID,
+			Key:               cfg.Key,
+			Certificate:       cfg.Certificate,
+			MetadataURL:       *metadataURL,
+			AcsURL:            *acsURL,
+			IDPMetadata:       idpMetadata,
+			AuthnNameIDFormat: crewsaml.UnspecifiedNameIDFormat,
+			SignatureMethod:   dsig.RSASHA256SignatureMethod,
+		},
+		mappings: cfg.AttributeMappings,
+	}, nil
+}
+
+func (p *ServiceProvider) Name() string {
+	return p.name
+}
+
+// Metadata returns the SP metadata document to be imported by the identity
+// provider.
+func (p *ServiceProvider) Metadata() ([]byte, error) {
+	descriptor := p.sp.Metadata()
+	for i := range descriptor.SPSSODescriptors {
+		services := descriptor.SPSSODescriptors[i].AssertionConsumerServices[:0]
+		for _, service := range descriptor.SPSSODescriptors[i].AssertionConsumerServices {
+			if service.Binding == crewsaml.HTTPPostBinding {
+				services = append(services, service)
+			}
+		}
+		descriptor.SPSSODescriptors[i].AssertionConsumerServices = services
+	}
+	return xml.MarshalIndent(descriptor, "", "  ")
+}
+
+// AuthURL builds a signed HTTP-Redirect AuthnRequest. The returned request ID
+// must be kept with the login transaction and passed to ParseResponse.
+func (p *ServiceProvider) AuthURL(relayState string) (string, string, error) {
+	ssoURL := p.sp.GetSSOBindingLocation(crewsaml.HTTPRedirectBinding)
+	request, err := p.sp.MakeAuthenticationRequest(ssoURL, crewsaml.HTTPRedirectBinding, crewsaml.HTTPPostBinding)
+	if err != nil {
+		return "", "", err
+	}
+	redirectURL, err := request.Redirect(url.QueryEscape(relayState), p.sp)
+	if err != nil {
+		return "", "", err
+	}
+	return redirectURL.String(), request.ID, nil
+}
+
+// ParseResponse validates a base64 encoded SAMLResponse issued in reply to
+// requestID and maps the assertion to an Identity. The response or assertion
+// must be signed by the identity provider, addressed to the ACS URL and
+// restricted to this SP's entity ID.
+func (p *ServiceProvider) ParseResponse(samlResponse, requestID string) (*Identity, error) {
+	raw, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed encoding", ErrInvalidResponse)
+	}
+
+	assertion, err := p.sp.ParseXMLResponse(raw, []string{requestID}, p.sp.AcsURL)
+	if err != nil {
+		var invalid *crewsaml.InvalidResponseError
+		if errors.As(err, &invalid) && invalid.PrivateErr != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, invalid.PrivateErr)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+	}
+
+	return p.identityFromAssertion(assertion)
+}
+
+func (p *ServiceProvider) identityFromAssertion(assertion *crewsaml.Assertion) (*Identity, error) {
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, fmt.Errorf("%w: assertion has no NameID", ErrInvalidResponse)
+	}
+	nameID := assertion.Subject.NameID
+
+	attributes := make(map[string]string)
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if len(attribute.Values) == 0 {
+				continue
+			}
+			value := strings.TrimSpace(attribute.Values[0].Value)
+			if value == "" {
+				continue
+			}
+			if _, ok := attributes[attribute.Name]; !ok {
+				attributes[attribute.Name] = value
+			}
+			if attribute.FriendlyName != "" {
+				if _, ok := attributes[attribute.FriendlyName]; !ok {
+					attributes[attribute.FriendlyName] = value
+				}
+			}
+		}
+	}
+
+	identity := &Identity{
+		Issuer:     p.sp.IDPMetadata.EntityID,
+		Subject:    nameID.Value,
+		Email:      p.attribute(attributes, AttributeEmail),
+		GivenName:  p.attribute(attributes, AttributeGivenName),
+		FamilyName: p.attribute(attributes, AttributeFamilyName),
+	}
+	if identity.Email == "" && (nameID.Format == string(crewsaml.EmailAddressNameIDFormat) || strings.Contains(nameID.Value, "@")) {
+		identity.Email = nameID.Value
+	}
+	return identity, nil
+}
+
+func (p *ServiceProvider) attribute(attributes map[string]string, field string) string {
+	if name, ok := p.mappings[field]; ok {
+		return attributes[name]
+	}
+	for _, name := range defaultAttributeNames[field] {
+		if value, ok := attributes[name]; ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// ParseIDPMetadata parses an IdP EntityDescriptor and checks that it offers an
+// HTTP-Redirect single sign-on endpoint and a signing certificate.
+func ParseIDPMetadata(data []byte) (*crewsaml.EntityDescriptor, error) {
+	descriptor, err := samlsp.ParseMetadata(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid IdP metadata: %w", err)
+	}
+	if descriptor.EntityID == "" {
+		return nil, errors.New("invalid IdP metadata: missing entityID")
+	}
+
+	hasRedirectSSO, hasSigningKey := false, false
+	for _, idp := range descriptor.IDPSSODescriptors {
+		for _, service := range idp.SingleSignOnServices {
+			if service.Binding == crewsaml.HTTPRedirectBinding && service.Location != "" {
+				hasRedirectSSO = true
+			}
+		}
+		for _, key := range idp.KeyDescriptors {
+			if (key.Use == "" || key.Use == "signing") && len(key.KeyInfo.X509Data.X509Certificates) > 0 {
+				hasSigningKey = true
+			}
+		}
+	}
+	if !hasRedirectSSO {
+		return nil, errors.New("invalid IdP metadata: no HTTP-Redirect single sign-on service")
+	}
+	if !hasSigningKey {
+		return nil, errors.New("invalid IdP metadata: no signing certificate")
+	}
+	return descriptor, nil
+}
+
+// LoadKeyPair reads the PEM encoded SP certificate and RSA private key used to
+// sign AuthnRequests.
+func LoadKeyPair(certPath, keyPath string) (crypto.Signer, *x509.Certificate, error) {
+	keyPair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load SAML key pair: %w", err)
+	}
+	certificate, err := x509.ParseCertificate(keyPair.Certificate[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse SAML certificate: %w", err)
+	}
+	signer, ok := keyPair.PrivateKey.(crypto.Signer)
+	if !ok {
+		return nil, nil, errors.New("SAML private key cannot sign")
+	}
+	return signer, certificate, nil
+}
@@ -0,0 +1,23 @@
+package core
+
+// SigningKeyStatus is the stage of a token signing key in its rotation.
+// Next keys are published before they sign anything so relying parties have
+// them cached by the time they become active; retiring keys no longer sign
+// but stay published until tokens signed with them have expired.
+type SigningKeyStatus string
+
+const (
+	SigningKeyStatusNext     SigningKeyStatus = "next"
+	SigningKeyStatusActive   SigningKeyStatus = "active"
+	SigningKeyStatusRetiring SigningKeyStatus = "retiring"
+	SigningKeyStatusRetired  SigningKeyStatus = "retired"
+)
+
+// IsPublished reports whether keys in this status are part of the JWKS.
+func (s SigningKeyStatus) IsPublished() bool {
+	return s == SigningKeyStatusNext || s == SigningKeyStatusActive || s == SigningKeyStatusRetiring
+}
+
+func (s SigningKeyStatus) String() string {
+	return string(s)
+}
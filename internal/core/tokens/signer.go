@@ -0,0 +1,61 @@
+// Package tokens signs the JWTs identity-go issues and publishes the matching
+// public keys.
+package tokens
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// SigningKey is a private key with its key ID and algorithm.
+type SigningKey struct {
+	ID        string
+	Algorithm jose.SignatureAlgorithm
+	Key       crypto.Signer
+}
+
+// KeySource supplies the key to sign with and the public keys relying
+// parties verify with, which include keys about to be used and keys recently
+// used.
+type KeySource interface {
+	SigningKey(ctx context.Context) (*SigningKey, error)
+	VerificationKeys(ctx context.Context) ([]jose.JSONWebKey, error)
+}
+
+// Signer issues JWTs signed with the current key of a KeySource.
+type Signer struct {
+	keys KeySource
+}
+
+func NewSigner(keys KeySource) *Signer {
+	return &Signer{keys: keys}
+}
+
+// Sign serializes claims as a compact JWS.
+func (s *Signer) Sign(ctx context.Context, claims any) (string, error) {
+	key, err := s.keys.SigningKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: key.Algorithm, Key: jose.JSONWebKey{Key: key.Key, KeyID: key.ID}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+	return jwt.Signed(signer).Claims(claims).Serialize()
+}
+
+// KeySet returns the public keys relying parties verify tokens with.
+func (s *Signer) KeySet(ctx context.Context) (jose.JSONWebKeySet, error) {
+	keys, err := s.keys.VerificationKeys(ctx)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	return jose.JSONWebKeySet{Keys: keys}, nil
+}
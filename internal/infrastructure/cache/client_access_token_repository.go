@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// ClientAccessToken is an access token an app obtained with the client
+// credentials grant. It identifies the app itself, not a member.
+type ClientAccessToken struct {
+	ClientID       string `json:"client_id"`
+	OrganizationID string `json:"organization_id"`
+	Scope          string `json:"scope"`
+	IssuedAt       int64  `json:"issued_at"`
+	ExpiresAt      int64  `json:"expires_at"`
+}
+
+type ClientAccessTokenRepository interface {
+	SaveToken(ctx context.Context, tokenHash string, token ClientAccessToken, ttl time.Duration) error
+	GetToken(ctx context.Context, tokenHash string) (*ClientAccessToken, error)
+	DeleteToken(ctx context.Context, tokenHash string) error
+	ClaimAssertion(ctx context.Context, clientID, assertionID string, ttl time.Duration) (bool, error)
+}
+
+type RedisClientAccessTokenRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisClientAccessTokenRepository(redisClient *redis.Client) *RedisClientAccessTokenRepository {
+	return &RedisClientAccessTokenRepository{redisClient: redisClient}
+}
+
+func (r *RedisClientAccessTokenRepository) SaveToken(ctx context.Context, tokenHash string, token ClientAccessToken, ttl time.Duration) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.Set(ctx, "client_token:"+tokenHash, data, ttl).Err()
+}
+
+func (r *RedisClientAccessTokenRepository) GetToken(ctx context.Context, tokenHash string) (*ClientAccessToken, error) {
+	data, err := r.redisClient.Get(ctx, "client_token:"+tokenHash).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var token ClientAccessToken
+	if err := json.Unmarshal([]byte(data), &token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+func (r *RedisClientAccessTokenRepository) DeleteToken(ctx context.Context, tokenHash string) error {
+	return r.redisClient.Del(ctx, "client_token:"+tokenHash).Err()
+}
+
+// ClaimAssertion records the jti of a client assertion until it expires and
+// reports false when the client already used it.
+func (r *RedisClientAccessTokenRepository) ClaimAssertion(ctx context.Context, clientID, assertionID string, ttl time.Duration) (bool, error) {
+	return r.redisClient.SetNX(ctx, "client_assertion:"+clientID+":"+assertionID, 1, ttl).Result()
+}
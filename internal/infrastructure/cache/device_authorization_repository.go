@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// DeviceAuthorization is an RFC 8628 device authorization waiting for a
+// member to approve it on the verification page. Session is the approving
+// session once approved. Interval is the initial polling interval; the
+// client's polling state is kept under its own key so polls never overwrite
+// the member's decision.
+type DeviceAuthorization struct {
+	ClientID  string       `json:"client_id"`
+	UserCode  string       `json:"user_code"`
+	ExpiresAt int64        `json:"expires_at"`
+	Interval  int          `json:"interval"`
+	Denied    bool         `json:"denied"`
+	Session   *SessionData `json:"session,omitempty"`
+}
+
+// recordPollScript stores the time of a poll and raises the interval by
+// ARGV[3] seconds when the previous poll was less than an interval ago. It
+// returns the resulting interval and 1 if the client polled too fast.
+var recordPollScript = redis.NewScript(`
+local last = tonumber(redis.call('HGET', KEYS[1], 'last_polled_at') or '0')
+local interval = tonumber(redis.call('HGET', KEYS[1], 'interval') or ARGV[2])
+local now = tonumber(ARGV[1])
+local slow = 0
+if last > 0 and now - last < interval then
+	interval = interval + tonumber(ARGV[3])
+	slow = 1
+end
+redis.call('HSET', KEYS[1], 'last_polled_at', now, 'interval', interval)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {interval, slow}
+`)
+
+type DeviceAuthorizationRepository interface {
+	SaveAuthorization(ctx context.Context, deviceCodeHash string, authorization DeviceAuthorization, ttl time.Duration) error
+	GetAuthorization(ctx context.Context, deviceCodeHash string) (*DeviceAuthorization, error)
+	GetDeviceCodeHash(ctx context.Context, userCode string) (string, error)
+	UpdateAuthorization(ctx context.Context, deviceCodeHash string, authorization DeviceAuthorization) error
+	ConsumeAuthorization(ctx context.Context, deviceCodeHash string) (*DeviceAuthorization, error)
+	RecordPoll(ctx context.Context, deviceCodeHash string, now time.Time, interval, slowDownStep int, ttl time.Duration) (bool, error)
+}
+
+type RedisDeviceAuthorizationRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisDeviceAuthorizationRepository(redisClient *redis.Client) *RedisDeviceAuthorizationRepository {
+	return &RedisDeviceAuthorizationRepository{redisClient: redisClient}
+}
+
+// SaveAuthorization stores a new authorization together with the index from
+// its user code. It returns core.ErrConflict if the user code is taken.
+func (r *RedisDeviceAuthorizationRepository) SaveAuthorization(ctx context.Context, deviceCodeHash string, authorization DeviceAuthorization, ttl time.Duration) error {
+	data, err := json.Marshal(authorization)
+	if err != nil {
+		return err
+	}
+
+	claimed, err := r.redisClient.SetNX(ctx, "device_user_code:"+authorization.UserCode, deviceCodeHash, ttl).Result()
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return core.ErrConflict
+	}
+
+	return r.redisClient.Set(ctx, "device_code:"+deviceCodeHash, data, ttl).Err()
+}
+
+func (r *RedisDeviceAuthorizationRepository) GetAuthorization(ctx context.Context, deviceCodeHash string) (*DeviceAuthorization, error) {
+	data, err := r.redisClient.Get(ctx, "device_code:"+deviceCodeHash).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var authorization DeviceAuthorization
+	if err := json.Unmarshal([]byte(data), &authorization); err != nil {
+		return nil, err
+	}
+
+	return &authorization, nil
+}
+
+func (r *RedisDeviceAuthorizationRepository) GetDeviceCodeHash(ctx context.Context, userCode string) (string, error) {
+	deviceCodeHash, err := r.redisClient.Get(ctx, "device_user_code:"+userCode).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return "", core.ErrInvalidToken
+		}
+		return "", err
+	}
+	return deviceCodeHash, nil
+}
+
+// UpdateAuthorization replaces an existing authorization without extending
+// its lifetime.
+func (r *RedisDeviceAuthorizationRepository) UpdateAuthorization(ctx context.Context, deviceCodeHash string, authorization DeviceAuthorization) error {
+	data, err := json.Marshal(authorization)
+	if err != nil {
+		return err
+	}
+
+	updated, err := r.redisClient.SetXX(ctx, "device_code:"+deviceCodeHash, data, redis.KeepTTL).Result()
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return core.ErrInvalidToken
+	}
+	return nil
+}
+
+// ConsumeAuthorization atomically reads and deletes the authorization so a
+// device code yields at most one token.
+func (r *RedisDeviceAuthorizationRepository) ConsumeAuthorization(ctx context.Context, deviceCodeHash string) (*DeviceAuthorization, error) {
+	data, err := r.redisClient.GetDel(ctx, "device_code:"+deviceCodeHash).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var authorization DeviceAuthorization
+	if err := json.Unmarshal([]byte(data), &authorization); err != nil {
+		return nil, err
+	}
+
+	_ = r.redisClient.Del(ctx, "device_user_code:"+authorization.UserCode, "device_poll:"+deviceCodeHash).Err()
+	return &authorization, nil
+}
+
+// RecordPoll atomically records a token request for the authorization and
+// reports whether it came sooner than the current interval, which is then
+// raised by slowDownStep seconds. interval is the starting interval.
+func (r *RedisDeviceAuthorizationRepository) RecordPoll(ctx context.Context, deviceCodeHash string, now time.Time, interval, slowDownStep int, ttl time.Duration) (bool, error) {
+	result, err := recordPollScript.Run(ctx, r.redisClient, []string{"device_poll:" + deviceCodeHash},
+		now.Unix(), interval, slowDownStep, ttl.Milliseconds()).Int64Slice()
+	if err != nil {
+		return false, err
+	}
+	return result[1] == 1, nil
+}
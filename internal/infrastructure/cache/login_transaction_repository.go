@@ -0,0 +1,61 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type LoginTransaction struct {
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"code_verifier"`
+	Nonce        string `json:"nonce"`
+	ReturnTo     string `json:"return_to"`
+	Host         string `json:"host"`
+	LinkMemberID string `json:"link_member_id,omitempty"`
+	Binding      string `json:"binding"`
+}
+
+type LoginTransactionRepository interface {
+	SaveTransaction(ctx context.Context, state string, transaction LoginTransaction, ttl time.Duration) error
+	ConsumeTransaction(ctx context.Context, state string) (*LoginTransaction, error)
+}
+
+type RedisLoginTransactionRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisLoginTransactionRepository(redisClient *redis.Client) *RedisLoginTransactionRepository {
+	return &RedisLoginTransactionRepository{redisClient: redisClient}
+}
+
+func (r *RedisLoginTransactionRepository) SaveTransaction(ctx context.Context, state string, transaction LoginTransaction, ttl time.Duration) error {
+	data, err := json.Marshal(transaction)
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.Set(ctx, "login_tx:"+state, data, ttl).Err()
+}
+
+// ConsumeTransaction atomically reads and deletes the transaction so a state
+// value can only ever be redeemed once.
+func (r *RedisLoginTransactionRepository) ConsumeTransaction(ctx context.Context, state string) (*LoginTransaction, error) {
+	data, err := r.redisClient.GetDel(ctx, "login_tx:"+state).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidState
+		}
+		return nil, err
+	}
+
+	var transaction LoginTransaction
+	if err := json.Unmarshal([]byte(data), &transaction); err != nil {
+		return nil, err
+	}
+
+	return &transaction, nil
+}
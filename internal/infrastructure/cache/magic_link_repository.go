@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type MagicLink struct {
+	Email    string `json:"email"`
+	ReturnTo string `json:"return_to"`
+	Binding  string `json:"binding"`
+}
+
+type MagicLinkRepository interface {
+	SaveLink(ctx context.Context, tokenHash string, link MagicLink, ttl time.Duration) error
+	ConsumeLink(ctx context.Context, tokenHash string) (*MagicLink, error)
+	CountRequest(ctx context.Context, email string, window time.Duration) (int64, error)
+}
+
+type RedisMagicLinkRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisMagicLinkRepository(redisClient *redis.Client) *RedisMagicLinkRepository {
+	return &RedisMagicLinkRepository{redisClient: redisClient}
+}
+
+func (r *RedisMagicLinkRepository) SaveLink(ctx context.Context, tokenHash string, link MagicLink, ttl time.Duration) error {
+	data, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.Set(ctx, "magic_link:"+tokenHash, data, ttl).Err()
+}
+
+// ConsumeLink atomically reads and deletes the link so a token can only be
+// redeemed once.
+func (r *RedisMagicLinkRepository) ConsumeLink(ctx context.Context, tokenHash string) (*MagicLink, error) {
+	data, err := r.redisClient.GetDel(ctx, "magic_link:"+tokenHash).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var link MagicLink
+	if err := json.Unmarshal([]byte(data), &link); err != nil {
+		return nil, err
+	}
+
+	return &link, nil
+}
+
+// CountRequest increments and returns the number of links requested for an
+// email address within the current window.
+func (r *RedisMagicLinkRepository) CountRequest(ctx context.Context, email string, window time.Duration) (int64, error) {
+	key := "magic_link_rate:" + email
+	count, err := r.redisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := r.redisClient.Expire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
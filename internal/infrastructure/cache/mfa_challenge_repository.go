@@ -0,0 +1,94 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// MFAChallenge is a login that passed the upstream provider and is waiting for
+// the member's second factor.
+type MFAChallenge struct {
+	MemberID    string   `json:"member_id"`
+	ReturnTo    string   `json:"return_to"`
+	Host        string   `json:"host"`
+	AuthMethods []string `json:"amr"`
+}
+
+type MFAChallengeRepository interface {
+	SaveChallenge(ctx context.Context, token string, challenge MFAChallenge, ttl time.Duration) error
+	GetChallenge(ctx context.Context, token string) (*MFAChallenge, error)
+	DeleteChallenge(ctx context.Context, token string) error
+	CountFailure(ctx context.Context, memberID string, window time.Duration) (int64, error)
+	FailureCount(ctx context.Context, memberID string) (int64, error)
+}
+
+type RedisMFAChallengeRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisMFAChallengeRepository(redisClient *redis.Client) *RedisMFAChallengeRepository {
+	return &RedisMFAChallengeRepository{redisClient: redisClient}
+}
+
+func (r *RedisMFAChallengeRepository) SaveChallenge(ctx context.Context, token string, challenge MFAChallenge, ttl time.Duration) error {
+	data, err := json.Marshal(challenge)
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.Set(ctx, "mfa_challenge:"+token, data, ttl).Err()
+}
+
+func (r *RedisMFAChallengeRepository) GetChallenge(ctx context.Context, token string) (*MFAChallenge, error) {
+	data, err := r.redisClient.Get(ctx, "mfa_challenge:"+token).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var challenge MFAChallenge
+	if err := json.Unmarshal([]byte(data), &challenge); err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
+
+func (r *RedisMFAChallengeRepository) DeleteChallenge(ctx context.Context, token string) error {
+	return r.redisClient.Del(ctx, "mfa_challenge:"+token).Err()
+}
+
+// CountFailure increments and returns the number of failed second factor
+// attempts for a member within the current window.
+func (r *RedisMFAChallengeRepository) CountFailure(ctx context.Context, memberID string, window time.Duration) (int64, error) {
+	key := "mfa_failures:" + memberID
+	count, err := r.redisClient.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if count == 1 {
+		if err := r.redisClient.Expire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return count, nil
+}
+
+// FailureCount returns the number of failed second factor attempts for a
+// member within the current window without recording one.
+func (r *RedisMFAChallengeRepository) FailureCount(ctx context.Context, memberID string) (int64, error) {
+	count, err := r.redisClient.Get(ctx, "mfa_failures:"+memberID).Int64()
+	if err != nil {
+		if err == redis.Nil {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return count, nil
+}
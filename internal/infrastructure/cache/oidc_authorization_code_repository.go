@@ -0,0 +1,63 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// OIDCAuthorizationCode is an authorization code issued to an app acting as
+// an OpenID Connect client, together with a snapshot of the session that
+// approved it.
+type OIDCAuthorizationCode struct {
+	ClientID      string      `json:"client_id"`
+	RedirectURI   string      `json:"redirect_uri"`
+	Scope         string      `json:"scope"`
+	Nonce         string      `json:"nonce,omitempty"`
+	CodeChallenge string      `json:"code_challenge,omitempty"`
+	Session       SessionData `json:"session"`
+}
+
+type OIDCAuthorizationCodeRepository interface {
+	SaveCode(ctx context.Context, codeHash string, code OIDCAuthorizationCode, ttl time.Duration) error
+	ConsumeCode(ctx context.Context, codeHash string) (*OIDCAuthorizationCode, error)
+}
+
+type RedisOIDCAuthorizationCodeRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisOIDCAuthorizationCodeRepository(redisClient *redis.Client) *RedisOIDCAuthorizationCodeRepository {
+	return &RedisOIDCAuthorizationCodeRepository{redisClient: redisClient}
+}
+
+func (r *RedisOIDCAuthorizationCodeRepository) SaveCode(ctx context.Context, codeHash string, code OIDCAuthorizationCode, ttl time.Duration) error {
+	data, err := json.Marshal(code)
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.Set(ctx, "oidc_code:"+codeHash, data, ttl).Err()
+}
+
+// ConsumeCode atomically reads and deletes the code so it can only be
+// redeemed once.
+func (r *RedisOIDCAuthorizationCodeRepository) ConsumeCode(ctx context.Context, codeHash string) (*OIDCAuthorizationCode, error) {
+	data, err := r.redisClient.GetDel(ctx, "oidc_code:"+codeHash).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var code OIDCAuthorizationCode
+	if err := json.Unmarshal([]byte(data), &code); err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RevokedTokenRepository remembers revoked tokens until they would have
+// expired, so a revoked token is never mistaken for a valid one.
+type RevokedTokenRepository interface {
+	RevokeToken(ctx context.Context, tokenHash string, ttl time.Duration) error
+	IsRevoked(ctx context.Context, tokenHash string) (bool, error)
+}
+
+type RedisRevokedTokenRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisRevokedTokenRepository(redisClient *redis.Client) *RedisRevokedTokenRepository {
+	return &RedisRevokedTokenRepository{redisClient: redisClient}
+}
+
+func (r *RedisRevokedTokenRepository) RevokeToken(ctx context.Context, tokenHash string, ttl time.Duration) error {
+	return r.redisClient.Set(ctx, "revoked_token:"+tokenHash, 1, ttl).Err()
+}
+
+func (r *RedisRevokedTokenRepository) IsRevoked(ctx context.Context, tokenHash string) (bool, error) {
+	count, err := r.redisClient.Exists(ctx, "revoked_token:"+tokenHash).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
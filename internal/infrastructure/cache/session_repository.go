@@ -10,16 +10,56 @@ import (
 )
 
 type SessionData struct {
-	MemberID       string `json:"member_id"`
-	Email          string `json:"email"`
-	OrganizationID string `json:"organization_id"`
-	MicrosoftID    string `json:"microsoft_id"`
+	MemberID       string   `json:"member_id"`
+	Email          string   `json:"email"`
+	OrganizationID string   `json:"organization_id"`
+	MicrosoftID    string   `json:"microsoft_id"`
+	MFASatisfied   bool     `json:"mfa_satisfied"`
+	AuthTime       int64    `json:"auth_time"`
+	AuthMethods    []string `json:"amr"`
+	ClientID       string   `json:"client_id,omitempty"`
+	FamilyID       string   `json:"family_id,omitempty"`
+	Audience       string   `json:"audience,omitempty"`
+	Scope          string   `json:"scope,omitempty"`
+	UserAgent      string   `json:"user_agent,omitempty"`
+	Device         string   `json:"device,omitempty"`
+	Browser        string   `json:"browser,omitempty"`
+	IP             string   `json:"ip,omitempty"`
+	Country        string   `json:"country,omitempty"`
+	CreatedAt      int64    `json:"created_at"`
+	LastSeenAt     int64    `json:"last_seen_at,omitempty"`
+	IdleTimeout    int64    `json:"idle_timeout,omitempty"`
+	ExpiresAt      int64    `json:"expires_at"`
 }
 
+// MemberSession is a session found through its member's session index.
+type MemberSession struct {
+	Token string
+	Data  SessionData
+}
+
+// SessionUsage is where and when a session was last used.
+type SessionUsage struct {
+	UserAgent  string
+	Device     string
+	Browser    string
+	IP         string
+	Country    string
+	LastSeenAt int64
+}
+
+// maxSessionUpdateAttempts bounds the retries of a session update that raced
+// with another write to the same session.
+const maxSessionUpdateAttempts = 5
+
 type SessionRepository interface {
 	CreateSession(ctx context.Context, token string, sessionData SessionData, ttl time.Duration) error
 	GetSession(ctx context.Context, token string) (*SessionData, error)
+	UpdateSession(ctx context.Context, token string, update func(*SessionData)) error
+	TouchSession(ctx context.Context, token string, usage SessionUsage, ttl time.Duration) error
 	DeleteSession(ctx context.Context, token string) error
+	DeleteAllSessionsForMember(ctx context.Context, memberID string) (int, error)
+	ListSessionsForMember(ctx context.Context, memberID string) ([]MemberSession, error)
 }
 
 type RedisSessionRepository struct {
@@ -30,13 +70,30 @@ func NewRedisSessionRepository(redisClient *redis.Client) *RedisSessionRepositor
 	return &RedisSessionRepository{redisClient: redisClient}
 }
 
+// CreateSession stores the session and adds it to the member's session index.
+// The index lives until the longest-lived session expires, including sessions
+// whose lifetime is extended on use up to their expires_at, so short-lived
+// access tokens do not cut it short; tokens of sessions that expired on their
+// own are pruned when the index is read.
 func (r *RedisSessionRepository) CreateSession(ctx context.Context, token string, sessionData SessionData, ttl time.Duration) error {
 	data, err := json.Marshal(sessionData)
 	if err != nil {
 		return err
 	}
 
-	return r.redisClient.Set(ctx, "session:"+token, data, ttl).Err()
+	indexKey := memberSessionsKey(sessionData.MemberID)
+	indexTTL, err := r.redisClient.TTL(ctx, indexKey).Result()
+	if err != nil {
+		return err
+	}
+	indexTTL = max(indexTTL, ttl, time.Until(time.Unix(sessionData.ExpiresAt, 0)))
+	_, err = r.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, "session:"+token, data, ttl)
+		pipe.SAdd(ctx, indexKey, token)
+		pipe.Expire(ctx, indexKey, indexTTL)
+		return nil
+	})
+	return err
 }
 
 func (r *RedisSessionRepository) GetSession(ctx context.Context, token string) (*SessionData, error) {
@@ -56,6 +113,167 @@ func (r *RedisSessionRepository) GetSession(ctx context.Context, token string) (
 	return &sessionData, nil
 }
 
+// UpdateSession applies update to an existing session without extending its
+// lifetime. The session is read and written atomically, so concurrent updates
+// are not lost.
+func (r *RedisSessionRepository) UpdateSession(ctx context.Context, token string, update func(*SessionData)) error {
+	return r.modifySession(ctx, token, redis.KeepTTL, update)
+}
+
+// TouchSession records usage on an existing session and sets it to expire
+// after ttl, or keeps its expiry if ttl is zero. Only the usage fields are
+// written, so a concurrent update such as a completed second factor is kept.
+func (r *RedisSessionRepository) TouchSession(ctx context.Context, token string, usage SessionUsage, ttl time.Duration) error {
+	if ttl == 0 {
+		ttl = redis.KeepTTL
+	}
+	return r.modifySession(ctx, token, ttl, func(sessionData *SessionData) {
+		sessionData.UserAgent = usage.UserAgent
+		sessionData.Device = usage.Device
+		sessionData.Browser = usage.Browser
+		sessionData.IP = usage.IP
+		sessionData.Country = usage.Country
+		sessionData.LastSeenAt = usage.LastSeenAt
+	})
+}
+
+// modifySession applies modify to the stored session under WATCH and retries
+// if the session changed in between. It returns core.ErrInvalidSession if the
+// session does not exist.
+func (r *RedisSessionRepository) modifySession(ctx context.Context, token string, ttl time.Duration, modify func(*SessionData)) error {
+	key := "session:" + token
+	update := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			if err == redis.Nil {
+				return core.ErrInvalidSession
+			}
+			return err
+		}
+
+		var sessionData SessionData
+		if err := json.Unmarshal(data, &sessionData); err != nil {
+			return err
+		}
+		modify(&sessionData)
+		data, err = json.Marshal(sessionData)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetXX(ctx, key, data, ttl)
+			return nil
+		})
+		return err
+	}
+
+	for range maxSessionUpdateAttempts {
+		err := r.redisClient.Watch(ctx, update, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return redis.TxFailedErr
+}
+
 func (r *RedisSessionRepository) DeleteSession(ctx context.Context, token string) error {
-	return r.redisClient.Del(ctx, "session:"+token).Err()
+	data, err := r.redisClient.GetDel(ctx, "session:"+token).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	}
+
+	var sessionData SessionData
+	if err := json.Unmarshal([]byte(data), &sessionData); err != nil {
+		return nil
+	}
+	return r.redisClient.SRem(ctx, memberSessionsKey(sessionData.MemberID), token).Err()
+}
+
+// DeleteAllSessionsForMember ends every session in the member's index and
+// returns how many were still active.
+func (r *RedisSessionRepository) DeleteAllSessionsForMember(ctx context.Context, memberID string) (int, error) {
+	indexKey := memberSessionsKey(memberID)
+	tokens, err := r.redisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(tokens) == 0 {
+		return 0, nil
+	}
+
+	keys := make([]string, len(tokens))
+	for i, token := range tokens {
+		keys[i] = "session:" + token
+	}
+
+	var deleted *redis.IntCmd
+	_, err = r.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.Del(ctx, keys...)
+		pipe.SRem(ctx, indexKey, toInterfaces(tokens)...)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(deleted.Val()), nil
+}
+
+// ListSessionsForMember returns the active sessions in the member's index and
+// removes the tokens of sessions that expired on their own from it.
+func (r *RedisSessionRepository) ListSessionsForMember(ctx context.Context, memberID string) ([]MemberSession, error) {
+	indexKey := memberSessionsKey(memberID)
+	tokens, err := r.redisClient.SMembers(ctx, indexKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(tokens))
+	for i, token := range tokens {
+		keys[i] = "session:" + token
+	}
+	values, err := r.redisClient.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]MemberSession, 0, len(tokens))
+	var expired []string
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			expired = append(expired, tokens[i])
+			continue
+		}
+		var sessionData SessionData
+		if err := json.Unmarshal([]byte(data), &sessionData); err != nil {
+			continue
+		}
+		sessions = append(sessions, MemberSession{Token: tokens[i], Data: sessionData})
+	}
+
+	if len(expired) > 0 {
+		if err := r.redisClient.SRem(ctx, indexKey, toInterfaces(expired)...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return sessions, nil
+}
+
+func memberSessionsKey(memberID string) string {
+	return "member_sessions:" + memberID
+}
+
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
 }
@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+// WebAuthnCeremony is the server side state of a passkey registration or
+// assertion between its begin and finish requests. Session holds the
+// library's challenge data.
+type WebAuthnCeremony struct {
+	Kind     string          `json:"kind"`
+	MemberID string          `json:"member_id,omitempty"`
+	ReturnTo string          `json:"return_to,omitempty"`
+	Host     string          `json:"host,omitempty"`
+	Session  json.RawMessage `json:"session"`
+}
+
+type WebAuthnCeremonyRepository interface {
+	SaveCeremony(ctx context.Context, token string, ceremony WebAuthnCeremony, ttl time.Duration) error
+	ConsumeCeremony(ctx context.Context, token string) (*WebAuthnCeremony, error)
+}
+
+type RedisWebAuthnCeremonyRepository struct {
+	redisClient *redis.Client
+}
+
+func NewRedisWebAuthnCeremonyRepository(redisClient *redis.Client) *RedisWebAuthnCeremonyRepository {
+	return &RedisWebAuthnCeremonyRepository{redisClient: redisClient}
+}
+
+func (r *RedisWebAuthnCeremonyRepository) SaveCeremony(ctx context.Context, token string, ceremony WebAuthnCeremony, ttl time.Duration) error {
+	data, err := json.Marshal(ceremony)
+	if err != nil {
+		return err
+	}
+
+	return r.redisClient.Set(ctx, "webauthn_ceremony:"+token, data, ttl).Err()
+}
+
+// ConsumeCeremony atomically reads and deletes the ceremony so each challenge
+// can only be answered once.
+func (r *RedisWebAuthnCeremonyRepository) ConsumeCeremony(ctx context.Context, token string) (*WebAuthnCeremony, error) {
+	data, err := r.redisClient.GetDel(ctx, "webauthn_ceremony:"+token).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, core.ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	var ceremony WebAuthnCeremony
+	if err := json.Unmarshal([]byte(data), &ceremony); err != nil {
+		return nil, err
+	}
+
+	return &ceremony, nil
+}
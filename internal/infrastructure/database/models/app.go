@@ -45,4 +45,28 @@ type App struct {
 	UpdatedAt       time.Time   `gorm:"autoUpdateTime" json:"updated_at"`
 	IsPlatformApp   bool        `gorm:"type:boolean;not null;default:false" json:"is_platform_app"`
 	Token           string      `gorm:"type:varchar(128);uniqueIndex;not null" json:"-"`
+
+	RequiredAuthMethod *string `gorm:"type:varchar(20)" json:"required_auth_method"`
+	RequireMFA         bool    `gorm:"type:boolean;not null;default:false" json:"require_mfa"`
+	MaxAuthAgeSeconds  int     `gorm:"not null;default:0" json:"max_auth_age_seconds"`
+
+	OIDCRedirectURIs     StringArray `gorm:"type:jsonb;default:'[]'" json:"oidc_redirect_uris"`
+	OIDCClientSecretHash *string     `gorm:"type:varchar(64)" json:"-"`
+
+	ClientScopes     StringArray `gorm:"type:jsonb;default:'[]'" json:"client_scopes"`
+	ClientSecretHash *string     `gorm:"type:varchar(64)" json:"-"`
+	ClientJWKS       *string     `gorm:"type:text" json:"-"`
+}
+
+// IsOIDCClient reports whether the app is registered as an OpenID Connect
+// client, which it is once it has a redirect URI.
+func (a *App) IsOIDCClient() bool {
+	return len(a.OIDCRedirectURIs) > 0
+}
+
+// AcceptsClientCredentials reports whether the app can obtain access tokens
+// with the client credentials grant, which it can once it has a client
+// secret or public keys for private_key_jwt assertions.
+func (a *App) AcceptsClientCredentials() bool {
+	return a.ClientSecretHash != nil || a.ClientJWKS != nil
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent is a security-relevant event kept for administrators to review.
+type AuditEvent struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrganizationID uuid.UUID  `gorm:"type:uuid;not null;index" json:"organization_id"`
+	MemberID       *uuid.UUID `gorm:"type:uuid;index" json:"member_id"`
+	Type           string     `gorm:"type:varchar(64);not null;index" json:"type"`
+	Details        StringMap  `gorm:"type:jsonb;default:'{}'" json:"details"`
+	CreatedAt      time.Time  `gorm:"autoCreateTime;index" json:"created_at"`
+}
+
+func (e *AuditEvent) TableName() string {
+	return "audit_events"
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vondr/identity-go/internal/core"
+)
+
+type StringMap map[string]string
+
+func (sm *StringMap) Scan(value interface{}) error {
+	if value == nil {
+		*sm = StringMap{}
+		return nil
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, sm)
+	case string:
+		return json.Unmarshal([]byte(v), sm)
+	default:
+		return errors.New("unsupported type for StringMap")
+	}
+}
+
+func (sm StringMap) Value() (driver.Value, error) {
+	if len(sm) == 0 {
+		return "{}", nil
+	}
+	return json.Marshal(sm)
+}
+
+type IdentityProvider struct {
+	ID                    uuid.UUID                 `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrganizationID        uuid.UUID                 `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Organization          *Organization             `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Slug                  string                    `gorm:"type:varchar(63);uniqueIndex;not null" json:"slug"`
+	Type                  core.IdentityProviderType `gorm:"type:varchar(20);not null" json:"type"`
+	Issuer                string                    `gorm:"type:varchar(512);not null" json:"issuer"`
+	ClientID              string                    `gorm:"type:varchar(255);not null" json:"client_id"`
+	ClientSecretEncrypted string                    `gorm:"type:text;not null" json:"-"`
+	AllowedTenantIDs      StringArray               `gorm:"type:jsonb;default:'[]'" json:"allowed_tenant_ids"`
+	ClaimMappings         StringMap                 `gorm:"type:jsonb;default:'{}'" json:"claim_mappings"`
+	Enabled               bool                      `gorm:"type:boolean;not null" json:"enabled"`
+	CreatedAt             time.Time                 `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt             time.Time                 `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (ip *IdentityProvider) TableName() string {
+	return "identity_providers"
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemberIdentity links an external account to a member. The account is
+// identified by the provider slug, the issuer that asserted it and the
+// subject at that issuer.
+type MemberIdentity struct {
+	ID         uuid.UUID           `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	MemberID   uuid.UUID           `gorm:"type:uuid;not null;index" json:"member_id"`
+	Member     *OrganizationMember `gorm:"foreignKey:MemberID" json:"member,omitempty"`
+	Provider   string              `gorm:"type:varchar(150);not null;uniqueIndex:idx_member_identities_account" json:"provider"`
+	Issuer     string              `gorm:"type:varchar(512);not null;default:'';uniqueIndex:idx_member_identities_account" json:"issuer"`
+	Subject    string              `gorm:"type:varchar(255);not null;uniqueIndex:idx_member_identities_account" json:"subject"`
+	LinkedAt   time.Time           `gorm:"autoCreateTime" json:"linked_at"`
+	LastUsedAt *time.Time          `json:"last_used_at"`
+}
+
+func (mi *MemberIdentity) TableName() string {
+	return "member_identities"
+}
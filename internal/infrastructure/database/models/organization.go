@@ -8,10 +8,13 @@ import (
 )
 
 type Organization struct {
-	ID        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
-	Name      string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"name"`
-	Hostname  *string        `gorm:"type:varchar(255);uniqueIndex" json:"hostname"`
-	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
-	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	ID                        uuid.UUID      `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	Name                      string         `gorm:"type:varchar(255);uniqueIndex;not null" json:"name"`
+	Hostname                  *string        `gorm:"type:varchar(255);uniqueIndex" json:"hostname"`
+	MFARequired               bool           `gorm:"type:boolean;not null;default:false" json:"mfa_required"`
+	SessionIdleTimeoutMinutes *int           `gorm:"type:integer" json:"session_idle_timeout_minutes"`
+	SessionLifetimeDays       *int           `gorm:"type:integer" json:"session_lifetime_days"`
+	CreatedAt                 time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt                 time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt                 gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type OrganizationDomain struct {
+	ID                uuid.UUID     `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrganizationID    uuid.UUID     `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Organization      *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Domain            string        `gorm:"type:varchar(255);uniqueIndex;not null" json:"domain"`
+	VerificationToken string        `gorm:"type:varchar(64);not null" json:"verification_token"`
+	VerifiedAt        *time.Time    `json:"verified_at"`
+	CreatedAt         time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (od *OrganizationDomain) TableName() string {
+	return "organization_domains"
+}
+
+func (od *OrganizationDomain) IsVerified() bool {
+	return od.VerifiedAt != nil
+}
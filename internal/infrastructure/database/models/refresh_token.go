@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshTokenFamily is the chain of refresh tokens descending from one
+// authorization. It keeps what the authorizing session established, so
+// refreshed access tokens carry the same auth time and methods, and ends at
+// ExpiresAt however often it is refreshed. Access tokens of a family with an
+// Audience are only accepted by forward auth for that app.
+type RefreshTokenFamily struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	MemberID       uuid.UUID   `gorm:"type:uuid;not null;index" json:"member_id"`
+	OrganizationID uuid.UUID   `gorm:"type:uuid;not null;index" json:"organization_id"`
+	ClientID       string      `gorm:"type:varchar(255);not null" json:"client_id"`
+	Scope          string      `gorm:"type:text;not null;default:''" json:"scope"`
+	Audience       string      `gorm:"type:varchar(255);not null;default:''" json:"audience"`
+	MicrosoftID    string      `gorm:"type:varchar(255);not null;default:''" json:"-"`
+	MFASatisfied   bool        `gorm:"type:boolean;not null;default:false" json:"mfa_satisfied"`
+	AuthTime       time.Time   `gorm:"not null" json:"auth_time"`
+	AuthMethods    StringArray `gorm:"type:jsonb;default:'[]'" json:"amr"`
+	ExpiresAt      time.Time   `gorm:"not null;index" json:"expires_at"`
+	RevokedAt      *time.Time  `json:"revoked_at"`
+	RevokedReason  *string     `gorm:"type:varchar(32)" json:"revoked_reason"`
+	LastUsedAt     *time.Time  `json:"last_used_at"`
+	CreatedAt      time.Time   `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (f *RefreshTokenFamily) TableName() string {
+	return "refresh_token_families"
+}
+
+// IsActive reports whether tokens of the family can still be refreshed.
+func (f *RefreshTokenFamily) IsActive(now time.Time) bool {
+	return f.RevokedAt == nil && now.Before(f.ExpiresAt)
+}
+
+// RefreshToken is one token of a family. Only a SHA-256 hash of the token is
+// stored. A token is rotated when it is used; a rotated token presented again
+// gives away that it was copied.
+type RefreshToken struct {
+	ID        uuid.UUID           `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	FamilyID  uuid.UUID           `gorm:"type:uuid;not null;index" json:"family_id"`
+	Family    *RefreshTokenFamily `gorm:"foreignKey:FamilyID;constraint:OnDelete:CASCADE" json:"family,omitempty"`
+	TokenHash string              `gorm:"type:varchar(64);uniqueIndex;not null" json:"-"`
+	RotatedAt *time.Time          `json:"rotated_at"`
+	CreatedAt time.Time           `gorm:"autoCreateTime" json:"created_at"`
+}
+
+func (t *RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
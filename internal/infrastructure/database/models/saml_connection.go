@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SAMLConnection struct {
+	ID                uuid.UUID     `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	OrganizationID    uuid.UUID     `gorm:"type:uuid;not null;index" json:"organization_id"`
+	Organization      *Organization `gorm:"foreignKey:OrganizationID" json:"organization,omitempty"`
+	Slug              string        `gorm:"type:varchar(63);uniqueIndex;not null" json:"slug"`
+	IDPEntityID       string        `gorm:"column:idp_entity_id;type:varchar(512);not null" json:"idp_entity_id"`
+	IDPMetadataXML    string        `gorm:"column:idp_metadata_xml;type:text;not null" json:"-"`
+	AttributeMappings StringMap     `gorm:"type:jsonb;default:'{}'" json:"attribute_mappings"`
+	Enabled           bool          `gorm:"type:boolean;not null" json:"enabled"`
+	CreatedAt         time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (sc *SAMLConnection) TableName() string {
+	return "saml_connections"
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SigningKey is a key identity-go signs tokens with. The private key is a
+// PKCS #8 PEM document encrypted with a key derived from ENCRYPTION_KEY; it
+// is discarded once the key is retired.
+type SigningKey struct {
+	ID                  uuid.UUID  `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	KeyID               string     `gorm:"type:varchar(100);uniqueIndex;not null" json:"kid"`
+	Algorithm           string     `gorm:"type:varchar(20);not null" json:"alg"`
+	Status              string     `gorm:"type:varchar(20);not null;index" json:"status"`
+	PublicKey           string     `gorm:"type:text;not null" json:"-"`
+	EncryptedPrivateKey *string    `gorm:"type:text" json:"-"`
+	ActivatedAt         *time.Time `json:"activated_at"`
+	RetiringAt          *time.Time `json:"retiring_at"`
+	RetiredAt           *time.Time `json:"retired_at"`
+	CreatedAt           time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt           time.Time  `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (sk *SigningKey) TableName() string {
+	return "signing_keys"
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type WebAuthnCredential struct {
+	ID              uuid.UUID           `gorm:"type:uuid;primary_key;default:uuid_generate_v4()" json:"id"`
+	MemberID        uuid.UUID           `gorm:"type:uuid;not null;index" json:"member_id"`
+	Member          *OrganizationMember `gorm:"foreignKey:MemberID" json:"member,omitempty"`
+	Name            string              `gorm:"type:varchar(100);not null" json:"name"`
+	CredentialID    []byte              `gorm:"type:bytea;uniqueIndex;not null" json:"-"`
+	PublicKey       []byte              `gorm:"type:bytea;not null" json:"-"`
+	AttestationType string              `gorm:"type:varchar(32)" json:"attestation_type"`
+	AAGUID          []byte              `gorm:"type:bytea" json:"-"`
+	Transports      StringArray         `gorm:"type:jsonb;default:'[]'" json:"transports"`
+	Flags           int16               `gorm:"not null;default:0" json:"-"`
+	SignCount       int64               `gorm:"not null;default:0" json:"sign_count"`
+	CloneWarning    bool                `gorm:"type:boolean;not null;default:false" json:"clone_warning"`
+	LastUsedAt      *time.Time          `json:"last_used_at"`
+	CreatedAt       time.Time           `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time           `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+func (wc *WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
@@ -24,7 +24,11 @@ func InitDB(databaseURL string) error {
 }
 
 func AutoMigrate() error {
-	return DB.AutoMigrate(
+	if err := DB.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp"`).Error; err != nil {
+		return fmt.Errorf("failed to enable uuid-ossp extension: %w", err)
+	}
+
+	if err := DB.AutoMigrate(
 		&models.Organization{},
 		&models.OrganizationMember{},
 		&models.App{},
@@ -33,7 +37,57 @@ func AutoMigrate() error {
 		&models.UserGroupMember{},
 		&models.AppAllowedCountry{},
 		&models.Invitation{},
-	)
+		&models.IdentityProvider{},
+		&models.SAMLConnection{},
+		&models.OrganizationDomain{},
+		&models.WebAuthnCredential{},
+		&models.MemberIdentity{},
+		&models.SigningKey{},
+		&models.RefreshTokenFamily{},
+		&models.RefreshToken{},
+		&models.AuditEvent{},
+	); err != nil {
+		return err
+	}
+
+	return migrateMemberIdentities(DB)
+}
+
+// migrateMemberIdentities moves the former microsoft_id and relatics_id
+// columns of organization_members into member_identities and drops them. The
+// issuer of those accounts was never stored; it is left empty and filled in
+// on the member's next login.
+func migrateMemberIdentities(db *gorm.DB) error {
+	legacyColumns := []struct {
+		column   string
+		provider string
+	}{
+		{column: "microsoft_id", provider: "microsoft"},
+		{column: "relatics_id", provider: "relatics"},
+	}
+
+	for _, legacy := range legacyColumns {
+		if !db.Migrator().HasColumn(&models.OrganizationMember{}, legacy.column) {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			insert := `INSERT INTO member_identities (member_id, provider, issuer, subject, linked_at)
+				SELECT id, ?, '', ` + legacy.column + `, created_at FROM organization_members
+				WHERE ` + legacy.column + ` IS NOT NULL AND deleted_at IS NULL
+				ON CONFLICT DO NOTHING`
+			if err := tx.Exec(insert, legacy.provider).Error; err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&models.OrganizationMember{}, legacy.column)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s to member identities: %w", legacy.column, err)
+		}
+		log.Printf("Migrated organization_members.%s to member_identities", legacy.column)
+	}
+
+	return nil
 }
 
 func GetDB() *gorm.DB {
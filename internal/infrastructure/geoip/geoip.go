@@ -34,7 +34,7 @@ func GetService() *GeoIPService {
 }
 
 func (s *GeoIPService) LookupCountry(ipStr string) (string, error) {
-	if s.db == nil {
+	if s == nil || s.db == nil {
 		return "", core.ErrGeoIPDisabled
 	}
 
@@ -52,7 +52,7 @@ func (s *GeoIPService) LookupCountry(ipStr string) (string, error) {
 }
 
 func (s *GeoIPService) IsEnabled() bool {
-	return s.db != nil
+	return s != nil && s.db != nil
 }
 
 func (s *GeoIPService) IsPrivateIP(ipStr string) bool {
@@ -0,0 +1,60 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/vondr/identity-go/internal/core"
+)
+
+const (
+	BackendGraph  = "graph"
+	BackendSMTP   = "smtp"
+	BackendOutbox = "outbox"
+)
+
+// NewFromConfig builds the mailer selected by MAIL_BACKEND. Without an explicit
+// backend, Microsoft Graph is used when its app registration is configured and
+// the file outbox otherwise. The outbox never delivers mail, so production
+// refuses to start with it.
+func NewFromConfig(cfg *core.Config) (Mailer, error) {
+	backend := cfg.MailBackend
+	if backend == "" {
+		if cfg.MicrosoftEmailClientID != "" {
+			backend = BackendGraph
+		} else {
+			backend = BackendOutbox
+		}
+	}
+
+	switch backend {
+	case BackendGraph:
+		if cfg.MicrosoftEmailTenantID == "" || cfg.MicrosoftEmailClientID == "" || cfg.MicrosoftEmailClientSecret == "" {
+			return nil, fmt.Errorf("graph mail backend requires MICROSOFT_EMAIL_TENANT_ID, MICROSOFT_EMAIL_CLIENT_ID and MICROSOFT_EMAIL_CLIENT_SECRET")
+		}
+		return NewGraphMailer(GraphConfig{
+			TenantID:     cfg.MicrosoftEmailTenantID,
+			ClientID:     cfg.MicrosoftEmailClientID,
+			ClientSecret: cfg.MicrosoftEmailClientSecret,
+			Sender:       cfg.MicrosoftEmailSender,
+		}), nil
+	case BackendSMTP:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("smtp mail backend requires SMTP_HOST")
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		}), nil
+	case BackendOutbox:
+		if cfg.Environment == "production" {
+			return nil, fmt.Errorf("outbox mail backend does not deliver mail; set MAIL_BACKEND to graph or smtp in production")
+		}
+		log.Printf("Warning: Mail is written to %s instead of being sent", cfg.MailOutboxDir)
+		return NewOutboxMailer(cfg.MailOutboxDir, cfg.MailFrom), nil
+	}
+	return nil, fmt.Errorf("unknown mail backend %q", backend)
+}
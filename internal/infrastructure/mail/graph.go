@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const graphBaseURL = "https://graph.microsoft.com/v1.0"
+
+type GraphConfig struct {
+	TenantID     string
+	ClientID     string
+	ClientSecret string
+	Sender       string
+}
+
+// GraphMailer sends mail through the Microsoft Graph sendMail API using an app
+// registration with the Mail.Send application permission.
+type GraphMailer struct {
+	sender     string
+	httpClient *http.Client
+}
+
+func NewGraphMailer(cfg GraphConfig) *GraphMailer {
+	credentials := &clientcredentials.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		TokenURL:     "https://login.microsoftonline.com/" + cfg.TenantID + "/oauth2/v2.0/token",
+		Scopes:       []string{"https://graph.microsoft.com/.default"},
+	}
+	return &GraphMailer{
+		sender:     cfg.Sender,
+		httpClient: credentials.Client(context.Background()),
+	}
+}
+
+type graphRecipient struct {
+	EmailAddress struct {
+		Address string `json:"address"`
+	} `json:"emailAddress"`
+}
+
+type graphSendMailRequest struct {
+	Message struct {
+		Subject string `json:"subject"`
+		Body    struct {
+			ContentType string `json:"contentType"`
+			Content     string `json:"content"`
+		} `json:"body"`
+		ToRecipients []graphRecipient `json:"toRecipients"`
+	} `json:"message"`
+	SaveToSentItems bool `json:"saveToSentItems"`
+}
+
+func (m *GraphMailer) Send(ctx context.Context, msg *Message) error {
+	var request graphSendMailRequest
+	request.Message.Subject = msg.Subject
+	if msg.HTMLBody != "" {
+		request.Message.Body.ContentType = "HTML"
+		request.Message.Body.Content = msg.HTMLBody
+	} else {
+		request.Message.Body.ContentType = "Text"
+		request.Message.Body.Content = msg.TextBody
+	}
+	var recipient graphRecipient
+	recipient.EmailAddress.Address = msg.To
+	request.Message.ToRecipients = []graphRecipient{recipient}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, graphBaseURL+"/users/"+url.PathEscape(m.sender)+"/sendMail", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("failed to send mail: status %d, body: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
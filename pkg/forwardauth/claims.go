@@ -0,0 +1,74 @@
+// Package forwardauth verifies the identity JWT identity-go's forward auth
+// passes to upstream services in the x-vondr-jwt header. Unlike the plain
+// x-vondr-user-id, x-vondr-email and x-vondr-organization-id headers, the
+// token cannot be forged by a client that reaches a service without going
+// through Traefik.
+//
+//	verifier := forwardauth.NewVerifier(forwardauth.Config{
+//		Issuer:   "https://auth.vondr.ai",
+//		Audience: "app.example.vondr.ai",
+//	})
+//	http.Handle("/", verifier.Middleware(handler))
+//
+// Handlers read the verified identity with ClaimsFromContext.
+package forwardauth
+
+import (
+	"strings"
+
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// HeaderName is the request header carrying the token.
+const HeaderName = "x-vondr-jwt"
+
+// Claims is the identity forward auth let through: a member, the member a
+// legacy M2M token acts for, or an app that authenticated with the client
+// credentials grant. Subject is the member ID, or the client ID for apps,
+// and Audience the host the request was addressed to.
+type Claims struct {
+	jwt.Claims
+	Email          string   `json:"email,omitempty"`
+	OrganizationID string   `json:"organization_id"`
+	Role           string   `json:"role,omitempty"`
+	Groups         []string `json:"groups"`
+	AppID          string   `json:"app_id,omitempty"`
+	ClientID       string   `json:"client_id,omitempty"`
+	Scope          string   `json:"scope,omitempty"`
+}
+
+// MemberID returns the subject of tokens issued for members, and an empty
+// string for client tokens.
+func (c *Claims) MemberID() string {
+	if c.IsClient() {
+		return ""
+	}
+	return c.Subject
+}
+
+// IsClient reports whether the token was issued for an app using the client
+// credentials grant rather than for a member.
+func (c *Claims) IsClient() bool {
+	return c.ClientID != ""
+}
+
+// HasScope reports whether a client token was granted the scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, granted := range strings.Fields(c.Scope) {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// InGroup reports whether the member belongs to the user group with the
+// given ID.
+func (c *Claims) InGroup(groupID string) bool {
+	for _, group := range c.Groups {
+		if group == groupID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package forwardauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// Algorithms lists the signature algorithms identity-go signs with.
+var Algorithms = []jose.SignatureAlgorithm{jose.RS256, jose.ES256, jose.EdDSA}
+
+// ErrInvalidToken is returned for tokens that are malformed, not signed by a
+// published key, expired, or issued for another issuer or audience.
+var ErrInvalidToken = errors.New("invalid forward auth token")
+
+const (
+	defaultKeySetTTL  = 5 * time.Minute
+	minRefreshSpacing = 30 * time.Second
+	defaultLeeway     = 5 * time.Second
+)
+
+type Config struct {
+	// Issuer is the identity-go public URL, e.g. https://auth.vondr.ai.
+	Issuer string
+	// Audience is the host the service is reached at. Tokens for other hosts
+	// are rejected. Middleware falls back to the Host header when empty, which
+	// is only safe when every route to the service preserves it.
+	Audience string
+	// JWKSURL defaults to Issuer + "/.well-known/jwks.json".
+	JWKSURL string
+	// HTTPClient fetches the key set; defaults to a client with a 10 second
+	// timeout.
+	HTTPClient *http.Client
+}
+
+// Verifier checks tokens against identity-go's published signing keys. Keys
+// are cached and refetched every five minutes, or sooner when a token names
+// a key the cache does not have yet.
+type Verifier struct {
+	issuer   string
+	audience string
+	jwksURL  string
+	client   *http.Client
+
+	mu          sync.Mutex
+	keys        jose.JSONWebKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func NewVerifier(cfg Config) *Verifier {
+	issuer := strings.TrimSuffix(cfg.Issuer, "/")
+	jwksURL := cfg.JWKSURL
+	if jwksURL == "" {
+		jwksURL = issuer + "/.well-known/jwks.json"
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Verifier{
+		issuer:   issuer,
+		audience: cfg.Audience,
+		jwksURL:  jwksURL,
+		client:   client,
+	}
+}
+
+// Verify checks the signature, issuer, audience and lifetime of a token. An
+// empty audience uses the one from Config.
+func (v *Verifier) Verify(ctx context.Context, rawToken, audience string) (*Claims, error) {
+	if audience == "" {
+		audience = v.audience
+	}
+	if audience == "" {
+		return nil, fmt.Errorf("%w: no audience to check", ErrInvalidToken)
+	}
+
+	token, err := jwt.ParseSigned(rawToken, Algorithms)
+	if err != nil || len(token.Headers) != 1 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+	key, err := v.key(ctx, token.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := token.Claims(key.Key, &claims); err != nil {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidToken)
+	}
+	err = claims.ValidateWithLeeway(jwt.Expected{
+		Issuer:      v.issuer,
+		AnyAudience: jwt.Audience{strings.ToLower(audience)},
+		Time:        time.Now(),
+	}, defaultLeeway)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if claims.Expiry == nil || claims.Subject == "" {
+		return nil, fmt.Errorf("%w: missing exp or sub", ErrInvalidToken)
+	}
+	return &claims, nil
+}
+
+// VerifyRequest verifies the token in the HeaderName header, using the
+// request host as audience when Config has none.
+func (v *Verifier) VerifyRequest(r *http.Request) (*Claims, error) {
+	rawToken := r.Header.Get(HeaderName)
+	if rawToken == "" {
+		return nil, fmt.Errorf("%w: missing %s header", ErrInvalidToken, HeaderName)
+	}
+	audience := v.audience
+	if audience == "" {
+		audience = requestHost(r)
+	}
+	return v.Verify(r.Context(), rawToken, audience)
+}
+
+type contextKey struct{}
+
+// Middleware rejects requests without a valid token with 401 and makes the
+// claims of the others available through ClaimsFromContext.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, err := v.VerifyRequest(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, claims)))
+	})
+}
+
+// ClaimsFromContext returns the claims Middleware verified.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
+
+func (v *Verifier) key(ctx context.Context, keyID string) (*jose.JSONWebKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	keys := v.keys.Key(keyID)
+	stale := time.Since(v.fetchedAt) > defaultKeySetTTL || len(keys) == 0
+	if stale && time.Since(v.attemptedAt) > minRefreshSpacing {
+		if err := v.fetchKeys(ctx); err != nil {
+			if len(keys) == 0 {
+				return nil, err
+			}
+		} else {
+			keys = v.keys.Key(keyID)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidToken, keyID)
+	}
+	return &keys[0], nil
+}
+
+// fetchKeys replaces the cached key set. The caller holds v.mu.
+func (v *Verifier) fetchKeys(ctx context.Context) error {
+	v.attemptedAt = time.Now()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch signing keys: %s", resp.Status)
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return fmt.Errorf("failed to decode signing keys: %w", err)
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+func requestHost(r *http.Request) string {
+	host := r.Host
+	if i := strings.LastIndex(host, ":"); i != -1 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return strings.ToLower(host)
+}